@@ -3,11 +3,15 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	_ "github.com/Masozee/kontena/api/docs"
 	"github.com/Masozee/kontena/api/internal/database"
 	"github.com/Masozee/kontena/api/internal/handlers"
 	"github.com/Masozee/kontena/api/internal/middleware"
+	"github.com/Masozee/kontena/api/mailer"
+	"github.com/Masozee/kontena/api/scheduler"
+	"github.com/Masozee/kontena/api/webhook"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -35,6 +39,17 @@ func main() {
 	// Initialize database
 	database.InitDB()
 
+	// Ticket updates are emailed through SMTP when configured, and into an outbox directory otherwise
+	handlers.Mailer = mailer.FromEnv()
+
+	// Webhooks only go to public addresses unless internal ones are explicitly allowed
+	webhook.AllowPrivateNetworks = os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+
+	// Start background jobs
+	scheduler.Start(time.Minute,
+		scheduler.Job{Name: "webhooks", Run: handlers.DeliverWebhooks},
+	)
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName: "Kontena CRM API",
@@ -44,6 +59,7 @@ func main() {
 	app.Use(logger.New())
 	app.Use(cors.New())
 	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
 
 	// Swagger documentation - updated configuration
 	app.Get("/swagger/*", swagger.New(swagger.Config{
@@ -119,6 +135,48 @@ func main() {
 	tickets.Patch("/:id", handlers.UpdateTicket)
 	tickets.Delete("/:id", handlers.DeleteTicket)
 
+	// Ticket comment routes
+	tickets.Get("/:id/comments", handlers.GetTicketComments)
+	tickets.Post("/:id/comments", handlers.CreateTicketComment)
+	tickets.Patch("/:id/comments/:comment_id", handlers.UpdateTicketComment)
+	tickets.Delete("/:id/comments/:comment_id", handlers.DeleteTicketComment)
+	tickets.Get("/:id/transitions", handlers.GetTicketTransitions)
+	tickets.Get("/:id/watchers", handlers.GetTicketWatchers)
+	tickets.Post("/:id/watchers", handlers.WatchTicket)
+	tickets.Delete("/:id/watchers/:staff_id", handlers.UnwatchTicket)
+
+	// Notification routes
+	notifications := api.Group("/notifications")
+	notifications.Get("/", handlers.GetNotifications)
+	notifications.Post("/read-all", handlers.MarkAllNotificationsRead)
+	notifications.Post("/:id/read", handlers.MarkNotificationRead)
+
+	// Workflow routes
+	workflows := api.Group("/workflows")
+	workflows.Get("/", handlers.GetWorkflows)
+	workflows.Get("/:id", handlers.GetWorkflow)
+	workflows.Post("/", handlers.CreateWorkflow)
+	workflows.Put("/:id", handlers.UpdateWorkflow)
+	workflows.Delete("/:id", handlers.DeleteWorkflow)
+
+	// Search routes
+	api.Get("/search", handlers.Search)
+
+	// Real-time routes
+	api.Get("/stream", handlers.Stream)
+
+	// Webhook routes
+	webhooks := api.Group("/webhooks")
+	webhooks.Get("/events", handlers.GetWebhookEvents)
+	webhooks.Get("/", handlers.GetWebhookEndpoints)
+	webhooks.Post("/", handlers.CreateWebhookEndpoint)
+	webhooks.Get("/:id", handlers.GetWebhookEndpoint)
+	webhooks.Put("/:id", handlers.UpdateWebhookEndpoint)
+	webhooks.Delete("/:id", handlers.DeleteWebhookEndpoint)
+	webhooks.Post("/:id/rotate-secret", handlers.RotateWebhookSecret)
+	webhooks.Get("/:id/deliveries", handlers.GetWebhookDeliveries)
+	webhooks.Post("/:id/deliveries/:delivery_id/replay", handlers.ReplayWebhookDelivery)
+
 	// Get port from environment
 	port := os.Getenv("APP_PORT")
 	if port == "" {
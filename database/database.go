@@ -4,17 +4,68 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 
 	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/search"
 )
 
 var DB *gorm.DB
 
+// Models are the models InitDB migrates
+var Models = []interface{}{
+	&models.Project{},
+	&models.Person{},
+	&models.KPI{},
+	&models.Task{},
+	&models.Report{},
+	&models.Milestone{},
+	&models.Risk{},
+	&models.Issue{},
+	&models.Document{},
+	&models.TimeTracking{},
+	&models.Comment{},
+	&models.CommentRevision{},
+	&models.Notification{},
+	&models.Workflow{},
+	&models.WorkflowState{},
+	&models.WorkflowTransition{},
+	&models.Board{},
+	&models.BoardColumn{},
+	&models.TaskRecurrence{},
+	&models.Sprint{},
+	&models.TaskStateChange{},
+	&models.RateCard{},
+	&models.ProjectCost{},
+	&models.KPIMeasurement{},
+	&models.HealthWeights{},
+	&models.ProjectHealth{},
+	&models.ProjectTemplate{},
+	&models.Allocation{},
+	&models.Holiday{},
+	&models.Leave{},
+	&models.CalendarFeed{},
+	&models.ProjectBaseline{},
+	&models.TaskDependency{},
+	&models.EstimateSettings{},
+	&models.CustomField{},
+	&models.Label{},
+	&models.Watcher{},
+	&models.NotificationPreference{},
+	&models.EmailTemplate{},
+	&models.Email{},
+	&models.EmailBounce{},
+	&models.WebhookEndpoint{},
+	&models.WebhookDelivery{},
+	&models.OutboxEvent{},
+}
+
 // InitDB initializes the database connection and migrates the schema
 func InitDB() {
 	// Load environment variables
@@ -65,20 +116,126 @@ func InitDB() {
 	DB = db
 
 	// Auto migrate the models
-	err = DB.AutoMigrate(
-		&models.Project{},
-		&models.Person{},
-		&models.KPI{},
-		&models.Task{},
-		&models.Report{},
-		&models.Milestone{},
-		&models.Risk{},
-		&models.Issue{},
-		&models.Document{},
-		&models.TimeTracking{},
-	)
+	err = DB.AutoMigrate(Models...)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
+
+	// KPIs created before statuses existed get theirs computed once
+	var kpis []models.KPI
+	DB.Where("status IS NULL OR status = ''").Find(&kpis)
+	for i := range kpis {
+		kpis[i].UpdateAchievement()
+		DB.Model(&kpis[i]).Select("achieved", "status").Updates(&kpis[i])
+	}
+
+	// Comma-separated asset tags become labels of the tenant's catalogue
+	if DB.Migrator().HasTable(&models.Asset{}) {
+		if err := DB.AutoMigrate(&models.Asset{}); err != nil {
+			log.Fatalf("Failed to migrate assets: %v", err)
+		}
+		if err := migrateAssetTags(DB); err != nil {
+			log.Fatalf("Failed to migrate asset tags: %v", err)
+		}
+	}
+
+	// Maintenance records remember whether their performer was reminded
+	if DB.Migrator().HasTable(&models.MaintenanceRecord{}) {
+		if err := DB.AutoMigrate(&models.MaintenanceRecord{}); err != nil {
+			log.Fatalf("Failed to migrate maintenance records: %v", err)
+		}
+	}
+
+	// Full-text search is backed by GIN indexes of the searched columns
+	if err := search.CreateIndexes(DB, models.SearchSources); err != nil {
+		log.Fatalf("Failed to create search indexes: %v", err)
+	}
+
 	log.Println("Database migration completed")
 }
+
+// migrateAssetTags copies the tags column of assets into labels. Tags are matched to the
+// tenant's labels by name regardless of case; missing labels are created. Once copied, the
+// tags move to the legacy_tags column and the tags column is dropped so the copy runs once;
+// legacy_tags is kept until a later release.
+func migrateAssetTags(db *gorm.DB) error {
+	if has, err := hasColumn(db, "assets", "tags"); err != nil || !has {
+		return err
+	}
+
+	var assets []struct {
+		ID       uint
+		TenantID uint
+		Tags     string
+	}
+	if err := db.Table("assets").Select("id, tenant_id, tags").Where("tags IS NOT NULL AND tags <> ''").Scan(&assets).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, asset := range assets {
+			labels, err := TagLabels(tx, asset.TenantID, asset.Tags)
+			if err != nil {
+				return err
+			}
+			for _, label := range labels {
+				row := map[string]interface{}{"asset_id": asset.ID, "label_id": label.ID}
+				if err := tx.Table("asset_labels").Clauses(clause.OnConflict{DoNothing: true}).Create(row).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Exec("UPDATE assets SET legacy_tags = tags").Error; err != nil {
+			return err
+		}
+		return tx.Exec("ALTER TABLE assets DROP COLUMN tags").Error
+	})
+}
+
+// TagLabels returns the tenant's labels named by comma-separated tags, each once. Tags are
+// matched to labels by name regardless of case and cut to the 50 characters of a label name;
+// missing labels are created.
+func TagLabels(tx *gorm.DB, tenantID uint, tags string) ([]models.Label, error) {
+	var labels []models.Label
+	seen := make(map[uint]bool)
+	for _, tag := range strings.Split(tags, ",") {
+		name := strings.TrimSpace(tag)
+		if name == "" {
+			continue
+		}
+		if runes := []rune(name); len(runes) > 50 {
+			name = string(runes[:50])
+		}
+
+		var label models.Label
+		if err := tx.Where("tenant_id = ? AND LOWER(name) = LOWER(?)", tenantID, name).Limit(1).Find(&label).Error; err != nil {
+			return nil, err
+		}
+		if label.ID == 0 {
+			label = models.Label{TenantID: tenantID, Name: name, Color: models.DefaultLabelColor}
+			if err := tx.Create(&label).Error; err != nil {
+				return nil, err
+			}
+		}
+		if !seen[label.ID] {
+			seen[label.ID] = true
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+// hasColumn reports whether a table has a column of exactly that name. Migrator().HasColumn
+// of SQLite also finds columns whose name ends with it.
+func hasColumn(db *gorm.DB, table, column string) (bool, error) {
+	columns, err := db.Migrator().ColumnTypes(table)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range columns {
+		if strings.EqualFold(c.Name(), column) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Masozee/kontena/api/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrateAssetTags(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to in-memory database: %v", err)
+	}
+	assert.NoError(t, db.AutoMigrate(&models.Tenant{}, &models.Label{}, &models.AssetCategory{}, &models.Asset{}))
+	assert.NoError(t, db.Exec("ALTER TABLE assets ADD COLUMN tags TEXT").Error)
+
+	long := strings.Repeat("é", 60)
+	db.Create(&models.Label{TenantID: 1, Name: "Finance", Color: "#ff0000"})
+	db.Create(&models.Asset{TenantID: 1, Name: "Laptop", CategoryID: 1})
+	db.Create(&models.Asset{TenantID: 1, Name: "Phone", CategoryID: 1})
+	db.Create(&models.Asset{TenantID: 2, Name: "Desk", CategoryID: 2})
+	db.Exec("UPDATE assets SET tags = ? WHERE id = 1", "laptop, FINANCE,,laptop")
+	db.Exec("UPDATE assets SET tags = ? WHERE id = 2", "Laptop,"+long)
+	db.Exec("UPDATE assets SET tags = ? WHERE id = 3", "finance")
+
+	assert.NoError(t, migrateAssetTags(db))
+
+	var labels []models.Label
+	db.Order("id").Find(&labels)
+	if assert.Len(t, labels, 4) {
+		assert.Equal(t, "Finance", labels[0].Name, "existing labels are matched regardless of case")
+		assert.Equal(t, "#ff0000", labels[0].Color)
+		assert.Equal(t, "laptop", labels[1].Name)
+		assert.Equal(t, models.DefaultLabelColor, labels[1].Color)
+		assert.Equal(t, strings.Repeat("é", 50), labels[2].Name, "long tags are cut to 50 characters")
+		assert.True(t, utf8.ValidString(labels[2].Name))
+		assert.Equal(t, uint(2), labels[3].TenantID, "each tenant gets its own labels")
+	}
+
+	var rows []struct{ AssetID, LabelID uint }
+	db.Table("asset_labels").Order("asset_id, label_id").Scan(&rows)
+	assert.Equal(t, []struct{ AssetID, LabelID uint }{{1, 1}, {1, 2}, {2, 2}, {2, 3}, {3, 4}}, rows)
+
+	// The tags are kept, and not copied again
+	has, err := hasColumn(db, "assets", "tags")
+	assert.NoError(t, err)
+	assert.False(t, has)
+	var laptop models.Asset
+	db.First(&laptop, 1)
+	assert.Equal(t, "laptop, FINANCE,,laptop", laptop.LegacyTags)
+	assert.NoError(t, migrateAssetTags(db))
+	var count int64
+	db.Model(&models.Label{}).Count(&count)
+	assert.Equal(t, int64(4), count)
+}
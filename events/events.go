@@ -0,0 +1,112 @@
+// Package events delivers domain events to in-process subscribers. Events are written to an
+// outbox in the transaction of the change they describe and dispatched from there, so a
+// subscriber sees every committed change at least once and never one that was rolled back.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Event is a change to an aggregate: a project, a task, an asset and so on.
+// Events of one aggregate are dispatched in the order they happened.
+type Event struct {
+	ID            uint
+	TenantID      uint
+	Type          string // "<aggregate>.<what happened>", e.g. "task.created"
+	AggregateType string
+	AggregateID   uint
+	Data          json.RawMessage
+	OccurredAt    time.Time
+}
+
+// Decode decodes the data of the event into v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// AggregateType returns the aggregate an event type is about: the part before the first dot
+func AggregateType(eventType string) string {
+	aggregate, _, _ := strings.Cut(eventType, ".")
+	return aggregate
+}
+
+// Handler handles an event in a transaction that also records that the subscriber handled it,
+// so changes a handler makes to the database happen exactly once. Other side effects, like
+// sending a message, can happen again if recording fails, and should be idempotent.
+type Handler func(tx *gorm.DB, e Event) error
+
+// Subscriber is a named handler of some event types. The name identifies the subscriber in the
+// outbox, so it must stay the same across releases.
+type Subscriber struct {
+	Name   string
+	Types  []string // all events when empty
+	Handle Handler
+}
+
+// Wants reports whether the subscriber handles the event type
+func (s Subscriber) Wants(eventType string) bool {
+	if len(s.Types) == 0 {
+		return true
+	}
+	for _, t := range s.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Bus holds the subscribers events are dispatched to
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []Subscriber
+}
+
+// Subscribe adds a subscriber. Names must be unique.
+func (b *Bus) Subscribe(s Subscriber) error {
+	if s.Name == "" || s.Handle == nil {
+		return fmt.Errorf("subscriber needs a name and a handler")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, existing := range b.subscribers {
+		if existing.Name == s.Name {
+			return fmt.Errorf("subscriber %q already exists", s.Name)
+		}
+	}
+	b.subscribers = append(b.subscribers, s)
+	return nil
+}
+
+// Subscribers returns the subscribers that handle the event type, in the order they subscribed
+func (b *Bus) Subscribers(eventType string) []Subscriber {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var wanted []Subscriber
+	for _, s := range b.subscribers {
+		if s.Wants(eventType) {
+			wanted = append(wanted, s)
+		}
+	}
+	return wanted
+}
+
+// Unsubscribe removes the subscriber with the name, if there is one
+func (b *Bus) Unsubscribe(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, s := range b.subscribers {
+		if s.Name == name {
+			b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+			return
+		}
+	}
+}
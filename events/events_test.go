@@ -0,0 +1,41 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestBusSubscribers(t *testing.T) {
+	handle := func(tx *gorm.DB, e Event) error { return nil }
+	bus := &Bus{}
+	assert.NoError(t, bus.Subscribe(Subscriber{Name: "all", Handle: handle}))
+	assert.NoError(t, bus.Subscribe(Subscriber{Name: "tasks", Types: []string{"task.created", "task.updated"}, Handle: handle}))
+	assert.Error(t, bus.Subscribe(Subscriber{Name: "tasks", Handle: handle}), "names are unique")
+	assert.Error(t, bus.Subscribe(Subscriber{Name: "nothing"}))
+
+	names := func(eventType string) []string {
+		var names []string
+		for _, s := range bus.Subscribers(eventType) {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"all", "tasks"}, names("task.created"))
+	assert.Equal(t, []string{"all"}, names("project.created"))
+
+	bus.Unsubscribe("all")
+	assert.Equal(t, []string{"tasks"}, names("task.created"))
+}
+
+func TestEventDecode(t *testing.T) {
+	assert.Equal(t, "task", AggregateType("task.created"))
+	assert.Equal(t, "procurement", AggregateType("procurement.status_changed"))
+
+	var data struct {
+		ID uint `json:"id"`
+	}
+	assert.NoError(t, Event{Data: []byte(`{"id": 7}`)}.Decode(&data))
+	assert.Equal(t, uint(7), data.ID)
+}
@@ -0,0 +1,371 @@
+// Package formula parses and evaluates small arithmetic expressions over named numeric
+// variables, such as "tasks_completed / tasks_total * 100". Only numbers, variables,
+// + - * / %, parentheses and the functions min, max, abs and round are supported, so a
+// formula cannot reach anything but the values it is given.
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxLength = 500 // characters in a formula
+	maxDepth  = 32  // nesting of parentheses and function calls
+)
+
+// ErrInvalidFormula is wrapped by every parse error
+var ErrInvalidFormula = errors.New("invalid formula")
+
+// ErrDivisionByZero is returned when a formula divides by zero
+var ErrDivisionByZero = errors.New("division by zero")
+
+// functions maps each function name to its implementation and allowed argument counts
+var functions = map[string]struct {
+	minArgs, maxArgs int
+	fn               func(args []float64) float64
+}{
+	"min": {1, -1, func(args []float64) float64 {
+		m := args[0]
+		for _, a := range args[1:] {
+			m = math.Min(m, a)
+		}
+		return m
+	}},
+	"max": {1, -1, func(args []float64) float64 {
+		m := args[0]
+		for _, a := range args[1:] {
+			m = math.Max(m, a)
+		}
+		return m
+	}},
+	"abs": {1, 1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"round": {1, 2, func(args []float64) float64 {
+		if len(args) == 1 {
+			return math.Round(args[0])
+		}
+		scale := math.Pow(10, math.Trunc(args[1]))
+		return math.Round(args[0]*scale) / scale
+	}},
+}
+
+// Formula is a parsed expression
+type Formula struct {
+	source string
+	root   node
+}
+
+// node is an element of the expression tree
+type node interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type number float64
+
+type variable string
+
+type unary struct {
+	operand node
+}
+
+type binary struct {
+	op          byte
+	left, right node
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+func (n number) eval(map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (v variable) eval(vars map[string]float64) (float64, error) {
+	value, ok := vars[string(v)]
+	if !ok {
+		return 0, fmt.Errorf("unknown variable %q", string(v))
+	}
+	return value, nil
+}
+
+func (u unary) eval(vars map[string]float64) (float64, error) {
+	value, err := u.operand.eval(vars)
+	return -value, err
+}
+
+func (b binary) eval(vars map[string]float64) (float64, error) {
+	left, err := b.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	right, err := b.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return left / right, nil
+	default: // '%'
+		if right == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return math.Mod(left, right), nil
+	}
+}
+
+func (c call) eval(vars map[string]float64) (float64, error) {
+	args := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		value, err := arg.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+	return functions[c.name].fn(args), nil
+}
+
+// Parse parses a formula
+func Parse(source string) (*Formula, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidFormula)
+	}
+	if len(source) > maxLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidFormula, maxLength)
+	}
+
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFormula, p.tokens[p.pos].text)
+	}
+	return &Formula{source: source, root: root}, nil
+}
+
+// String returns the formula as it was written
+func (f *Formula) String() string {
+	return f.source
+}
+
+// Vars returns the names of the variables the formula uses, sorted
+func (f *Formula) Vars() []string {
+	seen := make(map[string]bool)
+	var walk func(n node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case variable:
+			seen[string(n)] = true
+		case unary:
+			walk(n.operand)
+		case binary:
+			walk(n.left)
+			walk(n.right)
+		case call:
+			for _, arg := range n.args {
+				walk(arg)
+			}
+		}
+	}
+	walk(f.root)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Eval evaluates the formula with the given variable values
+func (f *Formula) Eval(vars map[string]float64) (float64, error) {
+	value, err := f.root.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("result is not a finite number")
+	}
+	return value, nil
+}
+
+// token is a lexical element of a formula
+type token struct {
+	kind byte // 'n' number, 'i' identifier, or the operator or punctuation character itself
+	text string
+}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		ch := rune(source[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case unicode.IsDigit(ch) || ch == '.':
+			start := i
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{'n', source[start:i]})
+		case unicode.IsLetter(ch) || ch == '_':
+			start := i
+			for i < len(source) && (unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i])) || source[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{'i', source[start:i]})
+		case strings.ContainsRune("+-*/%(),", ch):
+			tokens = append(tokens, token{source[i], source[i : i+1]})
+			i++
+		default:
+			return nil, fmt.Errorf("%w: unexpected character %q", ErrInvalidFormula, ch)
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser over the tokens of a formula
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].kind
+	}
+	return 0
+}
+
+// expression parses sums and differences
+func (p *parser) expression(depth int) (node, error) {
+	left, err := p.term(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '+' || p.peek() == '-' {
+		op := p.tokens[p.pos].kind
+		p.pos++
+		right, err := p.term(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op, left, right}
+	}
+	return left, nil
+}
+
+// term parses products, quotients and remainders
+func (p *parser) term(depth int) (node, error) {
+	left, err := p.factor(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '*' || p.peek() == '/' || p.peek() == '%' {
+		op := p.tokens[p.pos].kind
+		p.pos++
+		right, err := p.factor(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op, left, right}
+	}
+	return left, nil
+}
+
+// factor parses numbers, variables, function calls, negation and parenthesised expressions
+func (p *parser) factor(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nested more than %d levels", ErrInvalidFormula, maxDepth)
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected end", ErrInvalidFormula)
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch tok.kind {
+	case 'n':
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad number %q", ErrInvalidFormula, tok.text)
+		}
+		return number(value), nil
+	case 'i':
+		if p.peek() != '(' {
+			return variable(tok.text), nil
+		}
+		return p.call(tok.text, depth)
+	case '-':
+		operand, err := p.factor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return unary{operand}, nil
+	case '(':
+		inner, err := p.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("%w: missing )", ErrInvalidFormula)
+		}
+		p.pos++
+		return inner, nil
+	}
+	return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFormula, tok.text)
+}
+
+// call parses the arguments of a function call; the opening parenthesis is next
+func (p *parser) call(name string, depth int) (node, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidFormula, name)
+	}
+	p.pos++ // (
+
+	var args []node
+	for p.peek() != ')' {
+		if len(args) > 0 {
+			if p.peek() != ',' {
+				return nil, fmt.Errorf("%w: expected , or ) in %s()", ErrInvalidFormula, name)
+			}
+			p.pos++
+		}
+		arg, err := p.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.pos++ // )
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%w: wrong number of arguments to %s()", ErrInvalidFormula, name)
+	}
+	return call{name, args}, nil
+}
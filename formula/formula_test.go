@@ -0,0 +1,89 @@
+package formula
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]float64{
+		"tasks_completed": 6,
+		"tasks_total":     8,
+		"hours_logged":    120,
+		"budget":          10000,
+	}
+
+	tests := []struct {
+		formula string
+		want    float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"2 * -3", -6},
+		{"7 % 4", 3},
+		{"tasks_completed / tasks_total * 100", 75},
+		{"round(tasks_completed / 7 * 100, 1)", 85.7},
+		{"max(0, tasks_total - tasks_completed)", 2},
+		{"min(hours_logged, 100, 150)", 100},
+		{"abs(tasks_completed - tasks_total)", 2},
+		{"budget / 1000 + .5", 10.5},
+	}
+
+	for _, tt := range tests {
+		f, err := Parse(tt.formula)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.formula, err)
+			continue
+		}
+		got, err := f.Eval(vars)
+		if err != nil {
+			t.Errorf("Eval(%q) error = %v", tt.formula, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Eval(%q) = %v, want %v", tt.formula, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, source := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 2",
+		"os.Exit(1)",
+		"exec(1)",
+		"round()",
+		"abs(1, 2)",
+		"1 == 1",
+		"tasks_total; drop",
+	} {
+		if _, err := Parse(source); !errors.Is(err, ErrInvalidFormula) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidFormula", source, err)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	f, _ := Parse("tasks_completed / tasks_total")
+	if _, err := f.Eval(map[string]float64{"tasks_completed": 1, "tasks_total": 0}); !errors.Is(err, ErrDivisionByZero) {
+		t.Errorf("Eval() error = %v, want ErrDivisionByZero", err)
+	}
+	if _, err := f.Eval(map[string]float64{"tasks_completed": 1}); err == nil {
+		t.Error("Eval() with a missing variable succeeded")
+	}
+}
+
+func TestVars(t *testing.T) {
+	f, err := Parse("max(b, a) / (c + b) - round(a)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Vars(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("Vars() = %v", got)
+	}
+}
@@ -0,0 +1,906 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/recurrence"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultAbsenceDays is the length of the who's out range when no end is given
+	defaultAbsenceDays = 14
+	maxAbsenceDays     = 366
+)
+
+// personAbsence is a person's leave within a date range
+type personAbsence struct {
+	PersonID uint           `json:"person_id"`
+	Name     string         `json:"name"`
+	Role     string         `json:"role"`
+	DaysOut  int            `json:"days_out"` // working days missed, not counting holidays
+	Leave    []models.Leave `json:"leave"`
+}
+
+// absencesResponse is who is out within a date range
+type absencesResponse struct {
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	Holidays []models.Holiday `json:"holidays"` // annual holidays dated in the range
+	People   []personAbsence  `json:"people"`
+}
+
+// GetHolidays returns all holidays of a tenant
+// @Summary Get all holidays
+// @Description Get the tenant's holiday calendar
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Success 200 {array} models.Holiday
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /holidays [get]
+func GetHolidays(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var holidays []models.Holiday
+	result := database.DB.Where("tenant_id = ?", tenantID).Order("date ASC").Find(&holidays)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve holidays",
+		})
+	}
+
+	return c.JSON(holidays)
+}
+
+// CreateHoliday adds a holiday to a tenant's calendar
+// @Summary Create a holiday
+// @Description Add a day off for everyone in the tenant, optionally repeating every year
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param holiday body models.Holiday true "Holiday object"
+// @Success 201 {object} models.Holiday
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /holidays [post]
+func CreateHoliday(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var holiday models.Holiday
+	if err := c.BodyParser(&holiday); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if msg := validateHoliday(&holiday); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	holiday.ID = 0
+	holiday.TenantID = uint(tenantID)
+
+	result := database.DB.Create(&holiday)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create holiday: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(holiday)
+}
+
+// UpdateHoliday updates a holiday
+// @Summary Update a holiday
+// @Description Replace the name, date and repetition of a holiday
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Holiday ID"
+// @Param holiday body models.Holiday true "Holiday object"
+// @Success 200 {object} models.Holiday
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /holidays/{id} [put]
+func UpdateHoliday(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var holiday models.Holiday
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&holiday)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Holiday not found",
+		})
+	}
+
+	var updated models.Holiday
+	if err := c.BodyParser(&updated); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if msg := validateHoliday(&updated); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	holiday.Name = updated.Name
+	holiday.Date = updated.Date
+	holiday.Annual = updated.Annual
+
+	result = database.DB.Save(&holiday)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update holiday: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(holiday)
+}
+
+// DeleteHoliday deletes a holiday
+// @Summary Delete a holiday
+// @Description Remove a holiday from the tenant's calendar
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Holiday ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /holidays/{id} [delete]
+func DeleteHoliday(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var holiday models.Holiday
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&holiday)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Holiday not found",
+		})
+	}
+
+	result = database.DB.Delete(&holiday)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete holiday: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Holiday deleted successfully",
+	})
+}
+
+// GetLeaves returns leave requests of a tenant
+// @Summary Get leave requests
+// @Description Get leave requests, optionally filtered by person, status, type, or overlap with a date range
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param person_id query int false "Person ID"
+// @Param status query string false "Leave status"
+// @Param type query string false "Leave type"
+// @Param from query string false "Only leave ending on or after this time (RFC 3339)"
+// @Param to query string false "Only leave starting on or before this time (RFC 3339)"
+// @Success 200 {array} models.Leave
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /leave [get]
+func GetLeaves(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ?", tenantID)
+	if personID := c.Query("person_id"); personID != "" {
+		query = query.Where("person_id = ?", personID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if leaveType := c.Query("type"); leaveType != "" {
+		query = query.Where("type = ?", leaveType)
+	}
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid from time: must be RFC 3339",
+			})
+		}
+		query = query.Where("end_date >= ?", dayOf(from))
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid to time: must be RFC 3339",
+			})
+		}
+		query = query.Where("start_date <= ?", dayOf(to))
+	}
+
+	var leaves []models.Leave
+	result := query.Preload("Person").Order("start_date ASC").Find(&leaves)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve leave",
+		})
+	}
+
+	return c.JSON(leaves)
+}
+
+// GetLeave returns a leave request
+// @Summary Get a leave request
+// @Description Get a leave request by ID
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Leave ID"
+// @Success 200 {object} models.Leave
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /leave/{id} [get]
+func GetLeave(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var leave models.Leave
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).Preload("Person").First(&leave)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Leave not found",
+		})
+	}
+
+	return c.JSON(leave)
+}
+
+// CreateLeave requests leave for a person
+// @Summary Request leave
+// @Description Request leave for a person from the start date to the end date, inclusive. Requests start pending and only reduce capacity once approved.
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param leave body models.Leave true "Leave object"
+// @Success 201 {object} models.Leave
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /leave [post]
+func CreateLeave(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var leave models.Leave
+	if err := c.BodyParser(&leave); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if msg := validateLeave(uint(tenantID), &leave); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	leave.ID = 0
+	leave.TenantID = uint(tenantID)
+	leave.Status = models.LeaveStatusPending
+	leave.DecidedByID = nil
+	leave.DecidedAt = nil
+	leave.Person = nil
+
+	if leaveOverlaps(database.DB, &leave) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Leave overlaps another request of the same person",
+		})
+	}
+
+	result := database.DB.Create(&leave)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create leave: " + result.Error.Error(),
+		})
+	}
+
+	database.DB.Preload("Person").First(&leave, leave.ID)
+	return c.Status(fiber.StatusCreated).JSON(leave)
+}
+
+// UpdateLeave updates a pending leave request
+// @Summary Update a leave request
+// @Description Change the type, dates and reason of a leave request that is still pending
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Leave ID"
+// @Param leave body models.Leave true "Leave object"
+// @Success 200 {object} models.Leave
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /leave/{id} [put]
+func UpdateLeave(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var leave models.Leave
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&leave)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Leave not found",
+		})
+	}
+
+	if leave.Status != models.LeaveStatusPending {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only pending leave can be changed",
+		})
+	}
+
+	var updated models.Leave
+	if err := c.BodyParser(&updated); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	// The person on leave cannot change
+	updated.PersonID = leave.PersonID
+	if msg := validateLeave(uint(tenantID), &updated); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	leave.Type = updated.Type
+	leave.StartDate = updated.StartDate
+	leave.EndDate = updated.EndDate
+	leave.Reason = updated.Reason
+
+	if leaveOverlaps(database.DB, &leave) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Leave overlaps another request of the same person",
+		})
+	}
+
+	result = database.DB.Save(&leave)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update leave: " + result.Error.Error(),
+		})
+	}
+
+	database.DB.Preload("Person").First(&leave, leave.ID)
+	return c.JSON(leave)
+}
+
+// ApproveLeave approves a pending leave request
+// @Summary Approve leave
+// @Description Approve a pending leave request, taking the days out of the person's capacity. The deciding person is identified by X-Person-ID and cannot approve their own leave.
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "ID of the person deciding"
+// @Param id path int true "Leave ID"
+// @Success 200 {object} models.Leave
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /leave/{id}/approve [post]
+func ApproveLeave(c *fiber.Ctx) error {
+	return decideLeave(c, models.LeaveStatusApproved)
+}
+
+// RejectLeave rejects a pending leave request
+// @Summary Reject leave
+// @Description Reject a pending leave request. The deciding person is identified by X-Person-ID and cannot reject their own leave.
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "ID of the person deciding"
+// @Param id path int true "Leave ID"
+// @Success 200 {object} models.Leave
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /leave/{id}/reject [post]
+func RejectLeave(c *fiber.Ctx) error {
+	return decideLeave(c, models.LeaveStatusRejected)
+}
+
+// CancelLeave cancels a pending or approved leave request
+// @Summary Cancel leave
+// @Description Cancel a pending or approved leave request, giving the days back to the person's capacity
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Leave ID"
+// @Success 200 {object} models.Leave
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /leave/{id}/cancel [post]
+func CancelLeave(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var leave models.Leave
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&leave)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Leave not found",
+		})
+	}
+
+	if leave.Status != models.LeaveStatusPending && leave.Status != models.LeaveStatusApproved {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only pending or approved leave can be cancelled",
+		})
+	}
+
+	leave.Status = models.LeaveStatusCancelled
+	result = database.DB.Save(&leave)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel leave: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(leave)
+}
+
+// GetAbsences returns who is out within a date range
+// @Summary Get who is out
+// @Description Get the holidays and the people on leave between two dates, with the working days each person misses
+// @Tags absence
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param from query string false "First day (RFC 3339), defaults to today"
+// @Param to query string false "Last day (RFC 3339), defaults to two weeks after from"
+// @Param include_pending query bool false "Include leave that is not approved yet"
+// @Success 200 {object} absencesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /absences [get]
+func GetAbsences(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	from := time.Now()
+	if value := c.Query("from"); value != "" {
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid from time: must be RFC 3339",
+			})
+		}
+	}
+	from = dayOf(from)
+
+	to := from.AddDate(0, 0, defaultAbsenceDays-1)
+	if value := c.Query("to"); value != "" {
+		to, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid to time: must be RFC 3339",
+			})
+		}
+		to = dayOf(to)
+	}
+	if to.Before(from) || to.After(from.AddDate(0, 0, maxAbsenceDays-1)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "To must be on or after from and at most a year later",
+		})
+	}
+
+	statuses := []models.LeaveStatus{models.LeaveStatusApproved}
+	if c.QueryBool("include_pending") {
+		statuses = append(statuses, models.LeaveStatusPending)
+	}
+
+	var leaves []models.Leave
+	result := database.DB.Where("tenant_id = ? AND status IN ? AND start_date <= ? AND end_date >= ?", tenantID, statuses, to, from).
+		Preload("Person").
+		Order("start_date ASC").
+		Find(&leaves)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve leave",
+		})
+	}
+
+	calendar, err := loadHolidays(database.DB, uint(tenantID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve holidays",
+		})
+	}
+
+	response := absencesResponse{From: from, To: to, Holidays: []models.Holiday{}, People: []personAbsence{}}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if holiday := calendar.holiday(day); holiday != nil {
+			dated := *holiday
+			dated.Date = day
+			response.Holidays = append(response.Holidays, dated)
+		}
+	}
+
+	byPerson := make(map[uint]int) // position in People
+	for _, leave := range leaves {
+		if leave.Person == nil {
+			continue
+		}
+		i, ok := byPerson[leave.PersonID]
+		if !ok {
+			i = len(response.People)
+			byPerson[leave.PersonID] = i
+			response.People = append(response.People, personAbsence{
+				PersonID: leave.PersonID,
+				Name:     leave.Person.Name,
+				Role:     leave.Person.Role,
+			})
+		}
+
+		person := leave.Person
+		leave.Person = nil
+		absence := &response.People[i]
+		absence.Leave = append(absence.Leave, leave)
+		for day := maxTime(from, leave.StartDate); !day.After(to) && !day.After(leave.EndDate); day = day.AddDate(0, 0, 1) {
+			if person.WorksOn(day.Weekday()) && calendar.holiday(day) == nil {
+				absence.DaysOut++
+			}
+		}
+	}
+
+	sort.SliceStable(response.People, func(i, j int) bool {
+		return response.People[i].Name < response.People[j].Name
+	})
+
+	return c.JSON(response)
+}
+
+// decideLeave approves or rejects a pending leave request on behalf of the acting person
+func decideLeave(c *fiber.Ctx, status models.LeaveStatus) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var leave models.Leave
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&leave)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Leave not found",
+		})
+	}
+
+	if leave.Status != models.LeaveStatusPending {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only pending leave can be approved or rejected",
+		})
+	}
+
+	// Leave is decided by a known person of the tenant, who is recorded as the decider
+	actor := actorID(c)
+	if actor == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "X-Person-ID is required to approve or reject leave",
+		})
+	}
+	if _, err := actorRole(c, uint(tenantID)); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if *actor == leave.PersonID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Leave cannot be decided by the person taking it",
+		})
+	}
+
+	now := time.Now()
+	leave.Status = status
+	leave.DecidedByID = actor
+	leave.DecidedAt = &now
+
+	result = database.DB.Save(&leave)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update leave: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(leave)
+}
+
+// validateHoliday checks a holiday and moves its date to the start of the day
+func validateHoliday(holiday *models.Holiday) string {
+	if holiday.Name == "" {
+		return "Holiday name is required"
+	}
+	if holiday.Date.IsZero() {
+		return "Holiday date is required"
+	}
+	holiday.Date = dayOf(holiday.Date)
+	return ""
+}
+
+// validateLeave checks a leave request and moves its dates to the start of their days.
+// A request without an end date is for a single day.
+func validateLeave(tenantID uint, leave *models.Leave) string {
+	if leave.Type == "" {
+		leave.Type = models.LeaveTypeVacation
+	}
+
+	switch leave.Type {
+	case models.LeaveTypeVacation, models.LeaveTypeSick, models.LeaveTypeOther:
+	default:
+		return "Invalid leave type: " + string(leave.Type)
+	}
+
+	if leave.StartDate.IsZero() {
+		return "Leave start date is required"
+	}
+	if leave.EndDate.IsZero() {
+		leave.EndDate = leave.StartDate
+	}
+	leave.StartDate = dayOf(leave.StartDate)
+	leave.EndDate = dayOf(leave.EndDate)
+	if leave.EndDate.Before(leave.StartDate) {
+		return "Leave end date must not be before its start date"
+	}
+
+	var person models.Person
+	if err := database.DB.Where("id = ? AND tenant_id = ?", leave.PersonID, tenantID).First(&person).Error; err != nil {
+		return "Person not found or not in the same tenant"
+	}
+	return ""
+}
+
+// leaveOverlaps reports whether a person already has pending or approved leave on any of the requested days
+func leaveOverlaps(db *gorm.DB, leave *models.Leave) bool {
+	var count int64
+	db.Model(&models.Leave{}).
+		Where("person_id = ? AND id <> ? AND status IN ?", leave.PersonID, leave.ID, []models.LeaveStatus{models.LeaveStatusPending, models.LeaveStatusApproved}).
+		Where("start_date <= ? AND end_date >= ?", leave.EndDate, leave.StartDate).
+		Count(&count)
+	return count > 0
+}
+
+// workCalendar is a tenant's holidays and its people's approved leave. Capacity, scheduling
+// and due dates use it to count working time.
+type workCalendar struct {
+	holidays []models.Holiday
+	leave    map[uint][]models.Leave // approved leave by person
+}
+
+// loadHolidays returns a calendar of a tenant's holidays, without anyone's leave
+func loadHolidays(db *gorm.DB, tenantID uint) (*workCalendar, error) {
+	calendar := &workCalendar{leave: make(map[uint][]models.Leave)}
+	if err := db.Where("tenant_id = ?", tenantID).Find(&calendar.holidays).Error; err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// loadWorkCalendar returns a calendar of a tenant's holidays and the approved leave overlapping the days from one date to another
+func loadWorkCalendar(db *gorm.DB, tenantID uint, from, to time.Time) (*workCalendar, error) {
+	calendar, err := loadHolidays(db, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaves []models.Leave
+	result := db.Where("tenant_id = ? AND status = ? AND start_date <= ? AND end_date >= ?", tenantID, models.LeaveStatusApproved, to, from).Find(&leaves)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, leave := range leaves {
+		calendar.leave[leave.PersonID] = append(calendar.leave[leave.PersonID], leave)
+	}
+	return calendar, nil
+}
+
+// holiday returns the holiday on a day, or nil
+func (w *workCalendar) holiday(day time.Time) *models.Holiday {
+	for i := range w.holidays {
+		if w.holidays[i].FallsOn(day) {
+			return &w.holidays[i]
+		}
+	}
+	return nil
+}
+
+// onLeave reports whether a person has approved leave on a day
+func (w *workCalendar) onLeave(personID uint, day time.Time) bool {
+	for i := range w.leave[personID] {
+		if w.leave[personID][i].Covers(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// workingHours returns the hours a person is available on a day: their daily hours on their
+// working days, unless it is a holiday or they are on leave
+func (w *workCalendar) workingHours(person *models.Person, day time.Time) float64 {
+	if !person.WorksOn(day.Weekday()) || w.holiday(day) != nil || w.onLeave(person.ID, day) {
+		return 0
+	}
+	return person.DailyHours()
+}
+
+// nextWorkingDay moves a time on a weekend or holiday forward to the next working day, keeping the time of day
+func (w *workCalendar) nextWorkingDay(t time.Time) time.Time {
+	for i := 0; i < maxAbsenceDays; i++ {
+		t = recurrence.NextWeekday(t)
+		if w.holiday(t) == nil {
+			break
+		}
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// maxTime returns the later of two times
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,123 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupAbsenceDB sets up an isolated in-memory SQLite database with two people
+func setupAbsenceDB(t *testing.T) {
+	newTestDB(t)
+
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "developer", HoursPerWeek: 40, WorkingDays: "mon,tue,wed,thu,fri"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Bob", Email: "bob@example.com", Role: "developer", HoursPerWeek: 40, WorkingDays: "mon,tue,wed,thu,fri"})
+}
+
+func setupAbsenceApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Post("/holidays", handlers.CreateHoliday)
+	app.Post("/leave", handlers.CreateLeave)
+	app.Post("/leave/:id/approve", handlers.ApproveLeave)
+	app.Post("/leave/:id/cancel", handlers.CancelLeave)
+	app.Get("/absences", handlers.GetAbsences)
+	app.Get("/workload", handlers.GetWorkload)
+	return app
+}
+
+func TestLeaveApprovalFeedsCapacity(t *testing.T) {
+	setupAbsenceDB(t)
+	app := setupAbsenceApp()
+
+	status, _ := doRequest(t, app, "POST", "/holidays", `{"date":"2025-01-01T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "POST", "/holidays", `{"name":"New Year","date":"2025-01-01T00:00:00Z","annual":true}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	status, _ = doRequest(t, app, "POST", "/leave", `{"person_id":1,"type":"sabbatical","start_date":"2030-01-07T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "POST", "/leave", `{"person_id":1,"start_date":"2030-01-07T00:00:00Z","end_date":"2030-01-04T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, body := doRequest(t, app, "POST", "/leave", `{"person_id":1,"type":"vacation","start_date":"2030-01-07T00:00:00Z","end_date":"2030-01-13T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	var leave models.Leave
+	assert.NoError(t, json.Unmarshal(body, &leave))
+	assert.Equal(t, models.LeaveStatusPending, leave.Status)
+	status, _ = doRequest(t, app, "POST", "/leave", `{"person_id":1,"type":"sick","start_date":"2030-01-10T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	var absences struct {
+		Holidays []models.Holiday `json:"holidays"`
+		People   []struct {
+			Name    string `json:"name"`
+			DaysOut int    `json:"days_out"`
+		} `json:"people"`
+	}
+	const window = "/absences?from=2029-12-31T00:00:00Z&to=2030-01-13T00:00:00Z"
+
+	// Pending leave is only listed on request
+	_, body = doRequest(t, app, "GET", window, "")
+	assert.NoError(t, json.Unmarshal(body, &absences))
+	assert.Empty(t, absences.People)
+	_, body = doRequest(t, app, "GET", window+"&include_pending=true", "")
+	assert.NoError(t, json.Unmarshal(body, &absences))
+	assert.Len(t, absences.People, 1)
+
+	// Leave is approved by someone else, who has to say who they are
+	status, _ = doRequest(t, app, "POST", "/leave/1/approve", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequestAs(t, app, "9", "POST", "/leave/1/approve", "")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = doRequestAs(t, app, "1", "POST", "/leave/1/approve", "")
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, body = doRequestAs(t, app, "2", "POST", "/leave/1/approve", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NoError(t, json.Unmarshal(body, &leave))
+	assert.Equal(t, uint(2), *leave.DecidedByID)
+	status, _ = doRequestAs(t, app, "2", "POST", "/leave/1/approve", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, body = doRequest(t, app, "GET", window, "")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NoError(t, json.Unmarshal(body, &absences))
+	if assert.Len(t, absences.Holidays, 1) {
+		assert.Equal(t, 2030, absences.Holidays[0].Date.Year())
+	}
+	if assert.Len(t, absences.People, 1) {
+		assert.Equal(t, "Alice", absences.People[0].Name)
+		assert.Equal(t, 5, absences.People[0].DaysOut)
+	}
+
+	// The holiday and the approved leave come out of Alice's capacity
+	var workload struct {
+		People []struct {
+			Weeks []struct {
+				CapacityHours float64 `json:"capacity_hours"`
+				AbsenceHours  float64 `json:"absence_hours"`
+			} `json:"weeks"`
+		} `json:"people"`
+	}
+	_, body = doRequest(t, app, "GET", "/workload?from=2029-12-31T00:00:00Z&weeks=2&person_id=1", "")
+	assert.NoError(t, json.Unmarshal(body, &workload))
+	if assert.Len(t, workload.People, 1) && assert.Len(t, workload.People[0].Weeks, 2) {
+		assert.Equal(t, 32.0, workload.People[0].Weeks[0].CapacityHours)
+		assert.Equal(t, 8.0, workload.People[0].Weeks[0].AbsenceHours)
+		assert.Equal(t, 0.0, workload.People[0].Weeks[1].CapacityHours)
+	}
+
+	status, _ = doRequest(t, app, "POST", "/leave/1/cancel", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	_, body = doRequest(t, app, "GET", window, "")
+	absences.People = nil
+	assert.NoError(t, json.Unmarshal(body, &absences))
+	assert.Empty(t, absences.People)
+}
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Masozee/kontena/api/database"
 	"github.com/Masozee/kontena/api/models"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // Asset Category Handlers
@@ -207,6 +210,8 @@ func DeleteAssetCategory(c *fiber.Ctx) error {
 // @Param status query string false "Filter by status"
 // @Param location_id query int false "Filter by location ID"
 // @Param assigned_to query int false "Filter by assignee ID"
+// @Param labels query string false "Comma-separated label IDs"
+// @Param label_match query string false "any (default) or all of the labels"
 // @Success 200 {array} models.Asset
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -234,9 +239,19 @@ func GetAssets(c *fiber.Ctx) error {
 		query = query.Where("current_assignee = ?", assigneeID)
 	}
 
+	labelQuery, msg := labelFilter(c, database.DB, models.LabelEntityAsset)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	if labelQuery != nil {
+		query = query.Where("id IN (?)", labelQuery)
+	}
+
 	// Execute query
 	var assets []models.Asset
-	result := query.Preload("Category").Find(&assets)
+	result := query.Preload("Category").Preload("Labels").Find(&assets)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve assets",
@@ -267,6 +282,7 @@ func GetAsset(c *fiber.Ctx) error {
 		Preload("Category").
 		Preload("Location").
 		Preload("AssignedTo").
+		Preload("Labels").
 		First(&asset)
 
 	if result.Error != nil {
@@ -280,7 +296,7 @@ func GetAsset(c *fiber.Ctx) error {
 
 // CreateAsset creates a new asset
 // @Summary Create an asset
-// @Description Create a new asset
+// @Description Create a new asset. Deprecated: tags, comma-separated or a list, become the asset's labels.
 // @Tags assets
 // @Accept json
 // @Produce json
@@ -297,6 +313,12 @@ func CreateAsset(c *fiber.Ctx) error {
 			"error": "Invalid request body",
 		})
 	}
+	tags, sentTags, err := assetTags(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
 	// Set tenant ID from context
 	tenantIDStr := c.Locals("tenant_id").(string)
@@ -307,6 +329,7 @@ func CreateAsset(c *fiber.Ctx) error {
 		})
 	}
 	asset.TenantID = uint(tenantID)
+	asset.Labels = nil // labels are set through /assets/:id/labels, or the deprecated tags
 
 	// Validate required fields
 	if asset.Name == "" {
@@ -335,8 +358,18 @@ func CreateAsset(c *fiber.Ctx) error {
 		asset.Status = models.AssetStatusInStock
 	}
 
-	result = database.DB.Create(&asset)
-	if result.Error != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&asset).Error; err != nil {
+			return err
+		}
+		if sentTags {
+			if err := setAssetTags(tx, asset, tags); err != nil {
+				return err
+			}
+		}
+		return publishEvent(tx, asset.TenantID, models.EventAssetCreated, asset.ID, asset)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create asset",
 		})
@@ -347,7 +380,7 @@ func CreateAsset(c *fiber.Ctx) error {
 
 // UpdateAsset updates an asset
 // @Summary Update an asset
-// @Description Update an asset by ID
+// @Description Update an asset by ID. Deprecated: tags, comma-separated or a list, replace the asset's labels.
 // @Tags assets
 // @Accept json
 // @Produce json
@@ -377,6 +410,12 @@ func UpdateAsset(c *fiber.Ctx) error {
 			"error": "Invalid request body",
 		})
 	}
+	tags, sentTags, err := assetTags(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
 	// Update fields
 	if updateData.Name != "" {
@@ -411,10 +450,25 @@ func UpdateAsset(c *fiber.Ctx) error {
 	asset.CurrentAssignee = updateData.CurrentAssignee
 	asset.ExpectedLifespan = updateData.ExpectedLifespan
 	asset.Notes = updateData.Notes
-	asset.Tags = updateData.Tags
 	asset.Barcode = updateData.Barcode
 
-	database.DB.Save(&asset)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&asset).Error; err != nil {
+			return err
+		}
+		if sentTags {
+			if err := setAssetTags(tx, &asset, tags); err != nil {
+				return err
+			}
+		}
+		return publishEvent(tx, asset.TenantID, models.EventAssetUpdated, asset.ID, asset)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update asset",
+		})
+	}
+
 	return c.JSON(asset)
 }
 
@@ -458,7 +512,18 @@ func DeleteAsset(c *fiber.Ctx) error {
 		})
 	}
 
-	database.DB.Delete(&asset)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&asset).Error; err != nil {
+			return err
+		}
+		return publishEvent(tx, asset.TenantID, models.EventAssetDeleted, asset.ID, asset)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete asset",
+		})
+	}
+
 	return c.Status(fiber.StatusNoContent).JSON(fiber.Map{
 		"message": "Asset deleted successfully",
 	})
@@ -1020,9 +1085,19 @@ func CreateProcurementRequest(c *fiber.Ctx) error {
 		})
 	}
 
-	// Set default values
+	// New requests start in the workflow's initial state unless a valid state is given
+	def, _, err := resolveWorkflow(uint(tenantID), nil, models.WorkflowEntityProcurement)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load workflow: " + err.Error(),
+		})
+	}
 	if request.Status == "" {
-		request.Status = models.ProcurementStatusDraft
+		request.Status = models.ProcurementStatus(def.InitialState())
+	} else if !def.HasState(string(request.Status)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid procurement request status: " + string(request.Status),
+		})
 	}
 
 	if request.RequestNumber == "" {
@@ -1054,6 +1129,14 @@ func CreateProcurementRequest(c *fiber.Ctx) error {
 		})
 	}
 
+	// Requesters watch their requests
+	if err := watch(tx, request.TenantID, models.WatchEntityProcurement, request.ID, request.RequestedByID); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to add requester as watcher",
+		})
+	}
+
 	// Process items if provided
 	if len(request.Items) > 0 {
 		for i := range request.Items {
@@ -1091,6 +1174,13 @@ func CreateProcurementRequest(c *fiber.Ctx) error {
 		}
 	}
 
+	if err := publishEvent(tx, request.TenantID, models.EventProcurementCreated, request.ID, request); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record procurement event",
+		})
+	}
+
 	// Commit transaction
 	tx.Commit()
 
@@ -1130,8 +1220,21 @@ func UpdateProcurementRequest(c *fiber.Ctx) error {
 		})
 	}
 
-	// Only allow updates to draft or submitted requests
-	if request.Status != models.ProcurementStatusDraft && request.Status != models.ProcurementStatusSubmitted {
+	tenantIDUint, err := strconv.ParseUint(tenantID, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+		})
+	}
+	def, _, err := resolveWorkflow(uint(tenantIDUint), nil, models.WorkflowEntityProcurement)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load workflow: " + err.Error(),
+		})
+	}
+
+	// Only allow updates while the request can still move to another status
+	if len(def.Available(string(request.Status))) == 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Cannot update procurement request in " + string(request.Status) + " status",
 		})
@@ -1145,51 +1248,19 @@ func UpdateProcurementRequest(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update fields
-	if updateData.Status != "" {
-		// Validate status transitions
-		if request.Status == models.ProcurementStatusDraft {
-			if updateData.Status != models.ProcurementStatusSubmitted &&
-				updateData.Status != models.ProcurementStatusCancelled {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": "Invalid status transition from draft",
-				})
-			}
-		} else if request.Status == models.ProcurementStatusSubmitted {
-			if updateData.Status != models.ProcurementStatusApproved &&
-				updateData.Status != models.ProcurementStatusRejected &&
-				updateData.Status != models.ProcurementStatusCancelled {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": "Invalid status transition from submitted",
-				})
-			}
-
-			// If approving, require approval fields
-			if updateData.Status == models.ProcurementStatusApproved {
-				if updateData.ApprovedByID == nil {
-					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-						"error": "Approved by ID is required when approving",
-					})
-				}
-
-				// Verify approver exists and belongs to tenant
-				var approver models.Person
-				result := database.DB.Where("id = ? AND tenant_id = ?", *updateData.ApprovedByID, tenantID).First(&approver)
-				if result.Error != nil {
-					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-						"error": "Invalid approver ID",
-					})
-				}
-
-				// Set approval date
-				now := time.Now()
-				request.ApprovalDate = &now
-			}
+	// Verify approver exists and belongs to tenant if provided
+	if updateData.ApprovedByID != nil {
+		var approver models.Person
+		result := database.DB.Where("id = ? AND tenant_id = ?", *updateData.ApprovedByID, tenantID).First(&approver)
+		if result.Error != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid approver ID",
+			})
 		}
-
-		request.Status = updateData.Status
 	}
 
+	// Update fields
+	previousStatus := request.Status
 	request.Notes = updateData.Notes
 	request.ExpectedDate = updateData.ExpectedDate
 	request.TotalBudget = updateData.TotalBudget
@@ -1198,7 +1269,51 @@ func UpdateProcurementRequest(c *fiber.Ctx) error {
 		request.ApprovedByID = updateData.ApprovedByID
 	}
 
-	database.DB.Save(&request)
+	if updateData.Status != "" {
+		request.Status = updateData.Status
+	}
+
+	// Validate the status change against the tenant's procurement workflow
+	if request.Status != previousStatus {
+		role, err := actorRole(c, uint(tenantIDUint))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err := def.Validate(string(previousStatus), string(request.Status), role, request); err != nil {
+			return workflowError(c, err)
+		}
+
+		// Record when the request was approved
+		if request.Status == models.ProcurementStatusApproved {
+			now := time.Now()
+			request.ApprovalDate = &now
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+		if request.Status != previousStatus {
+			if err := notifyProcurementStatus(tx, &request, previousStatus, actorID(c)); err != nil {
+				return err
+			}
+			return publishEvent(tx, request.TenantID, models.EventProcurementStatusChanged, request.ID, statusChange{
+				PreviousStatus: string(previousStatus),
+				Status:         string(request.Status),
+				Object:         request,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update procurement request: " + err.Error(),
+		})
+	}
 
 	// Reload the request with all relationships
 	database.DB.Where("id = ?", request.ID).
@@ -1279,6 +1394,30 @@ func DeleteProcurementRequest(c *fiber.Ctx) error {
 	})
 }
 
+// notifyProcurementStatus tells the requester and watchers of a procurement request that its status changed
+func notifyProcurementStatus(tx *gorm.DB, request *models.ProcurementRequest, previousStatus models.ProcurementStatus, actorID *uint) error {
+	watchers, err := watcherIDs(tx, models.WatchEntityProcurement, request.ID)
+	if err != nil {
+		return err
+	}
+
+	notificationType := models.NotificationTypeProcurementStatusChanged
+	if request.Status == models.ProcurementStatusApproved {
+		notificationType = models.NotificationTypeProcurementApproved
+	}
+
+	notification := models.Notification{
+		TenantID:   request.TenantID,
+		Type:       notificationType,
+		EntityType: string(models.WatchEntityProcurement),
+		EntityID:   request.ID,
+		ActorID:    actorID,
+		Message:    fmt.Sprintf("Procurement request %s moved from %s to %s", request.RequestNumber, previousStatus, request.Status),
+	}
+	_, err = notify(tx, notification, append([]uint{request.RequestedByID}, watchers...), withActor(actorID)...)
+	return err
+}
+
 // Asset Assignment Handlers
 
 // GetAssetAssignments returns all asset assignments for a tenant
@@ -1484,6 +1623,28 @@ func CreateAssetAssignment(c *fiber.Ctx) error {
 		})
 	}
 
+	notification := models.Notification{
+		TenantID:   assignment.TenantID,
+		Type:       models.NotificationTypeAssetAssigned,
+		EntityType: "asset_assignment",
+		EntityID:   assignment.ID,
+		ActorID:    &assignment.AssignedByID,
+		Message:    fmt.Sprintf("Asset %q was assigned to you", asset.Name),
+	}
+	if _, err := notify(tx, notification, []uint{assignment.AssignedToID}, assignment.AssignedByID); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create assignment notification",
+		})
+	}
+
+	if err := publishEvent(tx, assignment.TenantID, models.EventAssetAssigned, assignment.AssetID, assignment); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record asset event",
+		})
+	}
+
 	// Commit transaction
 	tx.Commit()
 
@@ -1772,6 +1933,7 @@ func CreateMaintenanceRecord(c *fiber.Ctx) error {
 		})
 	}
 	record.TenantID = uint(tenantID)
+	record.DueNotifiedAt = nil
 
 	// Validate required fields
 	if record.AssetID == 0 {
@@ -1957,6 +2119,7 @@ func UpdateMaintenanceRecord(c *fiber.Ctx) error {
 
 	if updateData.ScheduledDate != record.ScheduledDate {
 		record.ScheduledDate = updateData.ScheduledDate
+		record.DueNotifiedAt = nil // remind again for the new date
 	}
 
 	if updateData.PerformedByID != nil {
@@ -2051,3 +2214,41 @@ func DeleteMaintenanceRecord(c *fiber.Ctx) error {
 		"message": "Maintenance record deleted successfully",
 	})
 }
+
+// assetTagsWarning is the Warning header of asset requests that still send tags
+const assetTagsWarning = `299 - "tags is deprecated; set labels with /assets/{id}/labels"`
+
+// assetTags returns the tags of a request that sends the tags assets had before labels,
+// either comma-separated or as a list, and warns the client that they are deprecated.
+func assetTags(c *fiber.Ctx) (string, bool, error) {
+	var legacy struct {
+		Tags *json.RawMessage `json:"tags"`
+	}
+	if c.BodyParser(&legacy) != nil || legacy.Tags == nil {
+		return "", false, nil
+	}
+
+	c.Set(fiber.HeaderWarning, assetTagsWarning)
+	var tags string
+	if err := json.Unmarshal(*legacy.Tags, &tags); err == nil {
+		return tags, true, nil
+	}
+	var list []string
+	if err := json.Unmarshal(*legacy.Tags, &list); err != nil {
+		return "", false, fmt.Errorf("tags must be a comma-separated string or a list of strings")
+	}
+	return strings.Join(list, ","), true, nil
+}
+
+// setAssetTags replaces the labels of an asset with the labels named by its tags
+func setAssetTags(tx *gorm.DB, asset *models.Asset, tags string) error {
+	labels, err := database.TagLabels(tx, asset.TenantID, tags)
+	if err != nil {
+		return err
+	}
+	asset.Labels = make([]*models.Label, len(labels))
+	for i := range labels {
+		asset.Labels[i] = &labels[i]
+	}
+	return tx.Model(asset).Association("Labels").Replace(asset.Labels)
+}
@@ -0,0 +1,503 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// baselineRequest names a new baseline
+type baselineRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// dateVariance compares a date with its baseline
+type dateVariance struct {
+	Baseline     *time.Time `json:"baseline"`
+	Current      *time.Time `json:"current"`
+	VarianceDays *int       `json:"variance_days"` // positive when later than the baseline, unset when either date is
+}
+
+// budgetVariance compares the budget with its baseline
+type budgetVariance struct {
+	Baseline      float64  `json:"baseline"`
+	Current       float64  `json:"current"`
+	Change        float64  `json:"change"`
+	ChangePercent *float64 `json:"change_percent"` // unset when the baseline budget is zero
+}
+
+// scopeVariance compares the amount of work with its baseline
+type scopeVariance struct {
+	BaselineTasks          int     `json:"baseline_tasks"`
+	CurrentTasks           int     `json:"current_tasks"`
+	BaselineStoryPoints    float64 `json:"baseline_story_points"`
+	CurrentStoryPoints     float64 `json:"current_story_points"`
+	BaselineEstimatedHours float64 `json:"baseline_estimated_hours"`
+	CurrentEstimatedHours  float64 `json:"current_estimated_hours"`
+}
+
+// taskVariance compares a task's due date with its baseline
+type taskVariance struct {
+	TaskID uint              `json:"task_id"`
+	Title  string            `json:"title"`
+	Status models.TaskStatus `json:"status"`
+	dateVariance
+}
+
+// milestoneVariance compares a milestone's due date with its baseline
+type milestoneVariance struct {
+	MilestoneID uint   `json:"milestone_id"`
+	Title       string `json:"title"`
+	dateVariance
+}
+
+// baselineVariance is how a project has moved since a baseline was taken
+type baselineVariance struct {
+	BaselineID        uint                `json:"baseline_id"`
+	BaselineName      string              `json:"baseline_name"`
+	TakenAt           time.Time           `json:"taken_at"`
+	EndDate           dateVariance        `json:"end_date"`
+	Budget            budgetVariance      `json:"budget"`
+	Scope             scopeVariance       `json:"scope"`
+	SlippedTasks      int                 `json:"slipped_tasks"`      // tasks due later than planned
+	SlippedMilestones int                 `json:"slipped_milestones"` // milestones due later than planned
+	MaxSlipDays       int                 `json:"max_slip_days"`      // the largest slip of a task or milestone
+	Tasks             []taskVariance      `json:"tasks"`              // in both the baseline and the project
+	AddedTasks        []taskVariance      `json:"added_tasks"`
+	RemovedTasks      []taskVariance      `json:"removed_tasks"`
+	Milestones        []milestoneVariance `json:"milestones"`
+	AddedMilestones   []milestoneVariance `json:"added_milestones"`
+	RemovedMilestones []milestoneVariance `json:"removed_milestones"`
+}
+
+// GetProjectBaselines returns the baselines of a project
+// @Summary Get project baselines
+// @Description Get the baselines taken of a project, newest first
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Success 200 {array} models.ProjectBaseline
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/baselines [get]
+func GetProjectBaselines(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	var baselines []models.ProjectBaseline
+	result = database.DB.Where("project_id = ?", project.ID).Order("created_at DESC, id DESC").Find(&baselines)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve baselines",
+		})
+	}
+
+	return c.JSON(baselines)
+}
+
+// CreateProjectBaseline takes a baseline of a project
+// @Summary Take a project baseline
+// @Description Snapshot a project's task and milestone dates, budget and scope under a name unique within the project
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Param baseline body baselineRequest true "Baseline name and description"
+// @Success 201 {object} models.ProjectBaseline
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/baselines [post]
+func CreateProjectBaseline(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	req := new(baselineRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Baseline name is required",
+		})
+	}
+
+	var existing int64
+	database.DB.Model(&models.ProjectBaseline{}).Where("project_id = ? AND name = ?", project.ID, req.Name).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Project already has a baseline named " + req.Name,
+		})
+	}
+
+	baseline, err := snapshotBaseline(database.DB, &project)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read project: " + err.Error(),
+		})
+	}
+	baseline.Name = req.Name
+	baseline.Description = req.Description
+	baseline.CreatedByID = actorID(c)
+
+	result = database.DB.Create(&baseline)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create baseline: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(baseline)
+}
+
+// GetProjectBaseline returns a baseline
+// @Summary Get a project baseline
+// @Description Get a project baseline by ID
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Baseline ID"
+// @Success 200 {object} models.ProjectBaseline
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /baselines/{id} [get]
+func GetProjectBaseline(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var baseline models.ProjectBaseline
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&baseline)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Baseline not found",
+		})
+	}
+
+	return c.JSON(baseline)
+}
+
+// DeleteProjectBaseline deletes a baseline
+// @Summary Delete a project baseline
+// @Description Delete a project baseline by ID
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Baseline ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /baselines/{id} [delete]
+func DeleteProjectBaseline(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var baseline models.ProjectBaseline
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&baseline)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Baseline not found",
+		})
+	}
+
+	result = database.DB.Delete(&baseline)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete baseline: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Baseline deleted successfully",
+	})
+}
+
+// GetBaselineVariance compares a project with one of its baselines
+// @Summary Get schedule variance against a baseline
+// @Description Compare the project's current task and milestone dates, end date, budget and scope with a baseline, including tasks and milestones added or removed since
+// @Tags baselines
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Baseline ID"
+// @Success 200 {object} baselineVariance
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /baselines/{id}/variance [get]
+func GetBaselineVariance(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var baseline models.ProjectBaseline
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&baseline)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Baseline not found",
+		})
+	}
+
+	var project models.Project
+	result = database.DB.Where("id = ? AND tenant_id = ?", baseline.ProjectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	current, err := snapshotBaseline(database.DB, &project)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read project: " + err.Error(),
+		})
+	}
+
+	return c.JSON(compareBaselines(&baseline, &current))
+}
+
+// snapshotBaseline captures a project's current dates, budget and tasks as an unsaved baseline
+func snapshotBaseline(db *gorm.DB, project *models.Project) (models.ProjectBaseline, error) {
+	baseline := models.ProjectBaseline{
+		TenantID:   project.TenantID,
+		ProjectID:  project.ID,
+		StartDate:  project.StartDate,
+		EndDate:    project.EndDate,
+		Budget:     project.Budget,
+		Tasks:      []models.BaselineTask{},
+		Milestones: []models.BaselineMilestone{},
+	}
+
+	var tasks []models.Task
+	if err := db.Where("project_id = ?", project.ID).Order("id ASC").Find(&tasks).Error; err != nil {
+		return baseline, err
+	}
+	for _, task := range tasks {
+		baseline.Tasks = append(baseline.Tasks, models.BaselineTask{
+			TaskID:         task.ID,
+			Title:          task.Title,
+			Status:         task.Status,
+			DueDate:        task.DueDate,
+			StoryPoints:    task.StoryPoints,
+			EstimatedHours: task.EstimatedHours,
+		})
+	}
+
+	var milestones []models.Milestone
+	if err := db.Where("project_id = ?", project.ID).Order("id ASC").Find(&milestones).Error; err != nil {
+		return baseline, err
+	}
+	for _, milestone := range milestones {
+		baseline.Milestones = append(baseline.Milestones, models.BaselineMilestone{
+			MilestoneID: milestone.ID,
+			Title:       milestone.Title,
+			DueDate:     milestone.DueDate,
+		})
+	}
+
+	return baseline, nil
+}
+
+// compareBaselines reports how the current snapshot of a project differs from a baseline
+func compareBaselines(baseline, current *models.ProjectBaseline) baselineVariance {
+	variance := baselineVariance{
+		BaselineID:        baseline.ID,
+		BaselineName:      baseline.Name,
+		TakenAt:           baseline.CreatedAt,
+		EndDate:           compareDates(baseline.EndDate, current.EndDate),
+		Budget:            budgetVariance{Baseline: baseline.Budget, Current: current.Budget, Change: current.Budget - baseline.Budget},
+		Tasks:             []taskVariance{},
+		AddedTasks:        []taskVariance{},
+		RemovedTasks:      []taskVariance{},
+		Milestones:        []milestoneVariance{},
+		AddedMilestones:   []milestoneVariance{},
+		RemovedMilestones: []milestoneVariance{},
+	}
+	if baseline.Budget != 0 {
+		percent := variance.Budget.Change / baseline.Budget * 100
+		variance.Budget.ChangePercent = &percent
+	}
+	variance.Scope.BaselineTasks, variance.Scope.BaselineStoryPoints, variance.Scope.BaselineEstimatedHours = baselineScope(baseline.Tasks)
+	variance.Scope.CurrentTasks, variance.Scope.CurrentStoryPoints, variance.Scope.CurrentEstimatedHours = baselineScope(current.Tasks)
+
+	planned := make(map[uint]models.BaselineTask, len(baseline.Tasks))
+	for _, task := range baseline.Tasks {
+		planned[task.TaskID] = task
+	}
+	for _, task := range current.Tasks {
+		before, ok := planned[task.TaskID]
+		if !ok {
+			variance.AddedTasks = append(variance.AddedTasks, taskVariance{
+				TaskID: task.TaskID, Title: task.Title, Status: task.Status,
+				dateVariance: dateVariance{Current: task.DueDate},
+			})
+			continue
+		}
+		delete(planned, task.TaskID)
+
+		dates := compareDates(before.DueDate, task.DueDate)
+		if dates.VarianceDays != nil && *dates.VarianceDays > 0 {
+			variance.SlippedTasks++
+			variance.MaxSlipDays = max(variance.MaxSlipDays, *dates.VarianceDays)
+		}
+		variance.Tasks = append(variance.Tasks, taskVariance{
+			TaskID: task.TaskID, Title: task.Title, Status: task.Status, dateVariance: dates,
+		})
+	}
+	for _, task := range baseline.Tasks {
+		if _, removed := planned[task.TaskID]; removed {
+			variance.RemovedTasks = append(variance.RemovedTasks, taskVariance{
+				TaskID: task.TaskID, Title: task.Title, Status: task.Status,
+				dateVariance: dateVariance{Baseline: task.DueDate},
+			})
+		}
+	}
+
+	plannedMilestones := make(map[uint]models.BaselineMilestone, len(baseline.Milestones))
+	for _, milestone := range baseline.Milestones {
+		plannedMilestones[milestone.MilestoneID] = milestone
+	}
+	for _, milestone := range current.Milestones {
+		due := milestone.DueDate
+		before, ok := plannedMilestones[milestone.MilestoneID]
+		if !ok {
+			variance.AddedMilestones = append(variance.AddedMilestones, milestoneVariance{
+				MilestoneID: milestone.MilestoneID, Title: milestone.Title,
+				dateVariance: dateVariance{Current: &due},
+			})
+			continue
+		}
+		delete(plannedMilestones, milestone.MilestoneID)
+
+		plannedDue := before.DueDate
+		dates := compareDates(&plannedDue, &due)
+		if *dates.VarianceDays > 0 {
+			variance.SlippedMilestones++
+			variance.MaxSlipDays = max(variance.MaxSlipDays, *dates.VarianceDays)
+		}
+		variance.Milestones = append(variance.Milestones, milestoneVariance{
+			MilestoneID: milestone.MilestoneID, Title: milestone.Title, dateVariance: dates,
+		})
+	}
+	for _, milestone := range baseline.Milestones {
+		if _, removed := plannedMilestones[milestone.MilestoneID]; removed {
+			due := milestone.DueDate
+			variance.RemovedMilestones = append(variance.RemovedMilestones, milestoneVariance{
+				MilestoneID: milestone.MilestoneID, Title: milestone.Title,
+				dateVariance: dateVariance{Baseline: &due},
+			})
+		}
+	}
+
+	return variance
+}
+
+// compareDates returns the days between a baseline date and the current one
+func compareDates(baseline, current *time.Time) dateVariance {
+	variance := dateVariance{Baseline: baseline, Current: current}
+	if baseline != nil && current != nil {
+		days := int(math.Round(dayOf(*current).Sub(dayOf(*baseline)).Hours() / 24))
+		variance.VarianceDays = &days
+	}
+	return variance
+}
+
+// baselineScope totals the tasks, story points and estimated hours of a snapshot
+func baselineScope(tasks []models.BaselineTask) (count int, points, hours float64) {
+	for _, task := range tasks {
+		count++
+		if task.StoryPoints != nil {
+			points += *task.StoryPoints
+		}
+		if task.EstimatedHours != nil {
+			hours += *task.EstimatedHours
+		}
+	}
+	return count, points, hours
+}
@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupBaselineDB sets up an isolated in-memory SQLite database with a planned project
+func setupBaselineDB(t *testing.T) {
+	newTestDB(t)
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	design, build := start.AddDate(0, 0, 14), start.AddDate(0, 0, 30)
+	three, five := 3.0, 5.0
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website", Budget: 10000, StartDate: start, EndDate: &end})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Design", Status: models.TaskStatusTodo, DueDate: &design, StoryPoints: &three})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Build", Status: models.TaskStatusTodo, DueDate: &build, StoryPoints: &five})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Spike", Status: models.TaskStatusTodo})
+	database.DB.Create(&models.Milestone{ProjectID: 1, Title: "Beta", DueDate: start.AddDate(0, 0, 45)})
+}
+
+func setupBaselineApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Get("/projects/:id/baselines", handlers.GetProjectBaselines)
+	app.Post("/projects/:id/baselines", handlers.CreateProjectBaseline)
+	app.Get("/baselines/:id/variance", handlers.GetBaselineVariance)
+	return app
+}
+
+func TestBaselineVariance(t *testing.T) {
+	setupBaselineDB(t)
+	app := setupBaselineApp()
+
+	status, _ := doRequest(t, app, "POST", "/projects/1/baselines", `{"description":"No name"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, body := doRequest(t, app, "POST", "/projects/1/baselines", `{"name":"Kickoff"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	var baseline models.ProjectBaseline
+	assert.NoError(t, json.Unmarshal(body, &baseline))
+	assert.Len(t, baseline.Tasks, 3)
+	assert.Len(t, baseline.Milestones, 1)
+	status, _ = doRequest(t, app, "POST", "/projects/1/baselines", `{"name":"Kickoff"}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	// The plan slips after the baseline was agreed
+	later := time.Date(2024, time.January, 22, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)
+	two := 2.0
+	database.DB.Model(&models.Task{}).Where("id = ?", 1).Update("due_date", later)
+	database.DB.Delete(&models.Task{}, 3)
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Accessibility review", Status: models.TaskStatusTodo, StoryPoints: &two})
+	database.DB.Model(&models.Project{}).Where("id = ?", 1).Updates(map[string]interface{}{"budget": 12500, "end_date": end})
+	database.DB.Model(&models.Milestone{}).Where("id = ?", 1).Update("due_date", time.Date(2024, time.February, 12, 0, 0, 0, 0, time.UTC))
+
+	status, body = doRequest(t, app, "GET", "/baselines/1/variance", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var variance struct {
+		BaselineName string `json:"baseline_name"`
+		EndDate      struct {
+			VarianceDays *int `json:"variance_days"`
+		} `json:"end_date"`
+		Budget struct {
+			Change        float64  `json:"change"`
+			ChangePercent *float64 `json:"change_percent"`
+		} `json:"budget"`
+		Scope struct {
+			BaselineStoryPoints float64 `json:"baseline_story_points"`
+			CurrentStoryPoints  float64 `json:"current_story_points"`
+		} `json:"scope"`
+		SlippedTasks      int `json:"slipped_tasks"`
+		SlippedMilestones int `json:"slipped_milestones"`
+		MaxSlipDays       int `json:"max_slip_days"`
+		Tasks             []struct {
+			Title        string `json:"title"`
+			VarianceDays *int   `json:"variance_days"`
+		} `json:"tasks"`
+		AddedTasks []struct {
+			Title string `json:"title"`
+		} `json:"added_tasks"`
+		RemovedTasks []struct {
+			Title string `json:"title"`
+		} `json:"removed_tasks"`
+		Milestones []struct {
+			VarianceDays *int `json:"variance_days"`
+		} `json:"milestones"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &variance))
+	assert.Equal(t, "Kickoff", variance.BaselineName)
+	if assert.NotNil(t, variance.EndDate.VarianceDays) {
+		assert.Equal(t, 14, *variance.EndDate.VarianceDays)
+	}
+	assert.Equal(t, 2500.0, variance.Budget.Change)
+	if assert.NotNil(t, variance.Budget.ChangePercent) {
+		assert.Equal(t, 25.0, *variance.Budget.ChangePercent)
+	}
+	assert.Equal(t, 8.0, variance.Scope.BaselineStoryPoints)
+	assert.Equal(t, 10.0, variance.Scope.CurrentStoryPoints)
+	assert.Equal(t, 1, variance.SlippedTasks)
+	assert.Equal(t, 0, variance.SlippedMilestones)
+	assert.Equal(t, 7, variance.MaxSlipDays)
+	if assert.Len(t, variance.Tasks, 2) {
+		assert.Equal(t, 7, *variance.Tasks[0].VarianceDays)
+		assert.Equal(t, 0, *variance.Tasks[1].VarianceDays)
+	}
+	if assert.Len(t, variance.AddedTasks, 1) {
+		assert.Equal(t, "Accessibility review", variance.AddedTasks[0].Title)
+	}
+	if assert.Len(t, variance.RemovedTasks, 1) {
+		assert.Equal(t, "Spike", variance.RemovedTasks[0].Title)
+	}
+	if assert.Len(t, variance.Milestones, 1) {
+		assert.Equal(t, -3, *variance.Milestones[0].VarianceDays)
+	}
+}
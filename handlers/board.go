@@ -0,0 +1,791 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/rank"
+	"github.com/Masozee/kontena/api/workflow"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxRankLength is the key length after which a column is rebalanced instead of
+// generating ever longer keys
+const maxRankLength = 64
+
+var (
+	errWIPLimitReached = errors.New("Column has reached its work-in-progress limit")
+	errCardNotInColumn = errors.New("Neighbouring card not found in the target column")
+)
+
+// wipLimitError reports the board column a task could not enter because it is full
+type wipLimitError struct {
+	Column models.BoardColumn
+}
+
+func (e *wipLimitError) Error() string {
+	return "Column " + e.Column.Name + " has reached its work-in-progress limit"
+}
+
+func (e *wipLimitError) Unwrap() error {
+	return errWIPLimitReached
+}
+
+// moveCardRequest describes where a card should be placed on a board
+type moveCardRequest struct {
+	TaskID       uint  `json:"task_id"`
+	ColumnID     uint  `json:"column_id"`
+	AfterTaskID  *uint `json:"after_task_id"`  // card directly above the new position
+	BeforeTaskID *uint `json:"before_task_id"` // card directly below the new position
+}
+
+// GetBoards retrieves all boards for a project
+// @Summary Get all boards for a project
+// @Description Get all kanban boards of a project with their columns
+// @Tags boards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.Board
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/boards [get]
+func GetBoards(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	projectID, err := c.ParamsInt("project_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", projectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	var boards []models.Board
+	result = database.DB.Where("project_id = ?", projectID).
+		Preload("Columns", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Find(&boards)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve boards: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(boards)
+}
+
+// GetBoard retrieves a board with the cards of every column
+// @Summary Get a board by ID
+// @Description Get a kanban board with its columns and the tasks in each column, ordered by rank
+// @Tags boards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Board ID"
+// @Success 200 {object} models.Board
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /boards/{id} [get]
+func GetBoard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid board ID format",
+		})
+	}
+
+	board, err := findBoard(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Board not found",
+		})
+	}
+
+	if err := loadBoardCards(&board); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve cards: " + err.Error(),
+		})
+	}
+
+	return c.JSON(board)
+}
+
+// CreateBoard creates a new board for a project
+// @Summary Create a board
+// @Description Create a kanban board for a project. Each column shows the tasks in one status.
+// @Tags boards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Param board body models.Board true "Board object"
+// @Success 201 {object} models.Board
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/boards [post]
+func CreateBoard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	projectID, err := c.ParamsInt("project_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", projectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	board := new(models.Board)
+	if err := c.BodyParser(board); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	board.ID = 0
+	board.ProjectID = uint(projectID)
+
+	def, _, err := resolveWorkflow(uint(tenantID), &board.ProjectID, models.WorkflowEntityTask)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load workflow: " + err.Error(),
+		})
+	}
+
+	if msg := validateBoard(def, board); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	for i := range board.Columns {
+		board.Columns[i].ID = 0
+		board.Columns[i].Position = i
+	}
+
+	// Columns are created together with the board
+	result = database.DB.Create(&board)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create board: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(board)
+}
+
+// UpdateBoard replaces the name and columns of a board
+// @Summary Update a board
+// @Description Replace the name and columns of a kanban board
+// @Tags boards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Board ID"
+// @Param board body models.Board true "Board object"
+// @Success 200 {object} models.Board
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /boards/{id} [put]
+func UpdateBoard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid board ID format",
+		})
+	}
+
+	board, err := findBoard(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Board not found",
+		})
+	}
+
+	updatedBoard := new(models.Board)
+	if err := c.BodyParser(updatedBoard); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	updatedBoard.ProjectID = board.ProjectID
+	if updatedBoard.Name == "" {
+		updatedBoard.Name = board.Name
+	}
+
+	def, _, err := resolveWorkflow(uint(tenantID), &updatedBoard.ProjectID, models.WorkflowEntityTask)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load workflow: " + err.Error(),
+		})
+	}
+
+	if msg := validateBoard(def, updatedBoard); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("board_id = ?", board.ID).Delete(&models.BoardColumn{}).Error; err != nil {
+			return err
+		}
+
+		board.Name = updatedBoard.Name
+		board.Columns = nil
+		if err := tx.Save(&board).Error; err != nil {
+			return err
+		}
+
+		for i := range updatedBoard.Columns {
+			column := updatedBoard.Columns[i]
+			column.ID = 0
+			column.BoardID = board.ID
+			column.Position = i
+			if err := tx.Create(&column).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update board: " + err.Error(),
+		})
+	}
+
+	board, _ = findBoard(uint(tenantID), board.ID)
+	return c.JSON(board)
+}
+
+// DeleteBoard deletes a board. The tasks on it are not affected.
+// @Summary Delete a board
+// @Description Delete a kanban board by ID. The tasks on it are not affected.
+// @Tags boards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Board ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /boards/{id} [delete]
+func DeleteBoard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid board ID format",
+		})
+	}
+
+	board, err := findBoard(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Board not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("board_id = ?", board.ID).Delete(&models.BoardColumn{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&board).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete board: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Board deleted successfully",
+	})
+}
+
+// MoveCard moves a task to a position in a board column
+// @Summary Move a card
+// @Description Move a task to a column of the board, between after_task_id and before_task_id. Without neighbours the card goes to the bottom of the column. Moving into another column changes the task status, which must follow the project's workflow and respect the WIP limit of every board column showing that status.
+// @Tags boards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Board ID"
+// @Param move body moveCardRequest true "Target column and neighbouring cards"
+// @Success 200 {object} models.Task
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /boards/{id}/move [post]
+func MoveCard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid board ID format",
+		})
+	}
+
+	board, err := findBoard(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Board not found",
+		})
+	}
+
+	req := new(moveCardRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	var column *models.BoardColumn
+	for i := range board.Columns {
+		if board.Columns[i].ID == req.ColumnID {
+			column = &board.Columns[i]
+		}
+	}
+	if column == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Column not found on this board",
+		})
+	}
+
+	var task models.Task
+	result := database.DB.Where("id = ? AND project_id = ?", req.TaskID, board.ProjectID).First(&task)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found in the board's project",
+		})
+	}
+
+	// Moving to another column is a status change and must follow the project's workflow
+	moved := task
+	moved.Status = column.Status
+	if task.Status != column.Status {
+		role, err := actorRole(c, uint(tenantID))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		def, _, err := resolveWorkflow(uint(tenantID), &task.ProjectID, models.WorkflowEntityTask)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load workflow: " + err.Error(),
+			})
+		}
+		if err := def.Validate(string(task.Status), string(column.Status), role, moved); err != nil {
+			return workflowError(c, err)
+		}
+	}
+
+	// Completing the latest occurrence of a recurring task creates the next one
+	completed := false
+	if task.Status != column.Status {
+		completed, err = isTaskDone(database.DB, uint(tenantID), &moved)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load workflow: " + err.Error(),
+			})
+		}
+	}
+	statusChanged := task.Status != column.Status
+	previousStatus := task.Status
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if statusChanged {
+			if err := checkWIPLimit(tx, board.ProjectID, column.Status); err != nil {
+				return err
+			}
+		}
+
+		key, err := cardRank(tx, &task, column.Status, req)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&task).Updates(map[string]interface{}{
+			"status": column.Status,
+			"rank":   key,
+		}).Error; err != nil {
+			return err
+		}
+		task.Status = column.Status
+		task.Rank = key
+
+		if statusChanged {
+			if err := recordTaskState(tx, uint(tenantID), &task, actorID(c)); err != nil {
+				return err
+			}
+			if err := notifyTaskChange(tx, uint(tenantID), &task, previousStatus, task.AssignedToID, actorID(c)); err != nil {
+				return err
+			}
+		}
+		if completed {
+			if err := completeOccurrence(tx, &task); err != nil {
+				return err
+			}
+		}
+		return publishEvent(tx, uint(tenantID), models.EventTaskUpdated, task.ID, task)
+	})
+	switch {
+	case errors.Is(err, errWIPLimitReached):
+		return wipLimitReached(c, err)
+	case errors.Is(err, errCardNotInColumn):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to move card: " + err.Error(),
+		})
+	}
+
+	projectDataChanged(task.ProjectID)
+
+	database.DB.Preload("AssignedTo").First(&task, task.ID)
+	return c.JSON(task)
+}
+
+// checkWIPLimit returns a *wipLimitError when a task entering a status would put more tasks
+// in one of the project's board columns than its WIP limit allows. The columns showing the
+// status are locked first, so tasks entering the same column at once are counted one after the other.
+func checkWIPLimit(tx *gorm.DB, projectID uint, status models.TaskStatus) error {
+	var columns []models.BoardColumn
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "board_columns"}}).
+		Joins("JOIN boards ON boards.id = board_columns.board_id").
+		Where("boards.project_id = ? AND boards.deleted_at IS NULL", projectID).
+		Where("board_columns.status = ?", status).
+		Order("board_columns.id ASC").
+		Find(&columns).Error
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	counted := false
+	for _, column := range columns {
+		if column.WIPLimit == nil {
+			continue
+		}
+		if !counted {
+			if err := tx.Model(&models.Task{}).Where("project_id = ? AND status = ?", projectID, status).Count(&count).Error; err != nil {
+				return err
+			}
+			counted = true
+		}
+		if count >= int64(*column.WIPLimit) {
+			return &wipLimitError{Column: column}
+		}
+	}
+	return nil
+}
+
+// wipLimitReached responds to a task that could not enter a full board column
+func wipLimitReached(c *fiber.Ctx, err error) error {
+	response := fiber.Map{
+		"error": err.Error(),
+	}
+	var full *wipLimitError
+	if errors.As(err, &full) {
+		response["column_id"] = full.Column.ID
+		response["wip_limit"] = *full.Column.WIPLimit
+	}
+	return c.Status(fiber.StatusConflict).JSON(response)
+}
+
+// nextTaskRank returns a rank that places a new task of the project after all existing ones
+func nextTaskRank(db *gorm.DB, projectID uint) string {
+	var lastTask models.Task
+	db.Where("project_id = ?", projectID).Order("rank DESC").Limit(1).Find(&lastTask)
+	key, _ := rank.Between(lastTask.Rank, "")
+	return key
+}
+
+// findBoard loads a board with its columns, checking that its project belongs to the tenant
+func findBoard(tenantID, id uint) (models.Board, error) {
+	var board models.Board
+	result := database.DB.Joins("JOIN projects ON projects.id = boards.project_id").
+		Where("boards.id = ? AND projects.tenant_id = ?", id, tenantID).
+		Preload("Columns", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		First(&board)
+	return board, result.Error
+}
+
+// loadBoardCards fills every column of the board with its tasks in rank order
+func loadBoardCards(board *models.Board) error {
+	var statuses []models.TaskStatus
+	for _, column := range board.Columns {
+		statuses = append(statuses, column.Status)
+	}
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	var tasks []models.Task
+	result := database.DB.Where("project_id = ? AND status IN ?", board.ProjectID, statuses).
+		Preload("AssignedTo").
+		Order("rank ASC, id ASC").
+		Find(&tasks)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for i := range board.Columns {
+		board.Columns[i].Cards = []models.Task{}
+		for _, task := range tasks {
+			if task.Status == board.Columns[i].Status {
+				board.Columns[i].Cards = append(board.Columns[i].Cards, task)
+			}
+		}
+	}
+	return nil
+}
+
+// cardRank works out the rank of a card placed in a column. Normally only the moved
+// card gets a new rank; when the neighbouring ranks leave no room the column is rebalanced.
+func cardRank(tx *gorm.DB, task *models.Task, status models.TaskStatus, req *moveCardRequest) (string, error) {
+	inColumn := func() *gorm.DB {
+		return tx.Model(&models.Task{}).Where("project_id = ? AND status = ? AND id <> ?", task.ProjectID, status, task.ID)
+	}
+
+	var above, below *models.Task
+	if req.AfterTaskID != nil {
+		var t models.Task
+		if inColumn().Where("id = ?", *req.AfterTaskID).First(&t).Error != nil {
+			return "", errCardNotInColumn
+		}
+		above = &t
+	}
+	if req.BeforeTaskID != nil {
+		var t models.Task
+		if inColumn().Where("id = ?", *req.BeforeTaskID).First(&t).Error != nil {
+			return "", errCardNotInColumn
+		}
+		below = &t
+	}
+
+	// Fill in the missing neighbour so the new rank lands directly next to the given one
+	switch {
+	case above != nil && below == nil:
+		var t models.Task
+		if inColumn().Where("rank > ?", above.Rank).Order("rank ASC, id ASC").Limit(1).Find(&t).RowsAffected > 0 {
+			below = &t
+		}
+	case above == nil && below != nil:
+		var t models.Task
+		if inColumn().Where("rank < ?", below.Rank).Order("rank DESC, id DESC").Limit(1).Find(&t).RowsAffected > 0 {
+			above = &t
+		}
+	case above == nil && below == nil:
+		var t models.Task
+		if inColumn().Order("rank DESC, id DESC").Limit(1).Find(&t).RowsAffected > 0 {
+			above = &t
+		}
+	}
+
+	prev, next := "", ""
+	if above != nil {
+		prev = above.Rank
+	}
+	if below != nil {
+		next = below.Rank
+	}
+
+	// Cards created before ranks existed have none, which also calls for a rebalance
+	if (above == nil || prev != "") && (below == nil || next != "") {
+		if key, err := rank.Between(prev, next); err == nil && len(key) <= maxRankLength {
+			return key, nil
+		}
+	}
+
+	return rebalanceColumn(tx, task, status, above, below)
+}
+
+// rebalanceColumn gives every card in a column a fresh, evenly spaced rank with the
+// moved card placed after above, or before below when above is nil
+func rebalanceColumn(tx *gorm.DB, task *models.Task, status models.TaskStatus, above, below *models.Task) (string, error) {
+	var cards []models.Task
+	result := tx.Where("project_id = ? AND status = ? AND id <> ?", task.ProjectID, status, task.ID).
+		Order("rank ASC, id ASC").
+		Find(&cards)
+	if result.Error != nil {
+		return "", result.Error
+	}
+
+	position := len(cards)
+	for i, card := range cards {
+		if above != nil && card.ID == above.ID {
+			position = i + 1
+		}
+		if above == nil && below != nil && card.ID == below.ID {
+			position = i
+		}
+	}
+
+	ids := make([]uint, 0, len(cards)+1)
+	for _, card := range cards[:position] {
+		ids = append(ids, card.ID)
+	}
+	ids = append(ids, task.ID)
+	for _, card := range cards[position:] {
+		ids = append(ids, card.ID)
+	}
+
+	keys := rank.Spread(len(ids))
+	var key string
+	for i, id := range ids {
+		if id == task.ID {
+			key = keys[i]
+			continue
+		}
+		if err := tx.Model(&models.Task{}).Where("id = ?", id).Update("rank", keys[i]).Error; err != nil {
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// validateBoard checks a board's columns against the project's task workflow and returns a message if invalid
+func validateBoard(def workflow.Definition, board *models.Board) string {
+	if board.Name == "" {
+		return "Board name is required"
+	}
+
+	if len(board.Columns) == 0 {
+		return "Board must have at least one column"
+	}
+
+	statuses := make(map[models.TaskStatus]bool)
+	for _, column := range board.Columns {
+		if column.Name == "" {
+			return "Column name is required"
+		}
+		if !def.HasState(string(column.Status)) {
+			return "Invalid task status for column " + column.Name + ": " + string(column.Status)
+		}
+		if statuses[column.Status] {
+			return "Only one column can show status " + string(column.Status)
+		}
+		statuses[column.Status] = true
+		if column.WIPLimit != nil && *column.WIPLimit < 1 {
+			return "WIP limit for column " + column.Name + " must be at least 1"
+		}
+	}
+
+	return ""
+}
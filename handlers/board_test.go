@@ -0,0 +1,188 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupBoardDB sets up an isolated in-memory SQLite database with one project
+func setupBoardDB(t *testing.T) {
+	newTestDB(t)
+
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website"})
+}
+
+func setupBoardApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Post("/projects/:project_id/tasks", handlers.CreateTask)
+	app.Post("/projects/:project_id/boards", handlers.CreateBoard)
+	app.Get("/boards/:id", handlers.GetBoard)
+	app.Post("/boards/:id/move", handlers.MoveCard)
+	return app
+}
+
+func doRequest(t *testing.T, app *fiber.App, method, url, body string) (int, []byte) {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "1")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+func boardCardIDs(t *testing.T, app *fiber.App, column int) []uint {
+	status, body := doRequest(t, app, "GET", "/boards/1", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var board models.Board
+	assert.NoError(t, json.Unmarshal(body, &board))
+
+	var ids []uint
+	for _, card := range board.Columns[column].Cards {
+		ids = append(ids, card.ID)
+	}
+	return ids
+}
+
+func TestMoveCardOrdersWithinColumn(t *testing.T) {
+	setupBoardDB(t)
+	app := setupBoardApp()
+
+	for _, title := range []string{"A", "B", "C"} {
+		status, _ := doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"`+title+`"}`)
+		assert.Equal(t, fiber.StatusCreated, status)
+	}
+	status, _ := doRequest(t, app, "POST", "/projects/1/boards",
+		`{"name":"Sprint","columns":[{"name":"To do","status":"todo"},{"name":"Doing","status":"in_progress","wip_limit":1}]}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	assert.Equal(t, []uint{1, 2, 3}, boardCardIDs(t, app, 0))
+
+	// Move C between A and B; only C's rank changes
+	var before models.Task
+	database.DB.First(&before, 1)
+	status, _ = doRequest(t, app, "POST", "/boards/1/move", `{"task_id":3,"column_id":1,"after_task_id":1}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []uint{1, 3, 2}, boardCardIDs(t, app, 0))
+
+	var after models.Task
+	database.DB.First(&after, 1)
+	assert.Equal(t, before.Rank, after.Rank)
+
+	// Move B to the top
+	status, _ = doRequest(t, app, "POST", "/boards/1/move", `{"task_id":2,"column_id":1,"before_task_id":1}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []uint{2, 1, 3}, boardCardIDs(t, app, 0))
+}
+
+func TestMoveCardEnforcesWIPLimit(t *testing.T) {
+	setupBoardDB(t)
+	app := setupBoardApp()
+
+	doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"A"}`)
+	doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"B"}`)
+	doRequest(t, app, "POST", "/projects/1/boards",
+		`{"name":"Sprint","columns":[{"name":"To do","status":"todo"},{"name":"Doing","status":"in_progress","wip_limit":1}]}`)
+
+	status, body := doRequest(t, app, "POST", "/boards/1/move", `{"task_id":1,"column_id":2}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var task models.Task
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, models.TaskStatusInProgress, task.Status)
+
+	status, _ = doRequest(t, app, "POST", "/boards/1/move", `{"task_id":2,"column_id":2}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.Equal(t, []uint{2}, boardCardIDs(t, app, 0))
+}
+
+func TestWIPLimitAppliesWhereverATaskEntersAColumn(t *testing.T) {
+	setupBoardDB(t)
+	app := setupBoardApp()
+	app.Patch("/tasks/:id", handlers.UpdateTask)
+
+	doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"A"}`)
+	doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"B"}`)
+	doRequest(t, app, "POST", "/projects/1/boards",
+		`{"name":"Sprint","columns":[{"name":"To do","status":"todo","wip_limit":2},{"name":"Doing","status":"in_progress"}]}`)
+	doRequest(t, app, "POST", "/projects/1/boards",
+		`{"name":"Focus","columns":[{"name":"Now","status":"in_progress","wip_limit":1}]}`)
+
+	// New tasks enter the initial column
+	status, body := doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"C"}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+	var conflict struct {
+		ColumnID uint `json:"column_id"`
+		WIPLimit int  `json:"wip_limit"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &conflict))
+	assert.Equal(t, uint(1), conflict.ColumnID)
+	assert.Equal(t, 2, conflict.WIPLimit)
+
+	// Status changes through the task count against every board showing the status
+	status, body = doRequest(t, app, "PATCH", "/tasks/1", `{"status":"in_progress"}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	status, body = doRequest(t, app, "PATCH", "/tasks/2", `{"status":"in_progress"}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+	assert.NoError(t, json.Unmarshal(body, &conflict))
+	assert.Equal(t, uint(3), conflict.ColumnID)
+	status, _ = doRequest(t, app, "POST", "/boards/1/move", `{"task_id":2,"column_id":2}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	var task models.Task
+	database.DB.First(&task, 2)
+	assert.Equal(t, models.TaskStatusTodo, task.Status)
+
+	// Edits that keep the status are not held back
+	status, _ = doRequest(t, app, "PATCH", "/tasks/1", `{"title":"A2"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+}
+
+func TestMoveCardRebalancesUnrankedTasks(t *testing.T) {
+	setupBoardDB(t)
+	app := setupBoardApp()
+
+	// Tasks created before ranks existed have no rank
+	for _, title := range []string{"A", "B", "C"} {
+		database.DB.Create(&models.Task{ProjectID: 1, Title: title, Status: models.TaskStatusTodo})
+	}
+	doRequest(t, app, "POST", "/projects/1/boards", `{"name":"Sprint","columns":[{"name":"To do","status":"todo"}]}`)
+
+	status, _ := doRequest(t, app, "POST", "/boards/1/move", `{"task_id":1,"column_id":1,"after_task_id":3}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Equal(t, []uint{2, 3, 1}, boardCardIDs(t, app, 0))
+}
+
+func TestWorkflowLoadFailureIsAServerError(t *testing.T) {
+	setupBoardDB(t)
+	app := setupBoardApp()
+
+	doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"A"}`)
+	doRequest(t, app, "POST", "/projects/1/boards",
+		`{"name":"Sprint","columns":[{"name":"To do","status":"todo"},{"name":"Doing","status":"in_progress"}]}`)
+
+	// A failing lookup does not fall back to the built-in workflow
+	assert.NoError(t, database.DB.Migrator().DropTable(&models.Workflow{}))
+	status, _ := doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"B"}`)
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+	status, _ = doRequest(t, app, "POST", "/boards/1/move", `{"task_id":1,"column_id":2}`)
+	assert.Equal(t, fiber.StatusInternalServerError, status)
+
+	var task models.Task
+	database.DB.First(&task, 1)
+	assert.Equal(t, models.TaskStatusTodo, task.Status)
+}
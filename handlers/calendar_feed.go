@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/ical"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/workflow"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// calendarFeedPath is where main.go serves feeds by token
+	calendarFeedPath = "/api/v1/calendar/"
+	// calendarFeedHistoryDays is how far back feeds include past dates
+	calendarFeedHistoryDays = 90
+	// calendarFeedTokenBytes is the randomness in a feed token, hex-encoded to twice as many characters
+	calendarFeedTokenBytes = 32
+)
+
+// calendarFeedResponse is a calendar feed with the URL to subscribe to
+type calendarFeedResponse struct {
+	models.CalendarFeed
+	URL string `json:"url"`
+}
+
+// GetPersonCalendarFeed returns a person's calendar feed
+// @Summary Get a person's calendar feed
+// @Description Get the iCalendar feed URL of a person's task due dates, project milestones, scheduled maintenance and expected asset returns
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Person ID"
+// @Success 200 {object} calendarFeedResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /people/{id}/calendar-feed [get]
+func GetPersonCalendarFeed(c *fiber.Ctx) error {
+	return getCalendarFeed(c, models.CalendarFeedScopePerson)
+}
+
+// CreatePersonCalendarFeed creates a person's calendar feed, or regenerates its token
+// @Summary Create or regenerate a person's calendar feed
+// @Description Create the iCalendar feed of a person. If the person already has one its token is regenerated, which revokes the old URL.
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Person ID"
+// @Success 200 {object} calendarFeedResponse
+// @Success 201 {object} calendarFeedResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /people/{id}/calendar-feed [post]
+func CreatePersonCalendarFeed(c *fiber.Ctx) error {
+	return issueCalendarFeed(c, models.CalendarFeedScopePerson)
+}
+
+// DeletePersonCalendarFeed revokes a person's calendar feed
+// @Summary Revoke a person's calendar feed
+// @Description Delete a person's calendar feed so its URL stops working
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Person ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /people/{id}/calendar-feed [delete]
+func DeletePersonCalendarFeed(c *fiber.Ctx) error {
+	return revokeCalendarFeed(c, models.CalendarFeedScopePerson)
+}
+
+// GetProjectCalendarFeed returns a project's calendar feed
+// @Summary Get a project's calendar feed
+// @Description Get the iCalendar feed URL of a project's task and milestone due dates
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Success 200 {object} calendarFeedResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /projects/{id}/calendar-feed [get]
+func GetProjectCalendarFeed(c *fiber.Ctx) error {
+	return getCalendarFeed(c, models.CalendarFeedScopeProject)
+}
+
+// CreateProjectCalendarFeed creates a project's calendar feed, or regenerates its token
+// @Summary Create or regenerate a project's calendar feed
+// @Description Create the iCalendar feed of a project. If the project already has one its token is regenerated, which revokes the old URL.
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Success 200 {object} calendarFeedResponse
+// @Success 201 {object} calendarFeedResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/calendar-feed [post]
+func CreateProjectCalendarFeed(c *fiber.Ctx) error {
+	return issueCalendarFeed(c, models.CalendarFeedScopeProject)
+}
+
+// DeleteProjectCalendarFeed revokes a project's calendar feed
+// @Summary Revoke a project's calendar feed
+// @Description Delete a project's calendar feed so its URL stops working
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/calendar-feed [delete]
+func DeleteProjectCalendarFeed(c *fiber.Ctx) error {
+	return revokeCalendarFeed(c, models.CalendarFeedScopeProject)
+}
+
+// ServeCalendarFeed serves a calendar feed by its token
+// @Summary Get calendar feed events
+// @Description Get the events of a calendar feed as iCalendar. The token in the URL is the only credential, so no tenant header is needed. Dates from the last 90 days onwards are included.
+// @Tags calendar
+// @Produce text/calendar
+// @Param token path string true "Feed token, optionally followed by .ics"
+// @Success 200 {string} string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /calendar/{token} [get]
+func ServeCalendarFeed(c *fiber.Ctx) error {
+	token := strings.TrimSuffix(c.Params("token"), ".ics")
+
+	var feed models.CalendarFeed
+	result := database.DB.Where("token = ?", token).First(&feed)
+	if result.Error != nil || token == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Calendar feed not found",
+		})
+	}
+
+	since := dayOf(time.Now()).AddDate(0, 0, -calendarFeedHistoryDays)
+	calendar, err := buildCalendarFeed(database.DB, &feed, since)
+	if err == gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Calendar feed not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build calendar feed: " + err.Error(),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return c.SendString(calendar.String())
+}
+
+// getCalendarFeed returns the feed of the person or project in the path
+func getCalendarFeed(c *fiber.Ctx, scope models.CalendarFeedScope) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	query, msg := calendarFeedQuery(uint(tenantID), scope, c.Params("id"))
+	if msg != "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	var feed models.CalendarFeed
+	if err := query.First(&feed).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Calendar feed not found",
+		})
+	}
+
+	return c.JSON(newCalendarFeedResponse(c, feed))
+}
+
+// issueCalendarFeed creates the feed of the person or project in the path, or gives it a new token
+func issueCalendarFeed(c *fiber.Ctx, scope models.CalendarFeedScope) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	query, msg := calendarFeedQuery(uint(tenantID), scope, c.Params("id"))
+	if msg != "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	token, err := newCalendarFeedToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate calendar feed token",
+		})
+	}
+
+	status := fiber.StatusOK
+	var feed models.CalendarFeed
+	if query.First(&feed).Error != nil {
+		status = fiber.StatusCreated
+		id, _ := strconv.ParseUint(c.Params("id"), 10, 64)
+		target := uint(id)
+		feed = models.CalendarFeed{TenantID: uint(tenantID), Scope: scope}
+		if scope == models.CalendarFeedScopePerson {
+			feed.PersonID = &target
+		} else {
+			feed.ProjectID = &target
+		}
+	}
+	feed.Token = token
+
+	result := database.DB.Save(&feed)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save calendar feed: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(status).JSON(newCalendarFeedResponse(c, feed))
+}
+
+// revokeCalendarFeed deletes the feed of the person or project in the path
+func revokeCalendarFeed(c *fiber.Ctx, scope models.CalendarFeedScope) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	query, msg := calendarFeedQuery(uint(tenantID), scope, c.Params("id"))
+	if msg != "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	var feed models.CalendarFeed
+	if err := query.First(&feed).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Calendar feed not found",
+		})
+	}
+
+	result := database.DB.Delete(&feed)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete calendar feed: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Calendar feed revoked successfully",
+	})
+}
+
+// calendarFeedQuery checks that the person or project belongs to the tenant and returns a query
+// for its feed, or a message saying it was not found
+func calendarFeedQuery(tenantID uint, scope models.CalendarFeedScope, id string) (*gorm.DB, string) {
+	query := database.DB.Where("tenant_id = ? AND scope = ?", tenantID, scope)
+	if scope == models.CalendarFeedScopePerson {
+		var person models.Person
+		if err := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&person).Error; err != nil {
+			return nil, "Person not found"
+		}
+		return query.Where("person_id = ?", person.ID), ""
+	}
+
+	var project models.Project
+	if err := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&project).Error; err != nil {
+		return nil, "Project not found"
+	}
+	return query.Where("project_id = ?", project.ID), ""
+}
+
+// newCalendarFeedResponse adds the subscription URL to a feed
+func newCalendarFeedResponse(c *fiber.Ctx, feed models.CalendarFeed) calendarFeedResponse {
+	return calendarFeedResponse{
+		CalendarFeed: feed,
+		URL:          c.BaseURL() + calendarFeedPath + feed.Token + ".ics",
+	}
+}
+
+// newCalendarFeedToken returns an unguessable random token
+func newCalendarFeedToken() (string, error) {
+	b := make([]byte, calendarFeedTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// buildCalendarFeed collects the dates of a feed from the given day onwards. A person's feed has
+// their tasks, the milestones of their projects, the maintenance they perform and the assets they
+// have to return; a project's feed has its tasks and milestones.
+func buildCalendarFeed(db *gorm.DB, feed *models.CalendarFeed, since time.Time) (*ical.Calendar, error) {
+	calendar := &ical.Calendar{}
+
+	tasks := db.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("projects.tenant_id = ? AND projects.deleted_at IS NULL AND tasks.due_date >= ?", feed.TenantID, since)
+	milestones := db.Joins("JOIN projects ON projects.id = milestones.project_id").
+		Where("projects.tenant_id = ? AND projects.deleted_at IS NULL AND milestones.due_date >= ?", feed.TenantID, since)
+
+	var person models.Person
+	if feed.Scope == models.CalendarFeedScopePerson {
+		if err := db.Where("id = ? AND tenant_id = ?", feed.PersonID, feed.TenantID).First(&person).Error; err != nil {
+			return nil, err
+		}
+		calendar.Name = person.Name
+		tasks = tasks.Where("tasks.assigned_to_id = ?", person.ID)
+		milestones = milestones.Where("milestones.project_id IN (?)",
+			db.Table("project_people").Select("project_id").Where("person_id = ?", person.ID))
+	} else {
+		var project models.Project
+		if err := db.Where("id = ? AND tenant_id = ?", feed.ProjectID, feed.TenantID).First(&project).Error; err != nil {
+			return nil, err
+		}
+		calendar.Name = project.Name
+		tasks = tasks.Where("tasks.project_id = ?", project.ID)
+		milestones = milestones.Where("milestones.project_id = ?", project.ID)
+	}
+
+	var taskList []models.Task
+	if err := tasks.Preload("Project").Order("tasks.due_date ASC, tasks.id ASC").Find(&taskList).Error; err != nil {
+		return nil, err
+	}
+	workflows := make(map[uint]workflow.Definition)
+	for _, task := range taskList {
+		def, ok := workflows[task.ProjectID]
+		if !ok {
+			var err error
+			def, _, err = resolveWorkflowIn(db, feed.TenantID, &task.ProjectID, models.WorkflowEntityTask)
+			if err != nil {
+				return nil, err
+			}
+			workflows[task.ProjectID] = def
+		}
+		done := task.Status == models.TaskStatusCompleted || def.IsFinal(string(task.Status))
+		calendar.Events = append(calendar.Events, ical.Event{
+			UID:         fmt.Sprintf("task-%d@kontena", task.ID),
+			Summary:     doneSummary(task.Title, done),
+			Description: feedDescription(task.Project, string(task.Status), task.Description),
+			Start:       *task.DueDate,
+			AllDay:      true,
+			Updated:     task.UpdatedAt,
+			Categories:  []string{"Task"},
+		})
+	}
+
+	var milestoneList []models.Milestone
+	if err := milestones.Preload("Project").Order("milestones.due_date ASC, milestones.id ASC").Find(&milestoneList).Error; err != nil {
+		return nil, err
+	}
+	for _, milestone := range milestoneList {
+		calendar.Events = append(calendar.Events, ical.Event{
+			UID:         fmt.Sprintf("milestone-%d@kontena", milestone.ID),
+			Summary:     doneSummary(milestone.Title, milestone.Status == models.MilestoneStatusCompleted),
+			Description: feedDescription(milestone.Project, string(milestone.Status), milestone.Description),
+			Start:       milestone.DueDate,
+			AllDay:      true,
+			Updated:     milestone.UpdatedAt,
+			Categories:  []string{"Milestone"},
+		})
+	}
+
+	if feed.Scope != models.CalendarFeedScopePerson {
+		return calendar, nil
+	}
+
+	var maintenance []models.MaintenanceRecord
+	result := db.Where("tenant_id = ? AND performed_by_id = ? AND scheduled_date >= ?", feed.TenantID, person.ID, since).
+		Preload("Asset").
+		Order("scheduled_date ASC, id ASC").
+		Find(&maintenance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, record := range maintenance {
+		summary := "Maintenance"
+		if record.Asset != nil {
+			summary += ": " + record.Asset.Name
+		}
+		calendar.Events = append(calendar.Events, ical.Event{
+			UID:         fmt.Sprintf("maintenance-%d@kontena", record.ID),
+			Summary:     doneSummary(summary, record.Status == models.MaintenanceStatusCompleted),
+			Description: "Status: " + string(record.Status) + "\n" + record.Description,
+			Start:       record.ScheduledDate,
+			AllDay:      true,
+			Updated:     record.UpdatedAt,
+			Categories:  []string{"Maintenance"},
+		})
+	}
+
+	// Only assets still out are due back
+	var assignments []models.AssetAssignment
+	result = db.Where("tenant_id = ? AND assigned_to_id = ? AND return_date IS NULL AND expected_return >= ?", feed.TenantID, person.ID, since).
+		Preload("Asset").
+		Order("expected_return ASC, id ASC").
+		Find(&assignments)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, assignment := range assignments {
+		summary := "Return asset"
+		if assignment.Asset != nil {
+			summary = "Return " + assignment.Asset.Name
+		}
+		calendar.Events = append(calendar.Events, ical.Event{
+			UID:         fmt.Sprintf("asset-assignment-%d@kontena", assignment.ID),
+			Summary:     summary,
+			Description: assignment.Notes,
+			Start:       *assignment.ExpectedReturn,
+			AllDay:      true,
+			Updated:     assignment.UpdatedAt,
+			Categories:  []string{"Asset return"},
+		})
+	}
+
+	return calendar, nil
+}
+
+// doneSummary marks the summary of a finished item
+func doneSummary(summary string, done bool) string {
+	if done {
+		return "✓ " + summary
+	}
+	return summary
+}
+
+// feedDescription describes a dated project item by its project and status
+func feedDescription(project *models.Project, status, description string) string {
+	var lines []string
+	if project != nil {
+		lines = append(lines, "Project: "+project.Name)
+	}
+	lines = append(lines, "Status: "+status)
+	if description != "" {
+		lines = append(lines, "", description)
+	}
+	return strings.Join(lines, "\n")
+}
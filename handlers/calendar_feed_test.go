@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupCalendarFeedDB sets up an isolated in-memory SQLite database with dated work for one person
+func setupCalendarFeedDB(t *testing.T) {
+	newTestDB(t)
+
+	now := time.Now()
+	soon := now.AddDate(0, 0, 3)
+	longAgo := now.AddDate(-1, 0, 0)
+	alice := uint(1)
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "developer"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website", StartDate: now})
+	database.DB.Exec("INSERT INTO project_people (project_id, person_id) VALUES (1, 1)")
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Design", Status: models.TaskStatusCompleted, AssignedToID: &alice, DueDate: &soon})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Build", Status: models.TaskStatusTodo, DueDate: &soon})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Old", Status: models.TaskStatusTodo, AssignedToID: &alice, DueDate: &longAgo})
+	database.DB.Create(&models.Milestone{ProjectID: 1, Title: "Launch", DueDate: soon})
+	database.DB.Create(&models.AssetCategory{TenantID: 1, Name: "Laptops"})
+	database.DB.Create(&models.Asset{TenantID: 1, Name: "ThinkPad", CategoryID: 1})
+	database.DB.Create(&models.MaintenanceRecord{TenantID: 1, AssetID: 1, MaintenanceType: models.MaintenanceTypePreventive, ScheduledDate: soon, PerformedByID: &alice, Description: "Battery check"})
+	database.DB.Create(&models.AssetAssignment{TenantID: 1, AssetID: 1, AssignedToID: 1, AssignedByID: 1, AssignmentDate: now, ExpectedReturn: &soon})
+}
+
+func setupCalendarFeedApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/api/v1/calendar/:token", handlers.ServeCalendarFeed)
+	app.Use(middleware.TenantMiddleware())
+	app.Get("/people/:id/calendar-feed", handlers.GetPersonCalendarFeed)
+	app.Post("/people/:id/calendar-feed", handlers.CreatePersonCalendarFeed)
+	app.Post("/projects/:id/calendar-feed", handlers.CreateProjectCalendarFeed)
+	app.Delete("/projects/:id/calendar-feed", handlers.DeleteProjectCalendarFeed)
+	return app
+}
+
+// getFeed fetches a feed URL without a tenant header
+func getFeed(t *testing.T, app *fiber.App, url string) (int, string) {
+	resp, err := app.Test(httptest.NewRequest("GET", strings.TrimPrefix(url, "http://example.com"), nil))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestPersonCalendarFeed(t *testing.T) {
+	setupCalendarFeedDB(t)
+	app := setupCalendarFeedApp()
+
+	status, _ := doRequest(t, app, "GET", "/people/1/calendar-feed", "")
+	assert.Equal(t, fiber.StatusNotFound, status)
+	status, _ = doRequest(t, app, "POST", "/people/9/calendar-feed", "")
+	assert.Equal(t, fiber.StatusNotFound, status)
+
+	status, body := doRequest(t, app, "POST", "/people/1/calendar-feed", "")
+	assert.Equal(t, fiber.StatusCreated, status)
+	var feed struct {
+		Token string `json:"token"`
+		URL   string `json:"url"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &feed))
+	assert.Len(t, feed.Token, 64)
+	assert.True(t, strings.HasSuffix(feed.URL, "/api/v1/calendar/"+feed.Token+".ics"))
+
+	status, ics := getFeed(t, app, feed.URL)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Contains(t, ics, "X-WR-CALNAME:Alice\r\n")
+	assert.Contains(t, ics, "UID:task-1@kontena\r\n")
+	assert.Contains(t, ics, "SUMMARY:✓ Design\r\n")
+	assert.Contains(t, ics, "UID:milestone-1@kontena\r\n")
+	assert.Contains(t, ics, "SUMMARY:Maintenance: ThinkPad\r\n")
+	assert.Contains(t, ics, "SUMMARY:Return ThinkPad\r\n")
+	// Someone else's task and long past dates are left out
+	assert.NotContains(t, ics, "task-2@kontena")
+	assert.NotContains(t, ics, "task-3@kontena")
+
+	// Regenerating the token revokes the old URL
+	status, body = doRequest(t, app, "POST", "/people/1/calendar-feed", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	var regenerated struct {
+		URL string `json:"url"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &regenerated))
+	status, _ = getFeed(t, app, feed.URL)
+	assert.Equal(t, fiber.StatusNotFound, status)
+	status, _ = getFeed(t, app, regenerated.URL)
+	assert.Equal(t, fiber.StatusOK, status)
+}
+
+func TestProjectCalendarFeed(t *testing.T) {
+	setupCalendarFeedDB(t)
+	app := setupCalendarFeedApp()
+
+	status, body := doRequest(t, app, "POST", "/projects/1/calendar-feed", "")
+	assert.Equal(t, fiber.StatusCreated, status)
+	var feed struct {
+		URL string `json:"url"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &feed))
+
+	status, ics := getFeed(t, app, feed.URL)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.Contains(t, ics, "UID:task-2@kontena\r\n")
+	assert.Contains(t, ics, "UID:milestone-1@kontena\r\n")
+	assert.NotContains(t, ics, "maintenance-")
+
+	status, _ = doRequest(t, app, "DELETE", "/projects/1/calendar-feed", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = getFeed(t, app, feed.URL)
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
@@ -0,0 +1,602 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/like"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// commentUpdateRequest is the payload accepted when editing a comment
+type commentUpdateRequest struct {
+	Body string `json:"body"`
+}
+
+// GetTaskComments retrieves all comments on a task
+// @Summary Get comments for a task
+// @Description Get all comments on a task, oldest first. Replies reference their parent through parent_id.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.Comment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/comments [get]
+func GetTaskComments(c *fiber.Ctx) error {
+	return getComments(c, models.CommentEntityTask)
+}
+
+// CreateTaskComment adds a comment to a task
+// @Summary Comment on a task
+// @Description Add a comment or reply to a task. @mentions notify the mentioned people.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param comment body models.Comment true "Comment object"
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/comments [post]
+func CreateTaskComment(c *fiber.Ctx) error {
+	return createComment(c, models.CommentEntityTask)
+}
+
+// GetIssueComments retrieves all comments on an issue
+// @Summary Get comments for an issue
+// @Description Get all comments on an issue, oldest first. Replies reference their parent through parent_id.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Issue ID"
+// @Success 200 {array} models.Comment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/comments [get]
+func GetIssueComments(c *fiber.Ctx) error {
+	return getComments(c, models.CommentEntityIssue)
+}
+
+// CreateIssueComment adds a comment to an issue
+// @Summary Comment on an issue
+// @Description Add a comment or reply to an issue. @mentions notify the mentioned people.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Issue ID"
+// @Param comment body models.Comment true "Comment object"
+// @Success 201 {object} models.Comment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/comments [post]
+func CreateIssueComment(c *fiber.Ctx) error {
+	return createComment(c, models.CommentEntityIssue)
+}
+
+// GetComment retrieves a comment with its edit history
+// @Summary Get a comment
+// @Description Get a comment by ID including its previous revisions
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Comment ID"
+// @Success 200 {object} models.Comment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /comments/{id} [get]
+func GetComment(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid comment ID format",
+		})
+	}
+
+	var comment models.Comment
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).
+		Preload("Author").
+		Preload("Revisions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
+		Preload("Revisions.EditedBy").
+		First(&comment)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Comment not found",
+		})
+	}
+
+	return c.JSON(comment)
+}
+
+// UpdateComment edits the body of a comment and records the previous version
+// @Summary Edit a comment
+// @Description Edit a comment. Only the author, identified by X-Person-ID, may edit; the previous body is kept as a revision.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "ID of the person editing"
+// @Param id path int true "Comment ID"
+// @Param comment body commentUpdateRequest true "New body"
+// @Success 200 {object} models.Comment
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /comments/{id} [patch]
+func UpdateComment(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid comment ID format",
+		})
+	}
+
+	var comment models.Comment
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&comment)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Comment not found",
+		})
+	}
+
+	update := new(commentUpdateRequest)
+	if err := c.BodyParser(update); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if strings.TrimSpace(update.Body) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is required",
+		})
+	}
+
+	actor := actorID(c)
+	if actor == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "X-Person-ID is required to edit a comment",
+		})
+	}
+	if *actor != comment.AuthorID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author can edit this comment",
+		})
+	}
+
+	if update.Body == comment.Body {
+		return c.JSON(comment)
+	}
+
+	previousMentions := models.ParseMentions(comment.Body)
+
+	// Begin transaction
+	tx := database.DB.Begin()
+
+	revision := models.CommentRevision{
+		CommentID:  comment.ID,
+		Body:       comment.Body,
+		EditedByID: *actor,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record comment revision",
+		})
+	}
+
+	now := time.Now()
+	comment.Body = update.Body
+	comment.EditedAt = &now
+	if err := tx.Save(&comment).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update comment: " + err.Error(),
+		})
+	}
+
+	// Only people who were not already mentioned get a new notification
+	alreadyMentioned := make(map[string]bool)
+	for _, handle := range previousMentions {
+		alreadyMentioned[handle] = true
+	}
+	var newMentions []string
+	for _, handle := range models.ParseMentions(comment.Body) {
+		if !alreadyMentioned[handle] {
+			newMentions = append(newMentions, handle)
+		}
+	}
+
+	if _, err := notifyMentions(tx, &comment, newMentions); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create mention notifications",
+		})
+	}
+
+	// Commit transaction
+	tx.Commit()
+
+	database.DB.Preload("Author").First(&comment, comment.ID)
+	return c.JSON(comment)
+}
+
+// DeleteComment soft deletes a comment
+// @Summary Delete a comment
+// @Description Soft delete a comment by ID. Only the author, identified by X-Person-ID, may delete it. Replies to it are kept.
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "ID of the person deleting"
+// @Param id path int true "Comment ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /comments/{id} [delete]
+func DeleteComment(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid comment ID format",
+		})
+	}
+
+	var comment models.Comment
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&comment)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Comment not found",
+		})
+	}
+
+	actor := actorID(c)
+	if actor == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "X-Person-ID is required to delete a comment",
+		})
+	}
+	if *actor != comment.AuthorID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author can delete this comment",
+		})
+	}
+
+	result = database.DB.Delete(&comment)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete comment: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Comment deleted successfully",
+	})
+}
+
+// getComments lists the comments attached to a task or issue
+func getComments(c *fiber.Ctx, entityType models.CommentEntityType) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	entityID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + string(entityType) + " ID format",
+		})
+	}
+
+	if !commentEntityExists(entityType, entityID, tenantID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": commentEntityName(entityType) + " not found",
+		})
+	}
+
+	var comments []models.Comment
+	result := database.DB.
+		Where("tenant_id = ? AND entity_type = ? AND entity_id = ?", tenantID, entityType, entityID).
+		Preload("Author").
+		Order("created_at ASC").
+		Find(&comments)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve comments",
+		})
+	}
+
+	return c.JSON(comments)
+}
+
+// createComment adds a comment to a task or issue and notifies mentioned people
+func createComment(c *fiber.Ctx, entityType models.CommentEntityType) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	entityID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + string(entityType) + " ID format",
+		})
+	}
+
+	if !commentEntityExists(entityType, entityID, tenantID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": commentEntityName(entityType) + " not found",
+		})
+	}
+
+	comment := new(models.Comment)
+	if err := c.BodyParser(comment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	// Validate required fields
+	if strings.TrimSpace(comment.Body) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is required",
+		})
+	}
+
+	if comment.AuthorID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Author ID is required",
+		})
+	}
+
+	// Verify author belongs to the same tenant
+	var author models.Person
+	result := database.DB.Where("id = ? AND tenant_id = ?", comment.AuthorID, tenantID).First(&author)
+	if result.Error != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Author not found or not in the same tenant",
+		})
+	}
+
+	// Replies must stay in the same thread as their parent
+	if comment.ParentID != nil {
+		var parent models.Comment
+		result = database.DB.Where("id = ? AND tenant_id = ? AND entity_type = ? AND entity_id = ?",
+			*comment.ParentID, tenantID, entityType, entityID).First(&parent)
+		if result.Error != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Parent comment not found on this " + string(entityType),
+			})
+		}
+	}
+
+	comment.ID = 0
+	comment.TenantID = uint(tenantID)
+	comment.EntityType = entityType
+	comment.EntityID = uint(entityID)
+	comment.EditedAt = nil
+	comment.Revisions = nil
+
+	// Begin transaction
+	tx := database.DB.Begin()
+
+	if err := tx.Create(&comment).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create comment: " + err.Error(),
+		})
+	}
+
+	mentioned, err := notifyMentions(tx, comment, models.ParseMentions(comment.Body))
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create mention notifications",
+		})
+	}
+
+	// The author watches what they comment on; the other watchers hear about the comment
+	// unless it mentions them
+	if err := notifyCommentWatchers(tx, comment, mentioned); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create comment notifications",
+		})
+	}
+
+	if err := publishEvent(tx, comment.TenantID, models.EventCommentCreated, comment.ID, comment); err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record comment event",
+		})
+	}
+
+	// Commit transaction
+	tx.Commit()
+
+	database.DB.Preload("Author").First(&comment, comment.ID)
+	return c.Status(fiber.StatusCreated).JSON(comment)
+}
+
+// commentEntityExists reports whether a task or issue exists in one of the tenant's projects
+func commentEntityExists(entityType models.CommentEntityType, entityID int, tenantID int) bool {
+	var table string
+	switch entityType {
+	case models.CommentEntityTask:
+		table = "tasks"
+	case models.CommentEntityIssue:
+		table = "issues"
+	default:
+		return false
+	}
+
+	var count int64
+	database.DB.Table(table).
+		Joins("JOIN projects ON projects.id = "+table+".project_id").
+		Where(table+".id = ? AND projects.tenant_id = ?", entityID, tenantID).
+		Where(table + ".deleted_at IS NULL").
+		Count(&count)
+	return count > 0
+}
+
+// commentEntityName returns the display name of a commentable entity type
+func commentEntityName(entityType models.CommentEntityType) string {
+	switch entityType {
+	case models.CommentEntityTask:
+		return "Task"
+	case models.CommentEntityIssue:
+		return "Issue"
+	}
+	return "Record"
+}
+
+// notifyMentions creates a mention notification for every tenant member referenced by handle
+// and returns the people mentioned. A handle matches a person's full email address or the part
+// before the @.
+func notifyMentions(tx *gorm.DB, comment *models.Comment, handles []string) ([]uint, error) {
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	var clauses []string
+	var args []interface{}
+	for _, handle := range handles {
+		if strings.Contains(handle, "@") {
+			clauses = append(clauses, "LOWER(email) = ?")
+			args = append(args, handle)
+		} else {
+			clauses = append(clauses, `LOWER(email) LIKE ? ESCAPE '\'`)
+			args = append(args, like.Escape(handle)+"@%")
+		}
+	}
+
+	var mentioned []uint
+	result := tx.Model(&models.Person{}).Where("tenant_id = ?", comment.TenantID).
+		Where(strings.Join(clauses, " OR "), args...).
+		Pluck("id", &mentioned)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	notification := models.Notification{
+		TenantID:   comment.TenantID,
+		Type:       models.NotificationTypeMention,
+		EntityType: string(comment.EntityType),
+		EntityID:   comment.EntityID,
+		CommentID:  &comment.ID,
+		ActorID:    &comment.AuthorID,
+		Message:    fmt.Sprintf("You were mentioned in a comment on %s #%d", comment.EntityType, comment.EntityID),
+	}
+	// Authors are not notified about mentioning themselves
+	if _, err := notify(tx, notification, mentioned, comment.AuthorID); err != nil {
+		return nil, err
+	}
+
+	return mentioned, nil
+}
+
+// notifyCommentWatchers makes the author of a new comment watch the task or issue it is on,
+// and tells the other watchers about the comment, except those in skip
+func notifyCommentWatchers(tx *gorm.DB, comment *models.Comment, skip []uint) error {
+	entity := models.WatchEntity(comment.EntityType)
+	if err := watch(tx, comment.TenantID, entity, comment.EntityID, comment.AuthorID); err != nil {
+		return err
+	}
+
+	watchers, err := watcherIDs(tx, entity, comment.EntityID)
+	if err != nil {
+		return err
+	}
+
+	notification := models.Notification{
+		TenantID:   comment.TenantID,
+		Type:       models.NotificationTypeCommentAdded,
+		EntityType: string(comment.EntityType),
+		EntityID:   comment.EntityID,
+		CommentID:  &comment.ID,
+		ActorID:    &comment.AuthorID,
+		Message:    fmt.Sprintf("New comment on %s #%d", comment.EntityType, comment.EntityID),
+	}
+	_, err = notify(tx, notification, watchers, append(skip, comment.AuthorID)...)
+	return err
+}
@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupCommentApp serves task and issue comments on the notification database, with a task
+// and an issue in its project
+func setupCommentApp(t *testing.T) *fiber.App {
+	setupNotificationDB(t)
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Launch", Status: models.TaskStatusTodo})
+	database.DB.Create(&models.Issue{ProjectID: 1, Title: "Checkout broken", Description: "500 on submit", ReportedByID: 1})
+
+	app := setupNotificationApp()
+	app.Get("/tasks/:id/comments", handlers.GetTaskComments)
+	app.Get("/issues/:id/comments", handlers.GetIssueComments)
+	app.Post("/issues/:id/comments", handlers.CreateIssueComment)
+	app.Get("/comments/:id", handlers.GetComment)
+	app.Patch("/comments/:id", handlers.UpdateComment)
+	app.Delete("/comments/:id", handlers.DeleteComment)
+	return app
+}
+
+// mentionsOf returns the number of mention notifications each person got
+func mentionsOf(t *testing.T) map[uint]int {
+	var notifications []models.Notification
+	assert.NoError(t, database.DB.Where("type = ?", models.NotificationTypeMention).Find(&notifications).Error)
+	counts := make(map[uint]int)
+	for _, notification := range notifications {
+		counts[notification.PersonID]++
+	}
+	return counts
+}
+
+func TestCreateTaskAndIssueComments(t *testing.T) {
+	app := setupCommentApp(t)
+
+	status, body := doRequest(t, app, "POST", "/tasks/1/comments", `{"author_id": 1, "body": "Ready, @bob?"}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	status, body = doRequest(t, app, "POST", "/issues/1/comments", `{"author_id": 2, "body": "Seen it, @alice@example.com"}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	assert.Equal(t, map[uint]int{1: 1, 2: 1}, mentionsOf(t))
+
+	status, body = doRequest(t, app, "GET", "/issues/1/comments", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	var comments []models.Comment
+	assert.NoError(t, json.Unmarshal(body, &comments))
+	if assert.Len(t, comments, 1) {
+		assert.Equal(t, models.CommentEntityIssue, comments[0].EntityType)
+		assert.Equal(t, "Bob", comments[0].Author.Name)
+	}
+
+	status, _ = doRequest(t, app, "POST", "/issues/9/comments", `{"author_id": 1, "body": "Hello"}`)
+	assert.Equal(t, fiber.StatusNotFound, status)
+	status, _ = doRequest(t, app, "POST", "/tasks/1/comments", `{"author_id": 1, "body": "  "}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "POST", "/tasks/1/comments", `{"author_id": 9, "body": "Hello"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
+
+func TestCommentMentionsAreNotWildcards(t *testing.T) {
+	app := setupCommentApp(t)
+
+	// LIKE wildcards in a handle match nobody
+	status, body := doRequest(t, app, "POST", "/tasks/1/comments", `{"author_id": 1, "body": "Hey @% and @_ob and @c%"}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	assert.Empty(t, mentionsOf(t))
+
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Dee", Email: "d_e%e@example.com", Role: "member"})
+	status, body = doRequest(t, app, "POST", "/tasks/1/comments", `{"author_id": 1, "body": "Over to @d_e%e"}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	assert.Equal(t, map[uint]int{4: 1}, mentionsOf(t), "handles with wildcard characters still match exactly")
+}
+
+func TestUpdateCommentKeepsRevisionAndNotifiesNewMentions(t *testing.T) {
+	app := setupCommentApp(t)
+	status, body := doRequest(t, app, "POST", "/issues/1/comments", `{"author_id": 1, "body": "@bob can you look?"}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+
+	status, _ = doRequestAs(t, app, "2", "PATCH", "/comments/1", `{"body": "Hijacked"}`)
+	assert.Equal(t, fiber.StatusForbidden, status, "only the author edits")
+	status, _ = doRequest(t, app, "PATCH", "/comments/1", `{"body": "Hijacked"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status, "the editor is identified")
+	status, _ = doRequestAs(t, app, "1", "PATCH", "/comments/1", `{}`)
+	assert.Equal(t, fiber.StatusBadRequest, status, "the body is required")
+
+	status, body = doRequestAs(t, app, "1", "PATCH", "/comments/1", `{"body": "@bob can you look? @carol too"}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	assert.Equal(t, map[uint]int{2: 1, 3: 1}, mentionsOf(t), "people already mentioned are not notified again")
+
+	status, body = doRequest(t, app, "GET", "/comments/1", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	var comment models.Comment
+	assert.NoError(t, json.Unmarshal(body, &comment))
+	assert.NotNil(t, comment.EditedAt)
+	if assert.Len(t, comment.Revisions, 1) {
+		assert.Equal(t, "@bob can you look?", comment.Revisions[0].Body)
+	}
+}
+
+func TestOnlyTheAuthorDeletesAComment(t *testing.T) {
+	app := setupCommentApp(t)
+	status, body := doRequest(t, app, "POST", "/issues/1/comments", `{"author_id": 1, "body": "Looking into it"}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+
+	status, _ = doRequest(t, app, "DELETE", "/comments/1", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequestAs(t, app, "2", "DELETE", "/comments/1", "")
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, _ = doRequestAs(t, app, "1", "DELETE", "/comments/1", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = doRequest(t, app, "GET", "/comments/1", "")
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetRateCards retrieves all rate cards for the current tenant
+// @Summary Get all rate cards
+// @Description Get all rate cards for the current tenant ordered by effective date
+// @Tags rate-cards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param person_id query int false "Filter by person"
+// @Param role query string false "Filter by role"
+// @Success 200 {array} models.RateCard
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rate-cards [get]
+func GetRateCards(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ?", tenantID)
+	if personID := c.Query("person_id"); personID != "" {
+		query = query.Where("person_id = ?", personID)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	var cards []models.RateCard
+	result := query.Preload("Person").Order("effective_from DESC").Find(&cards)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve rate cards: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(cards)
+}
+
+// GetRateCard retrieves a rate card by ID
+// @Summary Get a rate card by ID
+// @Description Get a rate card by ID for the current tenant
+// @Tags rate-cards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Rate card ID"
+// @Success 200 {object} models.RateCard
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /rate-cards/{id} [get]
+func GetRateCard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var card models.RateCard
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).Preload("Person").First(&card)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Rate card not found",
+		})
+	}
+
+	return c.JSON(card)
+}
+
+// CreateRateCard creates a new rate card
+// @Summary Create a rate card
+// @Description Create an hourly rate for a person or a role, effective from a date
+// @Tags rate-cards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param card body models.RateCard true "Rate card object"
+// @Success 201 {object} models.RateCard
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rate-cards [post]
+func CreateRateCard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	card := new(models.RateCard)
+	if err := c.BodyParser(card); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	card.ID = 0
+	card.TenantID = uint(tenantID)
+	if msg := validateRateCard(card); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	result := database.DB.Create(&card)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create rate card: " + result.Error.Error(),
+		})
+	}
+
+	invalidateTenantHealth(uint(tenantID))
+
+	return c.Status(fiber.StatusCreated).JSON(card)
+}
+
+// UpdateRateCard updates a rate card
+// @Summary Update a rate card
+// @Description Replace the person or role, rate and effective dates of a rate card
+// @Tags rate-cards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Rate card ID"
+// @Param card body models.RateCard true "Rate card object"
+// @Success 200 {object} models.RateCard
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rate-cards/{id} [put]
+func UpdateRateCard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var card models.RateCard
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&card)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Rate card not found",
+		})
+	}
+
+	updatedCard := new(models.RateCard)
+	if err := c.BodyParser(updatedCard); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	card.PersonID = updatedCard.PersonID
+	card.Role = updatedCard.Role
+	card.HourlyRate = updatedCard.HourlyRate
+	card.EffectiveFrom = updatedCard.EffectiveFrom
+	card.EffectiveTo = updatedCard.EffectiveTo
+	if msg := validateRateCard(&card); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	result = database.DB.Save(&card)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update rate card: " + result.Error.Error(),
+		})
+	}
+
+	invalidateTenantHealth(uint(tenantID))
+
+	return c.JSON(card)
+}
+
+// DeleteRateCard deletes a rate card
+// @Summary Delete a rate card
+// @Description Delete a rate card by ID
+// @Tags rate-cards
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Rate card ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rate-cards/{id} [delete]
+func DeleteRateCard(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var card models.RateCard
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&card)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Rate card not found",
+		})
+	}
+
+	result = database.DB.Delete(&card)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete rate card: " + result.Error.Error(),
+		})
+	}
+
+	invalidateTenantHealth(uint(tenantID))
+
+	return c.JSON(fiber.Map{
+		"message": "Rate card deleted successfully",
+	})
+}
+
+// GetProjectCosts retrieves the non-labour costs linked to a project
+// @Summary Get project costs
+// @Description Get the purchase orders and maintenance records linked to a project as costs
+// @Tags project-costs
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.ProjectCost
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/costs [get]
+func GetProjectCosts(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	projectID, err := c.ParamsInt("project_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", projectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	var costs []models.ProjectCost
+	result = database.DB.Where("project_id = ?", projectID).
+		Preload("PurchaseOrder").
+		Preload("MaintenanceRecord").
+		Order("id ASC").
+		Find(&costs)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve project costs: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(costs)
+}
+
+// CreateProjectCost links a purchase order or maintenance record to a project
+// @Summary Link a cost to a project
+// @Description Link exactly one purchase order or maintenance record to a project. A record can only be linked to one project.
+// @Tags project-costs
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Param cost body models.ProjectCost true "Project cost object"
+// @Success 201 {object} models.ProjectCost
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/costs [post]
+func CreateProjectCost(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	projectID, err := c.ParamsInt("project_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", projectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	cost := new(models.ProjectCost)
+	if err := c.BodyParser(cost); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if (cost.PurchaseOrderID == nil) == (cost.MaintenanceRecordID == nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Exactly one of purchase_order_id or maintenance_record_id is required",
+		})
+	}
+
+	// Verify the linked record belongs to the tenant and is not counted on another project
+	linked := database.DB.Model(&models.ProjectCost{})
+	if cost.PurchaseOrderID != nil {
+		var order models.PurchaseOrder
+		result = database.DB.Where("id = ? AND tenant_id = ?", *cost.PurchaseOrderID, tenantID).First(&order)
+		if result.Error != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Purchase order not found or not in the same tenant",
+			})
+		}
+		linked = linked.Where("purchase_order_id = ?", *cost.PurchaseOrderID)
+	} else {
+		var record models.MaintenanceRecord
+		result = database.DB.Where("id = ? AND tenant_id = ?", *cost.MaintenanceRecordID, tenantID).First(&record)
+		if result.Error != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Maintenance record not found or not in the same tenant",
+			})
+		}
+		linked = linked.Where("maintenance_record_id = ?", *cost.MaintenanceRecordID)
+	}
+
+	var count int64
+	linked.Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Record is already linked to a project",
+		})
+	}
+
+	cost.ID = 0
+	cost.TenantID = uint(tenantID)
+	cost.ProjectID = uint(projectID)
+	cost.PurchaseOrder = nil
+	cost.MaintenanceRecord = nil
+
+	result = database.DB.Create(&cost)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to link cost: " + result.Error.Error(),
+		})
+	}
+
+	projectDataChanged(cost.ProjectID)
+
+	database.DB.Preload("PurchaseOrder").Preload("MaintenanceRecord").First(&cost, cost.ID)
+	return c.Status(fiber.StatusCreated).JSON(cost)
+}
+
+// DeleteProjectCost unlinks a cost from its project
+// @Summary Unlink a project cost
+// @Description Remove the link between a project and a purchase order or maintenance record
+// @Tags project-costs
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project cost ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /project-costs/{id} [delete]
+func DeleteProjectCost(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var cost models.ProjectCost
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&cost)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project cost not found",
+		})
+	}
+
+	// Unlinked records can be linked again, so the link is removed rather than soft deleted
+	result = database.DB.Unscoped().Delete(&cost)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unlink cost: " + result.Error.Error(),
+		})
+	}
+
+	projectDataChanged(cost.ProjectID)
+
+	return c.JSON(fiber.Map{
+		"message": "Project cost unlinked successfully",
+	})
+}
+
+// validateRateCard checks a rate card and returns a message describing the first problem
+func validateRateCard(card *models.RateCard) string {
+	if card.PersonID == nil && card.Role == "" {
+		return "Either person_id or role is required"
+	}
+	if card.PersonID != nil {
+		var person models.Person
+		result := database.DB.Where("id = ? AND tenant_id = ?", *card.PersonID, card.TenantID).First(&person)
+		if result.Error != nil {
+			return "Person not found or not in the same tenant"
+		}
+	}
+	if card.HourlyRate < 0 {
+		return "Hourly rate must not be negative"
+	}
+	if card.EffectiveFrom.IsZero() {
+		return "Effective from date is required"
+	}
+	if card.EffectiveTo != nil && card.EffectiveTo.Before(card.EffectiveFrom) {
+		return "Effective to date must not be before the effective from date"
+	}
+	return ""
+}
@@ -0,0 +1,780 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/like"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// customFieldParam prefixes query parameters that filter and sort on custom fields,
+// e.g. cf.client_code=ACME, cf.budget_code.gte=100 or sort=-cf.client_code
+const customFieldParam = "cf."
+
+// GetCustomFields returns the tenant's custom fields
+// @Summary Get custom fields
+// @Description Get the tenant's custom fields, optionally of one entity type
+// @Tags custom-fields
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param entity_type query string false "project, task or person"
+// @Success 200 {array} models.CustomField
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /custom-fields [get]
+func GetCustomFields(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ?", tenantID)
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+
+	var fields []models.CustomField
+	result := query.Order("entity_type ASC, position ASC, id ASC").Find(&fields)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve custom fields",
+		})
+	}
+
+	return c.JSON(fields)
+}
+
+// CreateCustomField adds a custom field to projects, tasks or people
+// @Summary Create a custom field
+// @Description Add a field to the tenant's projects, tasks or people. Types are text, number, date, select, multi_select and person; select and multi_select fields need options. The key names the field in custom_fields and in list filters.
+// @Tags custom-fields
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param field body models.CustomField true "Custom field"
+// @Success 201 {object} models.CustomField
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /custom-fields [post]
+func CreateCustomField(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var field models.CustomField
+	if err := c.BodyParser(&field); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if msg := validateCustomField(&field); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	var existing int64
+	database.DB.Model(&models.CustomField{}).Where("tenant_id = ? AND entity_type = ? AND key = ?", tenantID, field.EntityType, field.Key).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A " + string(field.EntityType) + " custom field with key " + field.Key + " already exists",
+		})
+	}
+
+	field.ID = 0
+	field.TenantID = uint(tenantID)
+
+	result := database.DB.Create(&field)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create custom field: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(field)
+}
+
+// UpdateCustomField updates a custom field
+// @Summary Update a custom field
+// @Description Replace the name, options, required flag and position of a custom field. Its entity type, key and type cannot change, and options records still have cannot be removed.
+// @Tags custom-fields
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Custom field ID"
+// @Param field body models.CustomField true "Custom field"
+// @Success 200 {object} models.CustomField
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /custom-fields/{id} [put]
+func UpdateCustomField(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var field models.CustomField
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&field)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Custom field not found",
+		})
+	}
+
+	var updated models.CustomField
+	if err := c.BodyParser(&updated); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	field.Name = updated.Name
+	field.Options = updated.Options
+	field.Required = updated.Required
+	field.Position = updated.Position
+
+	if msg := validateCustomField(&field); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	// Records keep their choices, so options cannot be dropped while records have them
+	if field.Type == models.CustomFieldTypeSelect || field.Type == models.CustomFieldTypeMultiSelect {
+		inUse, err := customFieldOptionsInUse(database.DB, &field, field.Options)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check custom field options: " + err.Error(),
+			})
+		}
+		if len(inUse) > 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "Options still in use cannot be removed: " + strings.Join(inUse, ", "),
+				"options": inUse,
+			})
+		}
+	}
+
+	result = database.DB.Save(&field)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update custom field: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(field)
+}
+
+// DeleteCustomField deletes a custom field and its values
+// @Summary Delete a custom field
+// @Description Delete a custom field and remove its values from the tenant's projects, tasks or people
+// @Tags custom-fields
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Custom field ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /custom-fields/{id} [delete]
+func DeleteCustomField(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var field models.CustomField
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&field)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Custom field not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := removeCustomFieldValues(tx, &field); err != nil {
+			return err
+		}
+		return tx.Delete(&field).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete custom field: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Custom field deleted successfully",
+	})
+}
+
+// validateCustomField checks a custom field definition and returns a message describing the first problem
+func validateCustomField(field *models.CustomField) string {
+	switch field.EntityType {
+	case models.CustomFieldEntityProject, models.CustomFieldEntityTask, models.CustomFieldEntityPerson:
+	default:
+		return "Invalid custom field entity type: " + string(field.EntityType)
+	}
+	if !models.IsValidCustomFieldKey(field.Key) {
+		return "Custom field key must start with a lowercase letter and contain only lowercase letters, digits and underscores"
+	}
+	if field.Name == "" {
+		return "Custom field name is required"
+	}
+
+	switch field.Type {
+	case models.CustomFieldTypeSelect, models.CustomFieldTypeMultiSelect:
+		if len(field.Options) == 0 {
+			return "Select custom fields need at least one option"
+		}
+		seen := make(map[string]bool)
+		for _, option := range field.Options {
+			if option == "" || seen[option] {
+				return "Custom field options must be non-empty and unique"
+			}
+			seen[option] = true
+		}
+	case models.CustomFieldTypeText, models.CustomFieldTypeNumber, models.CustomFieldTypeDate, models.CustomFieldTypePerson:
+		if len(field.Options) > 0 {
+			return "Only select custom fields have options"
+		}
+	default:
+		return "Invalid custom field type: " + string(field.Type)
+	}
+	return ""
+}
+
+// tenantCustomFields returns the tenant's custom fields of an entity type by key
+func tenantCustomFields(db *gorm.DB, tenantID uint, entity models.CustomFieldEntity) (map[string]*models.CustomField, error) {
+	var fields []models.CustomField
+	if err := db.Where("tenant_id = ? AND entity_type = ?", tenantID, entity).Order("position ASC, id ASC").Find(&fields).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*models.CustomField, len(fields))
+	for i := range fields {
+		byKey[fields[i].Key] = &fields[i]
+	}
+	return byKey, nil
+}
+
+// applyCustomFields merges the custom field values sent for a record into its current ones and
+// checks them against the tenant's definitions. A null value clears a field. It returns the new
+// values, or a message describing the first problem.
+func applyCustomFields(db *gorm.DB, tenantID uint, entity models.CustomFieldEntity, current, given models.CustomFieldValues) (models.CustomFieldValues, string) {
+	fields, err := tenantCustomFields(db, tenantID, entity)
+	if err != nil {
+		return nil, "Failed to load custom fields: " + err.Error()
+	}
+
+	values := make(models.CustomFieldValues, len(current)+len(given))
+	for key, value := range current {
+		if _, ok := fields[key]; ok {
+			values[key] = value
+		}
+	}
+	for key, value := range given {
+		field, ok := fields[key]
+		if !ok {
+			return nil, "Unknown custom field: " + key
+		}
+		normalized, msg := normalizeCustomFieldValue(db, tenantID, field, value)
+		if msg != "" {
+			return nil, msg
+		}
+		if normalized == nil {
+			delete(values, key)
+		} else {
+			values[key] = normalized
+		}
+	}
+
+	for key, field := range fields {
+		if _, ok := values[key]; field.Required && !ok {
+			return nil, "Custom field " + field.Name + " is required"
+		}
+	}
+	return values, ""
+}
+
+// normalizeCustomFieldValue checks a value sent for a custom field and returns it in the form it
+// is stored in: a string for text, select and date (YYYY-MM-DD) fields, a number for number and
+// person fields and a list of strings for multi-select fields. Empty values come back as nil.
+func normalizeCustomFieldValue(db *gorm.DB, tenantID uint, field *models.CustomField, value interface{}) (interface{}, string) {
+	if value == nil {
+		return nil, ""
+	}
+	invalid := fmt.Sprintf("Invalid value for custom field %s: expected %s", field.Name, field.Type)
+
+	switch field.Type {
+	case models.CustomFieldTypeText:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid
+		}
+		if text == "" {
+			return nil, ""
+		}
+		return text, ""
+
+	case models.CustomFieldTypeNumber:
+		number, ok := value.(float64)
+		if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, invalid
+		}
+		return number, ""
+
+	case models.CustomFieldTypeDate:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid
+		}
+		if text == "" {
+			return nil, ""
+		}
+		date, err := parseCustomFieldDate(text)
+		if err != nil {
+			return nil, invalid
+		}
+		return date, ""
+
+	case models.CustomFieldTypeSelect:
+		choice, ok := value.(string)
+		if !ok {
+			return nil, invalid
+		}
+		if choice == "" {
+			return nil, ""
+		}
+		if !field.HasOption(choice) {
+			return nil, "Invalid option for custom field " + field.Name + ": " + choice
+		}
+		return choice, ""
+
+	case models.CustomFieldTypeMultiSelect:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, invalid
+		}
+		var choices []string
+		seen := make(map[string]bool)
+		for _, item := range items {
+			choice, ok := item.(string)
+			if !ok {
+				return nil, invalid
+			}
+			if !field.HasOption(choice) {
+				return nil, "Invalid option for custom field " + field.Name + ": " + choice
+			}
+			if !seen[choice] {
+				seen[choice] = true
+				choices = append(choices, choice)
+			}
+		}
+		if len(choices) == 0 {
+			return nil, ""
+		}
+		return choices, ""
+
+	case models.CustomFieldTypePerson:
+		id, ok := value.(float64)
+		if !ok || id <= 0 || id != math.Trunc(id) {
+			return nil, invalid
+		}
+		var count int64
+		db.Model(&models.Person{}).Where("id = ? AND tenant_id = ?", uint(id), tenantID).Count(&count)
+		if count == 0 {
+			return nil, "Person for custom field " + field.Name + " not found or not in the same tenant"
+		}
+		return id, ""
+	}
+	return nil, invalid
+}
+
+// parseCustomFieldDate parses a date given as YYYY-MM-DD or RFC 3339 and returns it as YYYY-MM-DD
+func parseCustomFieldDate(text string) (string, error) {
+	date, err := time.Parse("2006-01-02", text)
+	if err != nil {
+		date, err = time.Parse(time.RFC3339, text)
+	}
+	if err != nil {
+		return "", err
+	}
+	return date.Format("2006-01-02"), nil
+}
+
+// customFieldRecords returns a query over the records of the field's tenant and entity type whose
+// custom fields mention its key. The match is on the stored JSON, so a record's values still need
+// checking for the key.
+func customFieldRecords(tx *gorm.DB, field *models.CustomField) *gorm.DB {
+	pattern := `%"` + like.Escape(field.Key) + `"%`
+	switch field.EntityType {
+	case models.CustomFieldEntityProject:
+		return tx.Model(&models.Project{}).Where(`tenant_id = ? AND custom_fields LIKE ? ESCAPE '\'`, field.TenantID, pattern)
+	case models.CustomFieldEntityTask:
+		return tx.Model(&models.Task{}).Joins("JOIN projects ON projects.id = tasks.project_id").
+			Where(`projects.tenant_id = ? AND tasks.custom_fields LIKE ? ESCAPE '\'`, field.TenantID, pattern)
+	case models.CustomFieldEntityPerson:
+		return tx.Model(&models.Person{}).Where(`tenant_id = ? AND custom_fields LIKE ? ESCAPE '\'`, field.TenantID, pattern)
+	}
+	return nil
+}
+
+// removeCustomFieldValues removes a deleted custom field's values from the records that have one
+func removeCustomFieldValues(tx *gorm.DB, field *models.CustomField) error {
+	switch field.EntityType {
+	case models.CustomFieldEntityProject:
+		var projects []models.Project
+		if err := customFieldRecords(tx, field).Find(&projects).Error; err != nil {
+			return err
+		}
+		for _, project := range projects {
+			if _, ok := project.CustomFields[field.Key]; !ok {
+				continue
+			}
+			delete(project.CustomFields, field.Key)
+			if err := tx.Model(&project).Select("custom_fields").Updates(&project).Error; err != nil {
+				return err
+			}
+		}
+	case models.CustomFieldEntityTask:
+		var tasks []models.Task
+		if err := customFieldRecords(tx, field).Find(&tasks).Error; err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if _, ok := task.CustomFields[field.Key]; !ok {
+				continue
+			}
+			delete(task.CustomFields, field.Key)
+			if err := tx.Model(&task).Select("custom_fields").Updates(&task).Error; err != nil {
+				return err
+			}
+		}
+	case models.CustomFieldEntityPerson:
+		var people []models.Person
+		if err := customFieldRecords(tx, field).Find(&people).Error; err != nil {
+			return err
+		}
+		for _, person := range people {
+			if _, ok := person.CustomFields[field.Key]; !ok {
+				continue
+			}
+			delete(person.CustomFields, field.Key)
+			if err := tx.Model(&person).Select("custom_fields").Updates(&person).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// customFieldOptionsInUse returns the options of a select field that records still have but
+// the given options leave out
+func customFieldOptionsInUse(db *gorm.DB, field *models.CustomField, options []string) ([]string, error) {
+	column := "custom_fields"
+	if field.EntityType == models.CustomFieldEntityTask {
+		column = "tasks.custom_fields"
+	}
+	var records []struct {
+		CustomFields models.CustomFieldValues `gorm:"serializer:json"`
+	}
+	if err := customFieldRecords(db, field).Select(column).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	kept := make(map[string]bool, len(options))
+	for _, option := range options {
+		kept[option] = true
+	}
+	used := make(map[string]bool)
+	var inUse []string
+	for _, record := range records {
+		value, ok := record.CustomFields[field.Key]
+		if !ok {
+			continue
+		}
+		choices := customFieldStrings(value)
+		if choice, ok := value.(string); ok {
+			choices = []string{choice}
+		}
+		for _, choice := range choices {
+			if !kept[choice] && !used[choice] {
+				used[choice] = true
+				inUse = append(inUse, choice)
+			}
+		}
+	}
+	sort.Strings(inUse)
+	return inUse, nil
+}
+
+// customFieldFilter is a condition on a custom field from a list's query parameters
+type customFieldFilter struct {
+	field *models.CustomField
+	op    string // "" for equals, "gte" or "lte"
+	value interface{}
+}
+
+// customFieldQuery is how a list is filtered and sorted on custom fields
+type customFieldQuery struct {
+	filters []customFieldFilter
+	sort    *models.CustomField
+	desc    bool
+}
+
+// parseCustomFieldQuery reads the custom field filters (cf.<key>, cf.<key>.gte, cf.<key>.lte)
+// and sort (sort=cf.<key>, or sort=-cf.<key> for descending) of a list request. It returns a
+// message describing the first problem.
+func parseCustomFieldQuery(c *fiber.Ctx, db *gorm.DB, tenantID uint, entity models.CustomFieldEntity) (*customFieldQuery, string) {
+	query := &customFieldQuery{}
+	var fields map[string]*models.CustomField
+	load := func() string {
+		if fields != nil {
+			return ""
+		}
+		var err error
+		if fields, err = tenantCustomFields(db, tenantID, entity); err != nil {
+			return "Failed to load custom fields: " + err.Error()
+		}
+		return ""
+	}
+
+	params := c.Queries()
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, customFieldParam) {
+			continue
+		}
+		if msg := load(); msg != "" {
+			return nil, msg
+		}
+
+		key, op := strings.TrimPrefix(name, customFieldParam), ""
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			key, op = key[:i], key[i+1:]
+		}
+		field, ok := fields[key]
+		if !ok {
+			return nil, "Unknown custom field: " + key
+		}
+
+		switch op {
+		case "":
+		case "gte", "lte":
+			if field.Type != models.CustomFieldTypeNumber && field.Type != models.CustomFieldTypeDate {
+				return nil, "Only number and date custom fields can be filtered by range"
+			}
+		default:
+			return nil, "Invalid custom field filter: " + name
+		}
+
+		value, err := parseCustomFieldParam(field, params[name])
+		if err != nil {
+			return nil, fmt.Sprintf("Invalid filter value for custom field %s: expected %s", field.Name, field.Type)
+		}
+		query.filters = append(query.filters, customFieldFilter{field: field, op: op, value: value})
+	}
+
+	if sortBy := c.Query("sort"); sortBy != "" {
+		query.desc = strings.HasPrefix(sortBy, "-")
+		key := strings.TrimPrefix(sortBy, "-")
+		if !strings.HasPrefix(key, customFieldParam) {
+			return nil, "Invalid sort: " + sortBy
+		}
+		if msg := load(); msg != "" {
+			return nil, msg
+		}
+		field, ok := fields[strings.TrimPrefix(key, customFieldParam)]
+		if !ok {
+			return nil, "Unknown custom field: " + strings.TrimPrefix(key, customFieldParam)
+		}
+		query.sort = field
+	}
+
+	return query, ""
+}
+
+// parseCustomFieldParam parses a filter value from a query parameter in the form values are stored in
+func parseCustomFieldParam(field *models.CustomField, param string) (interface{}, error) {
+	switch field.Type {
+	case models.CustomFieldTypeNumber:
+		return strconv.ParseFloat(param, 64)
+	case models.CustomFieldTypeDate:
+		return parseCustomFieldDate(param)
+	case models.CustomFieldTypePerson:
+		id, err := strconv.ParseUint(param, 10, 64)
+		return float64(id), err
+	}
+	return param, nil
+}
+
+// applyCustomFieldQuery filters and sorts records on their custom fields. Records without a
+// value for the sort field come last. It works on the loaded records rather than in SQL, so the
+// lists using it return all matching records; a paginated list would need these conditions in
+// its query instead.
+func applyCustomFieldQuery[T any](query *customFieldQuery, records []T, values func(*T) models.CustomFieldValues) []T {
+	if len(query.filters) > 0 {
+		kept := records[:0]
+		for i := range records {
+			if query.matches(values(&records[i])) {
+				kept = append(kept, records[i])
+			}
+		}
+		records = kept
+	}
+
+	if query.sort != nil {
+		key := query.sort.Key
+		sort.SliceStable(records, func(i, j int) bool {
+			a, aok := values(&records[i])[key]
+			b, bok := values(&records[j])[key]
+			if !aok || !bok {
+				return aok && !bok
+			}
+			cmp := compareCustomFieldValues(a, b)
+			if query.desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+	return records
+}
+
+// matches reports whether a record's custom field values meet every filter
+func (q *customFieldQuery) matches(values models.CustomFieldValues) bool {
+	for _, filter := range q.filters {
+		value, ok := values[filter.field.Key]
+		if !ok {
+			return false
+		}
+
+		if filter.field.Type == models.CustomFieldTypeMultiSelect {
+			found := false
+			for _, choice := range customFieldStrings(value) {
+				found = found || choice == filter.value
+			}
+			if !found {
+				return false
+			}
+			continue
+		}
+
+		cmp := compareCustomFieldValues(value, filter.value)
+		switch filter.op {
+		case "gte":
+			if cmp < 0 {
+				return false
+			}
+		case "lte":
+			if cmp > 0 {
+				return false
+			}
+		default:
+			if cmp != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compareCustomFieldValues orders two values of a field: numbers by value, anything else by its text
+func compareCustomFieldValues(a, b interface{}) int {
+	x, xok := a.(float64)
+	y, yok := b.(float64)
+	if xok && yok {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(customFieldText(a), customFieldText(b))
+}
+
+// customFieldText returns a value as text; multi-select choices are joined with commas
+func customFieldText(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	if choices := customFieldStrings(value); choices != nil {
+		return strings.Join(choices, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// customFieldStrings returns the choices of a multi-select value, as stored or as read back from JSON
+func customFieldStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		choices := make([]string, 0, len(v))
+		for _, item := range v {
+			if choice, ok := item.(string); ok {
+				choices = append(choices, choice)
+			}
+		}
+		return choices
+	}
+	return nil
+}
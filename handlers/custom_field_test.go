@@ -0,0 +1,147 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupCustomFieldDB sets up an isolated in-memory SQLite database with project custom fields
+func setupCustomFieldDB(t *testing.T) {
+	newTestDB(t)
+
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "manager"})
+}
+
+func setupCustomFieldApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Post("/custom-fields", handlers.CreateCustomField)
+	app.Put("/custom-fields/:id", handlers.UpdateCustomField)
+	app.Delete("/custom-fields/:id", handlers.DeleteCustomField)
+	app.Get("/projects", handlers.GetProjects)
+	app.Post("/projects", handlers.CreateProject)
+	app.Patch("/projects/:id", handlers.UpdateProject)
+	return app
+}
+
+func projectNames(t *testing.T, app *fiber.App, url string) []string {
+	status, body := doRequest(t, app, "GET", url, "")
+	assert.Equal(t, 200, status, string(body))
+
+	var projects []models.Project
+	assert.NoError(t, json.Unmarshal(body, &projects))
+	names := []string{}
+	for _, project := range projects {
+		names = append(names, project.Name)
+	}
+	return names
+}
+
+func TestCustomFieldDefinitions(t *testing.T) {
+	setupCustomFieldDB(t)
+	app := setupCustomFieldApp()
+
+	status, body := doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "client_code", "name": "Client code", "type": "text", "required": true}`)
+	assert.Equal(t, 201, status, string(body))
+
+	status, _ = doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "client_code", "name": "Client", "type": "text"}`)
+	assert.Equal(t, 409, status, "duplicate key")
+	status, _ = doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "task", "key": "client_code", "name": "Client", "type": "text"}`)
+	assert.Equal(t, 201, status, "keys are per entity type")
+	status, _ = doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "tier", "name": "Tier", "type": "select"}`)
+	assert.Equal(t, 400, status, "select fields need options")
+	status, _ = doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "Cost Centre", "name": "Cost centre", "type": "text"}`)
+	assert.Equal(t, 400, status, "invalid key")
+	status, _ = doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "issue", "key": "severity", "name": "Severity", "type": "text"}`)
+	assert.Equal(t, 400, status, "invalid entity type")
+}
+
+func TestProjectCustomFieldValues(t *testing.T) {
+	setupCustomFieldDB(t)
+	app := setupCustomFieldApp()
+
+	doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "client_code", "name": "Client code", "type": "text", "required": true}`)
+	doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "tier", "name": "Tier", "type": "select", "options": ["gold", "silver"]}`)
+	doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "contract_value", "name": "Contract value", "type": "number"}`)
+	doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "sponsor", "name": "Sponsor", "type": "person"}`)
+	doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "signed_on", "name": "Signed on", "type": "date"}`)
+
+	status, _ := doRequest(t, app, "POST", "/projects", `{"name": "Alpha"}`)
+	assert.Equal(t, 400, status, "required field is missing")
+	status, _ = doRequest(t, app, "POST", "/projects", `{"name": "Alpha", "custom_fields": {"client_code": "ACME", "tier": "bronze"}}`)
+	assert.Equal(t, 400, status, "not an option")
+	status, _ = doRequest(t, app, "POST", "/projects", `{"name": "Alpha", "custom_fields": {"client_code": "ACME", "colour": "red"}}`)
+	assert.Equal(t, 400, status, "unknown field")
+	status, _ = doRequest(t, app, "POST", "/projects", `{"name": "Alpha", "custom_fields": {"client_code": "ACME", "contract_value": "lots"}}`)
+	assert.Equal(t, 400, status, "not a number")
+	status, _ = doRequest(t, app, "POST", "/projects", `{"name": "Alpha", "custom_fields": {"client_code": "ACME", "sponsor": 99}}`)
+	assert.Equal(t, 400, status, "no such person")
+
+	status, body := doRequest(t, app, "POST", "/projects", `{"name": "Alpha", "custom_fields": {"client_code": "ACME", "tier": "gold", "contract_value": 100, "sponsor": 1, "signed_on": "2024-03-01T09:00:00Z"}}`)
+	assert.Equal(t, 201, status, string(body))
+	var project models.Project
+	assert.NoError(t, json.Unmarshal(body, &project))
+	assert.Equal(t, "2024-03-01", project.CustomFields["signed_on"])
+	doRequest(t, app, "POST", "/projects", `{"name": "Beta", "custom_fields": {"client_code": "BETA", "contract_value": 300}}`)
+	doRequest(t, app, "POST", "/projects", `{"name": "Gamma", "custom_fields": {"client_code": "ACME", "tier": "silver"}}`)
+
+	assert.Equal(t, []string{"Alpha", "Gamma"}, projectNames(t, app, "/projects?cf.client_code=ACME&sort=-cf.contract_value"))
+	assert.Equal(t, []string{"Beta"}, projectNames(t, app, "/projects?cf.contract_value.gte=200"))
+	assert.Equal(t, []string{"Alpha", "Beta", "Gamma"}, projectNames(t, app, "/projects?sort=cf.contract_value"))
+	assert.Equal(t, []string{"Gamma"}, projectNames(t, app, "/projects?cf.tier=silver"))
+	status, _ = doRequest(t, app, "GET", "/projects?cf.colour=red", "")
+	assert.Equal(t, 400, status)
+
+	// Values not sent are kept; a required field cannot be cleared
+	status, _ = doRequest(t, app, "PATCH", "/projects/2", `{"custom_fields": {"tier": "silver"}}`)
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []string{"Beta", "Gamma"}, projectNames(t, app, "/projects?cf.tier=silver"))
+	assert.Equal(t, []string{"Beta"}, projectNames(t, app, "/projects?cf.client_code=BETA"))
+	status, _ = doRequest(t, app, "PATCH", "/projects/2", `{"custom_fields": {"client_code": null}}`)
+	assert.Equal(t, 400, status)
+
+	// Deleting a field removes its values
+	status, _ = doRequest(t, app, "DELETE", "/custom-fields/2", "")
+	assert.Equal(t, 200, status)
+	var alpha models.Project
+	database.DB.First(&alpha, 1)
+	assert.NotContains(t, alpha.CustomFields, "tier")
+	assert.Equal(t, "ACME", alpha.CustomFields["client_code"])
+}
+
+func TestCustomFieldChangesKeepOtherValues(t *testing.T) {
+	setupCustomFieldDB(t)
+	app := setupCustomFieldApp()
+
+	doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "a_b", "name": "A B", "type": "text"}`)
+	doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "axb", "name": "AXB", "type": "text"}`)
+	doRequest(t, app, "POST", "/custom-fields", `{"entity_type": "project", "key": "tier", "name": "Tier", "type": "select", "options": ["gold", "silver", "bronze"]}`)
+	status, body := doRequest(t, app, "POST", "/projects", `{"name": "Alpha", "custom_fields": {"axb": "kept", "tier": "gold"}}`)
+	assert.Equal(t, 201, status, string(body))
+
+	// Options records have cannot be removed; unused ones can
+	status, body = doRequest(t, app, "PUT", "/custom-fields/3", `{"name": "Tier", "options": ["silver"]}`)
+	assert.Equal(t, 409, status)
+	var conflict struct {
+		Options []string `json:"options"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &conflict))
+	assert.Equal(t, []string{"gold"}, conflict.Options)
+	status, _ = doRequest(t, app, "PUT", "/custom-fields/3", `{"name": "Tier", "options": ["gold", "silver"]}`)
+	assert.Equal(t, 200, status)
+
+	// The underscore in a_b does not match other keys
+	status, _ = doRequest(t, app, "DELETE", "/custom-fields/1", "")
+	assert.Equal(t, 200, status)
+	var alpha models.Project
+	database.DB.First(&alpha, 1)
+	assert.Equal(t, "kept", alpha.CustomFields["axb"])
+}
@@ -0,0 +1,34 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestDB points database.DB at an isolated in-memory SQLite database with the schema
+// InitDB migrates, plus the tenant, vendor, asset and purchase order tables the app shares
+// but does not create. Tests seed their own records.
+func newTestDB(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to in-memory database: %v", err)
+	}
+
+	shared := []interface{}{
+		&models.Tenant{},
+		&models.Vendor{},
+		&models.AssetCategory{},
+		&models.Asset{},
+		&models.AssetAssignment{},
+		&models.MaintenanceRecord{},
+		&models.PurchaseOrder{},
+	}
+	if err := database.DB.AutoMigrate(append(shared, database.Models...)...); err != nil {
+		t.Fatalf("Failed to migrate in-memory database: %v", err)
+	}
+}
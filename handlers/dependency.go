@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// taskDependencyRequest adds a predecessor to a task
+type taskDependencyRequest struct {
+	DependsOnID uint `json:"depends_on_id"`
+	LagDays     int  `json:"lag_days"` // days to wait after the predecessor finishes
+}
+
+// GetTaskDependencies returns the tasks a task waits for
+// @Summary Get task dependencies
+// @Description Get the tasks that have to finish before a task can start
+// @Tags dependencies
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.TaskDependency
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/dependencies [get]
+func GetTaskDependencies(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	task, err := findTask(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	var dependencies []models.TaskDependency
+	result := database.DB.Where("task_id = ?", task.ID).Preload("DependsOn").Order("id ASC").Find(&dependencies)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve dependencies",
+		})
+	}
+
+	return c.JSON(dependencies)
+}
+
+// CreateTaskDependency makes a task wait for another task
+// @Summary Add a task dependency
+// @Description Make a task start only after another task of the same project finishes, plus lag_days. Dependencies that would form a cycle are rejected.
+// @Tags dependencies
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param dependency body taskDependencyRequest true "Predecessor and lag"
+// @Success 201 {object} models.TaskDependency
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/dependencies [post]
+func CreateTaskDependency(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	task, err := findTask(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	req := new(taskDependencyRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.LagDays < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Lag days cannot be negative",
+		})
+	}
+	if req.DependsOnID == task.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A task cannot depend on itself",
+		})
+	}
+
+	predecessor, err := findTask(uint(tenantID), req.DependsOnID)
+	if err != nil || predecessor.ProjectID != task.ProjectID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Depends-on task not found in the same project",
+		})
+	}
+
+	var existing int64
+	database.DB.Model(&models.TaskDependency{}).Where("task_id = ? AND depends_on_id = ?", task.ID, predecessor.ID).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Task already depends on this task",
+		})
+	}
+
+	cycle, err := dependsOn(database.DB, predecessor.ID, task.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check dependencies: " + err.Error(),
+		})
+	}
+	if cycle {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Dependency would create a cycle",
+		})
+	}
+
+	dependency := models.TaskDependency{TaskID: task.ID, DependsOnID: predecessor.ID, LagDays: req.LagDays}
+	result := database.DB.Create(&dependency)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create dependency: " + result.Error.Error(),
+		})
+	}
+
+	dependency.DependsOn = &predecessor
+	return c.Status(fiber.StatusCreated).JSON(dependency)
+}
+
+// DeleteTaskDependency removes a dependency between two tasks
+// @Summary Remove a task dependency
+// @Description Stop a task waiting for another task
+// @Tags dependencies
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param depends_on_id path int true "ID of the task it depends on"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/dependencies/{depends_on_id} [delete]
+func DeleteTaskDependency(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	task, err := findTask(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	var dependency models.TaskDependency
+	result := database.DB.Where("task_id = ? AND depends_on_id = ?", task.ID, c.Params("depends_on_id")).First(&dependency)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Dependency not found",
+		})
+	}
+
+	result = database.DB.Delete(&dependency)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete dependency: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Dependency deleted successfully",
+	})
+}
+
+// findTask loads a task, checking that its project belongs to the tenant
+func findTask(tenantID, id uint) (models.Task, error) {
+	var task models.Task
+	result := database.DB.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("tasks.id = ? AND projects.tenant_id = ?", id, tenantID).
+		First(&task)
+	return task, result.Error
+}
+
+// dependsOn reports whether a task waits, directly or through other tasks, for another task
+func dependsOn(db *gorm.DB, taskID, otherID uint) (bool, error) {
+	seen := map[uint]bool{taskID: true}
+	queue := []uint{taskID}
+	for len(queue) > 0 {
+		var predecessors []uint
+		if err := db.Model(&models.TaskDependency{}).Where("task_id IN ?", queue).Pluck("depends_on_id", &predecessors).Error; err != nil {
+			return false, err
+		}
+
+		queue = queue[:0]
+		for _, id := range predecessors {
+			if id == otherID {
+				return true, nil
+			}
+			if !seen[id] {
+				seen[id] = true
+				queue = append(queue, id)
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,723 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/mailer"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Mailer delivers queued emails. Without one, emails stay queued until it is set.
+var Mailer mailer.Sender
+
+// Delivery of queued emails
+const (
+	maxEmailAttempts   = 6
+	emailRetryDelay    = time.Minute // doubled after every failed attempt
+	emailDeliveryBatch = 100
+)
+
+// maintenanceReminderLead is how long before scheduled maintenance its performer is reminded
+const maintenanceReminderLead = 24 * time.Hour
+
+// emailTemplateView is a template as a tenant sees it: its own version, or the built-in one
+type emailTemplateView struct {
+	Event   string `json:"event"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	Custom  bool   `json:"custom"` // the tenant overrides the built-in template
+}
+
+// emailBounceRequest is the payload accepted when recording a bounce
+type emailBounceRequest struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// GetEmailTemplates returns the email template of every event
+// @Summary Get email templates
+// @Description Get the email template used for every event, with custom set where the tenant overrides the built-in one
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Success 200 {array} emailTemplateView
+// @Failure 400 {object} map[string]string
+// @Router /email-templates [get]
+func GetEmailTemplates(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var custom []models.EmailTemplate
+	result := database.DB.Where("tenant_id = ?", tenantID).Find(&custom)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve email templates",
+		})
+	}
+	overrides := make(map[string]models.EmailTemplate, len(custom))
+	for _, template := range custom {
+		overrides[template.Event] = template
+	}
+
+	views := make([]emailTemplateView, 0, len(mailer.Events))
+	for _, event := range mailer.Events {
+		view := emailTemplateView{Event: event, Subject: mailer.Defaults[event].Subject, Body: mailer.Defaults[event].Body}
+		if template, ok := overrides[event]; ok {
+			view.Subject, view.Body, view.Custom = template.Subject, template.Body, true
+		}
+		views = append(views, view)
+	}
+
+	return c.JSON(views)
+}
+
+// UpdateEmailTemplate overrides the built-in email template of an event
+// @Summary Override an email template
+// @Description Set the tenant's own subject and body for an event's emails, in Go text/template syntax. Templates that do not render are rejected.
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param event path string true "Event"
+// @Param template body mailer.Template true "Subject and body"
+// @Success 200 {object} emailTemplateView
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /email-templates/{event} [put]
+func UpdateEmailTemplate(c *fiber.Ctx) error {
+	event := c.Params("event")
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	if _, ok := mailer.Defaults[event]; !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown email event: " + event,
+		})
+	}
+
+	update := new(mailer.Template)
+	if err := c.BodyParser(update); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if strings.TrimSpace(update.Subject) == "" || strings.TrimSpace(update.Body) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Subject and body are required",
+		})
+	}
+	if err := checkEmailTemplate(*update); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template: " + err.Error(),
+		})
+	}
+
+	template := models.EmailTemplate{
+		TenantID: uint(tenantID),
+		Event:    event,
+		Subject:  update.Subject,
+		Body:     update.Body,
+	}
+	result := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "event"}},
+		DoUpdates: clause.AssignmentColumns([]string{"subject", "body", "updated_at"}),
+	}).Create(&template)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save email template",
+		})
+	}
+
+	return c.JSON(emailTemplateView{Event: event, Subject: update.Subject, Body: update.Body, Custom: true})
+}
+
+// DeleteEmailTemplate removes a tenant's own template so the built-in one is used again
+// @Summary Reset an email template
+// @Description Remove the tenant's own template for an event so the built-in one is used again
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param event path string true "Event"
+// @Success 200 {object} emailTemplateView
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /email-templates/{event} [delete]
+func DeleteEmailTemplate(c *fiber.Ctx) error {
+	event := c.Params("event")
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	builtIn, ok := mailer.Defaults[event]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Unknown email event: " + event,
+		})
+	}
+
+	result := database.DB.Where("tenant_id = ? AND event = ?", tenantID, event).Delete(&models.EmailTemplate{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset email template",
+		})
+	}
+
+	return c.JSON(emailTemplateView{Event: event, Subject: builtIn.Subject, Body: builtIn.Body})
+}
+
+// GetEmails returns the tenant's outgoing emails, newest first
+// @Summary Get outgoing emails
+// @Description Get the tenant's queued, sent, failed and bounced emails, newest first
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param status query string false "Only emails with this status (pending, sent, failed or bounced)"
+// @Param limit query int false "Maximum number of emails (default 50, at most 200)"
+// @Success 200 {array} models.Email
+// @Failure 400 {object} map[string]string
+// @Router /emails [get]
+func GetEmails(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultNotificationLimit)
+	if limit < 1 || limit > maxNotificationLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxNotificationLimit),
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ?", tenantID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	emails := []models.Email{}
+	result := query.Order("created_at DESC, id DESC").Limit(limit).Find(&emails)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve emails",
+		})
+	}
+
+	return c.JSON(emails)
+}
+
+// GetEmailBounces returns the addresses no more email is sent to
+// @Summary Get email bounces
+// @Description Get the addresses that rejected mail. Nothing more is sent to them until the bounce is removed.
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Success 200 {array} models.EmailBounce
+// @Failure 400 {object} map[string]string
+// @Router /email-bounces [get]
+func GetEmailBounces(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	bounces := []models.EmailBounce{}
+	result := database.DB.Where("tenant_id = ?", tenantID).Order("created_at DESC, id DESC").Find(&bounces)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve email bounces",
+		})
+	}
+
+	return c.JSON(bounces)
+}
+
+// CreateEmailBounce records a bounce reported after delivery, e.g. by the mail provider
+// @Summary Record an email bounce
+// @Description Record that an address rejected mail. Its queued emails are dropped and nothing more is sent to it.
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param bounce body emailBounceRequest true "Address and reason"
+// @Success 201 {object} models.EmailBounce
+// @Failure 400 {object} map[string]string
+// @Router /email-bounces [post]
+func CreateEmailBounce(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	request := new(emailBounceRequest)
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	address := strings.ToLower(strings.TrimSpace(request.Address))
+	if !mailer.ValidAddress(address) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A valid email address is required",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return recordBounce(tx, uint(tenantID), address, request.Reason)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record email bounce",
+		})
+	}
+
+	var bounce models.EmailBounce
+	database.DB.Where("tenant_id = ? AND address = ?", tenantID, address).First(&bounce)
+
+	return c.Status(fiber.StatusCreated).JSON(bounce)
+}
+
+// DeleteEmailBounce removes a bounce so the address is emailed again
+// @Summary Remove an email bounce
+// @Description Remove a bounce, e.g. after the address was fixed, so email is sent to it again
+// @Tags emails
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Bounce ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /email-bounces/{id} [delete]
+func DeleteEmailBounce(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).Delete(&models.EmailBounce{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove email bounce",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Email bounce not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email bounce removed successfully",
+	})
+}
+
+// SendNotificationEmails queues the emails of new notifications and of the daily digests, then
+// delivers whatever is due. It is run periodically by the scheduler.
+func SendNotificationEmails(now time.Time) error {
+	return errors.Join(
+		queueNotificationEmails(now),
+		queueNotificationDigests(now),
+		DeliverEmails(now),
+	)
+}
+
+// DeliverEmails sends the queued emails whose attempt is due. Temporary failures are retried
+// with a doubling delay until maxEmailAttempts; bounces stop all email to the address.
+func DeliverEmails(now time.Time) error {
+	if Mailer == nil {
+		return nil
+	}
+
+	var due []models.Email
+	result := database.DB.Where("status = ? AND next_attempt_at <= ?", models.EmailStatusPending, now).
+		Order("id").Limit(emailDeliveryBatch).Find(&due)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var errs []error
+	for i := range due {
+		email := &due[i]
+		if email.Status != models.EmailStatusPending {
+			continue // bounced along with an earlier email to the same address
+		}
+
+		email.Attempts++
+		sendErr := Mailer.Send(mailer.Message{To: email.To, Subject: email.Subject, Body: email.Body})
+		switch {
+		case sendErr == nil:
+			email.Status = models.EmailStatusSent
+			email.SentAt = &now
+			email.NextAttemptAt = nil
+			email.LastError = ""
+		case errors.Is(sendErr, mailer.ErrBounced):
+			email.Status = models.EmailStatusBounced
+			email.NextAttemptAt = nil
+			email.LastError = sendErr.Error()
+		case email.Attempts >= maxEmailAttempts:
+			email.Status = models.EmailStatusFailed
+			email.NextAttemptAt = nil
+			email.LastError = sendErr.Error()
+		default:
+			next := now.Add(emailRetryDelay << (email.Attempts - 1))
+			email.NextAttemptAt = &next
+			email.LastError = sendErr.Error()
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(email).Error; err != nil {
+				return err
+			}
+			if email.Status != models.EmailStatusBounced {
+				return nil
+			}
+			return recordBounce(tx, email.TenantID, email.To, sendErr.Error())
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("email %d: %w", email.ID, err))
+			continue
+		}
+
+		if email.Status == models.EmailStatusBounced {
+			for j := i + 1; j < len(due); j++ {
+				if due[j].TenantID == email.TenantID && due[j].To == email.To {
+					due[j].Status = models.EmailStatusBounced
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NotifyMaintenanceDue reminds the performers of scheduled maintenance that is due within
+// maintenanceReminderLead, once per scheduled date. It is run periodically by the scheduler.
+func NotifyMaintenanceDue(now time.Time) error {
+	if !database.DB.Migrator().HasTable(&models.MaintenanceRecord{}) {
+		return nil
+	}
+
+	var due []models.MaintenanceRecord
+	result := database.DB.Preload("Asset").
+		Where("status = ? AND due_notified_at IS NULL AND performed_by_id IS NOT NULL AND scheduled_date <= ?",
+			models.MaintenanceStatusScheduled, now.Add(maintenanceReminderLead)).
+		Find(&due)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var errs []error
+	for i := range due {
+		record := &due[i]
+		asset := "an asset"
+		if record.Asset != nil {
+			asset = record.Asset.Name
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			notification := models.Notification{
+				TenantID:   record.TenantID,
+				Type:       models.NotificationTypeMaintenanceDue,
+				EntityType: "maintenance_record",
+				EntityID:   record.ID,
+				Message:    fmt.Sprintf("%s maintenance of %s is due on %s", record.MaintenanceType, asset, record.ScheduledDate.Format("2006-01-02")),
+			}
+			if _, err := notify(tx, notification, []uint{*record.PerformedByID}); err != nil {
+				return err
+			}
+			return tx.Model(record).Update("due_notified_at", now).Error
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("maintenance record %d: %w", record.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// queueNotificationEmails queues an email for every notification to be emailed immediately
+func queueNotificationEmails(now time.Time) error {
+	var pending []models.Notification
+	result := database.DB.Preload("Person").Preload("Actor").
+		Where("email = ? AND digest = ? AND emailed_at IS NULL", true, false).
+		Order("id").Find(&pending)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	tenants := make(map[uint]string)
+	var errs []error
+	for i := range pending {
+		notification := &pending[i]
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if notification.Person != nil {
+				event := notificationEmailEvent(notification.Type)
+				data := mailer.Data{
+					Recipient:  notification.Person.Name,
+					Tenant:     tenantName(tx, tenants, notification.TenantID),
+					Message:    notification.Message,
+					EntityType: notification.EntityType,
+					EntityID:   notification.EntityID,
+					Date:       notification.CreatedAt,
+				}
+				if notification.Actor != nil {
+					data.Actor = notification.Actor.Name
+				}
+				if err := queueEmail(tx, notification.TenantID, &notification.PersonID, event, notification.Person.Email, data, now); err != nil {
+					return err
+				}
+			}
+			return tx.Model(notification).Update("emailed_at", now).Error
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification %d: %w", notification.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// queueNotificationDigests queues one digest email per person with the notifications they
+// want in the daily digest, once the day they happened on is over
+func queueNotificationDigests(now time.Time) error {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var pending []models.Notification
+	result := database.DB.Preload("Person").Preload("Actor").
+		Where("email = ? AND digest = ? AND emailed_at IS NULL AND created_at < ?", true, true, today).
+		Order("person_id, created_at, id").Find(&pending)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	tenants := make(map[uint]string)
+	var errs []error
+	for start := 0; start < len(pending); {
+		end := start
+		for end < len(pending) && pending[end].PersonID == pending[start].PersonID {
+			end++
+		}
+		batch := pending[start:end]
+		start = end
+
+		first := batch[0]
+		ids := make([]uint, 0, len(batch))
+		items := make([]mailer.Item, 0, len(batch))
+		for _, notification := range batch {
+			ids = append(ids, notification.ID)
+			item := mailer.Item{Type: string(notification.Type), Message: notification.Message, Date: notification.CreatedAt}
+			if notification.Actor != nil {
+				item.Actor = notification.Actor.Name
+			}
+			items = append(items, item)
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if first.Person != nil {
+				data := mailer.Data{
+					Recipient: first.Person.Name,
+					Tenant:    tenantName(tx, tenants, first.TenantID),
+					Date:      first.CreatedAt,
+					Items:     items,
+				}
+				if err := queueEmail(tx, first.TenantID, &first.PersonID, mailer.EventDigest, first.Person.Email, data, now); err != nil {
+					return err
+				}
+			}
+			return tx.Model(&models.Notification{}).Where("id IN ?", ids).Update("emailed_at", now).Error
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("digest of person %d: %w", first.PersonID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// queueEmail renders the tenant's template of the event and queues the email for delivery.
+// Nothing is queued for missing, invalid or bounced addresses.
+func queueEmail(tx *gorm.DB, tenantID uint, personID *uint, event, address string, data mailer.Data, now time.Time) error {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if !mailer.ValidAddress(address) {
+		return nil
+	}
+
+	var bounces int64
+	if err := tx.Model(&models.EmailBounce{}).Where("tenant_id = ? AND address = ?", tenantID, address).Count(&bounces).Error; err != nil {
+		return err
+	}
+	if bounces > 0 {
+		return nil
+	}
+
+	template, err := tenantEmailTemplate(tx, tenantID, event)
+	if err != nil {
+		return err
+	}
+	msg, err := template.Render(address, data)
+	if err != nil {
+		// A tenant template that no longer renders falls back to the built-in one
+		log.Printf("Email template %s of tenant %d failed to render: %v", event, tenantID, err)
+		if msg, err = mailer.Defaults[event].Render(address, data); err != nil {
+			return err
+		}
+	}
+
+	email := models.Email{
+		TenantID:      tenantID,
+		PersonID:      personID,
+		Event:         event,
+		To:            address,
+		Subject:       msg.Subject,
+		Body:          msg.Body,
+		Status:        models.EmailStatusPending,
+		NextAttemptAt: &now,
+	}
+	return tx.Create(&email).Error
+}
+
+// recordBounce stops email to an address: the bounce is recorded once and the address's
+// queued emails are dropped
+func recordBounce(tx *gorm.DB, tenantID uint, address, reason string) error {
+	bounce := models.EmailBounce{TenantID: tenantID, Address: address, Reason: reason}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&bounce).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.Email{}).
+		Where("tenant_id = ? AND to_address = ? AND status = ?", tenantID, address, models.EmailStatusPending).
+		Updates(map[string]interface{}{"status": models.EmailStatusBounced, "next_attempt_at": nil}).Error
+}
+
+// tenantEmailTemplate returns the tenant's own template of the event, or the built-in one
+func tenantEmailTemplate(tx *gorm.DB, tenantID uint, event string) (mailer.Template, error) {
+	var custom []models.EmailTemplate
+	if err := tx.Where("tenant_id = ? AND event = ?", tenantID, event).Limit(1).Find(&custom).Error; err != nil {
+		return mailer.Template{}, err
+	}
+	if len(custom) == 0 {
+		return mailer.Defaults[event], nil
+	}
+	return mailer.Template{Subject: custom[0].Subject, Body: custom[0].Body}, nil
+}
+
+// notificationEmailEvent returns the template event of a notification type
+func notificationEmailEvent(t models.NotificationType) string {
+	if _, ok := mailer.Defaults[string(t)]; ok {
+		return string(t)
+	}
+	return mailer.EventNotification
+}
+
+// tenantName returns the name of a tenant, looked up once per run
+func tenantName(tx *gorm.DB, names map[uint]string, tenantID uint) string {
+	if name, ok := names[tenantID]; ok {
+		return name
+	}
+
+	var tenant models.Tenant
+	tx.Select("name").Where("id = ?", tenantID).Limit(1).Find(&tenant)
+	names[tenantID] = tenant.Name
+	return tenant.Name
+}
+
+// checkEmailTemplate reports whether a template parses and renders with sample data
+func checkEmailTemplate(t mailer.Template) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+
+	sample := mailer.Data{
+		Recipient:  "Alice",
+		Tenant:     "Acme",
+		Actor:      "Bob",
+		Message:    "Something happened",
+		EntityType: "task",
+		EntityID:   1,
+		Date:       time.Now(),
+		Items:      []mailer.Item{{Type: "mention", Message: "Something happened", Actor: "Bob", Date: time.Now()}},
+	}
+	_, err := t.Render("alice@example.com", sample)
+	return err
+}
@@ -0,0 +1,233 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/mailer"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupEmailDB sets up the notification database and an outbox that records sent emails
+func setupEmailDB(t *testing.T) *mailer.Outbox {
+	setupNotificationDB(t)
+	outbox := &mailer.Outbox{Reject: map[string]bool{}}
+	handlers.Mailer = outbox
+	t.Cleanup(func() { handlers.Mailer = nil })
+	return outbox
+}
+
+func setupEmailApp() *fiber.App {
+	app := setupNotificationApp()
+	app.Get("/email-templates", handlers.GetEmailTemplates)
+	app.Put("/email-templates/:event", handlers.UpdateEmailTemplate)
+	app.Delete("/email-templates/:event", handlers.DeleteEmailTemplate)
+	app.Get("/emails", handlers.GetEmails)
+	app.Get("/email-bounces", handlers.GetEmailBounces)
+	app.Post("/email-bounces", handlers.CreateEmailBounce)
+	app.Delete("/email-bounces/:id", handlers.DeleteEmailBounce)
+	return app
+}
+
+// flakySender fails a number of times before it delivers
+type flakySender struct {
+	failures int
+	sent     int
+}
+
+func (s *flakySender) Send(msg mailer.Message) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+	s.sent++
+	return nil
+}
+
+func TestNotificationEmailsUseTenantTemplates(t *testing.T) {
+	outbox := setupEmailDB(t)
+	app := setupEmailApp()
+
+	status, body := doRequest(t, app, "PUT", "/email-templates/task_assigned", `{"subject": "{{.Tenant}}: new work", "body": "Hi {{.Recipient}}. {{.Message}}"}`)
+	assert.Equal(t, 200, status, string(body))
+	status, _ = doRequest(t, app, "PUT", "/email-templates/task_assigned", `{"subject": "{{.Nope}}", "body": "x"}`)
+	assert.Equal(t, 400, status, "templates must render")
+	status, _ = doRequest(t, app, "PUT", "/email-templates/birthday", `{"subject": "x", "body": "x"}`)
+	assert.Equal(t, 404, status)
+
+	status, body = doRequest(t, app, "GET", "/email-templates", "")
+	assert.Equal(t, 200, status)
+	var templates []struct {
+		Event   string `json:"event"`
+		Subject string `json:"subject"`
+		Custom  bool   `json:"custom"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &templates))
+	assert.Len(t, templates, len(mailer.Events))
+	assert.Equal(t, "task_assigned", templates[0].Event)
+	assert.True(t, templates[0].Custom)
+	assert.False(t, templates[1].Custom)
+
+	// Bob wants assignments by email right away, Carol in the digest
+	doRequestAs(t, app, "2", "PUT", "/notifications/preferences", `[{"type": "task_assigned", "in_app": true, "email": true}]`)
+	doRequestAs(t, app, "3", "PUT", "/notifications/preferences", `[{"type": "task_assigned", "in_app": true, "email": true, "delivery": "digest"}]`)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch", "assigned_to_id": 2}`)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Docs", "assigned_to_id": 3}`)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Tests", "assigned_to_id": 3}`)
+
+	now := time.Now()
+	assert.NoError(t, handlers.SendNotificationEmails(now))
+	sent := outbox.Messages()
+	assert.Len(t, sent, 1, "digests wait for the day to end")
+	assert.Equal(t, "bob@example.com", sent[0].To)
+	assert.Equal(t, "Test Tenant: new work", sent[0].Subject)
+	assert.Equal(t, `Hi Bob. You were assigned task #1 "Launch"`, sent[0].Body)
+
+	// Nothing is sent twice
+	assert.NoError(t, handlers.SendNotificationEmails(now))
+	assert.Len(t, outbox.Messages(), 1)
+
+	assert.NoError(t, handlers.SendNotificationEmails(now.AddDate(0, 0, 1)))
+	sent = outbox.Messages()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "carol@example.com", sent[1].To)
+	assert.Equal(t, "[Test Tenant] Your daily summary: 2 notifications", sent[1].Subject)
+	assert.Contains(t, sent[1].Body, `- You were assigned task #2 "Docs" (Alice)`)
+	assert.Contains(t, sent[1].Body, `- You were assigned task #3 "Tests" (Alice)`)
+
+	status, body = doRequest(t, app, "GET", "/emails?status=sent", "")
+	assert.Equal(t, 200, status)
+	var emails []models.Email
+	assert.NoError(t, json.Unmarshal(body, &emails))
+	assert.Len(t, emails, 2)
+	assert.Equal(t, mailer.EventDigest, emails[0].Event)
+
+	status, body = doRequest(t, app, "DELETE", "/email-templates/task_assigned", "")
+	assert.Equal(t, 200, status)
+	assert.JSONEq(t, `{"event": "task_assigned", "subject": "`+mailer.Defaults[mailer.EventTaskAssigned].Subject+`", "body": `+
+		string(mustJSON(t, mailer.Defaults[mailer.EventTaskAssigned].Body))+`, "custom": false}`, string(body))
+}
+
+func TestEmailDeliveryRetriesAndBounces(t *testing.T) {
+	outbox := setupEmailDB(t)
+	app := setupEmailApp()
+
+	doRequestAs(t, app, "2", "PUT", "/notifications/preferences", `[{"type": "task_assigned", "in_app": true, "email": true}]`)
+	doRequestAs(t, app, "3", "PUT", "/notifications/preferences", `[{"type": "task_assigned", "in_app": true, "email": true}]`)
+
+	// Temporary failures are retried with a doubling delay
+	flaky := &flakySender{failures: 2}
+	handlers.Mailer = flaky
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch", "assigned_to_id": 2}`)
+	now := time.Now()
+	assert.NoError(t, handlers.SendNotificationEmails(now))
+	var email models.Email
+	database.DB.First(&email)
+	assert.Equal(t, models.EmailStatusPending, email.Status)
+	assert.Equal(t, 1, email.Attempts)
+	assert.Equal(t, "connection refused", email.LastError)
+	assert.WithinDuration(t, now.Add(time.Minute), *email.NextAttemptAt, time.Second)
+
+	assert.NoError(t, handlers.DeliverEmails(now.Add(30*time.Second)))
+	assert.NoError(t, handlers.DeliverEmails(now.Add(time.Minute)))
+	database.DB.First(&email)
+	assert.Equal(t, 2, email.Attempts)
+	assert.WithinDuration(t, now.Add(3*time.Minute), *email.NextAttemptAt, time.Second)
+
+	assert.NoError(t, handlers.DeliverEmails(now.Add(3*time.Minute)))
+	database.DB.First(&email)
+	assert.Equal(t, models.EmailStatusSent, email.Status)
+	assert.Equal(t, 1, flaky.sent)
+
+	// A bounce stops all email to the address until it is removed
+	handlers.Mailer = outbox
+	outbox.Reject["carol@example.com"] = true
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Docs", "assigned_to_id": 3}`)
+	assert.NoError(t, handlers.SendNotificationEmails(now))
+	status, body := doRequest(t, app, "GET", "/email-bounces", "")
+	assert.Equal(t, 200, status)
+	var bounces []models.EmailBounce
+	assert.NoError(t, json.Unmarshal(body, &bounces))
+	assert.Len(t, bounces, 1)
+	assert.Equal(t, "carol@example.com", bounces[0].Address)
+
+	delete(outbox.Reject, "carol@example.com")
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Tests", "assigned_to_id": 3}`)
+	assert.NoError(t, handlers.SendNotificationEmails(now))
+	assert.Empty(t, outbox.Messages(), "bounced addresses are not emailed")
+
+	status, _ = doRequest(t, app, "DELETE", "/email-bounces/1", "")
+	assert.Equal(t, 200, status)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Ship", "assigned_to_id": 3}`)
+	assert.NoError(t, handlers.SendNotificationEmails(now))
+	assert.Len(t, outbox.Messages(), 1)
+
+	// Bounces reported by the provider drop the address's queued emails
+	status, _ = doRequest(t, app, "POST", "/email-bounces", `{"address": "not an address"}`)
+	assert.Equal(t, 400, status)
+	handlers.Mailer = nil // emails stay queued without a mailer
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Party", "assigned_to_id": 2}`)
+	assert.NoError(t, handlers.SendNotificationEmails(now))
+	status, _ = doRequest(t, app, "POST", "/email-bounces", `{"address": "Bob@Example.com", "reason": "mailbox full"}`)
+	assert.Equal(t, 201, status)
+	_, body = doRequest(t, app, "GET", "/emails?status=bounced", "")
+	var bounced []models.Email
+	assert.NoError(t, json.Unmarshal(body, &bounced))
+	assert.Len(t, bounced, 2)
+	assert.Equal(t, "bob@example.com", bounced[0].To)
+}
+
+func TestEmailDeliveryGivesUp(t *testing.T) {
+	setupEmailDB(t)
+	app := setupEmailApp()
+
+	handlers.Mailer = &flakySender{failures: 100}
+	doRequestAs(t, app, "2", "PUT", "/notifications/preferences", `[{"type": "task_assigned", "in_app": false, "email": true}]`)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch", "assigned_to_id": 2}`)
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, handlers.SendNotificationEmails(now))
+		now = now.Add(time.Hour)
+	}
+
+	var email models.Email
+	database.DB.First(&email)
+	assert.Equal(t, models.EmailStatusFailed, email.Status)
+	assert.Equal(t, 6, email.Attempts)
+	assert.Nil(t, email.NextAttemptAt)
+}
+
+func TestMaintenanceDueReminder(t *testing.T) {
+	setupEmailDB(t)
+	app := setupEmailApp()
+
+	now := time.Now()
+	performer := uint(2)
+	database.DB.Create(&models.AssetCategory{TenantID: 1, Name: "Vehicles"})
+	database.DB.Create(&models.Asset{TenantID: 1, Name: "Van", CategoryID: 1})
+	database.DB.Create(&models.MaintenanceRecord{TenantID: 1, AssetID: 1, MaintenanceType: models.MaintenanceTypePreventive,
+		Status: models.MaintenanceStatusScheduled, ScheduledDate: now.Add(12 * time.Hour), PerformedByID: &performer, Description: "Oil"})
+	database.DB.Create(&models.MaintenanceRecord{TenantID: 1, AssetID: 1, MaintenanceType: models.MaintenanceTypeInspection,
+		Status: models.MaintenanceStatusScheduled, ScheduledDate: now.Add(72 * time.Hour), PerformedByID: &performer, Description: "MOT"})
+
+	assert.NoError(t, handlers.NotifyMaintenanceDue(now))
+	assert.NoError(t, handlers.NotifyMaintenanceDue(now))
+	assert.Equal(t, []models.NotificationType{models.NotificationTypeMaintenanceDue}, notificationTypes(t, app, "2"))
+
+	var record models.MaintenanceRecord
+	database.DB.First(&record, 1)
+	assert.NotNil(t, record.DueNotifiedAt)
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return data
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/workflow"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Groupings of the estimate accuracy report
+const (
+	estimateGroupPerson  = "person"
+	estimateGroupProject = "project"
+	estimateGroupType    = "type"
+)
+
+// estimateAccuracyGroup compares the estimates of a group of tasks with the time logged on them
+type estimateAccuracyGroup struct {
+	ID              *uint    `json:"id"`   // person or project, unset for task types and unassigned tasks
+	Name            string   `json:"name"` // empty for tasks without a type
+	Tasks           int      `json:"tasks"`
+	EstimatedHours  float64  `json:"estimated_hours"`
+	ActualHours     float64  `json:"actual_hours"`
+	VarianceHours   float64  `json:"variance_hours"`   // positive when more time was spent than estimated
+	VariancePercent *float64 `json:"variance_percent"` // of the estimated hours
+	AccuracyPercent float64  `json:"accuracy_percent"` // average per task of 100 less the size of its variance, at least 0
+	Overruns        int      `json:"overruns"`
+}
+
+// estimateOverrun is a task whose logged hours exceed its estimate by the overrun percentage
+type estimateOverrun struct {
+	TaskID         uint    `json:"task_id"`
+	Title          string  `json:"title"`
+	ProjectID      uint    `json:"project_id"`
+	AssignedToID   *uint   `json:"assigned_to_id"`
+	Type           string  `json:"type"`
+	EstimatedHours float64 `json:"estimated_hours"`
+	ActualHours    float64 `json:"actual_hours"`
+	OverrunPercent float64 `json:"overrun_percent"`
+}
+
+// estimateAccuracyReport is how well a tenant's tasks were estimated
+type estimateAccuracyReport struct {
+	GroupBy        string                  `json:"group_by"`
+	OverrunPercent float64                 `json:"overrun_percent"`
+	Groups         []estimateAccuracyGroup `json:"groups"`
+	Overruns       []estimateOverrun       `json:"overruns"`
+}
+
+// GetEstimateSettings returns the tenant's estimate settings
+// @Summary Get estimate settings
+// @Description Get the percentage by which the hours logged on a task may exceed its estimate before it counts as an overrun
+// @Tags estimates
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Success 200 {object} models.EstimateSettings
+// @Failure 400 {object} map[string]string
+// @Router /estimate-settings [get]
+func GetEstimateSettings(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	return c.JSON(tenantEstimateSettings(database.DB, uint(tenantID)))
+}
+
+// UpdateEstimateSettings sets the tenant's estimate settings
+// @Summary Set estimate settings
+// @Description Set the percentage by which the hours logged on a task may exceed its estimate before it counts as an overrun
+// @Tags estimates
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param settings body models.EstimateSettings true "Estimate settings"
+// @Success 200 {object} models.EstimateSettings
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /estimate-settings [put]
+func UpdateEstimateSettings(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	// Fields not sent keep their current value
+	settings := tenantEstimateSettings(database.DB, uint(tenantID))
+	existingID := settings.ID
+	if err := c.BodyParser(&settings); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+	settings.ID = existingID
+	settings.TenantID = uint(tenantID)
+
+	if settings.OverrunPercent < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Overrun percent must not be negative",
+		})
+	}
+
+	result := database.DB.Save(&settings)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save estimate settings: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(settings)
+}
+
+// GetEstimateAccuracy reports how the estimates of tasks compare with the time logged on them
+// @Summary Get estimate accuracy
+// @Description Compare the original estimates of completed tasks with the hours logged on them, grouped by assignee, project or task type, and list the tasks that overran their estimate by more than the tenant's overrun percentage
+// @Tags estimates
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param group_by query string false "person (default), project or type"
+// @Param project_id query int false "Only tasks of this project"
+// @Param include_open query bool false "Include tasks that are not completed"
+// @Success 200 {object} estimateAccuracyReport
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /estimate-accuracy [get]
+func GetEstimateAccuracy(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	groupBy := c.Query("group_by", estimateGroupPerson)
+	switch groupBy {
+	case estimateGroupPerson, estimateGroupProject, estimateGroupType:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group_by: " + groupBy,
+		})
+	}
+
+	query := database.DB.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("projects.tenant_id = ? AND projects.deleted_at IS NULL AND tasks.estimated_hours > 0", tenantID)
+	if projectID := c.Query("project_id"); projectID != "" {
+		query = query.Where("tasks.project_id = ?", projectID)
+	}
+	var tasks []models.Task
+	if err := query.Order("tasks.id ASC").Find(&tasks).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve tasks: " + err.Error(),
+		})
+	}
+
+	if !c.QueryBool("include_open") {
+		tasks, err = doneTasks(database.DB, uint(tenantID), tasks)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load workflow: " + err.Error(),
+			})
+		}
+	}
+
+	settings := tenantEstimateSettings(database.DB, uint(tenantID))
+	report, err := estimateAccuracy(database.DB, uint(tenantID), tasks, groupBy, &settings)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute estimate accuracy: " + err.Error(),
+		})
+	}
+
+	return c.JSON(report)
+}
+
+// tenantEstimateSettings returns the tenant's estimate settings, or the defaults if it has none
+func tenantEstimateSettings(db *gorm.DB, tenantID uint) models.EstimateSettings {
+	var settings models.EstimateSettings
+	if err := db.Where("tenant_id = ?", tenantID).First(&settings).Error; err != nil {
+		return models.DefaultEstimateSettings(tenantID)
+	}
+	return settings
+}
+
+// doneTasks returns the tasks that are completed or in a final state of their project's workflow
+func doneTasks(db *gorm.DB, tenantID uint, tasks []models.Task) ([]models.Task, error) {
+	workflows := make(map[uint]workflow.Definition)
+	done := tasks[:0]
+	for _, task := range tasks {
+		def, ok := workflows[task.ProjectID]
+		if !ok {
+			var err error
+			def, _, err = resolveWorkflowIn(db, tenantID, &task.ProjectID, models.WorkflowEntityTask)
+			if err != nil {
+				return nil, err
+			}
+			workflows[task.ProjectID] = def
+		}
+		if task.Status == models.TaskStatusCompleted || def.IsFinal(string(task.Status)) {
+			done = append(done, task)
+		}
+	}
+	return done, nil
+}
+
+// estimateAccuracy compares the estimates of tasks with the hours logged on them, by group
+func estimateAccuracy(db *gorm.DB, tenantID uint, tasks []models.Task, groupBy string, settings *models.EstimateSettings) (*estimateAccuracyReport, error) {
+	report := &estimateAccuracyReport{
+		GroupBy:        groupBy,
+		OverrunPercent: settings.OverrunPercent,
+		Groups:         []estimateAccuracyGroup{},
+		Overruns:       []estimateOverrun{},
+	}
+	if len(tasks) == 0 {
+		return report, nil
+	}
+
+	taskIDs := make([]uint, len(tasks))
+	for i := range tasks {
+		taskIDs[i] = tasks[i].ID
+	}
+	var logged []struct {
+		TaskID uint
+		Hours  float64
+	}
+	result := db.Model(&models.TimeTracking{}).Select("task_id, SUM(hours) AS hours").
+		Where("task_id IN ?", taskIDs).Group("task_id").Scan(&logged)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	actual := make(map[uint]float64, len(logged))
+	for _, row := range logged {
+		actual[row.TaskID] = row.Hours
+	}
+
+	// Names of the people and projects tasks are grouped by
+	names := make(map[uint]string)
+	switch groupBy {
+	case estimateGroupPerson:
+		var people []models.Person
+		if err := db.Unscoped().Where("tenant_id = ?", tenantID).Find(&people).Error; err != nil {
+			return nil, err
+		}
+		for _, person := range people {
+			names[person.ID] = person.Name
+		}
+	case estimateGroupProject:
+		var projects []models.Project
+		if err := db.Where("tenant_id = ?", tenantID).Find(&projects).Error; err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			names[project.ID] = project.Name
+		}
+	}
+
+	groups := make(map[string]*estimateAccuracyGroup)
+	var keys []string
+	for _, task := range tasks {
+		var id *uint
+		name := task.Type
+		switch groupBy {
+		case estimateGroupPerson:
+			id, name = task.AssignedToID, "Unassigned"
+			if id != nil {
+				name = names[*id]
+			}
+		case estimateGroupProject:
+			projectID := task.ProjectID
+			id, name = &projectID, names[projectID]
+		}
+
+		key := name
+		if id != nil {
+			key = strconv.FormatUint(uint64(*id), 10)
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &estimateAccuracyGroup{ID: id, Name: name}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+
+		estimated, spent := *task.EstimatedHours, actual[task.ID]
+		group.Tasks++
+		group.EstimatedHours += estimated
+		group.ActualHours += spent
+		group.AccuracyPercent += math.Max(100-math.Abs(spent-estimated)/estimated*100, 0)
+
+		if settings.Overruns(estimated, spent) {
+			group.Overruns++
+			report.Overruns = append(report.Overruns, estimateOverrun{
+				TaskID:         task.ID,
+				Title:          task.Title,
+				ProjectID:      task.ProjectID,
+				AssignedToID:   task.AssignedToID,
+				Type:           task.Type,
+				EstimatedHours: estimated,
+				ActualHours:    spent,
+				OverrunPercent: (spent/estimated - 1) * 100,
+			})
+		}
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		group.VarianceHours = group.ActualHours - group.EstimatedHours
+		if group.EstimatedHours > 0 {
+			percent := group.VarianceHours / group.EstimatedHours * 100
+			group.VariancePercent = &percent
+		}
+		group.AccuracyPercent /= float64(group.Tasks)
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Name < report.Groups[j].Name
+	})
+	sort.SliceStable(report.Overruns, func(i, j int) bool {
+		return report.Overruns[i].OverrunPercent > report.Overruns[j].OverrunPercent
+	})
+
+	return report, nil
+}
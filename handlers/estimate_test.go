@@ -0,0 +1,181 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupEstimateDB sets up an isolated in-memory SQLite database with estimated tasks
+func setupEstimateDB(t *testing.T) {
+	newTestDB(t)
+
+	ten, eight, four := 10.0, 8.0, 4.0
+	alice, bob := uint(1), uint(2)
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website", StartDate: time.Now()})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "developer"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Bob", Email: "bob@example.com", Role: "developer"})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Fix login", Type: "bug", Status: models.TaskStatusCompleted, AssignedToID: &alice, EstimatedHours: &ten})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Search", Type: "feature", Status: models.TaskStatusCompleted, AssignedToID: &alice, EstimatedHours: &eight})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Export", Type: "feature", Status: models.TaskStatusTodo, AssignedToID: &bob, EstimatedHours: &four})
+}
+
+func setupEstimateApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Patch("/tasks/:id", handlers.UpdateTask)
+	app.Post("/tasks/:id/time-entries", handlers.CreateTaskTimeEntry)
+	app.Delete("/time-entries/:id", handlers.DeleteTimeEntry)
+	app.Put("/estimate-settings", handlers.UpdateEstimateSettings)
+	app.Get("/estimate-accuracy", handlers.GetEstimateAccuracy)
+	return app
+}
+
+func TestTimeEntriesReduceRemainingEstimate(t *testing.T) {
+	setupEstimateDB(t)
+	app := setupEstimateApp()
+
+	remaining := func() *float64 {
+		var task models.Task
+		database.DB.First(&task, 1)
+		return task.RemainingHours
+	}
+	overrunAlerts := func() int64 {
+		var count int64
+		database.DB.Model(&models.Notification{}).Where("type = ?", models.NotificationTypeEstimateOverrun).Count(&count)
+		return count
+	}
+
+	status, body := doRequest(t, app, "POST", "/tasks/1/time-entries", `{"person_id": 1, "hours": 6}`)
+	assert.Equal(t, 201, status, string(body))
+	if assert.NotNil(t, remaining()) {
+		assert.Equal(t, 4.0, *remaining())
+	}
+	assert.Equal(t, int64(0), overrunAlerts())
+
+	// 13 hours is more than 20% over the 10 hour estimate: Bob who logged and Alice who is assigned are alerted
+	status, _ = doRequest(t, app, "POST", "/tasks/1/time-entries", `{"person_id": 2, "hours": 7}`)
+	assert.Equal(t, 201, status)
+	assert.Equal(t, 0.0, *remaining())
+	assert.Equal(t, int64(2), overrunAlerts())
+
+	status, _ = doRequest(t, app, "POST", "/tasks/1/time-entries", `{"person_id": 1, "hours": 1}`)
+	assert.Equal(t, 201, status)
+	assert.Equal(t, int64(2), overrunAlerts(), "a task is alerted once")
+
+	status, _ = doRequest(t, app, "DELETE", "/time-entries/3", "")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1.0, *remaining())
+
+	status, _ = doRequest(t, app, "POST", "/tasks/1/time-entries", `{"hours": 2}`)
+	assert.Equal(t, 400, status, "person is required without X-Person-ID")
+}
+
+func TestUpdateTaskKeepsFieldsNotSent(t *testing.T) {
+	setupEstimateDB(t)
+	app := setupEstimateApp()
+
+	status, _ := doRequest(t, app, "PATCH", "/tasks/3", `{"estimated_hours": 4, "story_points": 2}`)
+	assert.Equal(t, 200, status)
+	status, _ = doRequest(t, app, "POST", "/tasks/3/time-entries", `{"person_id": 2, "hours": 1}`)
+	assert.Equal(t, 201, status)
+
+	status, body := doRequest(t, app, "PATCH", "/tasks/3", `{"status": "in_progress"}`)
+	assert.Equal(t, 200, status, string(body))
+
+	var task models.Task
+	database.DB.First(&task, 3)
+	assert.Equal(t, models.TaskStatusInProgress, task.Status)
+	assert.Equal(t, "Export", task.Title)
+	assert.Equal(t, "feature", task.Type)
+	if assert.NotNil(t, task.EstimatedHours) && assert.NotNil(t, task.RemainingHours) && assert.NotNil(t, task.StoryPoints) {
+		assert.Equal(t, 4.0, *task.EstimatedHours)
+		assert.Equal(t, 3.0, *task.RemainingHours)
+		assert.Equal(t, 2.0, *task.StoryPoints)
+	}
+	if assert.NotNil(t, task.AssignedToID) {
+		assert.Equal(t, uint(2), *task.AssignedToID)
+	}
+
+	// A new estimate less the hours logged replaces the remaining estimate; a title sent must not be empty
+	status, _ = doRequest(t, app, "PATCH", "/tasks/3", `{"estimated_hours": 6}`)
+	assert.Equal(t, 200, status)
+	database.DB.First(&task, 3)
+	assert.Equal(t, 5.0, *task.RemainingHours)
+	status, _ = doRequest(t, app, "PATCH", "/tasks/3", `{"title": ""}`)
+	assert.Equal(t, 400, status)
+}
+
+func TestEstimateAccuracy(t *testing.T) {
+	setupEstimateDB(t)
+	app := setupEstimateApp()
+
+	day := time.Now()
+	database.DB.Create(&models.TimeTracking{TaskID: 1, PersonID: 1, Hours: 13, Date: day})
+	database.DB.Create(&models.TimeTracking{TaskID: 2, PersonID: 1, Hours: 6, Date: day})
+	database.DB.Create(&models.TimeTracking{TaskID: 3, PersonID: 2, Hours: 5, Date: day})
+
+	var report struct {
+		OverrunPercent float64 `json:"overrun_percent"`
+		Groups         []struct {
+			Name            string   `json:"name"`
+			Tasks           int      `json:"tasks"`
+			EstimatedHours  float64  `json:"estimated_hours"`
+			ActualHours     float64  `json:"actual_hours"`
+			VariancePercent *float64 `json:"variance_percent"`
+			AccuracyPercent float64  `json:"accuracy_percent"`
+			Overruns        int      `json:"overruns"`
+		} `json:"groups"`
+		Overruns []struct {
+			TaskID uint `json:"task_id"`
+		} `json:"overruns"`
+	}
+
+	// Open tasks are left out by default
+	status, body := doRequest(t, app, "GET", "/estimate-accuracy", "")
+	assert.Equal(t, 200, status, string(body))
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 20.0, report.OverrunPercent)
+	if assert.Len(t, report.Groups, 1) {
+		alice := report.Groups[0]
+		assert.Equal(t, "Alice", alice.Name)
+		assert.Equal(t, 2, alice.Tasks)
+		assert.Equal(t, 18.0, alice.EstimatedHours)
+		assert.Equal(t, 19.0, alice.ActualHours)
+		assert.InDelta(t, 72.5, alice.AccuracyPercent, 0.001) // 70% and 75% accurate
+		assert.Equal(t, 1, alice.Overruns)
+	}
+	if assert.Len(t, report.Overruns, 1) {
+		assert.Equal(t, uint(1), report.Overruns[0].TaskID)
+	}
+
+	// Raising the overrun percentage clears the overrun; task types group open tasks too
+	status, _ = doRequest(t, app, "PUT", "/estimate-settings", `{"overrun_percent": 50}`)
+	assert.Equal(t, 200, status)
+	status, body = doRequest(t, app, "GET", "/estimate-accuracy?group_by=type&include_open=true", "")
+	assert.Equal(t, 200, status)
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Empty(t, report.Overruns)
+	if assert.Len(t, report.Groups, 2) {
+		assert.Equal(t, "bug", report.Groups[0].Name)
+		feature := report.Groups[1]
+		assert.Equal(t, "feature", feature.Name)
+		assert.Equal(t, 2, feature.Tasks)
+		assert.Equal(t, 11.0, feature.ActualHours)
+		if assert.NotNil(t, feature.VariancePercent) {
+			assert.InDelta(t, -8.333, *feature.VariancePercent, 0.001)
+		}
+	}
+
+	status, _ = doRequest(t, app, "GET", "/estimate-accuracy?group_by=month", "")
+	assert.Equal(t, 400, status)
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxFinancialPoints caps the length of a financials series
+const maxFinancialPoints = 520
+
+// financialPoint holds the earned value metrics of a project as of a date
+type financialPoint struct {
+	Date             string   `json:"date"`
+	PlannedValue     float64  `json:"planned_value"`     // budget scheduled to be spent by this date
+	EarnedValue      float64  `json:"earned_value"`      // budget of the work completed by this date
+	ActualCost       float64  `json:"actual_cost"`       // labour and non-labour cost incurred by this date
+	LabourCost       float64  `json:"labour_cost"`       // time tracked, priced with rate cards
+	NonLabourCost    float64  `json:"non_labour_cost"`   // linked purchase orders and maintenance records
+	CPI              *float64 `json:"cpi"`               // earned value / actual cost, unset without cost
+	SPI              *float64 `json:"spi"`               // earned value / planned value, unset without planned value
+	CostVariance     float64  `json:"cost_variance"`     // earned value - actual cost
+	ScheduleVariance float64  `json:"schedule_variance"` // earned value - planned value
+	BudgetVariance   float64  `json:"budget_variance"`   // planned value - actual cost
+}
+
+// financialsResponse is the cost and earned value report of a project
+type financialsResponse struct {
+	ProjectID            uint             `json:"project_id"`
+	Budget               float64          `json:"budget"`
+	PercentComplete      float64          `json:"percent_complete"`
+	EstimateAtCompletion *float64         `json:"estimate_at_completion"` // budget / CPI, unset without cost
+	UnratedHours         float64          `json:"unrated_hours"`          // tracked hours with no matching rate card, not in labour cost
+	Interval             string           `json:"interval"`
+	Current              financialPoint   `json:"current"`
+	Series               []financialPoint `json:"series"`
+}
+
+// costEntry is an amount incurred on a date
+type costEntry struct {
+	Date   time.Time
+	Amount float64
+}
+
+// GetProjectFinancials returns planned value, earned value and actual cost of a project over time
+// @Summary Get project financials
+// @Description Get planned value, earned value, actual cost, CPI, SPI and variances of a project. Planned value spreads the budget evenly between the project's start and end dates; earned value is the budget times the share of work done, weighted by story points when tasks are estimated; actual cost prices tracked time with rate cards and adds linked purchase orders and maintenance records.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Param interval query string false "Series interval: week (default) or month"
+// @Success 200 {object} financialsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/financials [get]
+func GetProjectFinancials(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	interval := c.Query("interval", "week")
+	if interval != "week" && interval != "month" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid interval: must be week or month",
+		})
+	}
+
+	labour, unratedHours, err := projectLabourCosts(database.DB, &project)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute labour cost: " + err.Error(),
+		})
+	}
+
+	nonLabour, err := projectNonLabourCosts(database.DB, project.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute project costs: " + err.Error(),
+		})
+	}
+
+	progress, err := loadProjectProgress(database.DB, uint(tenantID), project.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute project progress: " + err.Error(),
+		})
+	}
+
+	now := time.Now()
+	start := project.StartDate
+	if start.IsZero() {
+		start = project.CreatedAt
+	}
+
+	evaluate := func(at time.Time) financialPoint {
+		point := financialPoint{
+			Date:          at.Format("2006-01-02"),
+			PlannedValue:  project.Budget * plannedFraction(start, project.EndDate, at),
+			EarnedValue:   project.Budget * progress.fractionDone(at),
+			LabourCost:    sumCosts(labour, at),
+			NonLabourCost: sumCosts(nonLabour, at),
+		}
+		point.ActualCost = point.LabourCost + point.NonLabourCost
+		if point.ActualCost > 0 {
+			cpi := point.EarnedValue / point.ActualCost
+			point.CPI = &cpi
+		}
+		if point.PlannedValue > 0 {
+			spi := point.EarnedValue / point.PlannedValue
+			point.SPI = &spi
+		}
+		point.CostVariance = point.EarnedValue - point.ActualCost
+		point.ScheduleVariance = point.EarnedValue - point.PlannedValue
+		point.BudgetVariance = point.PlannedValue - point.ActualCost
+		return point
+	}
+
+	response := financialsResponse{
+		ProjectID:       project.ID,
+		Budget:          project.Budget,
+		PercentComplete: progress.fractionDone(now) * 100,
+		UnratedHours:    unratedHours,
+		Interval:        interval,
+		Current:         evaluate(now),
+		Series:          []financialPoint{},
+	}
+	if response.Current.CPI != nil && *response.Current.CPI > 0 {
+		eac := project.Budget / *response.Current.CPI
+		response.EstimateAtCompletion = &eac
+	}
+
+	for at := start; at.Before(now) && len(response.Series) < maxFinancialPoints; {
+		response.Series = append(response.Series, evaluate(at))
+		if interval == "month" {
+			at = at.AddDate(0, 1, 0)
+		} else {
+			at = at.AddDate(0, 0, 7)
+		}
+	}
+	response.Series = append(response.Series, response.Current)
+
+	return c.JSON(response)
+}
+
+// plannedFraction returns the share of the budget scheduled to be spent by a date, spread
+// evenly between start and end. Without an end date nothing is planned.
+func plannedFraction(start time.Time, end *time.Time, at time.Time) float64 {
+	if end == nil || at.Before(start) {
+		return 0
+	}
+	if !at.Before(*end) || !end.After(start) {
+		return 1
+	}
+	return float64(at.Sub(start)) / float64(end.Sub(start))
+}
+
+// sumCosts adds up the entries incurred on or before a date
+func sumCosts(entries []costEntry, at time.Time) float64 {
+	total := 0.0
+	for _, entry := range entries {
+		if !entry.Date.After(at) {
+			total += entry.Amount
+		}
+	}
+	return total
+}
+
+// projectLabourCosts prices every time entry on the project's tasks with the rate card in
+// effect on its date, and returns the hours that had no rate card
+func projectLabourCosts(db *gorm.DB, project *models.Project) ([]costEntry, float64, error) {
+	var entries []models.TimeTracking
+	result := db.Joins("JOIN tasks ON tasks.id = time_trackings.task_id").
+		Where("tasks.project_id = ? AND tasks.deleted_at IS NULL", project.ID).
+		Preload("Person").
+		Find(&entries)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var cards []models.RateCard
+	result = db.Where("tenant_id = ?", project.TenantID).Order("effective_from DESC").Find(&cards)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	costs := make([]costEntry, 0, len(entries))
+	unrated := 0.0
+	for _, entry := range entries {
+		rate, ok := hourlyRate(cards, entry.PersonID, entry.Person, entry.Date)
+		if !ok {
+			unrated += entry.Hours
+			continue
+		}
+		costs = append(costs, costEntry{Date: entry.Date, Amount: entry.Hours * rate})
+	}
+	return costs, unrated, nil
+}
+
+// hourlyRate finds the rate of a person on a date: their own rate card first, then their role's.
+// Cards must be ordered by effective date, newest first.
+func hourlyRate(cards []models.RateCard, personID uint, person *models.Person, on time.Time) (float64, bool) {
+	applies := func(card *models.RateCard) bool {
+		return !card.EffectiveFrom.After(on) && (card.EffectiveTo == nil || on.Before(card.EffectiveTo.AddDate(0, 0, 1)))
+	}
+	for i := range cards {
+		if cards[i].PersonID != nil && *cards[i].PersonID == personID && applies(&cards[i]) {
+			return cards[i].HourlyRate, true
+		}
+	}
+	if person == nil {
+		return 0, false
+	}
+	for i := range cards {
+		if cards[i].PersonID == nil && cards[i].Role == person.Role && applies(&cards[i]) {
+			return cards[i].HourlyRate, true
+		}
+	}
+	return 0, false
+}
+
+// projectNonLabourCosts returns the amounts of the purchase orders and maintenance records linked to a project
+func projectNonLabourCosts(db *gorm.DB, projectID uint) ([]costEntry, error) {
+	var links []models.ProjectCost
+	result := db.Where("project_id = ?", projectID).
+		Preload("PurchaseOrder").
+		Preload("MaintenanceRecord").
+		Find(&links)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	costs := make([]costEntry, 0, len(links))
+	for i := range links {
+		amount, date := links[i].Amount()
+		costs = append(costs, costEntry{Date: date, Amount: amount})
+	}
+	return costs, nil
+}
+
+// projectProgress replays when each task of a project was done
+type projectProgress struct {
+	tasks   []models.Task
+	done    map[uint]bool // current done state of tasks with no recorded history
+	history map[uint][]models.TaskStateChange
+	points  bool // weight tasks by story points rather than counting them
+	total   float64
+}
+
+// loadProjectProgress loads the tasks of a project and their recorded state changes
+func loadProjectProgress(db *gorm.DB, tenantID, projectID uint) (*projectProgress, error) {
+	progress := &projectProgress{
+		done:    make(map[uint]bool),
+		history: make(map[uint][]models.TaskStateChange),
+	}
+
+	if err := db.Where("project_id = ?", projectID).Find(&progress.tasks).Error; err != nil {
+		return nil, err
+	}
+
+	var changes []models.TaskStateChange
+	result := db.Where("project_id = ?", projectID).Order("created_at ASC, id ASC").Find(&changes)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, change := range changes {
+		progress.history[change.TaskID] = append(progress.history[change.TaskID], change)
+	}
+
+	for i := range progress.tasks {
+		task := &progress.tasks[i]
+		if task.StoryPoints != nil && *task.StoryPoints > 0 {
+			progress.points = true
+		}
+		if len(progress.history[task.ID]) == 0 {
+			done, err := isTaskDone(db, tenantID, task)
+			if err != nil {
+				return nil, err
+			}
+			progress.done[task.ID] = done
+		}
+	}
+	for i := range progress.tasks {
+		progress.total += progress.weight(&progress.tasks[i])
+	}
+	return progress, nil
+}
+
+// weight returns how much a task counts towards the project's scope
+func (p *projectProgress) weight(task *models.Task) float64 {
+	if !p.points {
+		return 1
+	}
+	if task.StoryPoints == nil {
+		return 0
+	}
+	return *task.StoryPoints
+}
+
+// fractionDone returns the share of the project's current scope that was done at a date
+func (p *projectProgress) fractionDone(at time.Time) float64 {
+	if p.total == 0 {
+		return 0
+	}
+
+	done := 0.0
+	for i := range p.tasks {
+		task := &p.tasks[i]
+		changes := p.history[task.ID]
+		if len(changes) == 0 {
+			// Tasks from before history was recorded count from their last update
+			if p.done[task.ID] && !task.UpdatedAt.After(at) {
+				done += p.weight(task)
+			}
+			continue
+		}
+
+		n := sort.Search(len(changes), func(i int) bool { return changes[i].CreatedAt.After(at) })
+		if n > 0 && changes[n-1].Done {
+			done += p.weight(task)
+		}
+	}
+	return done / p.total
+}
@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupFinancialsDB sets up an isolated in-memory SQLite database with a project halfway through
+// its schedule, two developers and a purchase order
+func setupFinancialsDB(t *testing.T) {
+	newTestDB(t)
+
+	now := time.Now()
+	end := now.AddDate(0, 0, 10)
+	five := 5.0
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website", Budget: 1000, StartDate: now.AddDate(0, 0, -10), EndDate: &end})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "developer"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Bob", Email: "bob@example.com", Role: "developer"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Carol", Email: "carol@example.com", Role: "designer"})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Build", Status: models.TaskStatusTodo, StoryPoints: &five})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Ship", Status: models.TaskStatusTodo, StoryPoints: &five})
+	database.DB.Create(&models.PurchaseOrder{TenantID: 1, OrderNumber: "PO-1", VendorID: 1, OrderDate: now.AddDate(0, 0, -2), TotalAmount: 100, CreatedByID: 1})
+}
+
+func setupFinancialsApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Patch("/tasks/:id", handlers.UpdateTask)
+	app.Post("/rate-cards", handlers.CreateRateCard)
+	app.Post("/projects/:project_id/costs", handlers.CreateProjectCost)
+	app.Get("/projects/:id/financials", handlers.GetProjectFinancials)
+	return app
+}
+
+func TestProjectFinancialsEarnedValue(t *testing.T) {
+	setupFinancialsDB(t)
+	app := setupFinancialsApp()
+
+	day := func(offset int) string { return time.Now().AddDate(0, 0, offset).Format(time.RFC3339) }
+	for _, card := range []string{
+		`{"role":"developer","hourly_rate":50,"effective_from":"` + day(-30) + `"}`,
+		`{"person_id":2,"hourly_rate":80,"effective_from":"` + day(-30) + `"}`,
+		// Expired before any time was tracked
+		`{"person_id":1,"hourly_rate":1000,"effective_from":"` + day(-60) + `","effective_to":"` + day(-31) + `"}`,
+	} {
+		status, _ := doRequest(t, app, "POST", "/rate-cards", card)
+		assert.Equal(t, fiber.StatusCreated, status)
+	}
+
+	database.DB.Create(&models.TimeTracking{TaskID: 1, PersonID: 1, Hours: 10, Date: time.Now().AddDate(0, 0, -5)})
+	database.DB.Create(&models.TimeTracking{TaskID: 1, PersonID: 2, Hours: 5, Date: time.Now().AddDate(0, 0, -3)})
+	database.DB.Create(&models.TimeTracking{TaskID: 2, PersonID: 3, Hours: 4, Date: time.Now().AddDate(0, 0, -1)})
+
+	status, _ := doRequest(t, app, "POST", "/projects/1/costs", `{"purchase_order_id":1}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = doRequest(t, app, "POST", "/projects/1/costs", `{"purchase_order_id":1}`)
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	status, _ = doRequest(t, app, "PATCH", "/tasks/1", `{"status":"completed"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, body := doRequest(t, app, "GET", "/projects/1/financials", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var report struct {
+		PercentComplete float64 `json:"percent_complete"`
+		UnratedHours    float64 `json:"unrated_hours"`
+		Current         struct {
+			PlannedValue  float64  `json:"planned_value"`
+			EarnedValue   float64  `json:"earned_value"`
+			ActualCost    float64  `json:"actual_cost"`
+			LabourCost    float64  `json:"labour_cost"`
+			NonLabourCost float64  `json:"non_labour_cost"`
+			CPI           *float64 `json:"cpi"`
+			SPI           *float64 `json:"spi"`
+		} `json:"current"`
+		Series []struct {
+			EarnedValue float64 `json:"earned_value"`
+		} `json:"series"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &report))
+
+	assert.Equal(t, 50.0, report.PercentComplete)
+	assert.Equal(t, 4.0, report.UnratedHours)
+	assert.InDelta(t, 500, report.Current.PlannedValue, 1)
+	assert.Equal(t, 500.0, report.Current.EarnedValue)
+	assert.Equal(t, 900.0, report.Current.LabourCost)
+	assert.Equal(t, 100.0, report.Current.NonLabourCost)
+	assert.Equal(t, 1000.0, report.Current.ActualCost)
+	assert.InDelta(t, 0.5, *report.Current.CPI, 0.001)
+	assert.InDelta(t, 1, *report.Current.SPI, 0.01)
+
+	// The task was completed today, so earlier points have earned nothing
+	assert.Len(t, report.Series, 3)
+	assert.Equal(t, 0.0, report.Series[0].EarnedValue)
+	assert.Equal(t, 500.0, report.Series[2].EarnedValue)
+}
@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// healthCacheTTL bounds how stale a cached health can get from changes that do not drop it,
+// such as tasks passing their due date
+const healthCacheTTL = 5 * time.Minute
+
+// highRiskPenalty is how much each open high-impact risk takes off the risk factor's score
+const highRiskPenalty = 25
+
+// tenantHealthResponse is the health of every project of a tenant
+type tenantHealthResponse struct {
+	Score    float64                `json:"score"` // average of the projects' scores
+	Green    int                    `json:"green"`
+	Amber    int                    `json:"amber"`
+	Red      int                    `json:"red"`
+	Projects []models.ProjectHealth `json:"projects"` // least healthy first
+}
+
+// GetProjectHealth returns the health score of a project and the factors contributing to it
+// @Summary Get project health
+// @Description Get a project's health score from 0 to 100, its red/amber/green status and the weighted factors it is computed from
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Success 200 {object} models.ProjectHealth
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/health [get]
+func GetProjectHealth(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	weights := tenantHealthWeights(uint(tenantID))
+	health, err := projectHealth(database.DB, &project, &weights, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute project health: " + err.Error(),
+		})
+	}
+
+	return c.JSON(health)
+}
+
+// GetProjectsHealth returns the health of every project of the tenant
+// @Summary Get health of all projects
+// @Description Get the health of every project of the tenant, least healthy first, with the number of projects in each status
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param status query string false "Filter by status (red, amber, green)"
+// @Success 200 {object} tenantHealthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/health [get]
+func GetProjectsHealth(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var projects []models.Project
+	result := database.DB.Where("tenant_id = ?", tenantID).Find(&projects)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve projects",
+		})
+	}
+
+	weights := tenantHealthWeights(uint(tenantID))
+	now := time.Now()
+	response := tenantHealthResponse{Projects: []models.ProjectHealth{}}
+	total := 0.0
+	for i := range projects {
+		health, err := projectHealth(database.DB, &projects[i], &weights, now)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to compute health of project %d: %v", projects[i].ID, err),
+			})
+		}
+
+		total += health.Score
+		switch health.Status {
+		case models.HealthStatusGreen:
+			response.Green++
+		case models.HealthStatusAmber:
+			response.Amber++
+		case models.HealthStatusRed:
+			response.Red++
+		}
+
+		if status := c.Query("status"); status == "" || status == string(health.Status) {
+			response.Projects = append(response.Projects, health)
+		}
+	}
+	if len(projects) > 0 {
+		response.Score = total / float64(len(projects))
+	}
+
+	sort.SliceStable(response.Projects, func(i, j int) bool {
+		return response.Projects[i].Score < response.Projects[j].Score
+	})
+
+	return c.JSON(response)
+}
+
+// GetHealthWeights returns the tenant's project health weights
+// @Summary Get project health weights
+// @Description Get how the factors of project health are weighted for the tenant, and the scores below which projects turn amber or red
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Success 200 {object} models.HealthWeights
+// @Failure 400 {object} map[string]string
+// @Router /health-weights [get]
+func GetHealthWeights(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	return c.JSON(tenantHealthWeights(uint(tenantID)))
+}
+
+// UpdateHealthWeights sets the tenant's project health weights
+// @Summary Set project health weights
+// @Description Set how the factors of project health are weighted for the tenant. Cached health of the tenant's projects is recomputed.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param weights body models.HealthWeights true "Health weights"
+// @Success 200 {object} models.HealthWeights
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /health-weights [put]
+func UpdateHealthWeights(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	// Fields not sent keep their current value
+	weights := tenantHealthWeights(uint(tenantID))
+	existingID := weights.ID
+	if err := c.BodyParser(&weights); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+	weights.ID = existingID
+	weights.TenantID = uint(tenantID)
+
+	if msg := validateHealthWeights(&weights); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	result := database.DB.Save(&weights)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save health weights: " + result.Error.Error(),
+		})
+	}
+
+	invalidateTenantHealth(uint(tenantID))
+
+	return c.JSON(weights)
+}
+
+// tenantHealthWeights returns the tenant's health weights, or the defaults if it has none
+func tenantHealthWeights(tenantID uint) models.HealthWeights {
+	var weights models.HealthWeights
+	if err := database.DB.Where("tenant_id = ?", tenantID).First(&weights).Error; err != nil {
+		return models.DefaultHealthWeights(tenantID)
+	}
+	return weights
+}
+
+// validateHealthWeights checks health weights and returns a message describing the first problem
+func validateHealthWeights(weights *models.HealthWeights) string {
+	values := []float64{
+		weights.OverdueTasks, weights.BlockedTasks, weights.DelayedMilestones, weights.KPIs,
+		weights.HighRisks, weights.UnresolvedIssues, weights.BudgetBurn,
+	}
+	total := 0.0
+	for _, value := range values {
+		if value < 0 {
+			return "Health weights must not be negative"
+		}
+		total += value
+	}
+	if total == 0 {
+		return "At least one health weight must be positive"
+	}
+	if weights.RedBelow < 0 || weights.AmberBelow > 100 || weights.RedBelow > weights.AmberBelow {
+		return "Health thresholds must satisfy 0 <= red_below <= amber_below <= 100"
+	}
+	return ""
+}
+
+// projectHealth returns the cached health of a project, computing it when there is none or it has expired
+func projectHealth(db *gorm.DB, project *models.Project, weights *models.HealthWeights, now time.Time) (models.ProjectHealth, error) {
+	var health models.ProjectHealth
+	result := db.Where("project_id = ? AND computed_at > ?", project.ID, now.Add(-healthCacheTTL)).First(&health)
+	if result.Error != nil {
+		factors, err := projectHealthFactors(db, project, now)
+		if err != nil {
+			return health, err
+		}
+
+		health = models.ProjectHealth{ProjectID: project.ID, TenantID: project.TenantID, Factors: factors, ComputedAt: now}
+		health.Score, health.Status = weights.Score(health.Factors)
+		err = db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "project_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score", "status", "factors", "computed_at"}),
+		}).Create(&health).Error
+		if err != nil {
+			return health, err
+		}
+	}
+
+	health.ProjectName = project.Name
+	return health, nil
+}
+
+// projectDataChanged brings what is derived from a project's records up to date after they
+// changed: its computed KPIs are re-evaluated and its cached health dropped
+func projectDataChanged(projectID uint) {
+	refreshComputedKPIs(projectID)
+	invalidateProjectHealth(projectID)
+}
+
+// invalidateProjectHealth drops the cached health of a project after its records changed
+func invalidateProjectHealth(projectID uint) {
+	database.DB.Where("project_id = ?", projectID).Delete(&models.ProjectHealth{})
+}
+
+// invalidateTenantHealth drops the cached health of every project of a tenant, after a change
+// that affects them all such as new weights, workflows or rates
+func invalidateTenantHealth(tenantID uint) {
+	database.DB.Where("tenant_id = ?", tenantID).Delete(&models.ProjectHealth{})
+}
+
+// projectHealthFactors scores each factor of a project's health from 0 (worst) to 100
+func projectHealthFactors(db *gorm.DB, project *models.Project, now time.Time) ([]models.HealthFactor, error) {
+	values, err := projectMetricValues(db, project, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocked int64
+	result := db.Model(&models.Task{}).Where("project_id = ? AND status = ?", project.ID, models.TaskStatusBlocked).Count(&blocked)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var delayed int64
+	result = db.Model(&models.Milestone{}).
+		Where("project_id = ? AND status <> ? AND (status = ? OR due_date < ?)", project.ID, models.MilestoneStatusCompleted, models.MilestoneStatusDelayed, now).
+		Count(&delayed)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var kpiStatuses []models.KPIStatus
+	if err := db.Model(&models.KPI{}).Where("project_id = ?", project.ID).Pluck("status", &kpiStatuses).Error; err != nil {
+		return nil, err
+	}
+	kpiScore := 0.0
+	for _, status := range kpiStatuses {
+		switch status {
+		case models.KPIStatusGreen:
+			kpiScore += 100
+		case models.KPIStatusAmber:
+			kpiScore += 50
+		}
+	}
+	if len(kpiStatuses) > 0 {
+		kpiScore /= float64(len(kpiStatuses))
+	}
+
+	var risks []models.Risk
+	if err := db.Where("project_id = ?", project.ID).Find(&risks).Error; err != nil {
+		return nil, err
+	}
+	riskWorkflow, _, err := resolveWorkflowIn(db, project.TenantID, &project.ID, models.WorkflowEntityRisk)
+	if err != nil {
+		return nil, err
+	}
+	highRisks := 0
+	for _, risk := range risks {
+		if strings.EqualFold(risk.Impact, "high") && !riskWorkflow.IsFinal(string(risk.Status)) {
+			highRisks++
+		}
+	}
+
+	planned := plannedFraction(project.StartDate, project.EndDate, now)
+	burn := 0.0
+	if project.Budget > 0 {
+		burn = values["actual_cost"] / project.Budget
+	}
+	budgetScore := 100.0
+	if planned > 0 && burn > planned {
+		budgetScore = math.Max(0, 100-(burn/planned-1)*100)
+	}
+
+	return []models.HealthFactor{
+		{
+			Name:       models.HealthFactorOverdueTasks,
+			Score:      ratioScore(values["tasks_overdue"], values["tasks_open"]),
+			Applicable: values["tasks_total"] > 0,
+			Detail:     fmt.Sprintf("%d of %d open tasks overdue", int(values["tasks_overdue"]), int(values["tasks_open"])),
+		},
+		{
+			Name:       models.HealthFactorBlockedTasks,
+			Score:      ratioScore(float64(blocked), values["tasks_open"]),
+			Applicable: values["tasks_total"] > 0,
+			Detail:     fmt.Sprintf("%d of %d open tasks blocked", blocked, int(values["tasks_open"])),
+		},
+		{
+			Name:       models.HealthFactorDelayedMilestones,
+			Score:      ratioScore(float64(delayed), values["milestones_total"]),
+			Applicable: values["milestones_total"] > 0,
+			Detail:     fmt.Sprintf("%d of %d milestones delayed", delayed, int(values["milestones_total"])),
+		},
+		{
+			Name:       models.HealthFactorKPIs,
+			Score:      kpiScore,
+			Applicable: len(kpiStatuses) > 0,
+			Detail:     fmt.Sprintf("%d KPIs, green scoring 100, amber 50 and red 0", len(kpiStatuses)),
+		},
+		{
+			Name:       models.HealthFactorHighRisks,
+			Score:      math.Max(0, 100-float64(highRisks)*highRiskPenalty),
+			Applicable: len(risks) > 0,
+			Detail:     fmt.Sprintf("%d open high-impact risks", highRisks),
+		},
+		{
+			Name:       models.HealthFactorUnresolvedIssues,
+			Score:      ratioScore(values["issues_open"], values["issues_total"]),
+			Applicable: values["issues_total"] > 0,
+			Detail:     fmt.Sprintf("%d of %d issues unresolved", int(values["issues_open"]), int(values["issues_total"])),
+		},
+		{
+			Name:       models.HealthFactorBudgetBurn,
+			Score:      budgetScore,
+			Applicable: project.Budget > 0 && planned > 0,
+			Detail:     fmt.Sprintf("%.0f%% of budget spent, %.0f%% of schedule elapsed", burn*100, planned*100),
+		},
+	}, nil
+}
+
+// ratioScore scores the share of bad items out of a total, 100 when there are none
+func ratioScore(bad, total float64) float64 {
+	if total <= 0 {
+		return 100
+	}
+	return math.Max(0, 100*(1-bad/total))
+}
@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupHealthDB sets up an isolated in-memory SQLite database with a struggling project and an empty one
+func setupHealthDB(t *testing.T) {
+	newTestDB(t)
+
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website", StartDate: now.AddDate(0, 0, -30)})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Intranet", StartDate: now.AddDate(0, 0, -30)})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Design", Status: models.TaskStatusCompleted})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Build", Status: models.TaskStatusTodo, DueDate: &yesterday})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Test", Status: models.TaskStatusBlocked})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Ship", Status: models.TaskStatusTodo})
+	database.DB.Create(&models.Milestone{ProjectID: 1, Title: "Beta", DueDate: now.AddDate(0, 0, 7), Status: models.MilestoneStatusDelayed})
+	database.DB.Create(&models.Milestone{ProjectID: 1, Title: "Launch", DueDate: now.AddDate(0, 0, 30)})
+	database.DB.Create(&models.KPI{ProjectID: 1, Description: "Signups", TargetValue: 100, Unit: "count", Status: models.KPIStatusRed})
+	database.DB.Create(&models.KPI{ProjectID: 1, Description: "Uptime", TargetValue: 99, Unit: "%", Status: models.KPIStatusGreen})
+	database.DB.Create(&models.Risk{ProjectID: 1, Description: "Vendor delay", Impact: "High", Probability: "Medium"})
+	database.DB.Create(&models.Risk{ProjectID: 1, Description: "Scope creep", Impact: "Low", Probability: "High"})
+}
+
+func setupHealthApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Get("/projects/health", handlers.GetProjectsHealth)
+	app.Get("/projects/:id/health", handlers.GetProjectHealth)
+	app.Get("/health-weights", handlers.GetHealthWeights)
+	app.Put("/health-weights", handlers.UpdateHealthWeights)
+	app.Patch("/tasks/:id", handlers.UpdateTask)
+	return app
+}
+
+func TestProjectHealthScoreAndCache(t *testing.T) {
+	setupHealthDB(t)
+	app := setupHealthApp()
+
+	status, body := doRequest(t, app, "GET", "/projects/1/health", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var health models.ProjectHealth
+	assert.NoError(t, json.Unmarshal(body, &health))
+	// Overdue and blocked 1 of 3 open, 1 of 2 milestones delayed, KPIs red and green, one high risk;
+	// no issues or budget to measure
+	assert.InDelta(t, (20*200.0/3+10*200.0/3+15*50+15*50+15*75)/75, health.Score, 1e-9)
+	assert.Equal(t, models.HealthStatusAmber, health.Status)
+	assert.Equal(t, "Website", health.ProjectName)
+	if assert.Len(t, health.Factors, 7) {
+		assert.Equal(t, models.HealthFactorOverdueTasks, health.Factors[0].Name)
+		assert.Equal(t, 20.0, health.Factors[0].Weight)
+		assert.False(t, health.Factors[5].Applicable)
+	}
+
+	// Records changed behind the API's back are only picked up once the cache expires
+	database.DB.Create(&models.Risk{ProjectID: 1, Description: "Key person leaves", Impact: "High", Probability: "Low"})
+	first := health.Score
+	_, body = doRequest(t, app, "GET", "/projects/1/health", "")
+	assert.NoError(t, json.Unmarshal(body, &health))
+	assert.Equal(t, first, health.Score)
+
+	// A change through the API drops the cached health
+	status, _ = doRequest(t, app, "PATCH", "/tasks/2", `{"title":"Build","status":"completed"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	_, body = doRequest(t, app, "GET", "/projects/1/health", "")
+	assert.NoError(t, json.Unmarshal(body, &health))
+	assert.InDelta(t, (20*100.0+10*50+15*50+15*50+15*50)/75, health.Score, 1e-9)
+}
+
+func TestProjectsHealthWithTenantWeights(t *testing.T) {
+	setupHealthDB(t)
+	app := setupHealthApp()
+
+	status, _ := doRequest(t, app, "PUT", "/health-weights", `{"amber_below":50,"red_below":60}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "PUT", "/health-weights", `{"overdue_tasks":-1}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	// Only KPIs count, and anything below 90 is red
+	status, _ = doRequest(t, app, "PUT", "/health-weights",
+		`{"overdue_tasks":0,"blocked_tasks":0,"delayed_milestones":0,"kpis":1,"high_risks":0,"unresolved_issues":0,"budget_burn":0,"amber_below":95,"red_below":90}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, body := doRequest(t, app, "GET", "/health-weights", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	var weights models.HealthWeights
+	assert.NoError(t, json.Unmarshal(body, &weights))
+	assert.Equal(t, 1.0, weights.KPIs)
+	assert.Equal(t, 0.0, weights.OverdueTasks)
+
+	status, body = doRequest(t, app, "GET", "/projects/health", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var response struct {
+		Score    float64                `json:"score"`
+		Green    int                    `json:"green"`
+		Red      int                    `json:"red"`
+		Projects []models.ProjectHealth `json:"projects"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &response))
+	assert.Equal(t, 1, response.Green)
+	assert.Equal(t, 1, response.Red)
+	assert.InDelta(t, 75, response.Score, 1e-9)
+	if assert.Len(t, response.Projects, 2) {
+		assert.Equal(t, "Website", response.Projects[0].ProjectName)
+		assert.Equal(t, 50.0, response.Projects[0].Score)
+		assert.Equal(t, models.HealthStatusGreen, response.Projects[1].Status)
+	}
+
+	_, body = doRequest(t, app, "GET", "/projects/health?status=red", "")
+	assert.NoError(t, json.Unmarshal(body, &response))
+	assert.Len(t, response.Projects, 1)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
+	"time"
 
 	"github.com/Masozee/kontena/api/database"
 	"github.com/Masozee/kontena/api/models"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // GetKPIs retrieves all KPIs for a specific project
@@ -16,6 +19,8 @@ import (
 // @Produce json
 // @Param tenant_id header string true "Tenant ID"
 // @Param project_id path int true "Project ID"
+// @Param status query string false "Filter by status (red, amber, green)"
+// @Param direction query string false "Filter by direction (increase, decrease, range)"
 // @Success 200 {array} models.KPI
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -52,8 +57,16 @@ func GetKPIs(c *fiber.Ctx) error {
 		})
 	}
 
+	query := database.DB.Where("project_id = ?", projectID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if direction := c.Query("direction"); direction != "" {
+		query = query.Where("direction = ?", direction)
+	}
+
 	var kpis []models.KPI
-	result = database.DB.Where("project_id = ?", projectID).Find(&kpis)
+	result = query.Find(&kpis)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve KPIs",
@@ -177,28 +190,64 @@ func CreateKPI(c *fiber.Ctx) error {
 		})
 	}
 
-	if kpi.TargetValue <= 0 {
+	if kpi.Unit == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "KPI target value must be greater than 0",
+			"error": "KPI unit is required",
 		})
 	}
 
-	if kpi.Unit == "" {
+	if msg := validateKPI(kpi); msg != "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "KPI unit is required",
+			"error": msg,
+		})
+	}
+
+	measurement := new(kpiMeasurementRequest)
+	if err := c.BodyParser(measurement); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
 		})
 	}
 
 	kpi.ProjectID = uint(projectID)
+
+	// A computed KPI starts from its formula rather than a value sent by the client
+	if kpi.SourceType == models.KPISourceComputed {
+		now := time.Now()
+		values, err := projectMetricValues(database.DB, &project, now)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to compute project metrics: " + err.Error(),
+			})
+		}
+		kpi.CurrentValue = 0
+		kpi.LastComputedAt = &now
+		if value, err := evaluateKPIFormula(kpi.Formula, values); err != nil {
+			kpi.ComputeError = err.Error()
+		} else {
+			kpi.CurrentValue = value
+		}
+		measurement.Source = string(models.KPISourceComputed)
+		measurement.MeasuredAt = &now
+	}
 	kpi.UpdateAchievement() // Set achieved status based on current value
 
-	result = database.DB.Create(&kpi)
-	if result.Error != nil {
+	// The starting value is the first measurement of the KPI's history
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&kpi).Error; err != nil {
+			return err
+		}
+		_, err := recordKPIMeasurement(tx, kpi, kpi.CurrentValue, measurement, actorID(c))
+		return err
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create KPI: " + result.Error.Error(),
+			"error": "Failed to create KPI: " + err.Error(),
 		})
 	}
 
+	invalidateProjectHealth(kpi.ProjectID)
+
 	return c.Status(fiber.StatusCreated).JSON(kpi)
 }
 
@@ -262,24 +311,68 @@ func UpdateKPI(c *fiber.Ctx) error {
 		})
 	}
 
+	measurement := new(kpiMeasurementRequest)
+	if err := c.BodyParser(measurement); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
 	// Ensure project ID cannot be changed
 	updatedKPI.ProjectID = existingKPI.ProjectID
 	updatedKPI.ID = uint(id)
 
-	// Update achievement status based on current value
-	if updatedKPI.CurrentValue > 0 || updatedKPI.TargetValue > 0 {
-		updatedKPI.UpdateAchievement()
+	// A change of the current value is recorded as a measurement. Computed KPIs take
+	// their value from their formula, so a current_value sent for them is ignored.
+	previousValue := existingKPI.CurrentValue
+	invalid := ""
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		omit := []string{"achieved", "status", "last_computed_at", "compute_error"}
+		if existingKPI.SourceType == models.KPISourceComputed || updatedKPI.SourceType == models.KPISourceComputed {
+			omit = append(omit, "current_value")
+		}
+		if err := tx.Model(&existingKPI).Omit(omit...).Updates(updatedKPI).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&existingKPI, id).Error; err != nil {
+			return err
+		}
+
+		// Achievement and status follow from the merged KPI, not just the fields sent
+		if invalid = validateKPI(&existingKPI); invalid != "" {
+			return errors.New(invalid)
+		}
+		if existingKPI.SourceType == models.KPISourceComputed {
+			values, err := projectMetricValues(tx, &project, time.Now())
+			if err != nil {
+				return err
+			}
+			return applyKPIFormula(tx, &existingKPI, values, time.Now())
+		}
+		existingKPI.UpdateAchievement()
+		if err := tx.Model(&existingKPI).Select("achieved", "status").Updates(&existingKPI).Error; err != nil {
+			return err
+		}
+
+		if existingKPI.CurrentValue != previousValue {
+			_, err := recordKPIMeasurement(tx, &existingKPI, existingKPI.CurrentValue, measurement, actorID(c))
+			return err
+		}
+		return nil
+	})
+	if invalid != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": invalid,
+		})
 	}
-
-	result = database.DB.Model(&existingKPI).Updates(updatedKPI)
-	if result.Error != nil {
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update KPI: " + result.Error.Error(),
+			"error": "Failed to update KPI: " + err.Error(),
 		})
 	}
 
-	// Get the updated KPI
-	database.DB.First(&existingKPI, id)
+	invalidateProjectHealth(existingKPI.ProjectID)
+
 	return c.JSON(existingKPI)
 }
 
@@ -342,7 +435,62 @@ func DeleteKPI(c *fiber.Ctx) error {
 		})
 	}
 
+	invalidateProjectHealth(kpi.ProjectID)
+
 	return c.JSON(fiber.Map{
 		"message": "KPI deleted successfully",
 	})
 }
+
+// validateKPI checks the direction, target, thresholds and formula of a KPI and returns a message
+// describing the first problem
+func validateKPI(kpi *models.KPI) string {
+	if kpi.Direction == "" {
+		kpi.Direction = models.KPIDirectionIncrease
+	}
+
+	switch kpi.Direction {
+	case models.KPIDirectionIncrease:
+		if kpi.TargetValue <= kpi.BaselineValue {
+			return "KPI target value must be greater than the baseline value"
+		}
+		if kpi.AmberThreshold != nil && kpi.RedThreshold != nil && *kpi.RedThreshold > *kpi.AmberThreshold {
+			return "KPI red threshold must not be above the amber threshold"
+		}
+	case models.KPIDirectionDecrease:
+		if kpi.TargetValue >= kpi.BaselineValue {
+			return "KPI target value must be less than the baseline value"
+		}
+		if kpi.AmberThreshold != nil && kpi.RedThreshold != nil && *kpi.RedThreshold < *kpi.AmberThreshold {
+			return "KPI red threshold must not be below the amber threshold"
+		}
+	case models.KPIDirectionRange:
+		if kpi.RangeMin == nil && kpi.RangeMax == nil {
+			return "KPI range_min or range_max is required for a range KPI"
+		}
+		if kpi.RangeMin != nil && kpi.RangeMax != nil && *kpi.RangeMin > *kpi.RangeMax {
+			return "KPI range_min must not be greater than range_max"
+		}
+		if (kpi.AmberThreshold != nil && *kpi.AmberThreshold < 0) || (kpi.RedThreshold != nil && *kpi.RedThreshold < 0) {
+			return "KPI thresholds of a range KPI must not be negative"
+		}
+		if kpi.AmberThreshold != nil && kpi.RedThreshold != nil && *kpi.RedThreshold < *kpi.AmberThreshold {
+			return "KPI red threshold must not be below the amber threshold"
+		}
+	default:
+		return "Invalid KPI direction: " + string(kpi.Direction)
+	}
+
+	switch kpi.SourceType {
+	case "":
+		kpi.SourceType = models.KPISourceManual
+	case models.KPISourceManual:
+	case models.KPISourceComputed:
+		if msg := validateKPIFormula(kpi.Formula); msg != "" {
+			return "Invalid KPI formula: " + msg
+		}
+	default:
+		return "Invalid KPI source type: " + string(kpi.SourceType)
+	}
+	return ""
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/timeseries"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// kpiMeasurementRequest describes a measurement. On KPI create and update only the
+// note, source and measured_at apply; the value is the KPI's current value.
+type kpiMeasurementRequest struct {
+	Value      *float64   `json:"value"`
+	Note       string     `json:"note"`
+	Source     string     `json:"source"`
+	MeasuredAt *time.Time `json:"measured_at"` // defaults to now
+}
+
+// kpiTrend is the rate a KPI is moving at and when it reaches its target at that rate
+type kpiTrend struct {
+	SlopePerDay  float64    `json:"slope_per_day"`
+	ForecastDate *time.Time `json:"forecast_date"` // unset when the KPI is not moving towards its target
+}
+
+// kpiHistoryResponse is the measurement history of a KPI
+type kpiHistoryResponse struct {
+	KPIID        uint                `json:"kpi_id"`
+	TargetValue  float64             `json:"target_value"`
+	CurrentValue float64             `json:"current_value"`
+	Achieved     bool                `json:"achieved"`
+	Interval     timeseries.Interval `json:"interval"`
+	Buckets      []timeseries.Bucket `json:"buckets"`
+	Trend        *kpiTrend           `json:"trend"` // unset with fewer than two measurements
+}
+
+// CreateKPIMeasurement records a measurement of a KPI
+// @Summary Record a KPI measurement
+// @Description Record a value of a KPI, optionally backdated with measured_at. The KPI's current value follows its latest measurement.
+// @Tags kpis
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "KPI ID"
+// @Param measurement body kpiMeasurementRequest true "Measurement"
+// @Success 201 {object} models.KPIMeasurement
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /kpis/{id}/measurements [post]
+func CreateKPIMeasurement(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid KPI ID format",
+		})
+	}
+
+	kpi, err := findKPI(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "KPI not found",
+		})
+	}
+
+	if kpi.SourceType == models.KPISourceComputed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Measurements of a computed KPI are recorded when it is recomputed",
+		})
+	}
+
+	req := new(kpiMeasurementRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.Value == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Measurement value is required",
+		})
+	}
+
+	var measurement models.KPIMeasurement
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		measurement, err = recordKPIMeasurement(tx, &kpi, *req.Value, req, actorID(c))
+		if err != nil {
+			return err
+		}
+
+		// Backdated measurements fill in history without changing the current value
+		var later int64
+		tx.Model(&models.KPIMeasurement{}).Where("kpi_id = ? AND measured_at > ?", kpi.ID, measurement.MeasuredAt).Count(&later)
+		if later > 0 {
+			return nil
+		}
+
+		kpi.CurrentValue = measurement.Value
+		kpi.UpdateAchievement()
+		return tx.Model(&kpi).Select("current_value", "achieved", "status").Updates(&kpi).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to record measurement: " + err.Error(),
+		})
+	}
+
+	invalidateProjectHealth(kpi.ProjectID)
+
+	return c.Status(fiber.StatusCreated).JSON(measurement)
+}
+
+// GetKPIHistory returns the measurements of a KPI aggregated over time with its trend
+// @Summary Get KPI history
+// @Description Get the measurements of a KPI grouped into daily, weekly or monthly buckets, with the current rate of change and a forecast of when the target value will be reached
+// @Tags kpis
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "KPI ID"
+// @Param interval query string false "Bucket size: daily, weekly (default) or monthly"
+// @Param from query string false "Only measurements from this time (RFC 3339)"
+// @Param to query string false "Only measurements up to this time (RFC 3339)"
+// @Success 200 {object} kpiHistoryResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /kpis/{id}/history [get]
+func GetKPIHistory(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid KPI ID format",
+		})
+	}
+
+	kpi, err := findKPI(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "KPI not found",
+		})
+	}
+
+	interval, err := timeseries.ParseInterval(c.Query("interval", string(timeseries.Weekly)))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	query := database.DB.Where("kpi_id = ?", kpi.ID)
+	for param, clause := range map[string]string{"from": "measured_at >= ?", "to": "measured_at <= ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid " + param + " time: must be RFC 3339",
+			})
+		}
+		query = query.Where(clause, at)
+	}
+
+	var measurements []models.KPIMeasurement
+	result := query.Order("measured_at ASC, id ASC").Find(&measurements)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve measurements: " + result.Error.Error(),
+		})
+	}
+
+	points := make([]timeseries.Point, len(measurements))
+	for i, m := range measurements {
+		points[i] = timeseries.Point{Time: m.MeasuredAt, Value: m.Value}
+	}
+
+	response := kpiHistoryResponse{
+		KPIID:        kpi.ID,
+		TargetValue:  kpi.TargetValue,
+		CurrentValue: kpi.CurrentValue,
+		Achieved:     kpi.Achieved,
+		Interval:     interval,
+		Buckets:      timeseries.Aggregate(points, interval),
+	}
+	if response.Buckets == nil {
+		response.Buckets = []timeseries.Bucket{}
+	}
+
+	if trend, ok := timeseries.LinearTrend(points); ok {
+		response.Trend = &kpiTrend{SlopePerDay: trend.SlopePerDay}
+		if eta, ok := trend.Forecast(points[len(points)-1], kpi.TargetValue); ok {
+			response.Trend.ForecastDate = &eta
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// findKPI loads a KPI, checking that its project belongs to the tenant
+func findKPI(tenantID, id uint) (models.KPI, error) {
+	var kpi models.KPI
+	result := database.DB.Joins("JOIN projects ON projects.id = kpis.project_id").
+		Where("kpis.id = ? AND projects.tenant_id = ?", id, tenantID).
+		First(&kpi)
+	return kpi, result.Error
+}
+
+// recordKPIMeasurement appends a value to a KPI's history
+func recordKPIMeasurement(tx *gorm.DB, kpi *models.KPI, value float64, req *kpiMeasurementRequest, recordedByID *uint) (models.KPIMeasurement, error) {
+	measurement := models.KPIMeasurement{
+		KPIID:        kpi.ID,
+		Value:        value,
+		Note:         req.Note,
+		Source:       req.Source,
+		MeasuredAt:   time.Now(),
+		RecordedByID: recordedByID,
+	}
+	if measurement.Source == "" {
+		measurement.Source = "manual"
+	}
+	if req.MeasuredAt != nil {
+		measurement.MeasuredAt = *req.MeasuredAt
+	}
+	err := tx.Create(&measurement).Error
+	return measurement, err
+}
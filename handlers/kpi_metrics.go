@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/formula"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// kpiRecomputeInterval is how often the scheduler re-evaluates computed KPIs; changes to tasks
+// and costs re-evaluate their project's KPIs straight away
+const kpiRecomputeInterval = 15 * time.Minute
+
+// projectMetric is a project figure that computed KPI formulas can use
+type projectMetric struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Value       float64 `json:"value"`
+}
+
+// projectMetrics lists the metrics formulas may refer to, in the order they are documented
+var projectMetrics = []projectMetric{
+	{Name: "tasks_total", Description: "Number of tasks"},
+	{Name: "tasks_completed", Description: "Tasks in the completed status or a final workflow state"},
+	{Name: "tasks_open", Description: "Tasks not completed"},
+	{Name: "tasks_overdue", Description: "Open tasks past their due date"},
+	{Name: "percent_tasks_completed", Description: "Completed tasks as a percentage of all tasks"},
+	{Name: "story_points_total", Description: "Story points of all tasks"},
+	{Name: "story_points_completed", Description: "Story points of completed tasks"},
+	{Name: "hours_logged", Description: "Hours tracked on the project's tasks"},
+	{Name: "budget", Description: "Project budget"},
+	{Name: "labour_cost", Description: "Tracked hours priced with rate cards"},
+	{Name: "non_labour_cost", Description: "Linked purchase orders and maintenance records"},
+	{Name: "actual_cost", Description: "Labour plus non-labour cost"},
+	{Name: "issues_total", Description: "Number of issues"},
+	{Name: "issues_open", Description: "Issues not in a final workflow state"},
+	{Name: "risks_total", Description: "Number of risks"},
+	{Name: "risks_open", Description: "Risks not in a final workflow state"},
+	{Name: "milestones_total", Description: "Number of milestones"},
+	{Name: "milestones_completed", Description: "Completed milestones"},
+	{Name: "milestones_overdue", Description: "Milestones not completed past their due date"},
+	{Name: "days_elapsed", Description: "Days since the project started"},
+	{Name: "days_remaining", Description: "Days until the project ends, 0 without an end date"},
+}
+
+// GetProjectMetrics returns the metrics computed KPIs can use, with the project's current values
+// @Summary Get project metrics
+// @Description Get the whitelisted metrics that computed KPI formulas can refer to, with their current values for the project
+// @Tags kpis
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} projectMetric
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/metrics [get]
+func GetProjectMetrics(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	projectID, err := c.ParamsInt("project_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", projectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	values, err := projectMetricValues(database.DB, &project, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute project metrics: " + err.Error(),
+		})
+	}
+
+	metrics := make([]projectMetric, len(projectMetrics))
+	for i, metric := range projectMetrics {
+		metric.Value = values[metric.Name]
+		metrics[i] = metric
+	}
+
+	return c.JSON(metrics)
+}
+
+// RecomputeKPI evaluates a computed KPI's formula now
+// @Summary Recompute a KPI
+// @Description Evaluate a computed KPI's formula against the project's current metrics
+// @Tags kpis
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "KPI ID"
+// @Success 200 {object} models.KPI
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /kpis/{id}/recompute [post]
+func RecomputeKPI(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid KPI ID format",
+		})
+	}
+
+	kpi, err := findKPI(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "KPI not found",
+		})
+	}
+
+	if kpi.SourceType != models.KPISourceComputed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only computed KPIs can be recomputed",
+		})
+	}
+
+	if err := recomputeProjectKPIs(database.DB, kpi.ProjectID, time.Now(), kpi.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to recompute KPI: " + err.Error(),
+		})
+	}
+
+	database.DB.First(&kpi, kpi.ID)
+	return c.JSON(kpi)
+}
+
+// RecomputeKPIs re-evaluates computed KPIs that have not been computed within the recompute interval.
+// It is run periodically by the scheduler.
+func RecomputeKPIs(now time.Time) error {
+	var projectIDs []uint
+	result := database.DB.Model(&models.KPI{}).
+		Where("source_type = ? AND (last_computed_at IS NULL OR last_computed_at <= ?)", models.KPISourceComputed, now.Add(-kpiRecomputeInterval)).
+		Distinct().
+		Pluck("project_id", &projectIDs)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var errs []error
+	for _, projectID := range projectIDs {
+		if err := recomputeProjectKPIs(database.DB, projectID, now); err != nil {
+			errs = append(errs, fmt.Errorf("project %d: %w", projectID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// refreshComputedKPIs re-evaluates the computed KPIs of a project after its data changed.
+// Failures are logged rather than failing the change that triggered them.
+func refreshComputedKPIs(projectID uint) {
+	if err := recomputeProjectKPIs(database.DB, projectID, time.Now()); err != nil {
+		log.Printf("Failed to recompute KPIs of project %d: %v", projectID, err)
+	}
+}
+
+// recomputeProjectKPIs evaluates the computed KPIs of a project, or only the given ones
+func recomputeProjectKPIs(db *gorm.DB, projectID uint, now time.Time, kpiIDs ...uint) error {
+	query := db.Where("project_id = ? AND source_type = ?", projectID, models.KPISourceComputed)
+	if len(kpiIDs) > 0 {
+		query = query.Where("id IN ?", kpiIDs)
+	}
+
+	var kpis []models.KPI
+	if err := query.Find(&kpis).Error; err != nil {
+		return err
+	}
+	if len(kpis) == 0 {
+		return nil
+	}
+
+	var project models.Project
+	if err := db.First(&project, projectID).Error; err != nil {
+		return err
+	}
+
+	values, err := projectMetricValues(db, &project, now)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i := range kpis {
+			if err := applyKPIFormula(tx, &kpis[i], values, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// KPI statuses feed into project health
+	invalidateProjectHealth(projectID)
+	return nil
+}
+
+// applyKPIFormula evaluates a computed KPI and saves its value, recording a measurement when it changed.
+// An evaluation error is stored on the KPI, which keeps its last value.
+func applyKPIFormula(tx *gorm.DB, kpi *models.KPI, values map[string]float64, now time.Time) error {
+	kpi.LastComputedAt = &now
+	kpi.ComputeError = ""
+
+	value, err := evaluateKPIFormula(kpi.Formula, values)
+	if err != nil {
+		kpi.ComputeError = err.Error()
+		kpi.UpdateAchievement()
+		return tx.Model(kpi).Select("achieved", "status", "last_computed_at", "compute_error").Updates(kpi).Error
+	}
+
+	previousValue := kpi.CurrentValue
+	kpi.CurrentValue = value
+	kpi.UpdateAchievement()
+	if err := tx.Model(kpi).Select("current_value", "achieved", "status", "last_computed_at", "compute_error").Updates(kpi).Error; err != nil {
+		return err
+	}
+
+	if value != previousValue {
+		_, err := recordKPIMeasurement(tx, kpi, value, &kpiMeasurementRequest{Source: string(models.KPISourceComputed), MeasuredAt: &now}, nil)
+		return err
+	}
+	return nil
+}
+
+// evaluateKPIFormula parses and evaluates a formula over metric values
+func evaluateKPIFormula(source string, values map[string]float64) (float64, error) {
+	f, err := formula.Parse(source)
+	if err != nil {
+		return 0, err
+	}
+	return f.Eval(values)
+}
+
+// validateKPIFormula checks that a formula parses and only refers to whitelisted metrics
+func validateKPIFormula(source string) string {
+	f, err := formula.Parse(source)
+	if err != nil {
+		return err.Error()
+	}
+
+	known := make(map[string]bool, len(projectMetrics))
+	for _, metric := range projectMetrics {
+		known[metric.Name] = true
+	}
+	for _, name := range f.Vars() {
+		if !known[name] {
+			return "Unknown metric in formula: " + name
+		}
+	}
+	return ""
+}
+
+// projectMetricValues computes every whitelisted metric of a project
+func projectMetricValues(db *gorm.DB, project *models.Project, now time.Time) (map[string]float64, error) {
+	values := make(map[string]float64, len(projectMetrics))
+	for _, metric := range projectMetrics {
+		values[metric.Name] = 0
+	}
+
+	var tasks []models.Task
+	if err := db.Where("project_id = ?", project.ID).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	taskWorkflow, _, err := resolveWorkflowIn(db, project.TenantID, &project.ID, models.WorkflowEntityTask)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		done := task.Status == models.TaskStatusCompleted || taskWorkflow.IsFinal(string(task.Status))
+		points := 0.0
+		if task.StoryPoints != nil {
+			points = *task.StoryPoints
+		}
+
+		values["tasks_total"]++
+		values["story_points_total"] += points
+		if done {
+			values["tasks_completed"]++
+			values["story_points_completed"] += points
+		} else if task.DueDate != nil && task.DueDate.Before(now) {
+			values["tasks_overdue"]++
+		}
+	}
+	values["tasks_open"] = values["tasks_total"] - values["tasks_completed"]
+	if values["tasks_total"] > 0 {
+		values["percent_tasks_completed"] = values["tasks_completed"] / values["tasks_total"] * 100
+	}
+
+	labour, _, err := projectLabourCosts(db, project)
+	if err != nil {
+		return nil, err
+	}
+	nonLabour, err := projectNonLabourCosts(db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	values["budget"] = project.Budget
+	values["labour_cost"] = sumCosts(labour, now)
+	values["non_labour_cost"] = sumCosts(nonLabour, now)
+	values["actual_cost"] = values["labour_cost"] + values["non_labour_cost"]
+
+	var hours float64
+	result := db.Model(&models.TimeTracking{}).
+		Joins("JOIN tasks ON tasks.id = time_trackings.task_id").
+		Where("tasks.project_id = ? AND tasks.deleted_at IS NULL", project.ID).
+		Select("COALESCE(SUM(time_trackings.hours), 0)").
+		Scan(&hours)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	values["hours_logged"] = hours
+
+	var issues []models.Issue
+	if err := db.Where("project_id = ?", project.ID).Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	issueWorkflow, _, err := resolveWorkflowIn(db, project.TenantID, &project.ID, models.WorkflowEntityIssue)
+	if err != nil {
+		return nil, err
+	}
+	for _, issue := range issues {
+		values["issues_total"]++
+		if !issueWorkflow.IsFinal(string(issue.Status)) {
+			values["issues_open"]++
+		}
+	}
+
+	var risks []models.Risk
+	if err := db.Where("project_id = ?", project.ID).Find(&risks).Error; err != nil {
+		return nil, err
+	}
+	riskWorkflow, _, err := resolveWorkflowIn(db, project.TenantID, &project.ID, models.WorkflowEntityRisk)
+	if err != nil {
+		return nil, err
+	}
+	for _, risk := range risks {
+		values["risks_total"]++
+		if !riskWorkflow.IsFinal(string(risk.Status)) {
+			values["risks_open"]++
+		}
+	}
+
+	var milestones []models.Milestone
+	if err := db.Where("project_id = ?", project.ID).Find(&milestones).Error; err != nil {
+		return nil, err
+	}
+	for _, milestone := range milestones {
+		values["milestones_total"]++
+		if milestone.Status == models.MilestoneStatusCompleted {
+			values["milestones_completed"]++
+		} else if milestone.DueDate.Before(now) {
+			values["milestones_overdue"]++
+		}
+	}
+
+	if !project.StartDate.IsZero() && project.StartDate.Before(now) {
+		values["days_elapsed"] = float64(int(now.Sub(project.StartDate).Hours() / 24))
+	}
+	if project.EndDate != nil && project.EndDate.After(now) {
+		values["days_remaining"] = float64(int(project.EndDate.Sub(now).Hours() / 24))
+	}
+
+	return values, nil
+}
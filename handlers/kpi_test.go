@@ -0,0 +1,192 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupKPIDB sets up an isolated in-memory SQLite database with one project
+func setupKPIDB(t *testing.T) {
+	newTestDB(t)
+
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website"})
+}
+
+func setupKPIApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Get("/projects/:project_id/kpis", handlers.GetKPIs)
+	app.Post("/projects/:project_id/kpis", handlers.CreateKPI)
+	app.Patch("/kpis/:id", handlers.UpdateKPI)
+	app.Post("/kpis/:id/measurements", handlers.CreateKPIMeasurement)
+	app.Get("/kpis/:id/history", handlers.GetKPIHistory)
+	app.Post("/kpis/:id/recompute", handlers.RecomputeKPI)
+	app.Get("/projects/:project_id/metrics", handlers.GetProjectMetrics)
+	app.Patch("/tasks/:id", handlers.UpdateTask)
+	return app
+}
+
+func TestKPIHistoryRecordsEveryValue(t *testing.T) {
+	setupKPIDB(t)
+	app := setupKPIApp()
+
+	status, _ := doRequest(t, app, "POST", "/projects/1/kpis",
+		`{"description":"Signups","target_value":100,"current_value":10,"unit":"count","measured_at":"2024-03-01T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	status, _ = doRequest(t, app, "PATCH", "/kpis/1", `{"current_value":24,"note":"Launch week","measured_at":"2024-03-08T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = doRequest(t, app, "POST", "/kpis/1/measurements", `{"value":38,"source":"import","measured_at":"2024-03-15T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// A backdated measurement joins the history but leaves the current value alone
+	status, _ = doRequest(t, app, "POST", "/kpis/1/measurements", `{"value":5,"measured_at":"2024-02-01T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	var count int64
+	database.DB.Model(&models.KPIMeasurement{}).Count(&count)
+	assert.Equal(t, int64(4), count)
+
+	status, body := doRequest(t, app, "GET", "/kpis/1/history?from=2024-03-01T00:00:00Z", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var history struct {
+		CurrentValue float64 `json:"current_value"`
+		Buckets      []struct {
+			Start time.Time `json:"start"`
+			Last  float64   `json:"last"`
+		} `json:"buckets"`
+		Trend struct {
+			SlopePerDay  float64    `json:"slope_per_day"`
+			ForecastDate *time.Time `json:"forecast_date"`
+		} `json:"trend"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &history))
+	assert.Equal(t, 38.0, history.CurrentValue)
+	assert.Len(t, history.Buckets, 3)
+	assert.Equal(t, 24.0, history.Buckets[1].Last)
+	assert.InDelta(t, 2, history.Trend.SlopePerDay, 1e-9)
+
+	// 62 more at 2 a day from 15 March
+	if assert.NotNil(t, history.Trend.ForecastDate) {
+		assert.True(t, history.Trend.ForecastDate.Equal(time.Date(2024, time.April, 15, 0, 0, 0, 0, time.UTC)))
+	}
+
+	status, _ = doRequest(t, app, "GET", "/kpis/1/history?interval=hourly", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
+
+func TestKPIStatusFollowsDirectionAndThresholds(t *testing.T) {
+	setupKPIDB(t)
+	app := setupKPIApp()
+
+	// A decrease KPI must have its target below the baseline
+	status, _ := doRequest(t, app, "POST", "/projects/1/kpis",
+		`{"description":"Open defects","direction":"decrease","baseline_value":5,"target_value":10,"unit":"count"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, body := doRequest(t, app, "POST", "/projects/1/kpis",
+		`{"description":"Open defects","direction":"decrease","baseline_value":50,"target_value":10,"amber_threshold":20,"red_threshold":40,"current_value":30,"unit":"count"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	var kpi models.KPI
+	assert.NoError(t, json.Unmarshal(body, &kpi))
+	assert.Equal(t, models.KPIStatusAmber, kpi.Status)
+	assert.False(t, kpi.Achieved)
+
+	doRequest(t, app, "POST", "/projects/1/kpis", `{"description":"Revenue","target_value":100,"current_value":120,"unit":"$"}`)
+
+	// Updating only the current value recomputes status against the stored thresholds
+	status, body = doRequest(t, app, "PATCH", "/kpis/1", `{"current_value":45}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NoError(t, json.Unmarshal(body, &kpi))
+	assert.Equal(t, models.KPIStatusRed, kpi.Status)
+
+	status, body = doRequest(t, app, "GET", "/projects/1/kpis?status=red", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var red []models.KPI
+	assert.NoError(t, json.Unmarshal(body, &red))
+	if assert.Len(t, red, 1) {
+		assert.Equal(t, "Open defects", red[0].Description)
+	}
+
+	// The merged KPI is validated, so a partial update cannot invert the thresholds
+	status, _ = doRequest(t, app, "PATCH", "/kpis/1", `{"red_threshold":15}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
+
+func TestComputedKPIFollowsProjectData(t *testing.T) {
+	setupKPIDB(t)
+	app := setupKPIApp()
+
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Design", Status: models.TaskStatusCompleted})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Build", Status: models.TaskStatusTodo})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Ship", Status: models.TaskStatusTodo})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Announce", Status: models.TaskStatusTodo})
+
+	// Formulas may only use whitelisted metrics
+	status, _ := doRequest(t, app, "POST", "/projects/1/kpis",
+		`{"description":"Done","source_type":"computed","formula":"secrets / 2","target_value":100,"unit":"%"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "POST", "/projects/1/kpis",
+		`{"description":"Done","source_type":"computed","formula":"tasks_completed /","target_value":100,"unit":"%"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, body := doRequest(t, app, "POST", "/projects/1/kpis",
+		`{"description":"Done","source_type":"computed","formula":"tasks_completed / tasks_total * 100","target_value":100,"current_value":99,"unit":"%"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	var kpi models.KPI
+	assert.NoError(t, json.Unmarshal(body, &kpi))
+	assert.Equal(t, 25.0, kpi.CurrentValue)
+	assert.NotNil(t, kpi.LastComputedAt)
+
+	// Completing a task recomputes the KPI without anyone touching it
+	status, _ = doRequest(t, app, "PATCH", "/tasks/2", `{"title":"Build","status":"completed"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	database.DB.First(&kpi, kpi.ID)
+	assert.Equal(t, 50.0, kpi.CurrentValue)
+
+	var measurements []models.KPIMeasurement
+	database.DB.Order("id").Find(&measurements)
+	if assert.Len(t, measurements, 2) {
+		assert.Equal(t, "computed", measurements[1].Source)
+	}
+
+	// Manual values are not accepted for a computed KPI
+	status, _ = doRequest(t, app, "POST", "/kpis/1/measurements", `{"value":80}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, body = doRequest(t, app, "PATCH", "/kpis/1", `{"current_value":80}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NoError(t, json.Unmarshal(body, &kpi))
+	assert.Equal(t, 50.0, kpi.CurrentValue)
+
+	// An evaluation error is kept on the KPI, which keeps its last value
+	database.DB.Where("project_id = ?", 1).Delete(&models.Task{})
+	status, body = doRequest(t, app, "POST", "/kpis/1/recompute", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NoError(t, json.Unmarshal(body, &kpi))
+	assert.Equal(t, 50.0, kpi.CurrentValue)
+	assert.Equal(t, "division by zero", kpi.ComputeError)
+
+	status, body = doRequest(t, app, "GET", "/projects/1/metrics", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	var metrics []struct {
+		Name  string  `json:"name"`
+		Value float64 `json:"value"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &metrics))
+	assert.NotEmpty(t, metrics)
+	assert.Equal(t, "tasks_total", metrics[0].Name)
+	assert.Equal(t, 0.0, metrics[0].Value)
+}
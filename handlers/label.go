@@ -0,0 +1,987 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ways of matching several labels in list filters
+const (
+	labelMatchAny = "any"
+	labelMatchAll = "all"
+)
+
+// labelTarget describes where the labels of an entity type are kept
+type labelTarget struct {
+	name      string // display name
+	table     string
+	joinTable string
+	column    string // column of the join table referencing the entity
+	tenanted  bool   // whether the entity table has its own tenant_id, rather than its project's
+}
+
+// labelTargets are the labelable entity types
+var labelTargets = map[models.LabelEntity]labelTarget{
+	models.LabelEntityProject: {name: "Project", table: "projects", joinTable: "project_labels", column: "project_id", tenanted: true},
+	models.LabelEntityTask:    {name: "Task", table: "tasks", joinTable: "task_labels", column: "task_id"},
+	models.LabelEntityIssue:   {name: "Issue", table: "issues", joinTable: "issue_labels", column: "issue_id"},
+	models.LabelEntityRisk:    {name: "Risk", table: "risks", joinTable: "risk_labels", column: "risk_id"},
+	models.LabelEntityAsset:   {name: "Asset", table: "assets", joinTable: "asset_labels", column: "asset_id", tenanted: true},
+}
+
+// labelSetRequest is the payload that adds labels to a record or replaces its labels
+type labelSetRequest struct {
+	LabelIDs []uint `json:"label_ids"`
+}
+
+// labelUsage is how often a label is used on each entity type
+type labelUsage struct {
+	Label  models.Label                 `json:"label"`
+	Counts map[models.LabelEntity]int64 `json:"counts"`
+	Total  int64                        `json:"total"`
+}
+
+// GetLabels returns the tenant's label catalogue
+// @Summary Get labels
+// @Description Get the tenant's labels ordered by name
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /labels [get]
+func GetLabels(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var labels []models.Label
+	result := database.DB.Where("tenant_id = ?", tenantID).Order("name ASC").Find(&labels)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve labels",
+		})
+	}
+
+	return c.JSON(labels)
+}
+
+// CreateLabel adds a label to the tenant's catalogue
+// @Summary Create a label
+// @Description Add a label to the tenant's catalogue. Names are unique per tenant regardless of case; colours are #RRGGBB and default to grey.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param label body models.Label true "Label"
+// @Success 201 {object} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /labels [post]
+func CreateLabel(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var label models.Label
+	if err := c.BodyParser(&label); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	label.ID = 0
+	label.TenantID = uint(tenantID)
+	if label.Color == "" {
+		label.Color = models.DefaultLabelColor
+	}
+
+	if msg := validateLabel(&label); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if labelNameTaken(database.DB, &label) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A label named " + label.Name + " already exists",
+		})
+	}
+
+	result := database.DB.Create(&label)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create label: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(label)
+}
+
+// UpdateLabel updates a label
+// @Summary Update a label
+// @Description Replace the name, colour and description of a label. Records keep the label.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Label ID"
+// @Param label body models.Label true "Label"
+// @Success 200 {object} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /labels/{id} [put]
+func UpdateLabel(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var label models.Label
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&label)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Label not found",
+		})
+	}
+
+	var updated models.Label
+	if err := c.BodyParser(&updated); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	label.Name = updated.Name
+	label.Description = updated.Description
+	if updated.Color != "" {
+		label.Color = updated.Color
+	}
+
+	if msg := validateLabel(&label); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if labelNameTaken(database.DB, &label) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A label named " + label.Name + " already exists",
+		})
+	}
+
+	result = database.DB.Save(&label)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update label: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(label)
+}
+
+// DeleteLabel deletes a label and removes it from every record
+// @Summary Delete a label
+// @Description Delete a label from the catalogue and remove it from the projects, tasks, issues, risks and assets carrying it
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Label ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /labels/{id} [delete]
+func DeleteLabel(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var label models.Label
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&label)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Label not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, entity := range models.LabelEntities {
+			if err := tx.Exec("DELETE FROM "+labelTargets[entity].joinTable+" WHERE label_id = ?", label.ID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&label).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete label: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Label deleted successfully",
+	})
+}
+
+// GetLabelStats returns how often each label is used
+// @Summary Get label usage
+// @Description Get for every label of the tenant the number of projects, tasks, issues, risks and assets carrying it, most used first. Deleted records are not counted.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Success 200 {array} labelUsage
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /labels/stats [get]
+func GetLabelStats(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var labels []models.Label
+	result := database.DB.Where("tenant_id = ?", tenantID).Order("name ASC").Find(&labels)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve labels",
+		})
+	}
+
+	usage := make([]labelUsage, len(labels))
+	byID := make(map[uint]*labelUsage, len(labels))
+	for i, label := range labels {
+		usage[i] = labelUsage{Label: label, Counts: make(map[models.LabelEntity]int64, len(models.LabelEntities))}
+		for _, entity := range models.LabelEntities {
+			usage[i].Counts[entity] = 0
+		}
+		byID[label.ID] = &usage[i]
+	}
+
+	for _, entity := range models.LabelEntities {
+		target := labelTargets[entity]
+		var rows []struct {
+			LabelID uint
+			Count   int64
+		}
+		result := database.DB.Table(target.joinTable).
+			Select(target.joinTable+".label_id, COUNT(*) AS count").
+			Joins("JOIN labels ON labels.id = "+target.joinTable+".label_id").
+			Joins("JOIN "+target.table+" ON "+target.table+".id = "+target.joinTable+"."+target.column).
+			Where("labels.tenant_id = ? AND "+target.table+".deleted_at IS NULL", tenantID).
+			Group(target.joinTable + ".label_id").
+			Scan(&rows)
+		if result.Error != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to count " + string(entity) + " labels: " + result.Error.Error(),
+			})
+		}
+		for _, row := range rows {
+			if u, ok := byID[row.LabelID]; ok {
+				u.Counts[entity] = row.Count
+				u.Total += row.Count
+			}
+		}
+	}
+
+	sort.SliceStable(usage, func(i, j int) bool { return usage[i].Total > usage[j].Total })
+	return c.JSON(usage)
+}
+
+// GetProjectLabels returns the labels of a project
+// @Summary Get project labels
+// @Description Get the labels of a project ordered by name
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/labels [get]
+func GetProjectLabels(c *fiber.Ctx) error {
+	return getEntityLabels(c, models.LabelEntityProject)
+}
+
+// AddProjectLabels adds labels to a project
+// @Summary Add project labels
+// @Description Add labels of the tenant's catalogue to a project. Labels it already carries are ignored.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/labels [post]
+func AddProjectLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityProject, false)
+}
+
+// SetProjectLabels replaces the labels of a project
+// @Summary Replace project labels
+// @Description Replace the labels of a project; an empty list removes them all
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/labels [put]
+func SetProjectLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityProject, true)
+}
+
+// RemoveProjectLabel removes a label from a project
+// @Summary Remove a project label
+// @Description Remove a label from a project
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/labels/{label_id} [delete]
+func RemoveProjectLabel(c *fiber.Ctx) error {
+	return removeEntityLabel(c, models.LabelEntityProject)
+}
+
+// GetTaskLabels returns the labels of a task
+// @Summary Get task labels
+// @Description Get the labels of a task ordered by name
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/labels [get]
+func GetTaskLabels(c *fiber.Ctx) error {
+	return getEntityLabels(c, models.LabelEntityTask)
+}
+
+// AddTaskLabels adds labels to a task
+// @Summary Add task labels
+// @Description Add labels of the tenant's catalogue to a task. Labels it already carries are ignored.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/labels [post]
+func AddTaskLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityTask, false)
+}
+
+// SetTaskLabels replaces the labels of a task
+// @Summary Replace task labels
+// @Description Replace the labels of a task; an empty list removes them all
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/labels [put]
+func SetTaskLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityTask, true)
+}
+
+// RemoveTaskLabel removes a label from a task
+// @Summary Remove a task label
+// @Description Remove a label from a task
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/labels/{label_id} [delete]
+func RemoveTaskLabel(c *fiber.Ctx) error {
+	return removeEntityLabel(c, models.LabelEntityTask)
+}
+
+// GetIssueLabels returns the labels of an issue
+// @Summary Get issue labels
+// @Description Get the labels of an issue ordered by name
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Issue ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/labels [get]
+func GetIssueLabels(c *fiber.Ctx) error {
+	return getEntityLabels(c, models.LabelEntityIssue)
+}
+
+// AddIssueLabels adds labels to an issue
+// @Summary Add issue labels
+// @Description Add labels of the tenant's catalogue to an issue. Labels it already carries are ignored.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Issue ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/labels [post]
+func AddIssueLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityIssue, false)
+}
+
+// SetIssueLabels replaces the labels of an issue
+// @Summary Replace issue labels
+// @Description Replace the labels of an issue; an empty list removes them all
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Issue ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/labels [put]
+func SetIssueLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityIssue, true)
+}
+
+// RemoveIssueLabel removes a label from an issue
+// @Summary Remove an issue label
+// @Description Remove a label from an issue
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Issue ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/labels/{label_id} [delete]
+func RemoveIssueLabel(c *fiber.Ctx) error {
+	return removeEntityLabel(c, models.LabelEntityIssue)
+}
+
+// GetRiskLabels returns the labels of a risk
+// @Summary Get risk labels
+// @Description Get the labels of a risk ordered by name
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Risk ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /risks/{id}/labels [get]
+func GetRiskLabels(c *fiber.Ctx) error {
+	return getEntityLabels(c, models.LabelEntityRisk)
+}
+
+// AddRiskLabels adds labels to a risk
+// @Summary Add risk labels
+// @Description Add labels of the tenant's catalogue to a risk. Labels it already carries are ignored.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Risk ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /risks/{id}/labels [post]
+func AddRiskLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityRisk, false)
+}
+
+// SetRiskLabels replaces the labels of a risk
+// @Summary Replace risk labels
+// @Description Replace the labels of a risk; an empty list removes them all
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Risk ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /risks/{id}/labels [put]
+func SetRiskLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityRisk, true)
+}
+
+// RemoveRiskLabel removes a label from a risk
+// @Summary Remove a risk label
+// @Description Remove a label from a risk
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Risk ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /risks/{id}/labels/{label_id} [delete]
+func RemoveRiskLabel(c *fiber.Ctx) error {
+	return removeEntityLabel(c, models.LabelEntityRisk)
+}
+
+// GetAssetLabels returns the labels of an asset
+// @Summary Get asset labels
+// @Description Get the labels of an asset ordered by name
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Asset ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /assets/{id}/labels [get]
+func GetAssetLabels(c *fiber.Ctx) error {
+	return getEntityLabels(c, models.LabelEntityAsset)
+}
+
+// AddAssetLabels adds labels to an asset
+// @Summary Add asset labels
+// @Description Add labels of the tenant's catalogue to an asset. Labels it already carries are ignored.
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Asset ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /assets/{id}/labels [post]
+func AddAssetLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityAsset, false)
+}
+
+// SetAssetLabels replaces the labels of an asset
+// @Summary Replace asset labels
+// @Description Replace the labels of an asset; an empty list removes them all
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Asset ID"
+// @Param labels body labelSetRequest true "Label IDs"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /assets/{id}/labels [put]
+func SetAssetLabels(c *fiber.Ctx) error {
+	return setEntityLabels(c, models.LabelEntityAsset, true)
+}
+
+// RemoveAssetLabel removes a label from an asset
+// @Summary Remove an asset label
+// @Description Remove a label from an asset
+// @Tags labels
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Asset ID"
+// @Param label_id path int true "Label ID"
+// @Success 200 {array} models.Label
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /assets/{id}/labels/{label_id} [delete]
+func RemoveAssetLabel(c *fiber.Ctx) error {
+	return removeEntityLabel(c, models.LabelEntityAsset)
+}
+
+// getEntityLabels lists the labels of a record
+func getEntityLabels(c *fiber.Ctx, entity models.LabelEntity) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	entityID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + string(entity) + " ID format",
+		})
+	}
+
+	if !labelEntityExists(database.DB, entity, entityID, tenantID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": labelTargets[entity].name + " not found",
+		})
+	}
+
+	labels, err := entityLabels(database.DB, entity, entityID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve labels",
+		})
+	}
+
+	return c.JSON(labels)
+}
+
+// setEntityLabels adds labels to a record, or replaces its labels, and returns the labels it then carries
+func setEntityLabels(c *fiber.Ctx, entity models.LabelEntity, replace bool) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	entityID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + string(entity) + " ID format",
+		})
+	}
+
+	if !labelEntityExists(database.DB, entity, entityID, tenantID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": labelTargets[entity].name + " not found",
+		})
+	}
+
+	var req labelSetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if !replace && len(req.LabelIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one label ID is required",
+		})
+	}
+
+	// Labels must come from the tenant's own catalogue
+	var count int64
+	database.DB.Model(&models.Label{}).Where("id IN ? AND tenant_id = ?", req.LabelIDs, tenantID).Count(&count)
+	if int(count) != len(uniqueIDs(req.LabelIDs)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Label not found or not in the same tenant",
+		})
+	}
+
+	target := labelTargets[entity]
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if replace {
+			if err := tx.Exec("DELETE FROM "+target.joinTable+" WHERE "+target.column+" = ?", entityID).Error; err != nil {
+				return err
+			}
+		}
+		for _, labelID := range req.LabelIDs {
+			row := map[string]interface{}{target.column: entityID, "label_id": labelID}
+			if err := tx.Table(target.joinTable).Clauses(clause.OnConflict{DoNothing: true}).Create(row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update labels: " + err.Error(),
+		})
+	}
+
+	labels, err := entityLabels(database.DB, entity, entityID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve labels",
+		})
+	}
+
+	return c.JSON(labels)
+}
+
+// removeEntityLabel removes a label from a record and returns the labels it still carries
+func removeEntityLabel(c *fiber.Ctx, entity models.LabelEntity) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	entityID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + string(entity) + " ID format",
+		})
+	}
+
+	labelID, err := c.ParamsInt("label_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid label ID format",
+		})
+	}
+
+	if !labelEntityExists(database.DB, entity, entityID, tenantID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": labelTargets[entity].name + " not found",
+		})
+	}
+
+	target := labelTargets[entity]
+	result := database.DB.Exec("DELETE FROM "+target.joinTable+" WHERE "+target.column+" = ? AND label_id = ?", entityID, labelID)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove label: " + result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": labelTargets[entity].name + " does not carry this label",
+		})
+	}
+
+	labels, err := entityLabels(database.DB, entity, entityID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve labels",
+		})
+	}
+
+	return c.JSON(labels)
+}
+
+// validateLabel trims a label's name and returns a message describing its first problem
+func validateLabel(label *models.Label) string {
+	label.Name = strings.TrimSpace(label.Name)
+	if label.Name == "" {
+		return "Label name is required"
+	}
+	if len(label.Name) > 50 {
+		return "Label name must be at most 50 characters"
+	}
+	if !models.IsValidLabelColor(label.Color) {
+		return "Label color must be a hex color such as #1e88e5"
+	}
+	if len(label.Description) > 255 {
+		return "Label description must be at most 255 characters"
+	}
+	return ""
+}
+
+// labelNameTaken reports whether another label of the tenant has the same name, ignoring case
+func labelNameTaken(db *gorm.DB, label *models.Label) bool {
+	var count int64
+	db.Model(&models.Label{}).
+		Where("tenant_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", label.TenantID, label.Name, label.ID).
+		Count(&count)
+	return count > 0
+}
+
+// labelEntityExists reports whether a labelable record exists for the tenant
+func labelEntityExists(db *gorm.DB, entity models.LabelEntity, entityID int, tenantID int) bool {
+	target, ok := labelTargets[entity]
+	if !ok {
+		return false
+	}
+
+	var count int64
+	query := db.Table(target.table).Where(target.table+".id = ? AND "+target.table+".deleted_at IS NULL", entityID)
+	if target.tenanted {
+		query = query.Where(target.table+".tenant_id = ?", tenantID)
+	} else {
+		query = query.Joins("JOIN projects ON projects.id = "+target.table+".project_id").
+			Where("projects.tenant_id = ?", tenantID)
+	}
+	query.Count(&count)
+	return count > 0
+}
+
+// entityLabels returns the labels of a record ordered by name
+func entityLabels(db *gorm.DB, entity models.LabelEntity, entityID int) ([]models.Label, error) {
+	target := labelTargets[entity]
+	labels := []models.Label{}
+	err := db.Joins("JOIN "+target.joinTable+" ON "+target.joinTable+".label_id = labels.id").
+		Where(target.joinTable+"."+target.column+" = ?", entityID).
+		Order("labels.name ASC").
+		Find(&labels).Error
+	return labels, err
+}
+
+// labelFilter reads the labels (comma-separated label IDs) and label_match (any or all) query
+// parameters of a list request. It returns a subquery selecting the IDs of the matching records,
+// or nil when no labels are given, or a message describing the first problem.
+func labelFilter(c *fiber.Ctx, db *gorm.DB, entity models.LabelEntity) (*gorm.DB, string) {
+	param := c.Query("labels")
+	if param == "" {
+		return nil, ""
+	}
+
+	var ids []uint
+	for _, part := range strings.Split(param, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return nil, "Invalid label ID: " + part
+		}
+		ids = append(ids, uint(id))
+	}
+	ids = uniqueIDs(ids)
+
+	target := labelTargets[entity]
+	subquery := db.Table(target.joinTable).Select(target.column).Where("label_id IN ?", ids)
+	switch c.Query("label_match", labelMatchAny) {
+	case labelMatchAny:
+	case labelMatchAll:
+		subquery = subquery.Group(target.column).Having("COUNT(DISTINCT label_id) = ?", len(ids))
+	default:
+		return nil, "label_match must be any or all"
+	}
+	return subquery, ""
+}
+
+// uniqueIDs returns the IDs without repetitions, in their first order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,197 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupLabelDB sets up an isolated in-memory SQLite database with labelable records
+func setupLabelDB(t *testing.T) {
+	newTestDB(t)
+
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "manager"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Alpha", Status: "active"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Beta", Status: "active"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Gamma", Status: "active"})
+	database.DB.Create(&models.Issue{ProjectID: 1, Title: "Login fails", Description: "Login fails", ReportedByID: 1})
+	database.DB.Create(&models.Label{TenantID: 2, Name: "Foreign", Color: "#000000"})
+}
+
+func setupLabelApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Get("/labels", handlers.GetLabels)
+	app.Get("/labels/stats", handlers.GetLabelStats)
+	app.Post("/labels", handlers.CreateLabel)
+	app.Put("/labels/:id", handlers.UpdateLabel)
+	app.Delete("/labels/:id", handlers.DeleteLabel)
+	app.Get("/projects", handlers.GetProjects)
+	app.Put("/projects/:id/labels", handlers.SetProjectLabels)
+	app.Post("/projects/:id/labels", handlers.AddProjectLabels)
+	app.Delete("/projects/:id/labels/:label_id", handlers.RemoveProjectLabel)
+	app.Get("/issues/:id/labels", handlers.GetIssueLabels)
+	app.Post("/issues/:id/labels", handlers.AddIssueLabels)
+	return app
+}
+
+func labelNames(t *testing.T, body []byte) []string {
+	var labels []models.Label
+	assert.NoError(t, json.Unmarshal(body, &labels), string(body))
+	names := []string{}
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
+func TestLabelCatalogue(t *testing.T) {
+	setupLabelDB(t)
+	app := setupLabelApp()
+
+	status, body := doRequest(t, app, "POST", "/labels", `{"name": " urgent ", "color": "#E53935"}`)
+	assert.Equal(t, 201, status, string(body))
+	var label models.Label
+	assert.NoError(t, json.Unmarshal(body, &label))
+	assert.Equal(t, "urgent", label.Name)
+
+	status, body = doRequest(t, app, "POST", "/labels", `{"name": "backend"}`)
+	assert.Equal(t, 201, status, string(body))
+	assert.NoError(t, json.Unmarshal(body, &label))
+	assert.Equal(t, models.DefaultLabelColor, label.Color)
+
+	status, _ = doRequest(t, app, "POST", "/labels", `{"name": "Urgent"}`)
+	assert.Equal(t, 409, status, "names are unique regardless of case")
+	status, _ = doRequest(t, app, "POST", "/labels", `{"name": "Foreign"}`)
+	assert.Equal(t, 201, status, "names are unique per tenant")
+	status, _ = doRequest(t, app, "POST", "/labels", `{"name": "red", "color": "red"}`)
+	assert.Equal(t, 400, status, "colours are hex triplets")
+	status, _ = doRequest(t, app, "POST", "/labels", `{"name": "  "}`)
+	assert.Equal(t, 400, status)
+
+	status, _ = doRequest(t, app, "PUT", "/labels/3", `{"name": "Backend"}`)
+	assert.Equal(t, 200, status, "a label may change the case of its own name")
+	status, _ = doRequest(t, app, "PUT", "/labels/3", `{"name": "urgent"}`)
+	assert.Equal(t, 409, status)
+	status, _ = doRequest(t, app, "PUT", "/labels/1", `{"name": "Foreign"}`)
+	assert.Equal(t, 404, status, "another tenant's label")
+
+	status, body = doRequest(t, app, "GET", "/labels", "")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []string{"Backend", "Foreign", "urgent"}, labelNames(t, body))
+}
+
+func TestLabelAssignmentAndFilters(t *testing.T) {
+	setupLabelDB(t)
+	app := setupLabelApp()
+
+	doRequest(t, app, "POST", "/labels", `{"name": "urgent"}`)  // 2
+	doRequest(t, app, "POST", "/labels", `{"name": "backend"}`) // 3
+	doRequest(t, app, "POST", "/labels", `{"name": "unused"}`)  // 4
+
+	status, body := doRequest(t, app, "PUT", "/projects/1/labels", `{"label_ids": [2, 3]}`)
+	assert.Equal(t, 200, status, string(body))
+	assert.Equal(t, []string{"backend", "urgent"}, labelNames(t, body))
+	status, body = doRequest(t, app, "POST", "/projects/2/labels", `{"label_ids": [2, 2]}`)
+	assert.Equal(t, 200, status, string(body))
+	status, body = doRequest(t, app, "POST", "/projects/2/labels", `{"label_ids": [2]}`)
+	assert.Equal(t, 200, status, "adding a label twice is ignored")
+	assert.Equal(t, []string{"urgent"}, labelNames(t, body))
+	doRequest(t, app, "POST", "/projects/3/labels", `{"label_ids": [3]}`)
+
+	status, _ = doRequest(t, app, "PUT", "/projects/1/labels", `{"label_ids": [1]}`)
+	assert.Equal(t, 400, status, "another tenant's label")
+	status, _ = doRequest(t, app, "POST", "/projects/9/labels", `{"label_ids": [2]}`)
+	assert.Equal(t, 404, status)
+
+	status, body = doRequest(t, app, "POST", "/issues/1/labels", `{"label_ids": [3]}`)
+	assert.Equal(t, 200, status, string(body))
+	status, body = doRequest(t, app, "GET", "/issues/1/labels", "")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, []string{"backend"}, labelNames(t, body))
+
+	assert.Equal(t, []string{"Alpha", "Beta", "Gamma"}, projectNames(t, app, "/projects?labels=2,3"))
+	assert.Equal(t, []string{"Alpha"}, projectNames(t, app, "/projects?labels=2,3&label_match=all"))
+	assert.Equal(t, []string{"Alpha", "Beta"}, projectNames(t, app, "/projects?labels=2"))
+	status, _ = doRequest(t, app, "GET", "/projects?labels=2&label_match=some", "")
+	assert.Equal(t, 400, status)
+	status, _ = doRequest(t, app, "GET", "/projects?labels=x", "")
+	assert.Equal(t, 400, status)
+
+	var projects []models.Project
+	_, body = doRequest(t, app, "GET", "/projects?labels=2&label_match=all", "")
+	assert.NoError(t, json.Unmarshal(body, &projects))
+	assert.Len(t, projects[0].Labels, 2, "projects are listed with their labels")
+
+	status, _ = doRequest(t, app, "DELETE", "/projects/2/labels/2", "")
+	assert.Equal(t, 200, status)
+	status, _ = doRequest(t, app, "DELETE", "/projects/2/labels/2", "")
+	assert.Equal(t, 404, status)
+
+	status, body = doRequest(t, app, "GET", "/labels/stats", "")
+	assert.Equal(t, 200, status, string(body))
+	var stats []struct {
+		Label  models.Label                 `json:"label"`
+		Counts map[models.LabelEntity]int64 `json:"counts"`
+		Total  int64                        `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &stats))
+	assert.Len(t, stats, 3)
+	assert.Equal(t, "backend", stats[0].Label.Name)
+	assert.Equal(t, int64(3), stats[0].Total)
+	assert.Equal(t, int64(2), stats[0].Counts[models.LabelEntityProject])
+	assert.Equal(t, int64(1), stats[0].Counts[models.LabelEntityIssue])
+	assert.Equal(t, int64(1), stats[1].Total)
+	assert.Equal(t, int64(0), stats[2].Total)
+
+	// Deleting a label takes it off every record
+	status, _ = doRequest(t, app, "DELETE", "/labels/3", "")
+	assert.Equal(t, 200, status)
+	_, body = doRequest(t, app, "GET", "/issues/1/labels", "")
+	assert.Empty(t, labelNames(t, body))
+	assert.Equal(t, []string{"Alpha"}, projectNames(t, app, "/projects?labels=2,3"))
+}
+
+func TestAssetRequestsMapTagsToLabels(t *testing.T) {
+	setupLabelDB(t)
+	database.DB.Create(&models.AssetCategory{TenantID: 1, Name: "Laptops"})
+	database.DB.Create(&models.Label{TenantID: 1, Name: "Finance", Color: "#ff0000"})
+	app := setupLabelApp()
+	app.Post("/assets", handlers.CreateAsset)
+	app.Put("/assets/:id", handlers.UpdateAsset)
+	app.Get("/assets/:id/labels", handlers.GetAssetLabels)
+
+	req := httptest.NewRequest("POST", "/assets", strings.NewReader(`{"name": "Laptop", "category_id": 1, "tags": "finance, Loaner", "legacy_tags": "ignored"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "1")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Warning"), "deprecated")
+	_, body := doRequest(t, app, "GET", "/assets/1/labels", "")
+	assert.Equal(t, []string{"Finance", "Loaner"}, labelNames(t, body))
+
+	// Tags replace the labels; requests without tags keep them
+	status, body := doRequest(t, app, "PUT", "/assets/1", `{"name": "Laptop", "tags": ["loaner"]}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	status, _ = doRequest(t, app, "PUT", "/assets/1", `{"name": "Laptop 2"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	_, body = doRequest(t, app, "GET", "/assets/1/labels", "")
+	assert.Equal(t, []string{"Loaner"}, labelNames(t, body))
+	status, _ = doRequest(t, app, "PUT", "/assets/1", `{"name": "Laptop", "tags": 3}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	// legacy_tags is read-only
+	var asset models.Asset
+	database.DB.First(&asset, 1)
+	assert.Empty(t, asset.LegacyTags)
+}
@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Page sizes of the notification list
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+)
+
+// notificationCount is the number of unread notifications of a person
+type notificationCount struct {
+	Unread int64                             `json:"unread"`
+	ByType map[models.NotificationType]int64 `json:"by_type"`
+}
+
+// GetNotifications returns a person's notifications, newest first
+// @Summary Get notifications
+// @Description Get the notifications of the person in X-Person-ID, newest first. Notifications the person only wants by email are left out.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "Acting person ID"
+// @Param unread query bool false "Only unread notifications"
+// @Param type query string false "Only notifications of this type"
+// @Param limit query int false "Maximum number of notifications (default 50, at most 200)"
+// @Success 200 {array} models.Notification
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications [get]
+func GetNotifications(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	personID, msg := notificationPerson(c, uint(tenantID))
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultNotificationLimit)
+	if limit < 1 || limit > maxNotificationLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxNotificationLimit),
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ? AND person_id = ? AND email_only = ?", tenantID, personID, false)
+	if c.QueryBool("unread") {
+		query = query.Where("read_at IS NULL")
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+
+	notifications := []models.Notification{}
+	result := query.Preload("Actor").Order("created_at DESC, id DESC").Limit(limit).Find(&notifications)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve notifications",
+		})
+	}
+
+	return c.JSON(notifications)
+}
+
+// GetUnreadNotificationCount returns the number of unread notifications of a person
+// @Summary Get unread notification count
+// @Description Get the number of unread notifications of the person in X-Person-ID, in total and by type
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "Acting person ID"
+// @Success 200 {object} notificationCount
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications/unread-count [get]
+func GetUnreadNotificationCount(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	personID, msg := notificationPerson(c, uint(tenantID))
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	var rows []struct {
+		Type  models.NotificationType
+		Count int64
+	}
+	result := database.DB.Model(&models.Notification{}).
+		Select("type, COUNT(*) AS count").
+		Where("tenant_id = ? AND person_id = ? AND email_only = ? AND read_at IS NULL", tenantID, personID, false).
+		Group("type").
+		Scan(&rows)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to count notifications",
+		})
+	}
+
+	count := notificationCount{ByType: make(map[models.NotificationType]int64, len(rows))}
+	for _, row := range rows {
+		count.ByType[row.Type] = row.Count
+		count.Unread += row.Count
+	}
+
+	return c.JSON(count)
+}
+
+// MarkNotificationRead marks a notification as read
+// @Summary Mark a notification read
+// @Description Mark one of the person's notifications as read. Marking it again keeps the first read time.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "Acting person ID"
+// @Param id path int true "Notification ID"
+// @Success 200 {object} models.Notification
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications/{id}/read [post]
+func MarkNotificationRead(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	personID, msg := notificationPerson(c, uint(tenantID))
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	var notification models.Notification
+	result := database.DB.Where("id = ? AND tenant_id = ? AND person_id = ?", c.Params("id"), tenantID, personID).First(&notification)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Notification not found",
+		})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := database.DB.Model(&notification).Update("read_at", now).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to mark notification read",
+			})
+		}
+	}
+
+	return c.JSON(notification)
+}
+
+// MarkAllNotificationsRead marks all of a person's notifications as read
+// @Summary Mark all notifications read
+// @Description Mark all unread notifications of the person as read, optionally only those of one type
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "Acting person ID"
+// @Param type query string false "Only notifications of this type"
+// @Success 200 {object} map[string]int64
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications/read-all [post]
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	personID, msg := notificationPerson(c, uint(tenantID))
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	query := database.DB.Model(&models.Notification{}).
+		Where("tenant_id = ? AND person_id = ? AND read_at IS NULL", tenantID, personID)
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+
+	result := query.Update("read_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to mark notifications read",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"updated": result.RowsAffected,
+	})
+}
+
+// GetNotificationPreferences returns a person's notification preferences
+// @Summary Get notification preferences
+// @Description Get for every notification type whether the person is notified in the app and by email, and whether emails go out immediately or in the daily digest. Types without a saved preference show the default: in the app only.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "Acting person ID"
+// @Success 200 {array} models.NotificationPreference
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications/preferences [get]
+func GetNotificationPreferences(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	personID, msg := notificationPerson(c, uint(tenantID))
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	preferences, err := personNotificationPreferences(database.DB, uint(tenantID), personID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve notification preferences",
+		})
+	}
+
+	return c.JSON(preferences)
+}
+
+// UpdateNotificationPreferences sets a person's notification preferences
+// @Summary Update notification preferences
+// @Description Set the preferences of the notification types sent; the other types keep theirs. Turning off both in_app and email mutes a type.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string true "Acting person ID"
+// @Param preferences body []models.NotificationPreference true "Preferences"
+// @Success 200 {array} models.NotificationPreference
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications/preferences [put]
+func UpdateNotificationPreferences(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	personID, msg := notificationPerson(c, uint(tenantID))
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	var updates []models.NotificationPreference
+	if err := c.BodyParser(&updates); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	for i := range updates {
+		if !models.IsValidNotificationType(updates[i].Type) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid notification type: " + string(updates[i].Type),
+			})
+		}
+		switch updates[i].Delivery {
+		case "":
+			updates[i].Delivery = models.NotificationDeliveryImmediate
+		case models.NotificationDeliveryImmediate, models.NotificationDeliveryDigest:
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Delivery must be immediate or digest",
+			})
+		}
+		updates[i].ID = 0
+		updates[i].TenantID = uint(tenantID)
+		updates[i].PersonID = personID
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range updates {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "person_id"}, {Name: "type"}},
+				DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "delivery", "updated_at"}),
+			}).Create(&updates[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update notification preferences: " + err.Error(),
+		})
+	}
+
+	preferences, err := personNotificationPreferences(database.DB, uint(tenantID), personID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve notification preferences",
+		})
+	}
+
+	return c.JSON(preferences)
+}
+
+// notificationPerson returns the acting person, whose notifications a request is about.
+// People only see and change their own notifications. It returns a message when there is none.
+func notificationPerson(c *fiber.Ctx, tenantID uint) (uint, string) {
+	actor := actorID(c)
+	if actor == nil {
+		return 0, "X-Person-ID is required"
+	}
+
+	var count int64
+	database.DB.Model(&models.Person{}).Where("id = ? AND tenant_id = ?", *actor, tenantID).Count(&count)
+	if count == 0 {
+		return 0, "Person not found or not in the same tenant"
+	}
+	return *actor, ""
+}
+
+// personNotificationPreferences returns the preferences of a person for every notification type
+func personNotificationPreferences(db *gorm.DB, tenantID, personID uint) ([]models.NotificationPreference, error) {
+	var saved []models.NotificationPreference
+	if err := db.Where("tenant_id = ? AND person_id = ?", tenantID, personID).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	byType := make(map[models.NotificationType]models.NotificationPreference, len(saved))
+	for _, preference := range saved {
+		byType[preference.Type] = preference
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(models.NotificationTypes))
+	for _, notificationType := range models.NotificationTypes {
+		preference, ok := byType[notificationType]
+		if !ok {
+			preference = models.DefaultNotificationPreference(tenantID, personID, notificationType)
+		}
+		preferences = append(preferences, preference)
+	}
+	return preferences, nil
+}
+
+// notify sends a notification to each recipient as their preferences ask. People are told once,
+// and those in except are skipped. It returns the people who were notified.
+func notify(tx *gorm.DB, notification models.Notification, recipients []uint, except ...uint) ([]uint, error) {
+	skip := make(map[uint]bool, len(except))
+	for _, personID := range except {
+		skip[personID] = true
+	}
+
+	var people []uint
+	for _, personID := range recipients {
+		if !skip[personID] {
+			skip[personID] = true
+			people = append(people, personID)
+		}
+	}
+	if len(people) == 0 {
+		return nil, nil
+	}
+
+	var saved []models.NotificationPreference
+	if err := tx.Where("person_id IN ? AND type = ?", people, notification.Type).Find(&saved).Error; err != nil {
+		return nil, err
+	}
+	preferences := make(map[uint]models.NotificationPreference, len(saved))
+	for _, preference := range saved {
+		preferences[preference.PersonID] = preference
+	}
+
+	var notified []uint
+	for _, personID := range people {
+		preference, ok := preferences[personID]
+		if !ok {
+			preference = models.DefaultNotificationPreference(notification.TenantID, personID, notification.Type)
+		}
+		if preference.Muted() {
+			continue
+		}
+
+		n := notification
+		n.ID = 0
+		n.PersonID = personID
+		n.EmailOnly = !preference.InApp
+		n.Email = preference.Email
+		n.Digest = preference.Email && preference.Delivery == models.NotificationDeliveryDigest
+		if err := tx.Create(&n).Error; err != nil {
+			return nil, err
+		}
+		notified = append(notified, personID)
+	}
+	return notified, nil
+}
+
+// withActor returns the people and the actor, if any, so that people are not told about their own actions
+func withActor(actorID *uint, people ...uint) []uint {
+	if actorID != nil {
+		people = append(people, *actorID)
+	}
+	return people
+}
@@ -0,0 +1,212 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupNotificationDB sets up an isolated in-memory SQLite database with one project and three people
+func setupNotificationDB(t *testing.T) {
+	newTestDB(t)
+
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website", Status: "active"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "manager"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Bob", Email: "bob@example.com", Role: "member"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Carol", Email: "carol@example.com", Role: "member"})
+}
+
+func setupNotificationApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Post("/projects/:project_id/tasks", handlers.CreateTask)
+	app.Patch("/tasks/:id", handlers.UpdateTask)
+	app.Post("/tasks/:id/comments", handlers.CreateTaskComment)
+	app.Get("/tasks/:id/watchers", handlers.GetTaskWatchers)
+	app.Post("/tasks/:id/watchers", handlers.WatchTask)
+	app.Delete("/tasks/:id/watchers/:person_id", handlers.UnwatchTask)
+	app.Patch("/projects/:id", handlers.UpdateProject)
+	app.Post("/projects/:id/watchers", handlers.WatchProject)
+	app.Get("/notifications", handlers.GetNotifications)
+	app.Get("/notifications/unread-count", handlers.GetUnreadNotificationCount)
+	app.Post("/notifications/read-all", handlers.MarkAllNotificationsRead)
+	app.Get("/notifications/preferences", handlers.GetNotificationPreferences)
+	app.Put("/notifications/preferences", handlers.UpdateNotificationPreferences)
+	app.Post("/notifications/:id/read", handlers.MarkNotificationRead)
+	return app
+}
+
+// doRequestAs is doRequest made by a person identified with X-Person-ID
+func doRequestAs(t *testing.T, app *fiber.App, personID, method, url, body string) (int, []byte) {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "1")
+	req.Header.Set("X-Person-ID", personID)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+func notificationTypes(t *testing.T, app *fiber.App, personID string) []models.NotificationType {
+	status, body := doRequestAs(t, app, personID, "GET", "/notifications", "")
+	assert.Equal(t, 200, status, string(body))
+
+	var notifications []models.Notification
+	assert.NoError(t, json.Unmarshal(body, &notifications))
+	types := []models.NotificationType{}
+	for _, notification := range notifications {
+		types = append(types, notification.Type)
+	}
+	return types
+}
+
+func TestTaskWatcherNotifications(t *testing.T) {
+	setupNotificationDB(t)
+	app := setupNotificationApp()
+
+	// Alice creates a task for Bob: both watch it and only Bob is told
+	status, body := doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch", "assigned_to_id": 2}`)
+	assert.Equal(t, 201, status, string(body))
+	status, body = doRequest(t, app, "GET", "/tasks/1/watchers", "")
+	assert.Equal(t, 200, status)
+	var watchers []models.Watcher
+	assert.NoError(t, json.Unmarshal(body, &watchers))
+	assert.Len(t, watchers, 2)
+	assert.Empty(t, notificationTypes(t, app, "1"))
+	assert.Equal(t, []models.NotificationType{models.NotificationTypeTaskAssigned}, notificationTypes(t, app, "2"))
+
+	// Carol watches, Bob moves the task; Alice and Carol hear about it
+	status, _ = doRequestAs(t, app, "3", "POST", "/tasks/1/watchers", "")
+	assert.Equal(t, 200, status)
+	status, _ = doRequestAs(t, app, "3", "POST", "/tasks/1/watchers", "")
+	assert.Equal(t, 200, status, "watching twice is harmless")
+	status, body = doRequestAs(t, app, "2", "PATCH", "/tasks/1", `{"title": "Launch", "assigned_to_id": 2, "status": "in_progress"}`)
+	assert.Equal(t, 200, status, string(body))
+	assert.Equal(t, []models.NotificationType{models.NotificationTypeTaskUpdated}, notificationTypes(t, app, "1"))
+	assert.Equal(t, []models.NotificationType{models.NotificationTypeTaskUpdated}, notificationTypes(t, app, "3"))
+	assert.Len(t, notificationTypes(t, app, "2"), 1, "the actor is not told about their own change")
+
+	// Reassigning tells the new assignee once, as an assignment
+	doRequestAs(t, app, "1", "PATCH", "/tasks/1", `{"title": "Launch", "assigned_to_id": 3}`)
+	assert.Equal(t, []models.NotificationType{models.NotificationTypeTaskAssigned, models.NotificationTypeTaskUpdated}, notificationTypes(t, app, "3"))
+	assert.Equal(t, models.NotificationTypeTaskUpdated, notificationTypes(t, app, "2")[0])
+
+	// A comment mentioning Carol gives her a mention only; the other watchers get the comment
+	status, body = doRequest(t, app, "POST", "/tasks/1/comments", `{"author_id": 1, "body": "@carol please check"}`)
+	assert.Equal(t, 201, status, string(body))
+	assert.Equal(t, models.NotificationTypeMention, notificationTypes(t, app, "3")[0])
+	assert.Equal(t, models.NotificationTypeCommentAdded, notificationTypes(t, app, "2")[0])
+	assert.NotContains(t, notificationTypes(t, app, "1"), models.NotificationTypeCommentAdded)
+
+	// Unwatched tasks stay quiet
+	status, _ = doRequest(t, app, "DELETE", "/tasks/1/watchers/1", "")
+	assert.Equal(t, 200, status)
+	status, _ = doRequest(t, app, "DELETE", "/tasks/1/watchers/1", "")
+	assert.Equal(t, 404, status)
+	before := len(notificationTypes(t, app, "1"))
+	doRequestAs(t, app, "3", "PATCH", "/tasks/1", `{"title": "Launch", "assigned_to_id": 3, "status": "todo"}`)
+	assert.Len(t, notificationTypes(t, app, "1"), before)
+
+	// Project watchers hear about status changes
+	doRequestAs(t, app, "2", "POST", "/projects/1/watchers", "")
+	doRequestAs(t, app, "1", "PATCH", "/projects/1", `{"status": "on_hold"}`)
+	assert.Equal(t, models.NotificationTypeProjectUpdated, notificationTypes(t, app, "2")[0])
+}
+
+func TestNotificationCenter(t *testing.T) {
+	setupNotificationDB(t)
+	app := setupNotificationApp()
+
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "One", "assigned_to_id": 2}`)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Two", "assigned_to_id": 2}`)
+	doRequest(t, app, "POST", "/tasks/1/comments", `{"author_id": 1, "body": "@bob hello"}`)
+
+	status, _ := doRequest(t, app, "GET", "/notifications", "")
+	assert.Equal(t, 400, status, "a person is required")
+
+	var count struct {
+		Unread int64                             `json:"unread"`
+		ByType map[models.NotificationType]int64 `json:"by_type"`
+	}
+	status, body := doRequestAs(t, app, "2", "GET", "/notifications/unread-count", "")
+	assert.Equal(t, 200, status, string(body))
+	assert.NoError(t, json.Unmarshal(body, &count))
+	assert.Equal(t, int64(3), count.Unread)
+	assert.Equal(t, int64(2), count.ByType[models.NotificationTypeTaskAssigned])
+
+	// Nobody reads another person's notifications
+	status, body = doRequestAs(t, app, "3", "GET", "/notifications/unread-count?person_id=2", "")
+	assert.Equal(t, 200, status, string(body))
+	assert.NoError(t, json.Unmarshal(body, &count))
+	assert.Equal(t, int64(0), count.Unread)
+
+	status, _ = doRequestAs(t, app, "3", "POST", "/notifications/1/read", "")
+	assert.Equal(t, 404, status, "someone else's notification")
+	status, body = doRequestAs(t, app, "2", "POST", "/notifications/1/read", "")
+	assert.Equal(t, 200, status, string(body))
+	var notification models.Notification
+	assert.NoError(t, json.Unmarshal(body, &notification))
+	assert.NotNil(t, notification.ReadAt)
+
+	_, body = doRequestAs(t, app, "2", "GET", "/notifications?unread=true", "")
+	var unread []models.Notification
+	assert.NoError(t, json.Unmarshal(body, &unread))
+	assert.Len(t, unread, 2)
+
+	status, body = doRequestAs(t, app, "2", "POST", "/notifications/read-all", "")
+	assert.Equal(t, 200, status)
+	assert.JSONEq(t, `{"updated": 2}`, string(body))
+	_, body = doRequestAs(t, app, "2", "GET", "/notifications/unread-count", "")
+	assert.NoError(t, json.Unmarshal(body, &count))
+	assert.Equal(t, int64(0), count.Unread)
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	setupNotificationDB(t)
+	app := setupNotificationApp()
+
+	status, body := doRequestAs(t, app, "2", "GET", "/notifications/preferences", "")
+	assert.Equal(t, 200, status)
+	var preferences []models.NotificationPreference
+	assert.NoError(t, json.Unmarshal(body, &preferences))
+	assert.Len(t, preferences, len(models.NotificationTypes))
+	assert.True(t, preferences[0].InApp)
+	assert.False(t, preferences[0].Email)
+
+	status, _ = doRequestAs(t, app, "2", "PUT", "/notifications/preferences", `[{"type": "task_assigned", "in_app": true, "delivery": "weekly"}]`)
+	assert.Equal(t, 400, status)
+	status, _ = doRequestAs(t, app, "2", "PUT", "/notifications/preferences", `[{"type": "birthday", "in_app": true}]`)
+	assert.Equal(t, 400, status)
+
+	// Bob wants assignments by email only, in the digest, and no comments at all
+	status, body = doRequestAs(t, app, "2", "PUT", "/notifications/preferences",
+		`[{"type": "task_assigned", "in_app": false, "email": true, "delivery": "digest"}, {"type": "comment_added", "in_app": false, "email": false}]`)
+	assert.Equal(t, 200, status, string(body))
+	status, _ = doRequestAs(t, app, "2", "PUT", "/notifications/preferences", `[{"type": "task_assigned", "in_app": false, "email": true, "delivery": "digest"}]`)
+	assert.Equal(t, 200, status, "saving a preference again updates it")
+
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "One", "assigned_to_id": 2}`)
+	doRequest(t, app, "POST", "/tasks/1/comments", `{"author_id": 1, "body": "Looks good"}`)
+
+	assert.Empty(t, notificationTypes(t, app, "2"), "email-only notifications stay out of the center")
+	var notifications []models.Notification
+	database.DB.Where("person_id = ?", 2).Find(&notifications)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, models.NotificationTypeTaskAssigned, notifications[0].Type)
+	assert.True(t, notifications[0].Email)
+	assert.True(t, notifications[0].Digest)
+	assert.True(t, notifications[0].EmailOnly)
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/events"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	eventDispatchBatch = 500 // pending events looked at per run
+	maxEventAttempts   = 10
+	eventRetryDelay    = 10 * time.Second // doubled after every failed attempt
+)
+
+// Events dispatches domain events to in-process subscribers. Webhooks are queued by its
+// built-in "webhooks" subscriber and real-time clients are updated by "realtime"; other
+// packages can add their own before the server starts.
+var Events = newEventBus()
+
+// dispatchMu keeps dispatch runs from overlapping, which would break per-aggregate ordering
+var dispatchMu sync.Mutex
+
+func newEventBus() *events.Bus {
+	bus := &events.Bus{}
+	subscribers := []events.Subscriber{
+		{Name: "webhooks", Types: models.WebhookEvents, Handle: queueWebhooks},
+		{Name: "realtime", Handle: publishRealtime},
+	}
+	for _, s := range subscribers {
+		if err := bus.Subscribe(s); err != nil {
+			panic(err)
+		}
+	}
+	return bus
+}
+
+// statusChange is the data of events about a status change
+type statusChange struct {
+	PreviousStatus string      `json:"previous_status"`
+	Status         string      `json:"status"`
+	Object         interface{} `json:"object"`
+}
+
+// GetEvents returns the tenant's domain events, newest first
+// @Summary Get domain events
+// @Description Get the domain events recorded in the outbox, newest first, with their dispatch status
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param type query string false "Only events of this type, e.g. task.created"
+// @Param aggregate_type query string false "Only events about this kind of aggregate, e.g. task"
+// @Param aggregate_id query int false "Only events about the aggregate with this ID"
+// @Param status query string false "Only events with this status (pending, dispatched, failed or discarded)"
+// @Param limit query int false "Maximum number of events (default 50, at most 200)"
+// @Success 200 {array} models.OutboxEvent
+// @Failure 400 {object} map[string]string
+// @Router /events [get]
+func GetEvents(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultNotificationLimit)
+	if limit < 1 || limit > maxNotificationLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxNotificationLimit),
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ?", tenantID)
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if aggregateType := c.Query("aggregate_type"); aggregateType != "" {
+		query = query.Where("aggregate_type = ?", aggregateType)
+	}
+	if aggregateID := c.Query("aggregate_id"); aggregateID != "" {
+		id, err := strconv.Atoi(aggregateID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid aggregate_id format",
+			})
+		}
+		query = query.Where("aggregate_id = ?", id)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	outbox := []models.OutboxEvent{}
+	result := query.Order("id DESC").Limit(limit).Find(&outbox)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve events",
+		})
+	}
+
+	return c.JSON(outbox)
+}
+
+// RetryEvent dispatches a failed event again
+// @Summary Retry a domain event
+// @Description Put an event that failed to dispatch back in the outbox. Subscribers that already handled it are skipped, and the later events of its aggregate keep waiting for it.
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Event ID"
+// @Success 200 {object} models.OutboxEvent
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /events/{id}/retry [post]
+func RetryEvent(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var event models.OutboxEvent
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&event)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Event not found",
+		})
+	}
+	if event.Status != models.OutboxEventFailed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Only failed events can be retried",
+		})
+	}
+
+	event.Status = models.OutboxEventPending
+	event.Attempts = 0
+	event.NextAttemptAt = nil
+	event.LastError = ""
+	result = database.DB.Model(&event).Select("status", "attempts", "next_attempt_at", "last_error").Updates(&event)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retry event",
+		})
+	}
+
+	return c.JSON(event)
+}
+
+// DiscardEvent gives up on a failed event for good
+// @Summary Discard a domain event
+// @Description Give up on an event that failed to dispatch, releasing the later events of its aggregate. Subscribers that have not handled it never will.
+// @Tags events
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Event ID"
+// @Success 200 {object} models.OutboxEvent
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /events/{id}/discard [post]
+func DiscardEvent(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var event models.OutboxEvent
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&event)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Event not found",
+		})
+	}
+	if event.Status != models.OutboxEventFailed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Only failed events can be discarded",
+		})
+	}
+
+	event.Status = models.OutboxEventDiscarded
+	result = database.DB.Model(&event).Select("status").Updates(&event)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to discard event",
+		})
+	}
+
+	return c.JSON(event)
+}
+
+// DispatchEvents hands pending outbox events to their subscribers. Events of an aggregate are
+// dispatched one at a time, oldest first: an event that a subscriber fails to handle is retried
+// with a doubling delay, and holds back the later events of its aggregate until it is
+// dispatched. After maxEventAttempts it is marked failed and keeps holding them back until it
+// is retried or discarded. Each round takes the oldest open event of every aggregate, so an
+// aggregate with a long backlog does not hold up the others. It is run by the scheduler every
+// second and hands out at most eventDispatchBatch events per run.
+func DispatchEvents(now time.Time) error {
+	dispatchMu.Lock()
+	defer dispatchMu.Unlock()
+
+	var errs []error
+	for attempted := 0; attempted < eventDispatchBatch; {
+		heads, err := dueAggregateHeads(now, eventDispatchBatch-attempted)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		progress := false
+		for i := range heads {
+			event := &heads[i]
+			attempted++
+			if _, err := dispatchEvent(event, now); err != nil {
+				errs = append(errs, fmt.Errorf("event %d: %w", event.ID, err))
+			}
+			if event.Status == models.OutboxEventDispatched {
+				progress = true
+			}
+		}
+
+		// Only a dispatched event lets the next event of its aggregate go
+		if !progress {
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// dueAggregateHeads returns the oldest open event of each aggregate, when it is pending and
+// due, oldest first
+func dueAggregateHeads(now time.Time, limit int) ([]models.OutboxEvent, error) {
+	heads := database.DB.Model(&models.OutboxEvent{}).Select("MIN(id)").
+		Where("status IN ?", models.OutboxEventsOpen).
+		Group("aggregate_type, aggregate_id")
+
+	var due []models.OutboxEvent
+	err := database.DB.Where("id IN (?)", heads).
+		Where("status = ?", models.OutboxEventPending).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("id").Limit(limit).Find(&due).Error
+	return due, err
+}
+
+// dispatchEvent hands an event to the subscribers that have not handled it yet and reports
+// whether it is done with. Each subscriber handles it in its own transaction, which also
+// records that it did.
+func dispatchEvent(event *models.OutboxEvent, now time.Time) (bool, error) {
+	e := events.Event{
+		ID:            event.ID,
+		TenantID:      event.TenantID,
+		Type:          event.Type,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		Data:          json.RawMessage(event.Data),
+		OccurredAt:    event.OccurredAt,
+	}
+
+	var failures []error
+	for _, subscriber := range Events.Subscribers(event.Type) {
+		if handledBy(event, subscriber.Name) {
+			continue
+		}
+
+		handled := append(append([]string{}, event.HandledBy...), subscriber.Name)
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := subscriber.Handle(tx, e); err != nil {
+				return err
+			}
+			mark := models.OutboxEvent{ID: event.ID, HandledBy: handled}
+			return tx.Model(&mark).Select("handled_by").Updates(&mark).Error
+		})
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", subscriber.Name, err))
+			continue
+		}
+		event.HandledBy = handled
+	}
+
+	if len(failures) == 0 {
+		event.Status = models.OutboxEventDispatched
+		event.DispatchedAt = &now
+		event.NextAttemptAt = nil
+		event.LastError = ""
+	} else {
+		event.Attempts++
+		event.LastError = errors.Join(failures...).Error()
+		if event.Attempts >= maxEventAttempts {
+			event.Status = models.OutboxEventFailed
+			event.NextAttemptAt = nil
+		} else {
+			next := now.Add(eventRetryDelay << (event.Attempts - 1))
+			event.NextAttemptAt = &next
+		}
+		log.Printf("Failed to dispatch event %d (%s): %s", event.ID, event.Type, event.LastError)
+	}
+
+	err := database.DB.Model(event).
+		Select("status", "attempts", "next_attempt_at", "last_error", "dispatched_at").Updates(event).Error
+	return event.Status != models.OutboxEventPending, err
+}
+
+// publishEvent writes a domain event about an aggregate to the outbox. Run it in the
+// transaction of the change so that only changes that were saved are dispatched.
+func publishEvent(tx *gorm.DB, tenantID uint, eventType string, aggregateID uint, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	event := models.OutboxEvent{
+		TenantID:      tenantID,
+		Type:          eventType,
+		AggregateType: events.AggregateType(eventType),
+		AggregateID:   aggregateID,
+		Data:          string(payload),
+		Status:        models.OutboxEventPending,
+		OccurredAt:    time.Now(),
+	}
+	return tx.Create(&event).Error
+}
+
+// handledBy reports whether the subscriber has handled the event
+func handledBy(event *models.OutboxEvent, subscriber string) bool {
+	for _, name := range event.HandledBy {
+		if name == subscriber {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/events"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// recordingSubscriber subscribes to task events, remembers the events it handled and fails
+// the events it is told to
+type recordingSubscriber struct {
+	handled []string
+	fail    map[string]int // times to fail, by "<type> #<aggregate>"
+}
+
+func subscribeRecorder(t *testing.T) *recordingSubscriber {
+	recorder := &recordingSubscriber{fail: map[string]int{}}
+	err := handlers.Events.Subscribe(events.Subscriber{
+		Name:  "recorder",
+		Types: []string{models.EventTaskCreated, models.EventTaskUpdated},
+		Handle: func(tx *gorm.DB, e events.Event) error {
+			key := fmt.Sprintf("%s #%d", e.Type, e.AggregateID)
+			if recorder.fail[key] > 0 {
+				recorder.fail[key]--
+				return errors.New("search index unavailable")
+			}
+			recorder.handled = append(recorder.handled, key)
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() { handlers.Events.Unsubscribe("recorder") })
+	return recorder
+}
+
+func setupOutboxApp() *fiber.App {
+	app := setupNotificationApp()
+	app.Get("/events", handlers.GetEvents)
+	app.Post("/events/:id/retry", handlers.RetryEvent)
+	return app
+}
+
+func outboxEvents(t *testing.T, app *fiber.App, query string) []models.OutboxEvent {
+	status, body := doRequest(t, app, "GET", "/events"+query, "")
+	assert.Equal(t, 200, status, string(body))
+
+	var outbox []models.OutboxEvent
+	assert.NoError(t, json.Unmarshal(body, &outbox))
+	return outbox
+}
+
+func TestDomainEventsAreOrderedPerAggregate(t *testing.T) {
+	setupNotificationDB(t)
+	app := setupOutboxApp()
+	recorder := subscribeRecorder(t)
+	recorder.fail["task.created #1"] = 1
+
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch"}`)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Docs"}`)
+	doRequestAs(t, app, "1", "PATCH", "/tasks/1", `{"title": "Launch v2"}`)
+
+	// Events are written with the change and wait in the outbox
+	outbox := outboxEvents(t, app, "?aggregate_type=task&aggregate_id=1")
+	assert.Len(t, outbox, 2)
+	assert.Equal(t, models.EventTaskUpdated, outbox[0].Type)
+	assert.Equal(t, models.OutboxEventPending, outbox[0].Status)
+
+	// A failed event holds back the later events of its task, but not of other tasks
+	now := time.Now()
+	assert.NoError(t, handlers.DispatchEvents(now))
+	assert.Equal(t, []string{"task.created #2"}, recorder.handled)
+	failed := outboxEvents(t, app, "?type=task.created&aggregate_id=1")[0]
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Equal(t, "recorder: search index unavailable", failed.LastError)
+	assert.Equal(t, []string{"webhooks", "realtime"}, failed.HandledBy, "subscribers that succeeded are not run again")
+	assert.WithinDuration(t, now.Add(10*time.Second), *failed.NextAttemptAt, time.Second)
+
+	assert.NoError(t, handlers.DispatchEvents(now.Add(5*time.Second)))
+	assert.Len(t, recorder.handled, 1, "retries wait for their delay")
+
+	assert.NoError(t, handlers.DispatchEvents(now.Add(10*time.Second)))
+	assert.Equal(t, []string{"task.created #2", "task.created #1", "task.updated #1"}, recorder.handled)
+	assert.Empty(t, outboxEvents(t, app, "?status=pending"))
+
+	// Dispatched events are not dispatched again
+	assert.NoError(t, handlers.DispatchEvents(now.Add(time.Minute)))
+	assert.Len(t, recorder.handled, 3)
+}
+
+func TestDomainEventsGiveUpAndRetry(t *testing.T) {
+	setupNotificationDB(t)
+	app := setupOutboxApp()
+	recorder := subscribeRecorder(t)
+	recorder.fail["task.created #1"] = 100
+	recorder.fail["task.created #2"] = 100
+
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch"}`)
+	doRequestAs(t, app, "1", "PATCH", "/tasks/1", `{"title": "Launch v2"}`)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Docs"}`)
+	doRequestAs(t, app, "1", "PATCH", "/tasks/2", `{"title": "Docs v2"}`)
+
+	now := time.Now()
+	for i := 0; i < 12; i++ {
+		assert.NoError(t, handlers.DispatchEvents(now))
+		now = now.Add(24 * time.Hour)
+	}
+
+	// Events that keep failing are given up on, and keep holding back the events after them
+	failed := outboxEvents(t, app, "?status=failed")
+	assert.Len(t, failed, 2)
+	assert.Equal(t, models.EventTaskCreated, failed[0].Type)
+	assert.Equal(t, 10, failed[0].Attempts)
+	assert.Empty(t, recorder.handled)
+	assert.NoError(t, handlers.DispatchEvents(now))
+	assert.Len(t, outboxEvents(t, app, "?status=pending"), 2)
+
+	app.Post("/events/:id/discard", handlers.DiscardEvent)
+	pending := outboxEvents(t, app, "?status=pending")
+	status, _ := doRequest(t, app, "POST", fmt.Sprintf("/events/%d/retry", pending[0].ID), "")
+	assert.Equal(t, 409, status)
+	status, _ = doRequest(t, app, "POST", fmt.Sprintf("/events/%d/discard", pending[0].ID), "")
+	assert.Equal(t, 409, status)
+
+	// A retried event goes before the events it held back
+	recorder.fail["task.created #1"] = 0
+	status, body := doRequest(t, app, "POST", fmt.Sprintf("/events/%d/retry", failed[1].ID), "")
+	assert.Equal(t, 200, status, string(body))
+	assert.NoError(t, handlers.DispatchEvents(now))
+	assert.Equal(t, []string{"task.created #1", "task.updated #1"}, recorder.handled)
+
+	// A discarded event releases them without being dispatched
+	status, body = doRequest(t, app, "POST", fmt.Sprintf("/events/%d/discard", failed[0].ID), "")
+	assert.Equal(t, 200, status, string(body))
+	assert.NoError(t, handlers.DispatchEvents(now))
+	assert.Equal(t, []string{"task.created #1", "task.updated #1", "task.updated #2"}, recorder.handled)
+	assert.Empty(t, outboxEvents(t, app, "?status=failed"))
+	assert.Len(t, outboxEvents(t, app, "?status=discarded"), 1)
+}
+
+func TestDomainEventBacklogDoesNotStarveOtherAggregates(t *testing.T) {
+	setupNotificationDB(t)
+	recorder := subscribeRecorder(t)
+	recorder.fail["task.updated #1"] = 1
+
+	backlog := make([]models.OutboxEvent, 600)
+	for i := range backlog {
+		backlog[i] = models.OutboxEvent{TenantID: 1, Type: models.EventTaskUpdated, AggregateType: "task", AggregateID: 1,
+			Data: `{"id": 1, "project_id": 1}`, Status: models.OutboxEventPending, OccurredAt: time.Now()}
+	}
+	assert.NoError(t, database.DB.Create(&backlog).Error)
+	assert.NoError(t, database.DB.Create(&models.OutboxEvent{TenantID: 1, Type: models.EventTaskUpdated, AggregateType: "task", AggregateID: 2,
+		Data: `{"id": 2, "project_id": 1}`, Status: models.OutboxEventPending, OccurredAt: time.Now()}).Error)
+
+	assert.NoError(t, handlers.DispatchEvents(time.Now()))
+	assert.Equal(t, []string{"task.updated #2"}, recorder.handled, "the held back task does not hold up the other")
+}
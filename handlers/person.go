@@ -35,6 +35,13 @@ func GetPeople(c *fiber.Ctx) error {
 		})
 	}
 
+	cfQuery, msg := parseCustomFieldQuery(c, database.DB, uint(tenantID), models.CustomFieldEntityPerson)
+	if msg != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
 	var people []models.Person
 	result := database.DB.Where("tenant_id = ?", tenantID).Find(&people)
 	if result.Error != nil {
@@ -43,6 +50,7 @@ func GetPeople(c *fiber.Ctx) error {
 		})
 	}
 
+	people = applyCustomFieldQuery(cfQuery, people, func(person *models.Person) models.CustomFieldValues { return person.CustomFields })
 	return c.JSON(people)
 }
 
@@ -149,8 +157,22 @@ func CreatePerson(c *fiber.Ctx) error {
 		})
 	}
 
+	if msg := validateCapacity(person); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
 	person.TenantID = uint(tenantID)
 
+	values, msg := applyCustomFields(database.DB, person.TenantID, models.CustomFieldEntityPerson, nil, person.CustomFields)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	person.CustomFields = values
+
 	result := database.DB.Create(&person)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -212,10 +234,27 @@ func UpdatePerson(c *fiber.Ctx) error {
 		})
 	}
 
+	if msg := validateCapacity(updatedPerson); msg != "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
 	// Ensure tenant ID cannot be changed
 	updatedPerson.TenantID = uint(tenantID)
 	updatedPerson.ID = uint(id)
 
+	// Custom fields that are sent are set; the rest keep their values
+	if updatedPerson.CustomFields != nil {
+		values, msg := applyCustomFields(database.DB, uint(tenantID), models.CustomFieldEntityPerson, existingPerson.CustomFields, updatedPerson.CustomFields)
+		if msg != "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		updatedPerson.CustomFields = values
+	}
+
 	result = database.DB.Model(&existingPerson).Updates(updatedPerson)
 	if result.Error != nil {
 		return c.Status(500).JSON(fiber.Map{
@@ -280,3 +319,17 @@ func DeletePerson(c *fiber.Ctx) error {
 		"message": "Person deleted successfully",
 	})
 }
+
+// validateCapacity checks the capacity fields that are set on a person and returns a message
+// describing the first problem
+func validateCapacity(person *models.Person) string {
+	if person.HoursPerWeek < 0 || person.HoursPerWeek > 168 {
+		return "Hours per week must be between 0 and 168"
+	}
+	if person.WorkingDays != "" {
+		if _, err := models.ParseWorkingDays(person.WorkingDays); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
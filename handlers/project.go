@@ -7,6 +7,7 @@ import (
 	"github.com/Masozee/kontena/api/database"
 	"github.com/Masozee/kontena/api/models"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // GetProjects returns all projects for a tenant
@@ -15,6 +16,8 @@ import (
 // @Tags projects
 // @Accept json
 // @Produce json
+// @Param labels query string false "Comma-separated label IDs"
+// @Param label_match query string false "any (default) or all of the labels"
 // @Success 200 {array} models.Project
 // @Router /projects [get]
 func GetProjects(c *fiber.Ctx) error {
@@ -33,8 +36,27 @@ func GetProjects(c *fiber.Ctx) error {
 		})
 	}
 
+	cfQuery, msg := parseCustomFieldQuery(c, database.DB, uint(tenantID), models.CustomFieldEntityProject)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	labelQuery, msg := labelFilter(c, database.DB, models.LabelEntityProject)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ?", tenantID)
+	if labelQuery != nil {
+		query = query.Where("id IN (?)", labelQuery)
+	}
+
 	var projects []models.Project
-	result := database.DB.Where("tenant_id = ?", tenantID).Find(&projects)
+	result := query.Preload("Labels").Find(&projects)
 
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -42,6 +64,7 @@ func GetProjects(c *fiber.Ctx) error {
 		})
 	}
 
+	projects = applyCustomFieldQuery(cfQuery, projects, func(project *models.Project) models.CustomFieldValues { return project.CustomFields })
 	return c.JSON(projects)
 }
 
@@ -72,7 +95,7 @@ func GetProject(c *fiber.Ctx) error {
 	}
 
 	var project models.Project
-	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&project)
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).Preload("Labels").First(&project)
 
 	if result.Error != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -177,16 +200,30 @@ func CreateProject(c *fiber.Ctx) error {
 	}
 
 	project.TenantID = uint(tenantID)
+	project.Labels = nil // labels are set through /projects/:id/labels
+
+	values, msg := applyCustomFields(database.DB, project.TenantID, models.CustomFieldEntityProject, nil, project.CustomFields)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	project.CustomFields = values
 
 	// Set default status if not provided
 	if project.Status == "" {
 		project.Status = "planning"
 	}
 
-	result := database.DB.Create(&project)
-	if result.Error != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&project).Error; err != nil {
+			return err
+		}
+		return publishEvent(tx, project.TenantID, models.EventProjectCreated, project.ID, project)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create project: " + result.Error.Error(),
+			"error": "Failed to create project: " + err.Error(),
 		})
 	}
 
@@ -241,9 +278,38 @@ func UpdateProject(c *fiber.Ctx) error {
 	// Ensure tenant ID doesn't change
 	updatedProject.TenantID = existingProject.TenantID
 	updatedProject.ID = existingProject.ID
+	updatedProject.Labels = nil
+
+	// Custom fields that are sent are set; the rest keep their values
+	if updatedProject.CustomFields != nil {
+		values, msg := applyCustomFields(database.DB, uint(tenantID), models.CustomFieldEntityProject, existingProject.CustomFields, updatedProject.CustomFields)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		updatedProject.CustomFields = values
+	}
 
-	// Update project
-	database.DB.Model(&existingProject).Updates(updatedProject)
+	// Update project; watchers hear about status changes and the change is recorded as an event
+	previousStatus := existingProject.Status
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existingProject).Updates(updatedProject).Error; err != nil {
+			return err
+		}
+		if updatedProject.Status != "" && updatedProject.Status != previousStatus {
+			if err := notifyProjectStatus(tx, &existingProject, previousStatus, updatedProject.Status, actorID(c)); err != nil {
+				return err
+			}
+		}
+		return publishEvent(tx, existingProject.TenantID, models.EventProjectUpdated, existingProject.ID, existingProject)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update project: " + err.Error(),
+		})
+	}
+	projectDataChanged(existingProject.ID)
 
 	return c.JSON(existingProject)
 }
@@ -285,8 +351,28 @@ func DeleteProject(c *fiber.Ctx) error {
 
 	// Delete project
 	database.DB.Delete(&project)
+	invalidateProjectHealth(project.ID)
 
 	return c.JSON(fiber.Map{
 		"message": "Project deleted successfully",
 	})
 }
+
+// notifyProjectStatus tells a project's watchers that its status changed
+func notifyProjectStatus(tx *gorm.DB, project *models.Project, from, to string, actorID *uint) error {
+	watchers, err := watcherIDs(tx, models.WatchEntityProject, project.ID)
+	if err != nil {
+		return err
+	}
+
+	notification := models.Notification{
+		TenantID:   project.TenantID,
+		Type:       models.NotificationTypeProjectUpdated,
+		EntityType: string(models.WatchEntityProject),
+		EntityID:   project.ID,
+		ActorID:    actorID,
+		Message:    fmt.Sprintf("Project %q moved from %s to %s", project.Name, from, to),
+	}
+	_, err = notify(tx, notification, watchers, withActor(actorID)...)
+	return err
+}
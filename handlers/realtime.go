@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/events"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/realtime"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Realtime streams domain events to clients. It is fed by the "realtime" subscriber of Events.
+var Realtime = realtime.NewHub(realtime.DefaultHistory)
+
+// Stream streams changes on channels as Server-Sent Events
+// @Summary Stream real-time updates
+// @Description Stream the changes on channels as Server-Sent Events. The channels are project:<id> (the project, its tasks and their comments), board:<id> (the tasks on a board), assets (every asset) and asset:<id>. Each event has the type of change as its event and its ID as its id; send the ID of the last event seen in Last-Event-ID to get the events missed while disconnected.
+// @Tags realtime
+// @Produce text/event-stream
+// @Param tenant_id header string true "Tenant ID"
+// @Param channels query string true "Comma-separated channels, e.g. project:1,board:2"
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stream [get]
+func Stream(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	channels, status, msg := streamChannels(uint(tenantID), c.Query("channels"))
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	var lastID uint64
+	if lastEventID != "" {
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid Last-Event-ID",
+			})
+		}
+	}
+
+	subscription, missed := Realtime.Subscribe(uint(tenantID), channels, lastID)
+	heartbeat := Realtime.Heartbeat
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		realtime.Stream(w, subscription, missed, heartbeat)
+	})
+	return nil
+}
+
+// streamChannels parses and checks the channels of a stream request. Channels of projects,
+// boards and assets of other tenants are not found.
+func streamChannels(tenantID uint, list string) ([]string, int, string) {
+	var channels []string
+	for _, channel := range strings.Split(list, ",") {
+		channel = strings.TrimSpace(channel)
+		if channel == "" {
+			continue
+		}
+		if channel == "assets" {
+			channels = append(channels, channel)
+			continue
+		}
+
+		kind, idStr, _ := strings.Cut(channel, ":")
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			return nil, fiber.StatusBadRequest, "Invalid channel: " + channel
+		}
+
+		var count int64
+		switch kind {
+		case "project":
+			database.DB.Model(&models.Project{}).Where("id = ? AND tenant_id = ?", id, tenantID).Count(&count)
+		case "board":
+			database.DB.Model(&models.Board{}).
+				Joins("JOIN projects ON projects.id = boards.project_id").
+				Where("boards.id = ? AND projects.tenant_id = ?", id, tenantID).Count(&count)
+		case "asset":
+			database.DB.Model(&models.Asset{}).Where("id = ? AND tenant_id = ?", id, tenantID).Count(&count)
+		default:
+			return nil, fiber.StatusBadRequest, "Invalid channel: " + channel
+		}
+		if count == 0 {
+			return nil, fiber.StatusNotFound, "Channel not found: " + channel
+		}
+		channels = append(channels, kind+":"+strconv.Itoa(id))
+	}
+
+	if len(channels) == 0 {
+		return nil, fiber.StatusBadRequest, "At least one channel is required"
+	}
+	return channels, 0, ""
+}
+
+// publishRealtime publishes a domain event on the channels it concerns.
+// It is the "realtime" subscriber of the event bus.
+func publishRealtime(tx *gorm.DB, e events.Event) error {
+	channels, err := realtimeChannels(tx, e)
+	if err != nil || len(channels) == 0 {
+		return err
+	}
+
+	_, err = Realtime.Publish(e.TenantID, channels, e.Type, e.Data)
+	return err
+}
+
+// realtimeChannels returns the channels a domain event is published on
+func realtimeChannels(tx *gorm.DB, e events.Event) ([]string, error) {
+	switch e.AggregateType {
+	case "project":
+		return []string{fmt.Sprintf("project:%d", e.AggregateID)}, nil
+	case "task":
+		var task struct {
+			ProjectID uint `json:"project_id"`
+		}
+		if err := e.Decode(&task); err != nil {
+			return nil, err
+		}
+		return projectChannels(tx, task.ProjectID, true)
+	case "comment":
+		var comment models.Comment
+		if err := e.Decode(&comment); err != nil {
+			return nil, err
+		}
+		if comment.EntityType != models.CommentEntityTask {
+			return nil, nil
+		}
+		var projectIDs []uint
+		if err := tx.Model(&models.Task{}).Unscoped().Where("id = ?", comment.EntityID).Pluck("project_id", &projectIDs).Error; err != nil {
+			return nil, err
+		}
+		if len(projectIDs) == 0 {
+			return nil, nil
+		}
+		return projectChannels(tx, projectIDs[0], false)
+	case "asset":
+		return []string{"assets", fmt.Sprintf("asset:%d", e.AggregateID)}, nil
+	}
+	return nil, nil
+}
+
+// projectChannels returns the channel of a project, and with boards the channels of its boards
+func projectChannels(tx *gorm.DB, projectID uint, boards bool) ([]string, error) {
+	channels := []string{fmt.Sprintf("project:%d", projectID)}
+	if !boards {
+		return channels, nil
+	}
+
+	var boardIDs []uint
+	if err := tx.Model(&models.Board{}).Where("project_id = ?", projectID).Pluck("id", &boardIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range boardIDs {
+		channels = append(channels, fmt.Sprintf("board:%d", id))
+	}
+	return channels, nil
+}
@@ -0,0 +1,153 @@
+package handlers_test
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type sseEvent struct {
+	id, event, data string
+	heartbeat       bool
+}
+
+// listen serves the app on a local port and returns its address
+func listen(t *testing.T, app *fiber.App) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + ln.Addr().String()
+}
+
+// openStream opens an event stream and returns its events as they arrive
+func openStream(t *testing.T, url, tenantID, lastEventID string) (<-chan sseEvent, int) {
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("X-Tenant-ID", tenantID)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	stream := make(chan sseEvent, 16)
+	if resp.StatusCode != http.StatusOK {
+		close(stream)
+		return stream, resp.StatusCode
+	}
+
+	go func() {
+		defer close(stream)
+		reader := bufio.NewReader(resp.Body)
+		var e sseEvent
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				if e != (sseEvent{}) {
+					stream <- e
+				}
+				e = sseEvent{}
+			case strings.HasPrefix(line, ": heartbeat"):
+				e.heartbeat = true
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				e.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return stream, resp.StatusCode
+}
+
+// nextEvent returns the next event of a stream that is not a heartbeat
+func nextEvent(t *testing.T, stream <-chan sseEvent) sseEvent {
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e := <-stream:
+			if !e.heartbeat {
+				return e
+			}
+		case <-timeout:
+			t.Fatal("no event received")
+			return sseEvent{}
+		}
+	}
+}
+
+func TestRealtimeStreamsBoardChanges(t *testing.T) {
+	setupNotificationDB(t)
+	database.DB.Create(&models.Tenant{Name: "Other Tenant", Domain: "other.example.com"})
+	database.DB.Create(&models.Project{TenantID: 2, Name: "Secret", Status: "active"})
+	database.DB.Create(&models.Board{ProjectID: 1, Name: "Website"})
+	database.DB.Create(&models.Board{ProjectID: 2, Name: "Secret"})
+
+	heartbeat := handlers.Realtime.Heartbeat
+	handlers.Realtime.Heartbeat = 20 * time.Millisecond
+	t.Cleanup(func() { handlers.Realtime.Heartbeat = heartbeat })
+
+	app := setupNotificationApp()
+	app.Get("/stream", handlers.Stream)
+	url := listen(t, app)
+
+	// Channels must exist in the tenant
+	_, status := openStream(t, url+"/stream", "1", "")
+	assert.Equal(t, 400, status)
+	_, status = openStream(t, url+"/stream?channels=board:2", "1", "")
+	assert.Equal(t, 404, status, "other tenants' boards are not found")
+	_, status = openStream(t, url+"/stream?channels=team:1", "1", "")
+	assert.Equal(t, 400, status)
+
+	stream, status := openStream(t, url+"/stream?channels=board:1", "1", "")
+	assert.Equal(t, 200, status)
+	other, status := openStream(t, url+"/stream?channels=board:2,assets", "2", "")
+	assert.Equal(t, 200, status)
+
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch"}`)
+	assert.NoError(t, handlers.DispatchEvents(time.Now()))
+	created := nextEvent(t, stream)
+	assert.Equal(t, models.EventTaskCreated, created.event)
+	assert.Contains(t, created.data, `"channels":["project:1","board:1"]`)
+	assert.Contains(t, created.data, `"title":"Launch"`)
+
+	// Idle streams get heartbeats
+	select {
+	case e := <-stream:
+		assert.True(t, e.heartbeat)
+	case <-time.After(time.Second):
+		t.Fatal("no heartbeat received")
+	}
+
+	// A client that reconnects gets what it missed
+	doRequestAs(t, app, "1", "PATCH", "/tasks/1", `{"title": "Launch v2"}`)
+	assert.NoError(t, handlers.DispatchEvents(time.Now()))
+	resumed, status := openStream(t, url+"/stream?channels=board:1", "1", created.id)
+	assert.Equal(t, 200, status)
+	updated := nextEvent(t, resumed)
+	assert.Equal(t, models.EventTaskUpdated, updated.event)
+	assert.Contains(t, updated.data, `"title":"Launch v2"`)
+
+	// The other tenant saw none of it
+	select {
+	case e := <-other:
+		assert.True(t, e.heartbeat, "unexpected event %s", e.event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/recurrence"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxCatchUpOccurrences bounds how many overdue occurrences of one series a single scheduler run creates
+const maxCatchUpOccurrences = 100
+
+var errNoOccurrences = errors.New("Recurrence rule has no occurrences")
+
+// taskRecurrenceRequest is the payload for setting a task's recurrence. Omitted fields keep
+// their current value; for a new series the copy options default to true.
+type taskRecurrenceRequest struct {
+	RRule           string     `json:"rrule" example:"FREQ=WEEKLY;BYDAY=MO"`
+	StartDate       *time.Time `json:"start_date"` // defaults to the task's due date
+	SkipWeekends    *bool      `json:"skip_weekends"`
+	CopyAssignee    *bool      `json:"copy_assignee"`
+	CopyDescription *bool      `json:"copy_description"`
+	LeadDays        *int       `json:"lead_days"`
+	Paused          *bool      `json:"paused"`
+}
+
+// GetTaskRecurrence retrieves the recurrence series of a task
+// @Summary Get a task's recurrence
+// @Description Get the recurrence series the task belongs to
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Success 200 {object} models.TaskRecurrence
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tasks/{id}/recurrence [get]
+func GetTaskRecurrence(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	taskID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	var task models.Task
+	result := database.DB.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("tasks.id = ? AND projects.tenant_id = ?", taskID, tenantID).
+		First(&task)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	if task.RecurrenceID == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task does not recur",
+		})
+	}
+
+	var series models.TaskRecurrence
+	result = database.DB.First(&series, *task.RecurrenceID)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Recurrence not found",
+		})
+	}
+
+	return c.JSON(series)
+}
+
+// SetTaskRecurrence makes a task recurring or changes its series
+// @Summary Set a task's recurrence
+// @Description Make a task the first occurrence of a recurring series, or change the rule and options of its series. The rule uses RFC 5545 RRULE syntax. Set paused to stop generating occurrences; resuming skips occurrences that were missed while paused.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param recurrence body taskRecurrenceRequest true "Recurrence rule and options"
+// @Success 200 {object} models.TaskRecurrence
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/recurrence [put]
+func SetTaskRecurrence(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	taskID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	var task models.Task
+	result := database.DB.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("tasks.id = ? AND projects.tenant_id = ?", taskID, tenantID).
+		First(&task)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	req := new(taskRecurrenceRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.LeadDays != nil && *req.LeadDays < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Lead days cannot be negative",
+		})
+	}
+
+	// Load the task's series, or start a new one with the task as its first occurrence
+	series := models.TaskRecurrence{
+		ProjectID:       task.ProjectID,
+		CopyAssignee:    true,
+		CopyDescription: true,
+	}
+	isNew := task.RecurrenceID == nil
+	if !isNew {
+		result = database.DB.First(&series, *task.RecurrenceID)
+		if result.Error != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Recurrence not found",
+			})
+		}
+	}
+	wasPaused := series.Paused
+
+	if req.RRule != "" {
+		series.RRule = req.RRule
+	}
+	if req.StartDate != nil {
+		series.StartDate = *req.StartDate
+	} else if isNew && task.DueDate != nil {
+		series.StartDate = *task.DueDate
+	}
+	if req.SkipWeekends != nil {
+		series.SkipWeekends = *req.SkipWeekends
+	}
+	if req.CopyAssignee != nil {
+		series.CopyAssignee = *req.CopyAssignee
+	}
+	if req.CopyDescription != nil {
+		series.CopyDescription = *req.CopyDescription
+	}
+	if req.LeadDays != nil {
+		series.LeadDays = *req.LeadDays
+	}
+	if req.Paused != nil {
+		series.Paused = *req.Paused
+	}
+
+	if series.RRule == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Recurrence rule is required",
+		})
+	}
+	if series.StartDate.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Start date is required when the task has no due date",
+		})
+	}
+
+	rule, err := recurrence.Parse(series.RRule)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	calendar, err := loadHolidays(database.DB, uint(tenantID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve holidays",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var after time.Time
+		if isNew {
+			first, ok := nextOccurrence(rule, &series, series.StartDate.Add(-time.Second), calendar)
+			if !ok {
+				return errNoOccurrences
+			}
+			if task.DueDate == nil {
+				task.DueDate = &first
+			}
+			series.LastTaskID = &task.ID
+			series.Occurrences = 1
+			after = *task.DueDate
+		} else {
+			// Continue the series after its latest occurrence
+			var last models.Task
+			if series.LastTaskID != nil && tx.First(&last, *series.LastTaskID).Error == nil && last.DueDate != nil {
+				after = *last.DueDate
+			} else {
+				after = series.StartDate.Add(-time.Second)
+			}
+		}
+
+		// Occurrences missed while the series was paused are skipped
+		if wasPaused && !series.Paused {
+			y, m, d := time.Now().Date()
+			if today := time.Date(y, m, d, 0, 0, 0, 0, series.StartDate.Location()); after.Before(today) {
+				after = today.Add(-time.Second)
+			}
+		}
+
+		scheduleRecurrence(rule, &series, after, calendar)
+		if err := tx.Save(&series).Error; err != nil {
+			return err
+		}
+
+		if isNew {
+			task.RecurrenceID = &series.ID
+			return tx.Model(&task).Updates(map[string]interface{}{
+				"recurrence_id": series.ID,
+				"due_date":      task.DueDate,
+			}).Error
+		}
+		return nil
+	})
+	if errors.Is(err, errNoOccurrences) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save recurrence: " + err.Error(),
+		})
+	}
+
+	return c.JSON(series)
+}
+
+// DeleteTaskRecurrence stops a task's series
+// @Summary Stop a task's recurrence
+// @Description Stop the recurring series the task belongs to. Tasks already generated are kept.
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/recurrence [delete]
+func DeleteTaskRecurrence(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	taskID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	var task models.Task
+	result := database.DB.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("tasks.id = ? AND projects.tenant_id = ?", taskID, tenantID).
+		First(&task)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	if task.RecurrenceID == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task does not recur",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Task{}).Where("recurrence_id = ?", *task.RecurrenceID).Update("recurrence_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.TaskRecurrence{}, *task.RecurrenceID).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to stop recurrence: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Recurrence stopped successfully",
+	})
+}
+
+// GenerateRecurringTasks creates every occurrence whose creation time has arrived.
+// It is run periodically by the scheduler.
+func GenerateRecurringTasks(now time.Time) error {
+	var due []models.TaskRecurrence
+	result := database.DB.Where("paused = ? AND next_run_at <= ?", false, now).Find(&due)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var errs []error
+	created := 0
+	changed := make(map[uint]bool) // projects whose computed KPIs need refreshing
+	for i := range due {
+		series := &due[i]
+		for n := 0; n < maxCatchUpOccurrences && series.NextRunAt != nil && !series.NextRunAt.After(now); n++ {
+			err := database.DB.Transaction(func(tx *gorm.DB) error {
+				_, err := createNextOccurrence(tx, series)
+				return err
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("recurrence %d: %w", series.ID, err))
+				break
+			}
+			created++
+			changed[series.ProjectID] = true
+		}
+	}
+
+	for projectID := range changed {
+		projectDataChanged(projectID)
+	}
+	if created > 0 {
+		log.Printf("Created %d recurring tasks", created)
+	}
+	return errors.Join(errs...)
+}
+
+// completeOccurrence creates the next occurrence of a series when its latest task is completed
+func completeOccurrence(tx *gorm.DB, task *models.Task) error {
+	if task.RecurrenceID == nil {
+		return nil
+	}
+
+	var series models.TaskRecurrence
+	if err := tx.First(&series, *task.RecurrenceID).Error; err != nil {
+		return nil
+	}
+
+	// Earlier occurrences, or ones whose successor the scheduler already created, do not generate again
+	if series.Paused || series.LastTaskID == nil || *series.LastTaskID != task.ID {
+		return nil
+	}
+
+	_, err := createNextOccurrence(tx, &series)
+	return err
+}
+
+// isTaskDone reports whether a status completes a task: the built-in completed status or a final workflow state
+func isTaskDone(db *gorm.DB, tenantID uint, task *models.Task) (bool, error) {
+	if task.Status == models.TaskStatusCompleted {
+		return true, nil
+	}
+	def, _, err := resolveWorkflowIn(db, tenantID, &task.ProjectID, models.WorkflowEntityTask)
+	if err != nil {
+		return false, err
+	}
+	return def.IsFinal(string(task.Status)), nil
+}
+
+// createNextOccurrence creates the next task of a series from its latest task and schedules the one after
+func createNextOccurrence(tx *gorm.DB, series *models.TaskRecurrence) (*models.Task, error) {
+	if series.NextDueAt == nil {
+		return nil, nil
+	}
+
+	rule, err := recurrence.Parse(series.RRule)
+	if err != nil {
+		return nil, err
+	}
+
+	var project models.Project
+	if err := tx.First(&project, series.ProjectID).Error; err != nil {
+		return nil, err
+	}
+
+	var previous models.Task
+	result := tx.Where("recurrence_id = ?", series.ID).Order("due_date DESC, id DESC").First(&previous)
+	if result.Error != nil {
+		return nil, errors.New("series has no task to copy")
+	}
+
+	calendar, err := loadHolidays(tx, project.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	def, _, err := resolveWorkflowIn(tx, project.TenantID, &series.ProjectID, models.WorkflowEntityTask)
+	if err != nil {
+		return nil, err
+	}
+	dueDate := *series.NextDueAt
+	task := models.Task{
+		ProjectID:      series.ProjectID,
+		Title:          previous.Title,
+		Status:         models.TaskStatus(def.InitialState()),
+		EstimatedHours: previous.EstimatedHours,
+		Type:           previous.Type,
+		CustomFields:   previous.CustomFields,
+		DueDate:        &dueDate,
+		RecurrenceID:   &series.ID,
+		Rank:           nextTaskRank(tx, series.ProjectID),
+	}
+	if series.CopyDescription {
+		task.Description = previous.Description
+	}
+	if series.CopyAssignee {
+		task.AssignedToID = previous.AssignedToID
+	}
+
+	if err := checkWIPLimit(tx, task.ProjectID, task.Status); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&task).Error; err != nil {
+		return nil, err
+	}
+	if err := recordTaskState(tx, project.TenantID, &task, nil); err != nil {
+		return nil, err
+	}
+
+	series.LastTaskID = &task.ID
+	series.Occurrences++
+	scheduleRecurrence(rule, series, dueDate, calendar)
+	if err := tx.Save(series).Error; err != nil {
+		return nil, err
+	}
+
+	return &task, nil
+}
+
+// scheduleRecurrence sets when the occurrence after the given due date is due and when it is created
+func scheduleRecurrence(rule *recurrence.Rule, series *models.TaskRecurrence, after time.Time, calendar *workCalendar) {
+	due, ok := nextOccurrence(rule, series, after, calendar)
+	if !ok {
+		series.NextDueAt = nil
+		series.NextRunAt = nil
+		return
+	}
+
+	run := due.AddDate(0, 0, -series.LeadDays)
+	series.NextDueAt = &due
+	series.NextRunAt = &run
+}
+
+// nextOccurrence returns the series' first due date after the given time, moving dates on a weekend
+// or tenant holiday to the next working day if configured
+func nextOccurrence(rule *recurrence.Rule, series *models.TaskRecurrence, after time.Time, calendar *workCalendar) (time.Time, bool) {
+	due, ok := rule.Next(series.StartDate, after)
+	if !ok {
+		return time.Time{}, false
+	}
+	if series.SkipWeekends {
+		due = calendar.nextWorkingDay(due)
+	}
+	return due, true
+}
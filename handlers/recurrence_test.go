@@ -0,0 +1,95 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupRecurrenceDB sets up an isolated in-memory SQLite database with one project, one person
+// and a task due on Monday 4 March 2024
+func setupRecurrenceDB(t *testing.T) {
+	newTestDB(t)
+
+	due := time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC)
+	assignee := uint(1)
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Operations"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "manager"})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Weekly report", Description: "Send to board", Status: models.TaskStatusTodo, DueDate: &due, AssignedToID: &assignee})
+}
+
+func setupRecurrenceApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Patch("/tasks/:id", handlers.UpdateTask)
+	app.Put("/tasks/:id/recurrence", handlers.SetTaskRecurrence)
+	return app
+}
+
+func TestCompletingRecurringTaskCreatesNextOccurrence(t *testing.T) {
+	setupRecurrenceDB(t)
+	app := setupRecurrenceApp()
+
+	status, body := doRequest(t, app, "PUT", "/tasks/1/recurrence", `{"rrule":"FREQ=WEEKLY;BYDAY=MO","copy_description":false}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var series models.TaskRecurrence
+	assert.NoError(t, json.Unmarshal(body, &series))
+	assert.True(t, series.NextDueAt.Equal(time.Date(2024, time.March, 11, 9, 0, 0, 0, time.UTC)))
+
+	status, _ = doRequest(t, app, "PATCH", "/tasks/1", `{"title":"Weekly report","status":"completed","assigned_to_id":1,"due_date":"2024-03-04T09:00:00Z"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var next models.Task
+	assert.NoError(t, database.DB.Where("id <> ?", 1).First(&next).Error)
+	assert.Equal(t, "Weekly report", next.Title)
+	assert.Equal(t, "", next.Description)
+	assert.Equal(t, models.TaskStatusTodo, next.Status)
+	assert.Equal(t, uint(1), *next.AssignedToID)
+	assert.Equal(t, series.ID, *next.RecurrenceID)
+	assert.True(t, next.DueDate.Equal(time.Date(2024, time.March, 11, 9, 0, 0, 0, time.UTC)))
+
+	// Completing the same task again does not create another occurrence
+	doRequest(t, app, "PATCH", "/tasks/1", `{"title":"Weekly report","status":"todo"}`)
+	doRequest(t, app, "PATCH", "/tasks/1", `{"title":"Weekly report","status":"completed"}`)
+	var count int64
+	database.DB.Model(&models.Task{}).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestGenerateRecurringTasksHonoursLeadTimeAndPause(t *testing.T) {
+	setupRecurrenceDB(t)
+	app := setupRecurrenceApp()
+	database.DB.Create(&models.Holiday{TenantID: 1, Name: "Easter Monday", Date: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)})
+
+	status, _ := doRequest(t, app, "PUT", "/tasks/1/recurrence", `{"rrule":"FREQ=MONTHLY;BYMONTHDAY=1","start_date":"2024-03-01T09:00:00Z","lead_days":3,"skip_weekends":true}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// 1 June 2024 is a Saturday, so that occurrence is due on Monday 3 June and created three days before
+	assert.NoError(t, handlers.GenerateRecurringTasks(time.Date(2024, time.May, 31, 12, 0, 0, 0, time.UTC)))
+
+	var tasks []models.Task
+	database.DB.Order("due_date ASC").Find(&tasks)
+	assert.Len(t, tasks, 4)
+	// 1 April is a holiday, so that occurrence moves to the Tuesday
+	assert.True(t, tasks[1].DueDate.Equal(time.Date(2024, time.April, 2, 9, 0, 0, 0, time.UTC)))
+	assert.True(t, tasks[3].DueDate.Equal(time.Date(2024, time.June, 3, 9, 0, 0, 0, time.UTC)))
+
+	// A paused series creates nothing
+	status, _ = doRequest(t, app, "PUT", "/tasks/1/recurrence", `{"paused":true}`)
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NoError(t, handlers.GenerateRecurringTasks(time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)))
+
+	var count int64
+	database.DB.Model(&models.Task{}).Count(&count)
+	assert.Equal(t, int64(4), count)
+}
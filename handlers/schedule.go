@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// scheduleHorizonDays is how far ahead auto-scheduling looks for time to fit the work in
+	scheduleHorizonDays = 365
+	// unassignedDailyHours is the work an unassigned task gets done per working day
+	unassignedDailyHours = 8
+)
+
+// autoScheduleRequest controls an auto-schedule run
+type autoScheduleRequest struct {
+	DryRun    bool       `json:"dry_run"`    // preview the schedule without changing any task
+	StartDate *time.Time `json:"start_date"` // defaults to today, or the project start if later
+}
+
+// scheduledTask is a task's current and proposed dates
+type scheduledTask struct {
+	TaskID           uint       `json:"task_id"`
+	Title            string     `json:"title"`
+	AssignedToID     *uint      `json:"assigned_to_id"`
+	Hours            float64    `json:"hours"`
+	CurrentStartDate *time.Time `json:"current_start_date"`
+	CurrentDueDate   *time.Time `json:"current_due_date"`
+	StartDate        time.Time  `json:"start_date"`
+	DueDate          time.Time  `json:"due_date"`
+	ShiftDays        *int       `json:"shift_days"` // days the due date moves, unset when it had none
+	Changed          bool       `json:"changed"`
+	Late             bool       `json:"late"` // due after the project ends
+}
+
+// autoScheduleResponse is a proposed or applied project schedule
+type autoScheduleResponse struct {
+	ProjectID  uint            `json:"project_id"`
+	DryRun     bool            `json:"dry_run"`
+	StartDate  time.Time       `json:"start_date"`
+	FinishDate *time.Time      `json:"finish_date"` // latest due date of the scheduled tasks
+	EndDate    *time.Time      `json:"end_date"`    // the project's planned end
+	Late       bool            `json:"late"`
+	Changed    int             `json:"changed"`
+	Tasks      []scheduledTask `json:"tasks"`
+}
+
+// AutoScheduleProject plans start and due dates for a project's open tasks
+// @Summary Auto-schedule a project
+// @Description Plan start and due dates for the project's open tasks from their estimates and dependencies. Each task starts once the tasks it depends on have finished, and its estimate is booked on its assignee's working hours, leaving out holidays, leave and the time their other projects need, so nobody is over-allocated. Unassigned tasks get 8 hours per weekday. Completed tasks keep their dates. With dry_run the proposed schedule is returned without changing any task.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Param options body autoScheduleRequest false "Schedule options"
+// @Success 200 {object} autoScheduleResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/auto-schedule [post]
+func AutoScheduleProject(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	req := new(autoScheduleRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	now := time.Now()
+	start := maxTime(dayOf(now), dayOf(project.StartDate))
+	if req.StartDate != nil {
+		start = dayOf(*req.StartDate)
+	}
+
+	schedule, err := planSchedule(database.DB, uint(tenantID), &project, start, now)
+	if errors.Is(err, errScheduleTooLong) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to schedule project: " + err.Error(),
+		})
+	}
+	schedule.DryRun = req.DryRun
+
+	if !req.DryRun && schedule.Changed > 0 {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			for _, task := range schedule.Tasks {
+				if !task.Changed {
+					continue
+				}
+				err := tx.Model(&models.Task{}).Where("id = ?", task.TaskID).
+					Updates(map[string]interface{}{"start_date": task.StartDate, "due_date": task.DueDate}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to apply schedule: " + err.Error(),
+			})
+		}
+		projectDataChanged(project.ID)
+	}
+
+	return c.JSON(schedule)
+}
+
+// errScheduleTooLong is returned when a task cannot be fitted in before the scheduling horizon
+var errScheduleTooLong = errors.New("not enough working time to schedule all tasks within a year")
+
+// planSchedule works out start and due dates for a project's open tasks from a date on.
+// Tasks are placed in dependency order, the ones due soonest first. A task starts the working
+// day after the last task it depends on finishes, plus the dependency's lag; its estimate is then
+// booked on the hours its assignee has left after their other projects, so tasks of the project
+// assigned to the same person are done one after another.
+func planSchedule(db *gorm.DB, tenantID uint, project *models.Project, start, now time.Time) (*autoScheduleResponse, error) {
+	schedule := &autoScheduleResponse{ProjectID: project.ID, StartDate: start, EndDate: project.EndDate, Tasks: []scheduledTask{}}
+
+	var tasks []models.Task
+	if err := db.Where("project_id = ?", project.ID).Order("id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return schedule, nil
+	}
+
+	taskIDs := make([]uint, len(tasks))
+	for i := range tasks {
+		taskIDs[i] = tasks[i].ID
+	}
+	var dependencies []models.TaskDependency
+	if err := db.Where("task_id IN ?", taskIDs).Find(&dependencies).Error; err != nil {
+		return nil, err
+	}
+	predecessors := make(map[uint][]models.TaskDependency)
+	for _, dependency := range dependencies {
+		predecessors[dependency.TaskID] = append(predecessors[dependency.TaskID], dependency)
+	}
+
+	// Completed tasks keep their dates and finish on their due date, or today without one
+	def, _, err := resolveWorkflowIn(db, tenantID, &project.ID, models.WorkflowEntityTask)
+	if err != nil {
+		return nil, err
+	}
+	finish := make(map[uint]time.Time)
+	var open []*models.Task
+	assignees := make(map[uint]bool)
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Status == models.TaskStatusCompleted || def.IsFinal(string(task.Status)) {
+			finish[task.ID] = dayOf(now)
+			if task.DueDate != nil {
+				finish[task.ID] = dayOf(*task.DueDate)
+			}
+			continue
+		}
+		open = append(open, task)
+		if task.AssignedToID != nil {
+			assignees[*task.AssignedToID] = true
+		}
+	}
+
+	order, err := scheduleOrder(open, predecessors, finish)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := loadHolidays(db, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	personIDs := make([]uint, 0, len(assignees))
+	for id := range assignees {
+		personIDs = append(personIDs, id)
+	}
+	var people []models.Person
+	if len(personIDs) > 0 {
+		if err := db.Where("tenant_id = ? AND id IN ?", tenantID, personIDs).Find(&people).Error; err != nil {
+			return nil, err
+		}
+	}
+	plan, err := planWorkload(db, tenantID, people, start, scheduleHorizonDays, now, 0)
+	if err != nil {
+		return nil, err
+	}
+	// booked is the time each person spends on this project's tasks as they are placed, by day
+	booked := make(map[uint][]float64, len(people))
+	for _, person := range people {
+		booked[person.ID] = make([]float64, scheduleHorizonDays)
+	}
+
+	for _, task := range order {
+		earliest := start
+		for _, dependency := range predecessors[task.ID] {
+			if done, ok := finish[dependency.DependsOnID]; ok {
+				earliest = maxTime(earliest, done.AddDate(0, 0, 1+dependency.LagDays))
+			}
+		}
+
+		hours := math.Max(task.RemainingEstimate(), 0)
+
+		var taskStart, taskDue time.Time
+		if days, ok := plan.days[personOf(task)]; ok && hours > 0 {
+			// Book the estimate on the hours the assignee has free, day by day
+			personID := *task.AssignedToID
+			d := int(math.Round(earliest.Sub(start).Hours() / 24))
+			for remaining := hours; remaining > 1e-9; d++ {
+				if d >= scheduleHorizonDays {
+					return nil, errScheduleTooLong
+				}
+				free := days[d].capacity - days[d].loadExcept(project.ID) - booked[personID][d]
+				if free <= 1e-9 {
+					continue
+				}
+				spent := math.Min(free, remaining)
+				booked[personID][d] += spent
+				remaining -= spent
+				if taskStart.IsZero() {
+					taskStart = start.AddDate(0, 0, d)
+				}
+				taskDue = start.AddDate(0, 0, d)
+			}
+		} else {
+			// Unassigned work takes a working day per eight hours; a task without an estimate takes one day
+			taskStart = calendar.nextWorkingDay(earliest)
+			taskDue = taskStart
+			for remaining := hours - unassignedDailyHours; remaining > 1e-9; remaining -= unassignedDailyHours {
+				taskDue = calendar.nextWorkingDay(taskDue.AddDate(0, 0, 1))
+				if taskDue.Sub(start) > scheduleHorizonDays*24*time.Hour {
+					return nil, errScheduleTooLong
+				}
+			}
+		}
+		finish[task.ID] = taskDue
+
+		entry := scheduledTask{
+			TaskID:           task.ID,
+			Title:            task.Title,
+			AssignedToID:     task.AssignedToID,
+			Hours:            hours,
+			CurrentStartDate: task.StartDate,
+			CurrentDueDate:   task.DueDate,
+			StartDate:        taskStart,
+			DueDate:          taskDue,
+			Changed:          !sameDay(task.StartDate, taskStart) || !sameDay(task.DueDate, taskDue),
+			Late:             project.EndDate != nil && taskDue.After(dayOf(*project.EndDate)),
+		}
+		if task.DueDate != nil {
+			shift := int(math.Round(taskDue.Sub(dayOf(*task.DueDate)).Hours() / 24))
+			entry.ShiftDays = &shift
+		}
+		schedule.Tasks = append(schedule.Tasks, entry)
+
+		if entry.Changed {
+			schedule.Changed++
+		}
+		if entry.Late {
+			schedule.Late = true
+		}
+		if schedule.FinishDate == nil || taskDue.After(*schedule.FinishDate) {
+			due := taskDue
+			schedule.FinishDate = &due
+		}
+	}
+
+	return schedule, nil
+}
+
+// scheduleOrder sorts open tasks so each comes after the open tasks it depends on. Among tasks
+// that are ready, the one due soonest goes first, then by board rank and ID.
+func scheduleOrder(open []*models.Task, predecessors map[uint][]models.TaskDependency, done map[uint]time.Time) ([]*models.Task, error) {
+	waiting := make(map[uint]int, len(open))
+	successors := make(map[uint][]*models.Task)
+	for _, task := range open {
+		for _, dependency := range predecessors[task.ID] {
+			if _, ok := done[dependency.DependsOnID]; ok {
+				continue
+			}
+			waiting[task.ID]++
+			successors[dependency.DependsOnID] = append(successors[dependency.DependsOnID], task)
+		}
+	}
+
+	var ready []*models.Task
+	for _, task := range open {
+		if waiting[task.ID] == 0 {
+			ready = append(ready, task)
+		}
+	}
+
+	order := make([]*models.Task, 0, len(open))
+	for len(ready) > 0 {
+		sort.SliceStable(ready, func(i, j int) bool {
+			return scheduledBefore(ready[i], ready[j])
+		})
+		task := ready[0]
+		ready = ready[1:]
+		order = append(order, task)
+
+		for _, successor := range successors[task.ID] {
+			waiting[successor.ID]--
+			if waiting[successor.ID] == 0 {
+				ready = append(ready, successor)
+			}
+		}
+	}
+
+	if len(order) < len(open) {
+		return nil, errors.New("task dependencies form a cycle")
+	}
+	return order, nil
+}
+
+// scheduledBefore reports whether a ready task is placed before another
+func scheduledBefore(a, b *models.Task) bool {
+	switch {
+	case a.DueDate != nil && b.DueDate != nil && !a.DueDate.Equal(*b.DueDate):
+		return a.DueDate.Before(*b.DueDate)
+	case a.DueDate != nil && b.DueDate == nil:
+		return true
+	case a.DueDate == nil && b.DueDate != nil:
+		return false
+	case a.Rank != b.Rank:
+		return a.Rank < b.Rank
+	}
+	return a.ID < b.ID
+}
+
+// personOf returns the ID of a task's assignee, or 0
+func personOf(task *models.Task) uint {
+	if task.AssignedToID == nil {
+		return 0
+	}
+	return *task.AssignedToID
+}
+
+// sameDay reports whether a date is set and falls on a day
+func sameDay(date *time.Time, day time.Time) bool {
+	return date != nil && dayOf(*date).Equal(day)
+}
@@ -0,0 +1,119 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupScheduleDB sets up an isolated in-memory SQLite database with a project starting on
+// Monday 7 January 2030, whose developer spends half their time on another project
+func setupScheduleDB(t *testing.T) {
+	newTestDB(t)
+
+	start := time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2030, time.January, 11, 0, 0, 0, 0, time.UTC)
+	eight, sixteen := 8.0, 16.0
+	alice := uint(1)
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website", StartDate: start, EndDate: &end})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Support", StartDate: start.AddDate(-1, 0, 0)})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "developer", HoursPerWeek: 40, WorkingDays: "mon,tue,wed,thu,fri"})
+	database.DB.Create(&models.Allocation{TenantID: 1, ProjectID: 2, PersonID: 1, Percent: 50, StartDate: start.AddDate(-1, 0, 0)})
+	database.DB.Create(&models.Holiday{TenantID: 1, Name: "Company day", Date: start.AddDate(0, 0, 2)})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Design", Status: models.TaskStatusTodo, AssignedToID: &alice, EstimatedHours: &eight})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Build", Status: models.TaskStatusTodo, AssignedToID: &alice, EstimatedHours: &eight})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Docs", Status: models.TaskStatusTodo, EstimatedHours: &sixteen})
+}
+
+func setupScheduleApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Post("/tasks/:id/dependencies", handlers.CreateTaskDependency)
+	app.Post("/projects/:id/auto-schedule", handlers.AutoScheduleProject)
+	return app
+}
+
+func TestTaskDependencies(t *testing.T) {
+	setupScheduleDB(t)
+	app := setupScheduleApp()
+
+	status, body := doRequest(t, app, "POST", "/tasks/2/dependencies", `{"depends_on_id": 1}`)
+	assert.Equal(t, 201, status, string(body))
+
+	status, _ = doRequest(t, app, "POST", "/tasks/2/dependencies", `{"depends_on_id": 1}`)
+	assert.Equal(t, 409, status, "duplicate dependency")
+
+	status, _ = doRequest(t, app, "POST", "/tasks/3/dependencies", `{"depends_on_id": 2}`)
+	assert.Equal(t, 201, status)
+
+	status, _ = doRequest(t, app, "POST", "/tasks/1/dependencies", `{"depends_on_id": 3}`)
+	assert.Equal(t, 409, status, "dependency would close a cycle")
+
+	status, _ = doRequest(t, app, "POST", "/tasks/1/dependencies", `{"depends_on_id": 1}`)
+	assert.Equal(t, 400, status, "task cannot depend on itself")
+}
+
+func TestAutoScheduleProject(t *testing.T) {
+	setupScheduleDB(t)
+	app := setupScheduleApp()
+
+	doRequest(t, app, "POST", "/tasks/2/dependencies", `{"depends_on_id": 1}`)
+	doRequest(t, app, "POST", "/tasks/3/dependencies", `{"depends_on_id": 1, "lag_days": 2}`)
+
+	status, body := doRequest(t, app, "POST", "/projects/1/auto-schedule", `{"dry_run": true}`)
+	assert.Equal(t, 200, status, string(body))
+
+	var schedule struct {
+		Late       bool      `json:"late"`
+		Changed    int       `json:"changed"`
+		FinishDate time.Time `json:"finish_date"`
+		Tasks      []struct {
+			TaskID    uint      `json:"task_id"`
+			StartDate time.Time `json:"start_date"`
+			DueDate   time.Time `json:"due_date"`
+			Late      bool      `json:"late"`
+		} `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &schedule))
+	assert.Len(t, schedule.Tasks, 3)
+	assert.Equal(t, 3, schedule.Changed)
+	assert.True(t, schedule.Late)
+
+	day := func(d int) time.Time { return time.Date(2030, time.January, d, 0, 0, 0, 0, time.UTC) }
+	dates := make(map[uint][2]time.Time)
+	for _, task := range schedule.Tasks {
+		dates[task.TaskID] = [2]time.Time{task.StartDate, task.DueDate}
+	}
+	// Alice has 4 free hours a day, so each 8 hour task takes two days; Wednesday is a holiday
+	assert.Equal(t, [2]time.Time{day(7), day(8)}, dates[1])
+	assert.Equal(t, [2]time.Time{day(10), day(11)}, dates[2])
+	// Unassigned work starts two days after Design and runs over the weekend
+	assert.Equal(t, [2]time.Time{day(11), day(14)}, dates[3])
+	assert.True(t, schedule.FinishDate.Equal(day(14)))
+
+	var task models.Task
+	database.DB.First(&task, 1)
+	assert.Nil(t, task.DueDate, "dry run does not change tasks")
+
+	status, _ = doRequest(t, app, "POST", "/projects/1/auto-schedule", "")
+	assert.Equal(t, 200, status)
+	var build models.Task
+	database.DB.First(&build, 2)
+	if assert.NotNil(t, build.DueDate) && assert.NotNil(t, build.StartDate) {
+		assert.True(t, build.StartDate.Equal(day(10)))
+		assert.True(t, build.DueDate.Equal(day(11)))
+	}
+
+	status, body = doRequest(t, app, "POST", "/projects/1/auto-schedule", `{"dry_run": true}`)
+	assert.Equal(t, 200, status)
+	assert.NoError(t, json.Unmarshal(body, &schedule))
+	assert.Equal(t, 0, schedule.Changed, "applied schedule is stable")
+}
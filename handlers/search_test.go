@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/search"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchAcrossRecords(t *testing.T) {
+	setupNotificationDB(t)
+	database.DB.Create(&models.Tenant{Name: "Other Tenant", Domain: "other.example.com"})
+	database.DB.Create(&models.Project{TenantID: 2, Name: "Dell rollout", Status: "active"})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Order Dell laptops", Status: models.TaskStatusTodo})
+	database.DB.Create(&models.Task{ProjectID: 2, Title: "Image Dell laptops", Status: models.TaskStatusTodo})
+	database.DB.Create(&models.Issue{ProjectID: 1, Title: "Checkout broken", Description: "Fails on the Dell tablet", ReportedByID: 1})
+	database.DB.Create(&models.Document{ProjectID: 1, Name: "Dell quote", FileURL: "https://files.example.com/quote.pdf", UploadedByID: 1})
+	database.DB.Create(&models.AssetCategory{TenantID: 1, Name: "Laptops"})
+	database.DB.Create(&models.Asset{TenantID: 1, Name: "Design laptop", CategoryID: 1, SerialNumber: "SN-48213", Barcode: "0012345", ModelNumber: "XPS 13", Manufacturer: "Dell"})
+	database.DB.Create(&models.Asset{TenantID: 2, Name: "Their laptop", CategoryID: 1, SerialNumber: "SN-48213"})
+	database.DB.Create(&models.Vendor{TenantID: 1, Name: "Dell Technologies", ContactEmail: "sales@dell.example.com"})
+
+	app := setupNotificationApp()
+	app.Get("/search", handlers.Search)
+
+	find := func(url string) []search.Result {
+		status, body := doRequest(t, app, "GET", url, "")
+		assert.Equal(t, fiber.StatusOK, status, string(body))
+		var results []search.Result
+		assert.NoError(t, json.Unmarshal(body, &results))
+		return results
+	}
+
+	results := find("/search?q=dell")
+	found := make(map[string]bool)
+	highlights := make(map[string]string)
+	for _, result := range results {
+		found[result.Type+":"+result.Title] = true
+		highlights[result.Type] = result.Highlight
+	}
+	assert.Equal(t, map[string]bool{
+		"task:Order Dell laptops":  true,
+		"issue:Checkout broken":    true,
+		"document:Dell quote":      true,
+		"asset:Design laptop":      true,
+		"vendor:Dell Technologies": true,
+	}, found, "other tenants' records are not found")
+	assert.Equal(t, "vendor", results[0].Type, "matches in names and contacts rank first")
+	assert.Equal(t, "Fails on the <mark>Dell</mark> tablet", highlights["issue"])
+
+	// Assets are found by serial number, barcode and model
+	for _, q := range []string{"sn-48213", "0012345", "xps"} {
+		results = find("/search?q=" + q)
+		if assert.Len(t, results, 1, q) {
+			assert.Equal(t, "asset", results[0].Type)
+		}
+	}
+
+	results = find("/search?q=dell&types=vendor,document&limit=1")
+	assert.Len(t, results, 1)
+	assert.Equal(t, "vendor", results[0].Type)
+
+	status, _ := doRequest(t, app, "GET", "/search?q=%20-%20", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "GET", "/search?q=dell&types=person", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "GET", "/search?q=dell&limit=500", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
@@ -0,0 +1,1053 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errInvalidCarryOver = errors.New("Carry-over sprint must be a planned or active sprint of the same project")
+
+// sprintTasksRequest is the payload for adding tasks to a sprint
+type sprintTasksRequest struct {
+	TaskIDs []uint `json:"task_ids"`
+}
+
+// closeSprintRequest is the payload for closing a sprint
+type closeSprintRequest struct {
+	CarryOverSprintID *uint `json:"carry_over_sprint_id"` // defaults to the next planned sprint, or the backlog if there is none
+}
+
+// sprintDay is one day of a sprint's burndown and burnup series
+type sprintDay struct {
+	Date            string  `json:"date"`
+	ScopePoints     float64 `json:"scope_points"`
+	CompletedPoints float64 `json:"completed_points"`
+	RemainingPoints float64 `json:"remaining_points"`
+	IdealPoints     float64 `json:"ideal_points"` // remaining points on a straight line from the first day's scope to zero
+	ScopeTasks      int     `json:"scope_tasks"`
+	CompletedTasks  int     `json:"completed_tasks"`
+}
+
+// sprintSeriesResponse is the daily series of a sprint
+type sprintSeriesResponse struct {
+	SprintID uint        `json:"sprint_id"`
+	Days     []sprintDay `json:"days"`
+}
+
+// sprintVelocity is the outcome of one closed sprint
+type sprintVelocity struct {
+	SprintID        uint      `json:"sprint_id"`
+	Name            string    `json:"name"`
+	StartDate       time.Time `json:"start_date"`
+	EndDate         time.Time `json:"end_date"`
+	CommittedPoints float64   `json:"committed_points"`
+	CompletedPoints float64   `json:"completed_points"`
+}
+
+// velocityResponse is the velocity history of a project
+type velocityResponse struct {
+	ProjectID       uint             `json:"project_id"`
+	Sprints         []sprintVelocity `json:"sprints"`
+	AverageVelocity float64          `json:"average_velocity"` // mean completed points over the returned sprints
+}
+
+// GetSprints retrieves all sprints for a project
+// @Summary Get all sprints for a project
+// @Description Get all sprints of a project ordered by start date
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Param status query string false "Filter by status (planned, active, closed)"
+// @Success 200 {array} models.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/sprints [get]
+func GetSprints(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	projectID, err := c.ParamsInt("project_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", projectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	query := database.DB.Where("project_id = ?", projectID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var sprints []models.Sprint
+	result = query.Order("start_date ASC").Find(&sprints)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve sprints: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(sprints)
+}
+
+// GetSprint retrieves a sprint with its tasks
+// @Summary Get a sprint by ID
+// @Description Get a sprint with its tasks
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Sprint ID"
+// @Success 200 {object} models.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /sprints/{id} [get]
+func GetSprint(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprint ID format",
+		})
+	}
+
+	sprint, err := findSprint(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sprint not found",
+		})
+	}
+
+	database.DB.Where("sprint_id = ?", sprint.ID).Preload("AssignedTo").Order("rank ASC, id ASC").Find(&sprint.Tasks)
+
+	return c.JSON(sprint)
+}
+
+// CreateSprint creates a new sprint for a project
+// @Summary Create a sprint
+// @Description Create a planned sprint for a project
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Param sprint body models.Sprint true "Sprint object"
+// @Success 201 {object} models.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/sprints [post]
+func CreateSprint(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	projectID, err := c.ParamsInt("project_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", projectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	sprint := new(models.Sprint)
+	if err := c.BodyParser(sprint); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	// Validate required fields
+	if sprint.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Sprint name is required",
+		})
+	}
+	if sprint.StartDate.IsZero() || sprint.EndDate.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Sprint start and end dates are required",
+		})
+	}
+	if sprint.EndDate.Before(sprint.StartDate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Sprint end date must not be before its start date",
+		})
+	}
+
+	sprint.ID = 0
+	sprint.ProjectID = uint(projectID)
+	sprint.Status = models.SprintStatusPlanned
+	sprint.ClosedAt = nil
+	sprint.CommittedPoints = 0
+	sprint.CompletedPoints = 0
+	sprint.Tasks = nil
+
+	result = database.DB.Create(&sprint)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create sprint: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(sprint)
+}
+
+// UpdateSprint updates a sprint
+// @Summary Update a sprint
+// @Description Update the name, goal and dates of a sprint that is not closed
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Sprint ID"
+// @Param sprint body models.Sprint true "Sprint object"
+// @Success 200 {object} models.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sprints/{id} [patch]
+func UpdateSprint(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprint ID format",
+		})
+	}
+
+	sprint, err := findSprint(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sprint not found",
+		})
+	}
+
+	if sprint.Status == models.SprintStatusClosed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot update a closed sprint",
+		})
+	}
+
+	updatedSprint := new(models.Sprint)
+	if err := c.BodyParser(updatedSprint); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if updatedSprint.Name != "" {
+		sprint.Name = updatedSprint.Name
+	}
+	if updatedSprint.Goal != "" {
+		sprint.Goal = updatedSprint.Goal
+	}
+	if !updatedSprint.StartDate.IsZero() {
+		sprint.StartDate = updatedSprint.StartDate
+	}
+	if !updatedSprint.EndDate.IsZero() {
+		sprint.EndDate = updatedSprint.EndDate
+	}
+	if sprint.EndDate.Before(sprint.StartDate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Sprint end date must not be before its start date",
+		})
+	}
+
+	result := database.DB.Save(&sprint)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update sprint: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(sprint)
+}
+
+// DeleteSprint deletes a sprint that has not been closed
+// @Summary Delete a sprint
+// @Description Delete a planned or active sprint. Its tasks return to the backlog.
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Sprint ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sprints/{id} [delete]
+func DeleteSprint(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprint ID format",
+		})
+	}
+
+	sprint, err := findSprint(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sprint not found",
+		})
+	}
+
+	if sprint.Status == models.SprintStatusClosed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot delete a closed sprint",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var tasks []models.Task
+		if err := tx.Where("sprint_id = ?", sprint.ID).Find(&tasks).Error; err != nil {
+			return err
+		}
+		for i := range tasks {
+			if err := moveTaskToSprint(tx, uint(tenantID), &tasks[i], nil, actorID(c), time.Now()); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&sprint).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete sprint: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Sprint deleted successfully",
+	})
+}
+
+// StartSprint starts a planned sprint
+// @Summary Start a sprint
+// @Description Start a planned sprint. A project can only have one active sprint.
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Sprint ID"
+// @Success 200 {object} models.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sprints/{id}/start [post]
+func StartSprint(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprint ID format",
+		})
+	}
+
+	sprint, err := findSprint(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sprint not found",
+		})
+	}
+
+	if sprint.Status != models.SprintStatusPlanned {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only planned sprints can be started",
+		})
+	}
+
+	var active int64
+	database.DB.Model(&models.Sprint{}).Where("project_id = ? AND status = ?", sprint.ProjectID, models.SprintStatusActive).Count(&active)
+	if active > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Project already has an active sprint",
+		})
+	}
+
+	sprint.Status = models.SprintStatusActive
+	result := database.DB.Save(&sprint)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start sprint: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(sprint)
+}
+
+// CloseSprint closes a sprint and carries over its unfinished tasks
+// @Summary Close a sprint
+// @Description Close a sprint, record its committed and completed points, and move unfinished tasks to carry_over_sprint_id, the next planned sprint, or the backlog
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Sprint ID"
+// @Param close body closeSprintRequest false "Where to carry unfinished tasks over to"
+// @Success 200 {object} models.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sprints/{id}/close [post]
+func CloseSprint(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprint ID format",
+		})
+	}
+
+	sprint, err := findSprint(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sprint not found",
+		})
+	}
+
+	if sprint.Status == models.SprintStatusClosed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Sprint is already closed",
+		})
+	}
+
+	req := new(closeSprintRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	closedAt := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Pick the sprint unfinished tasks move to
+		var target *uint
+		if req.CarryOverSprintID != nil {
+			var next models.Sprint
+			result := tx.Where("id = ? AND project_id = ? AND status <> ?", *req.CarryOverSprintID, sprint.ProjectID, models.SprintStatusClosed).First(&next)
+			if result.Error != nil || next.ID == sprint.ID {
+				return errInvalidCarryOver
+			}
+			target = &next.ID
+		} else {
+			var next models.Sprint
+			result := tx.Where("project_id = ? AND status = ? AND id <> ?", sprint.ProjectID, models.SprintStatusPlanned, sprint.ID).
+				Order("start_date ASC").Limit(1).Find(&next)
+			if result.RowsAffected > 0 {
+				target = &next.ID
+			}
+		}
+
+		// Snapshot the outcome before carrying tasks over, so they still count as committed
+		sprint.Status = models.SprintStatusClosed
+		sprint.ClosedAt = &closedAt
+		days, err := sprintSeries(tx, &sprint)
+		if err != nil {
+			return err
+		}
+		if len(days) > 0 {
+			sprint.CommittedPoints = days[0].ScopePoints
+			sprint.CompletedPoints = days[len(days)-1].CompletedPoints
+		}
+		if err := tx.Save(&sprint).Error; err != nil {
+			return err
+		}
+
+		var tasks []models.Task
+		if err := tx.Where("sprint_id = ?", sprint.ID).Find(&tasks).Error; err != nil {
+			return err
+		}
+		for i := range tasks {
+			done, err := isTaskDone(tx, uint(tenantID), &tasks[i])
+			if err != nil {
+				return err
+			}
+			if done {
+				continue
+			}
+			if err := moveTaskToSprint(tx, uint(tenantID), &tasks[i], target, actorID(c), closedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errInvalidCarryOver) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to close sprint: " + err.Error(),
+		})
+	}
+
+	return c.JSON(sprint)
+}
+
+// AddSprintTasks adds tasks to a sprint
+// @Summary Add tasks to a sprint
+// @Description Add tasks of the sprint's project to a sprint, moving them out of any other sprint
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Sprint ID"
+// @Param tasks body sprintTasksRequest true "Task IDs"
+// @Success 200 {object} models.Sprint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sprints/{id}/tasks [post]
+func AddSprintTasks(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprint ID format",
+		})
+	}
+
+	sprint, err := findSprint(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sprint not found",
+		})
+	}
+
+	if sprint.Status == models.SprintStatusClosed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot add tasks to a closed sprint",
+		})
+	}
+
+	req := new(sprintTasksRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	var tasks []models.Task
+	database.DB.Where("id IN ? AND project_id = ?", req.TaskIDs, sprint.ProjectID).Find(&tasks)
+	if len(req.TaskIDs) == 0 || len(tasks) != len(req.TaskIDs) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tasks not found in the sprint's project",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for i := range tasks {
+			if tasks[i].SprintID != nil && *tasks[i].SprintID == sprint.ID {
+				continue
+			}
+			if err := moveTaskToSprint(tx, uint(tenantID), &tasks[i], &sprint.ID, actorID(c), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to add tasks to sprint: " + err.Error(),
+		})
+	}
+
+	database.DB.Where("sprint_id = ?", sprint.ID).Preload("AssignedTo").Order("rank ASC, id ASC").Find(&sprint.Tasks)
+	return c.JSON(sprint)
+}
+
+// RemoveSprintTask moves a task from a sprint back to the backlog
+// @Summary Remove a task from a sprint
+// @Description Move a task from a sprint back to the backlog
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Sprint ID"
+// @Param task_id path int true "Task ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sprints/{id}/tasks/{task_id} [delete]
+func RemoveSprintTask(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprint ID format",
+		})
+	}
+
+	taskID, err := c.ParamsInt("task_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	sprint, err := findSprint(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sprint not found",
+		})
+	}
+
+	if sprint.Status == models.SprintStatusClosed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot remove tasks from a closed sprint",
+		})
+	}
+
+	var task models.Task
+	result := database.DB.Where("id = ? AND sprint_id = ?", taskID, sprint.ID).First(&task)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found in this sprint",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return moveTaskToSprint(tx, uint(tenantID), &task, nil, actorID(c), time.Now())
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove task from sprint: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Task removed from sprint successfully",
+	})
+}
+
+// GetSprintBurndown returns the daily burndown of a sprint
+// @Summary Get sprint burndown
+// @Description Get the remaining and ideal story points for each day of the sprint, replayed from recorded task changes
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Sprint ID"
+// @Success 200 {object} sprintSeriesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sprints/{id}/burndown [get]
+func GetSprintBurndown(c *fiber.Ctx) error {
+	return sprintSeriesHandler(c)
+}
+
+// GetSprintBurnup returns the daily burnup of a sprint
+// @Summary Get sprint burnup
+// @Description Get the scope and completed story points for each day of the sprint, replayed from recorded task changes
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Sprint ID"
+// @Success 200 {object} sprintSeriesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /sprints/{id}/burnup [get]
+func GetSprintBurnup(c *fiber.Ctx) error {
+	return sprintSeriesHandler(c)
+}
+
+// GetProjectVelocity returns the velocity of a project's closed sprints
+// @Summary Get project velocity
+// @Description Get committed and completed story points of the most recent closed sprints and their average
+// @Tags sprints
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Param limit query int false "Number of recent sprints (default 6)"
+// @Success 200 {object} velocityResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/velocity [get]
+func GetProjectVelocity(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	projectID, err := c.ParamsInt("project_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid project ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", projectID, tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	limit := c.QueryInt("limit", 6)
+	if limit < 1 {
+		limit = 6
+	}
+
+	var sprints []models.Sprint
+	result = database.DB.Where("project_id = ? AND status = ?", projectID, models.SprintStatusClosed).
+		Order("end_date DESC").
+		Limit(limit).
+		Find(&sprints)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve sprints: " + result.Error.Error(),
+		})
+	}
+
+	response := velocityResponse{
+		ProjectID: uint(projectID),
+		Sprints:   []sprintVelocity{},
+	}
+	total := 0.0
+	// Oldest first, so the list reads as a trend
+	for i := len(sprints) - 1; i >= 0; i-- {
+		sprint := sprints[i]
+		response.Sprints = append(response.Sprints, sprintVelocity{
+			SprintID:        sprint.ID,
+			Name:            sprint.Name,
+			StartDate:       sprint.StartDate,
+			EndDate:         sprint.EndDate,
+			CommittedPoints: sprint.CommittedPoints,
+			CompletedPoints: sprint.CompletedPoints,
+		})
+		total += sprint.CompletedPoints
+	}
+	if len(sprints) > 0 {
+		response.AverageVelocity = total / float64(len(sprints))
+	}
+
+	return c.JSON(response)
+}
+
+// sprintSeriesHandler responds with the daily series of the sprint in the id parameter
+func sprintSeriesHandler(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid sprint ID format",
+		})
+	}
+
+	sprint, err := findSprint(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Sprint not found",
+		})
+	}
+
+	days, err := sprintSeries(database.DB, &sprint)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute sprint series: " + err.Error(),
+		})
+	}
+
+	return c.JSON(sprintSeriesResponse{
+		SprintID: sprint.ID,
+		Days:     days,
+	})
+}
+
+// findSprint loads a sprint, checking that its project belongs to the tenant
+func findSprint(tenantID, id uint) (models.Sprint, error) {
+	var sprint models.Sprint
+	result := database.DB.Joins("JOIN projects ON projects.id = sprints.project_id").
+		Where("sprints.id = ? AND projects.tenant_id = ?", id, tenantID).
+		First(&sprint)
+	return sprint, result.Error
+}
+
+// sprintSeries replays the recorded changes of every task that was ever in the sprint and
+// returns the scope and completed work at the end of each day, up to today or the close of the sprint
+func sprintSeries(db *gorm.DB, sprint *models.Sprint) ([]sprintDay, error) {
+	cutoff := time.Now()
+	if sprint.ClosedAt != nil {
+		cutoff = *sprint.ClosedAt
+	}
+
+	var changes []models.TaskStateChange
+	result := db.Where("task_id IN (?)", db.Model(&models.TaskStateChange{}).Select("task_id").Where("sprint_id = ?", sprint.ID)).
+		Where("created_at < ?", cutoff).
+		Order("created_at ASC, id ASC").
+		Find(&changes)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	loc := sprint.StartDate.Location()
+	y, m, d := sprint.StartDate.Date()
+	first := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	y, m, d = sprint.EndDate.Date()
+	last := time.Date(y, m, d, 0, 0, 0, 0, loc)
+
+	var days []sprintDay
+	state := make(map[uint]models.TaskStateChange)
+	next := 0
+	for day := first; !day.After(last) && day.Before(cutoff); day = day.AddDate(0, 0, 1) {
+		end := day.AddDate(0, 0, 1)
+		if end.After(cutoff) {
+			end = cutoff
+		}
+		for next < len(changes) && changes[next].CreatedAt.Before(end) {
+			state[changes[next].TaskID] = changes[next]
+			next++
+		}
+
+		point := sprintDay{Date: day.Format("2006-01-02")}
+		for _, change := range state {
+			if change.Deleted || change.SprintID == nil || *change.SprintID != sprint.ID {
+				continue
+			}
+			points := 0.0
+			if change.StoryPoints != nil {
+				points = *change.StoryPoints
+			}
+			point.ScopePoints += points
+			point.ScopeTasks++
+			if change.Done {
+				point.CompletedPoints += points
+				point.CompletedTasks++
+			}
+		}
+		point.RemainingPoints = point.ScopePoints - point.CompletedPoints
+		days = append(days, point)
+	}
+
+	// The ideal line runs from the first day's scope to zero on the last day of the sprint
+	total := int(last.Sub(first).Hours()/24+0.5) + 1
+	for i := range days {
+		if len(days) == 0 || total <= 1 {
+			break
+		}
+		days[i].IdealPoints = days[0].ScopePoints * float64(total-1-i) / float64(total-1)
+	}
+
+	if days == nil {
+		days = []sprintDay{}
+	}
+	return days, nil
+}
+
+// moveTaskToSprint moves a task to a sprint, or to the backlog when sprintID is nil, and records the change
+func moveTaskToSprint(tx *gorm.DB, tenantID uint, task *models.Task, sprintID *uint, changedByID *uint, at time.Time) error {
+	task.SprintID = sprintID
+	if err := tx.Model(task).Update("sprint_id", sprintID).Error; err != nil {
+		return err
+	}
+	return recordTaskStateAt(tx, tenantID, task, changedByID, at)
+}
+
+// recordTaskState appends the task's current status, estimate and sprint to its history
+func recordTaskState(tx *gorm.DB, tenantID uint, task *models.Task, changedByID *uint) error {
+	return recordTaskStateAt(tx, tenantID, task, changedByID, time.Now())
+}
+
+// recordTaskStateAt is recordTaskState with an explicit time, so changes made by one action share a timestamp
+func recordTaskStateAt(tx *gorm.DB, tenantID uint, task *models.Task, changedByID *uint, at time.Time) error {
+	done, err := isTaskDone(tx, tenantID, task)
+	if err != nil {
+		return err
+	}
+	change := models.TaskStateChange{
+		TaskID:      task.ID,
+		ProjectID:   task.ProjectID,
+		SprintID:    task.SprintID,
+		Status:      task.Status,
+		StoryPoints: task.StoryPoints,
+		Done:        done,
+		Deleted:     task.DeletedAt.Valid,
+		ChangedByID: changedByID,
+		CreatedAt:   at,
+	}
+	return tx.Create(&change).Error
+}
+
+// equalPoints reports whether two optional estimates are the same
+func equalPoints(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
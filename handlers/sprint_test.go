@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupSprintDB sets up an isolated in-memory SQLite database with one project
+func setupSprintDB(t *testing.T) {
+	newTestDB(t)
+
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website"})
+}
+
+func setupSprintApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Post("/projects/:project_id/tasks", handlers.CreateTask)
+	app.Patch("/tasks/:id", handlers.UpdateTask)
+	app.Post("/projects/:project_id/sprints", handlers.CreateSprint)
+	app.Get("/projects/:project_id/velocity", handlers.GetProjectVelocity)
+	app.Post("/sprints/:id/start", handlers.StartSprint)
+	app.Post("/sprints/:id/close", handlers.CloseSprint)
+	app.Get("/sprints/:id/burndown", handlers.GetSprintBurndown)
+	return app
+}
+
+func TestSprintBurndownReplaysRecordedChanges(t *testing.T) {
+	setupSprintDB(t)
+	app := setupSprintApp()
+
+	sprint := models.Sprint{
+		ProjectID: 1,
+		Name:      "Sprint 1",
+		StartDate: time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2024, time.March, 8, 0, 0, 0, 0, time.UTC),
+		Status:    models.SprintStatusActive,
+	}
+	database.DB.Create(&sprint)
+
+	three, five, eight := 3.0, 5.0, 8.0
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "A", Status: models.TaskStatusTodo, SprintID: &sprint.ID, StoryPoints: &three})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "B", Status: models.TaskStatusTodo, SprintID: &sprint.ID, StoryPoints: &eight})
+
+	at := func(day, hour int) time.Time { return time.Date(2024, time.March, day, hour, 0, 0, 0, time.UTC) }
+	database.DB.Create(&[]models.TaskStateChange{
+		{TaskID: 1, ProjectID: 1, SprintID: &sprint.ID, Status: models.TaskStatusTodo, StoryPoints: &three, CreatedAt: at(3, 9)},
+		{TaskID: 2, ProjectID: 1, SprintID: &sprint.ID, Status: models.TaskStatusTodo, StoryPoints: &five, CreatedAt: at(3, 9)},
+		{TaskID: 1, ProjectID: 1, SprintID: &sprint.ID, Status: models.TaskStatusCompleted, StoryPoints: &three, Done: true, CreatedAt: at(5, 10)},
+		{TaskID: 2, ProjectID: 1, SprintID: &sprint.ID, Status: models.TaskStatusTodo, StoryPoints: &eight, CreatedAt: at(6, 15)},
+	})
+
+	status, body := doRequest(t, app, "GET", "/sprints/1/burndown", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var series struct {
+		Days []struct {
+			Date            string  `json:"date"`
+			ScopePoints     float64 `json:"scope_points"`
+			CompletedPoints float64 `json:"completed_points"`
+			RemainingPoints float64 `json:"remaining_points"`
+			IdealPoints     float64 `json:"ideal_points"`
+		} `json:"days"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &series))
+	assert.Len(t, series.Days, 5)
+
+	// Task A's current state is todo, but the history says it was done on 5 March
+	assert.Equal(t, "2024-03-04", series.Days[0].Date)
+	assert.Equal(t, 8.0, series.Days[0].RemainingPoints)
+	assert.Equal(t, 5.0, series.Days[1].RemainingPoints)
+	assert.Equal(t, 11.0, series.Days[2].ScopePoints)
+	assert.Equal(t, 8.0, series.Days[4].RemainingPoints)
+	assert.Equal(t, 8.0, series.Days[0].IdealPoints)
+	assert.Equal(t, 4.0, series.Days[2].IdealPoints)
+	assert.Equal(t, 0.0, series.Days[4].IdealPoints)
+}
+
+func TestCloseSprintCarriesOverUnfinishedTasks(t *testing.T) {
+	setupSprintDB(t)
+	app := setupSprintApp()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for i, name := range []string{"Sprint 1", "Sprint 2"} {
+		start := today.AddDate(0, 0, 14*i).Format(time.RFC3339)
+		end := today.AddDate(0, 0, 14*i+13).Format(time.RFC3339)
+		status, _ := doRequest(t, app, "POST", "/projects/1/sprints", `{"name":"`+name+`","start_date":"`+start+`","end_date":"`+end+`"}`)
+		assert.Equal(t, fiber.StatusCreated, status)
+	}
+
+	status, _ := doRequest(t, app, "POST", "/sprints/1/start", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = doRequest(t, app, "POST", "/sprints/2/start", "")
+	assert.Equal(t, fiber.StatusConflict, status)
+
+	for _, points := range []int{3, 5} {
+		status, _ = doRequest(t, app, "POST", "/projects/1/tasks", `{"title":"Task","sprint_id":1,"story_points":`+strconv.Itoa(points)+`}`)
+		assert.Equal(t, fiber.StatusCreated, status)
+	}
+	status, _ = doRequest(t, app, "PATCH", "/tasks/1", `{"status":"completed"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	status, body := doRequest(t, app, "POST", "/sprints/1/close", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var closed models.Sprint
+	assert.NoError(t, json.Unmarshal(body, &closed))
+	assert.Equal(t, models.SprintStatusClosed, closed.Status)
+	assert.Equal(t, 8.0, closed.CommittedPoints)
+	assert.Equal(t, 3.0, closed.CompletedPoints)
+
+	var done, open models.Task
+	database.DB.First(&done, 1)
+	database.DB.First(&open, 2)
+	assert.Equal(t, uint(1), *done.SprintID)
+	assert.Equal(t, uint(2), *open.SprintID)
+
+	status, body = doRequest(t, app, "GET", "/projects/1/velocity", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var velocity struct {
+		AverageVelocity float64 `json:"average_velocity"`
+		Sprints         []struct {
+			SprintID uint `json:"sprint_id"`
+		} `json:"sprints"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &velocity))
+	assert.Len(t, velocity.Sprints, 1)
+	assert.Equal(t, 3.0, velocity.AverageVelocity)
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Masozee/kontena/api/database"
 	"github.com/Masozee/kontena/api/models"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // GetTasks retrieves all tasks for a specific project
@@ -16,6 +23,8 @@ import (
 // @Produce json
 // @Param tenant_id header string true "Tenant ID"
 // @Param project_id path int true "Project ID"
+// @Param labels query string false "Comma-separated label IDs"
+// @Param label_match query string false "any (default) or all of the labels"
 // @Success 200 {array} models.Task
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -52,14 +61,34 @@ func GetTasks(c *fiber.Ctx) error {
 		})
 	}
 
+	cfQuery, msg := parseCustomFieldQuery(c, database.DB, uint(tenantID), models.CustomFieldEntityTask)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	labelQuery, msg := labelFilter(c, database.DB, models.LabelEntityTask)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	query := database.DB.Where("project_id = ?", projectID)
+	if labelQuery != nil {
+		query = query.Where("id IN (?)", labelQuery)
+	}
+
 	var tasks []models.Task
-	result = database.DB.Where("project_id = ?", projectID).Preload("AssignedTo").Find(&tasks)
+	result = query.Preload("AssignedTo").Preload("Labels").Order("rank ASC, id ASC").Find(&tasks)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to retrieve tasks: " + result.Error.Error(),
 		})
 	}
 
+	tasks = applyCustomFieldQuery(cfQuery, tasks, func(task *models.Task) models.CustomFieldValues { return task.CustomFields })
 	return c.JSON(tasks)
 }
 
@@ -99,7 +128,7 @@ func GetTask(c *fiber.Ctx) error {
 	}
 
 	var task models.Task
-	result := database.DB.Preload("AssignedTo").First(&task, id)
+	result := database.DB.Preload("AssignedTo").Preload("Labels").First(&task, id)
 	if result.Error != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Task not found",
@@ -120,7 +149,7 @@ func GetTask(c *fiber.Ctx) error {
 
 // CreateTask creates a new task for a project
 // @Summary Create a task
-// @Description Create a new task for a project
+// @Description Create a new task for a project. The task is refused when a board column showing its status has reached its WIP limit.
 // @Tags tasks
 // @Accept json
 // @Produce json
@@ -130,6 +159,7 @@ func GetTask(c *fiber.Ctx) error {
 // @Success 201 {object} models.Task
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /projects/{project_id}/tasks [post]
 func CreateTask(c *fiber.Ctx) error {
@@ -178,6 +208,22 @@ func CreateTask(c *fiber.Ctx) error {
 	}
 
 	task.ProjectID = uint(projectID)
+	task.Labels = nil // labels are set through /tasks/:id/labels
+
+	// New tasks start in the workflow's initial state unless a valid state is given
+	def, _, err := resolveWorkflow(uint(tenantID), &task.ProjectID, models.WorkflowEntityTask)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load workflow: " + err.Error(),
+		})
+	}
+	if task.Status == "" {
+		task.Status = models.TaskStatus(def.InitialState())
+	} else if !def.HasState(string(task.Status)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task status: " + string(task.Status),
+		})
+	}
 
 	// Verify assigned person belongs to the same tenant if provided
 	if task.AssignedToID != nil && *task.AssignedToID > 0 {
@@ -190,13 +236,84 @@ func CreateTask(c *fiber.Ctx) error {
 		}
 	}
 
-	result = database.DB.Create(&task)
-	if result.Error != nil {
+	// Verify the sprint belongs to the same project if provided
+	if task.SprintID != nil {
+		var sprint models.Sprint
+		result = database.DB.Where("id = ? AND project_id = ? AND status <> ?", *task.SprintID, task.ProjectID, models.SprintStatusClosed).First(&sprint)
+		if result.Error != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Sprint not found, closed or not in the same project",
+			})
+		}
+	}
+
+	if task.StoryPoints != nil && *task.StoryPoints < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Story points must not be negative",
+		})
+	}
+
+	if task.EstimatedHours != nil && *task.EstimatedHours < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Estimated hours must not be negative",
+		})
+	}
+
+	if task.RemainingHours != nil && *task.RemainingHours < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Remaining hours must not be negative",
+		})
+	}
+
+	if task.StartDate != nil && task.DueDate != nil && task.StartDate.After(*task.DueDate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Start date must not be after the due date",
+		})
+	}
+
+	values, msg := applyCustomFields(database.DB, uint(tenantID), models.CustomFieldEntityTask, nil, task.CustomFields)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	task.CustomFields = values
+
+	// New tasks go to the bottom of their board column
+	task.Rank = nextTaskRank(database.DB, task.ProjectID)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkWIPLimit(tx, task.ProjectID, task.Status); err != nil {
+			return err
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		if err := recordTaskState(tx, uint(tenantID), task, actorID(c)); err != nil {
+			return err
+		}
+		// Whoever creates a task watches it
+		if actor := actorID(c); actor != nil {
+			if err := watch(tx, uint(tenantID), models.WatchEntityTask, task.ID, *actor); err != nil {
+				return err
+			}
+		}
+		if err := notifyTaskAssigned(tx, uint(tenantID), task, actorID(c)); err != nil {
+			return err
+		}
+		return publishEvent(tx, uint(tenantID), models.EventTaskCreated, task.ID, task)
+	})
+	if errors.Is(err, errWIPLimitReached) {
+		return wipLimitReached(c, err)
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create task: " + result.Error.Error(),
+			"error": "Failed to create task: " + err.Error(),
 		})
 	}
 
+	projectDataChanged(task.ProjectID)
+
 	// Load the assigned person if exists
 	if task.AssignedToID != nil {
 		database.DB.Preload("AssignedTo").First(&task, task.ID)
@@ -207,7 +324,7 @@ func CreateTask(c *fiber.Ctx) error {
 
 // UpdateTask updates a task by ID
 // @Summary Update a task
-// @Description Update a task by ID
+// @Description Update the fields of a task that are sent; the others keep their values. A status change is refused when a board column showing the new status has reached its WIP limit.
 // @Tags tasks
 // @Accept json
 // @Produce json
@@ -218,8 +335,9 @@ func CreateTask(c *fiber.Ctx) error {
 // @Failure 400 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
-// @Router /tasks/{id} [put]
+// @Router /tasks/{id} [patch]
 func UpdateTask(c *fiber.Ctx) error {
 	tenantIDStr := c.Locals("tenant_id")
 	if tenantIDStr == nil {
@@ -260,16 +378,22 @@ func UpdateTask(c *fiber.Ctx) error {
 		})
 	}
 
-	// Parse the updated task data
+	// Parse the updated task data; only the fields sent are changed
 	updatedTask := new(models.Task)
 	if err := c.BodyParser(updatedTask); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid request body: " + err.Error(),
 		})
 	}
+	sent, err := sentFields(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
 
 	// Validate required fields
-	if updatedTask.Title == "" {
+	if sent["title"] && updatedTask.Title == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Task title is required",
 		})
@@ -286,20 +410,155 @@ func UpdateTask(c *fiber.Ctx) error {
 		}
 	}
 
+	if updatedTask.StoryPoints != nil && *updatedTask.StoryPoints < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Story points must not be negative",
+		})
+	}
+
+	if updatedTask.EstimatedHours != nil && *updatedTask.EstimatedHours < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Estimated hours must not be negative",
+		})
+	}
+
+	if updatedTask.RemainingHours != nil && *updatedTask.RemainingHours < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Remaining hours must not be negative",
+		})
+	}
+
+	startDate, dueDate := task.StartDate, task.DueDate
+	if sent["start_date"] {
+		startDate = updatedTask.StartDate
+	}
+	if sent["due_date"] {
+		dueDate = updatedTask.DueDate
+	}
+	if startDate != nil && dueDate != nil && startDate.After(*dueDate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Start date must not be after the due date",
+		})
+	}
+
+	// Custom fields that are sent are set; the rest keep their values
+	if updatedTask.CustomFields != nil {
+		values, msg := applyCustomFields(database.DB, uint(tenantID), models.CustomFieldEntityTask, task.CustomFields, updatedTask.CustomFields)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		task.CustomFields = values
+	}
+
 	// Update the task
-	task.Title = updatedTask.Title
-	task.Description = updatedTask.Description
-	task.Status = updatedTask.Status
-	task.DueDate = updatedTask.DueDate
-	task.AssignedToID = updatedTask.AssignedToID
+	previousStatus := task.Status
+	previousPoints := task.StoryPoints
+	previousAssignee := task.AssignedToID
+	if sent["title"] {
+		task.Title = updatedTask.Title
+	}
+	if sent["description"] {
+		task.Description = updatedTask.Description
+	}
+	task.StartDate = startDate
+	task.DueDate = dueDate
+	if sent["assigned_to_id"] {
+		task.AssignedToID = updatedTask.AssignedToID
+	}
+	if sent["story_points"] {
+		task.StoryPoints = updatedTask.StoryPoints
+	}
+	if sent["type"] {
+		task.Type = updatedTask.Type
+	}
+	// The remaining estimate is kept unless given; a new original estimate less the hours
+	// already logged replaces it
+	if sent["remaining_hours"] {
+		task.RemainingHours = updatedTask.RemainingHours
+	} else if sent["estimated_hours"] && task.RemainingHours != nil && !equalPoints(task.EstimatedHours, updatedTask.EstimatedHours) {
+		remaining := 0.0
+		if updatedTask.EstimatedHours != nil {
+			remaining = math.Max(*updatedTask.EstimatedHours-loggedHours(database.DB, task.ID), 0)
+		}
+		task.RemainingHours = &remaining
+	}
+	if sent["estimated_hours"] {
+		task.EstimatedHours = updatedTask.EstimatedHours
+	}
+	if updatedTask.Status != "" {
+		task.Status = updatedTask.Status
+	}
 
-	result = database.DB.Save(&task)
-	if result.Error != nil {
+	// Validate the status change against the project's workflow
+	if task.Status != previousStatus {
+		role, err := actorRole(c, uint(tenantID))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		def, _, err := resolveWorkflow(uint(tenantID), &task.ProjectID, models.WorkflowEntityTask)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load workflow: " + err.Error(),
+			})
+		}
+		if err := def.Validate(string(previousStatus), string(task.Status), role, task); err != nil {
+			return workflowError(c, err)
+		}
+	}
+
+	// Completing the latest occurrence of a recurring task creates the next one
+	completed := false
+	if task.Status != previousStatus {
+		completed, err = isTaskDone(database.DB, uint(tenantID), &task)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load workflow: " + err.Error(),
+			})
+		}
+	}
+	// Status and estimate changes feed sprint burndown and velocity
+	changed := task.Status != previousStatus || !equalPoints(task.StoryPoints, previousPoints)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if task.Status != previousStatus {
+			if err := checkWIPLimit(tx, task.ProjectID, task.Status); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+		if changed {
+			if err := recordTaskState(tx, uint(tenantID), &task, actorID(c)); err != nil {
+				return err
+			}
+		}
+		if err := notifyTaskChange(tx, uint(tenantID), &task, previousStatus, previousAssignee, actorID(c)); err != nil {
+			return err
+		}
+		if completed {
+			if err := completeOccurrence(tx, &task); err != nil {
+				return err
+			}
+		}
+		return publishEvent(tx, uint(tenantID), models.EventTaskUpdated, task.ID, task)
+	})
+	if errors.Is(err, errWIPLimitReached) {
+		return wipLimitReached(c, err)
+	}
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update task: " + result.Error.Error(),
+			"error": "Failed to update task: " + err.Error(),
 		})
 	}
 
+	projectDataChanged(task.ProjectID)
+
 	// Load the assigned person if exists
 	if task.AssignedToID != nil {
 		database.DB.Preload("AssignedTo").First(&task, task.ID)
@@ -362,15 +621,112 @@ func DeleteTask(c *fiber.Ctx) error {
 		})
 	}
 
-	// Delete the task
-	result = database.DB.Delete(&task)
-	if result.Error != nil {
+	// Delete the task and its dependencies, recording the deletion so it leaves sprint scope
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&task).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ? OR depends_on_id = ?", task.ID, task.ID).Delete(&models.TaskDependency{}).Error; err != nil {
+			return err
+		}
+		task.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		if err := recordTaskState(tx, uint(tenantID), &task, actorID(c)); err != nil {
+			return err
+		}
+		return publishEvent(tx, uint(tenantID), models.EventTaskDeleted, task.ID, task)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete task: " + result.Error.Error(),
+			"error": "Failed to delete task: " + err.Error(),
 		})
 	}
 
+	projectDataChanged(task.ProjectID)
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Task deleted successfully",
 	})
 }
+
+// notifyTaskAssigned tells a task's assignee about the assignment, and makes them watch the task
+func notifyTaskAssigned(tx *gorm.DB, tenantID uint, task *models.Task, actorID *uint) error {
+	if task.AssignedToID == nil {
+		return nil
+	}
+	if err := watch(tx, tenantID, models.WatchEntityTask, task.ID, *task.AssignedToID); err != nil {
+		return err
+	}
+
+	notification := models.Notification{
+		TenantID:   tenantID,
+		Type:       models.NotificationTypeTaskAssigned,
+		EntityType: string(models.WatchEntityTask),
+		EntityID:   task.ID,
+		ActorID:    actorID,
+		Message:    fmt.Sprintf("You were assigned task #%d %q", task.ID, task.Title),
+	}
+	_, err := notify(tx, notification, []uint{*task.AssignedToID}, withActor(actorID)...)
+	return err
+}
+
+// notifyTaskChange tells a task's new assignee about the assignment and its other watchers
+// about a change of status or assignee
+func notifyTaskChange(tx *gorm.DB, tenantID uint, task *models.Task, previousStatus models.TaskStatus, previousAssignee *uint, actorID *uint) error {
+	var changes []string
+	var except []uint
+	if task.Status != previousStatus {
+		changes = append(changes, fmt.Sprintf("moved from %s to %s", previousStatus, task.Status))
+	}
+	if !sameAssignee(task.AssignedToID, previousAssignee) {
+		if err := notifyTaskAssigned(tx, tenantID, task, actorID); err != nil {
+			return err
+		}
+		if task.AssignedToID == nil {
+			changes = append(changes, "was unassigned")
+		} else {
+			var assignee models.Person
+			tx.First(&assignee, *task.AssignedToID)
+			changes = append(changes, "was reassigned to "+assignee.Name)
+			except = append(except, *task.AssignedToID)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	watchers, err := watcherIDs(tx, models.WatchEntityTask, task.ID)
+	if err != nil {
+		return err
+	}
+	notification := models.Notification{
+		TenantID:   tenantID,
+		Type:       models.NotificationTypeTaskUpdated,
+		EntityType: string(models.WatchEntityTask),
+		EntityID:   task.ID,
+		ActorID:    actorID,
+		Message:    fmt.Sprintf("Task #%d %q %s", task.ID, task.Title, strings.Join(changes, " and ")),
+	}
+	_, err = notify(tx, notification, watchers, withActor(actorID, except...)...)
+	return err
+}
+
+// sentFields returns the top-level keys of a JSON request body, so an update changes only the fields it sends
+func sentFields(body []byte) (map[string]bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	sent := make(map[string]bool, len(fields))
+	for key := range fields {
+		sent[key] = true
+	}
+	return sent, nil
+}
+
+// sameAssignee reports whether two optional assignees are the same person
+func sameAssignee(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
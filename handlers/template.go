@@ -0,0 +1,636 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// projectContentOptions chooses what a template or clone takes from a project. Everything
+// is included unless set to false.
+type projectContentOptions struct {
+	Tasks      *bool `json:"tasks"`
+	Milestones *bool `json:"milestones"`
+	KPIs       *bool `json:"kpis"`
+	Members    *bool `json:"members"`
+	Assignees  *bool `json:"assignees"` // keep who tasks are assigned to
+}
+
+// included reports whether an option of projectContentOptions is on
+func included(option *bool) bool {
+	return option == nil || *option
+}
+
+// projectTemplateRequest describes a template to save from a project
+type projectTemplateRequest struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Include     projectContentOptions `json:"include"`
+}
+
+// newProjectRequest describes a project to create from a template or by cloning a project
+type newProjectRequest struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"` // defaults to the template's or source project's
+	StartDate   *time.Time            `json:"start_date"`  // dates are shifted relative to it; defaults to now
+	Budget      *float64              `json:"budget"`      // defaults to the template's or source project's
+	Include     projectContentOptions `json:"include"`
+}
+
+// GetProjectTemplates returns the tenant's project templates
+// @Summary Get all project templates
+// @Description Get all project templates for the current tenant
+// @Tags project-templates
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Success 200 {array} models.ProjectTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /project-templates [get]
+func GetProjectTemplates(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var templates []models.ProjectTemplate
+	result := database.DB.Where("tenant_id = ?", tenantID).Order("name ASC").Find(&templates)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve project templates",
+		})
+	}
+
+	return c.JSON(templates)
+}
+
+// GetProjectTemplate returns a project template
+// @Summary Get a project template
+// @Description Get a project template by ID
+// @Tags project-templates
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Template ID"
+// @Success 200 {object} models.ProjectTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /project-templates/{id} [get]
+func GetProjectTemplate(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var template models.ProjectTemplate
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&template)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project template not found",
+		})
+	}
+
+	return c.JSON(template)
+}
+
+// DeleteProjectTemplate deletes a project template
+// @Summary Delete a project template
+// @Description Delete a project template by ID. Projects created from it are not affected.
+// @Tags project-templates
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Template ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /project-templates/{id} [delete]
+func DeleteProjectTemplate(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var template models.ProjectTemplate
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&template)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project template not found",
+		})
+	}
+
+	result = database.DB.Delete(&template)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete project template: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Project template deleted successfully",
+	})
+}
+
+// CreateProjectTemplate saves a project as a template
+// @Summary Save a project as a template
+// @Description Save a project's milestones, tasks, KPIs and team as a template, with dates relative to the project's start
+// @Tags project-templates
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Param template body projectTemplateRequest true "Template name and what to include"
+// @Success 201 {object} models.ProjectTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/template [post]
+func CreateProjectTemplate(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	req := new(projectTemplateRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Template name is required",
+		})
+	}
+
+	template, err := snapshotProject(database.DB, &project, req.Include)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to read project: " + err.Error(),
+		})
+	}
+	template.Name = req.Name
+	template.Description = req.Description
+
+	result = database.DB.Create(&template)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create project template: " + result.Error.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(template)
+}
+
+// CreateProjectFromTemplate creates a project from a template
+// @Summary Create a project from a template
+// @Description Create a project with the template's milestones, tasks, KPIs and team in one transaction, shifting dates to the new start date
+// @Tags project-templates
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Template ID"
+// @Param project body newProjectRequest true "New project and what to include"
+// @Success 201 {object} models.Project
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /project-templates/{id}/projects [post]
+func CreateProjectFromTemplate(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var template models.ProjectTemplate
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&template)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project template not found",
+		})
+	}
+
+	req := new(newProjectRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Project name is required",
+		})
+	}
+
+	var project models.Project
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		project, err = instantiateTemplate(tx, uint(tenantID), &template, req, actorID(c))
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create project: " + err.Error(),
+		})
+	}
+
+	// Computed KPIs are evaluated once the project's records exist
+	projectDataChanged(project.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(loadCreatedProject(project.ID))
+}
+
+// CloneProject creates a copy of a project
+// @Summary Clone a project
+// @Description Copy a project's milestones, tasks, KPIs and team into a new project in one transaction, shifting dates to the new start date. Task progress and KPI values are reset.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Project ID"
+// @Param project body newProjectRequest true "New project and what to include"
+// @Success 201 {object} models.Project
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/clone [post]
+func CloneProject(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var source models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&source)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	req := new(newProjectRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Project name is required",
+		})
+	}
+
+	// The source is read inside the transaction so the copy is consistent
+	var project models.Project
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		template, err := snapshotProject(tx, &source, req.Include)
+		if err != nil {
+			return err
+		}
+		template.Description = source.Description
+
+		project, err = instantiateTemplate(tx, uint(tenantID), &template, req, actorID(c))
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to clone project: " + err.Error(),
+		})
+	}
+
+	// Computed KPIs are evaluated once the project's records exist
+	projectDataChanged(project.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(loadCreatedProject(project.ID))
+}
+
+// snapshotProject captures the parts of a project chosen by include as an unsaved template
+func snapshotProject(db *gorm.DB, project *models.Project, include projectContentOptions) (models.ProjectTemplate, error) {
+	template := models.ProjectTemplate{
+		TenantID:        project.TenantID,
+		SourceProjectID: &project.ID,
+		Budget:          project.Budget,
+		Tasks:           []models.TemplateTask{},
+		Milestones:      []models.TemplateMilestone{},
+		KPIs:            []models.TemplateKPI{},
+		Members:         []uint{},
+	}
+
+	// Dates are kept as whole days from the project's start
+	start := project.StartDate
+	if start.IsZero() {
+		start = project.CreatedAt
+	}
+	offset := func(t time.Time) int {
+		return int(math.Round(t.Sub(start).Hours() / 24))
+	}
+
+	if project.EndDate != nil {
+		days := offset(*project.EndDate)
+		template.DurationDays = &days
+	}
+
+	if included(include.Tasks) {
+		var tasks []models.Task
+		if err := db.Where("project_id = ?", project.ID).Order("rank ASC, id ASC").Find(&tasks).Error; err != nil {
+			return template, err
+		}
+		for _, task := range tasks {
+			t := models.TemplateTask{
+				Title:          task.Title,
+				Description:    task.Description,
+				StoryPoints:    task.StoryPoints,
+				EstimatedHours: task.EstimatedHours,
+				Type:           task.Type,
+				CustomFields:   task.CustomFields,
+			}
+			if included(include.Assignees) {
+				t.AssignedToID = task.AssignedToID
+			}
+			if task.DueDate != nil {
+				days := offset(*task.DueDate)
+				t.DueOffsetDays = &days
+			}
+			template.Tasks = append(template.Tasks, t)
+		}
+	}
+
+	if included(include.Milestones) {
+		var milestones []models.Milestone
+		if err := db.Where("project_id = ?", project.ID).Order("due_date ASC, id ASC").Find(&milestones).Error; err != nil {
+			return template, err
+		}
+		for _, milestone := range milestones {
+			template.Milestones = append(template.Milestones, models.TemplateMilestone{
+				Title:         milestone.Title,
+				Description:   milestone.Description,
+				DueOffsetDays: offset(milestone.DueDate),
+			})
+		}
+	}
+
+	if included(include.KPIs) {
+		var kpis []models.KPI
+		if err := db.Where("project_id = ?", project.ID).Order("id ASC").Find(&kpis).Error; err != nil {
+			return template, err
+		}
+		for _, kpi := range kpis {
+			template.KPIs = append(template.KPIs, models.TemplateKPI{
+				Description:    kpi.Description,
+				Direction:      kpi.Direction,
+				BaselineValue:  kpi.BaselineValue,
+				TargetValue:    kpi.TargetValue,
+				RangeMin:       kpi.RangeMin,
+				RangeMax:       kpi.RangeMax,
+				AmberThreshold: kpi.AmberThreshold,
+				RedThreshold:   kpi.RedThreshold,
+				Unit:           kpi.Unit,
+				SourceType:     kpi.SourceType,
+				Formula:        kpi.Formula,
+			})
+		}
+	}
+
+	if included(include.Members) {
+		result := db.Table("project_people").Where("project_id = ?", project.ID).Order("person_id ASC").Pluck("person_id", &template.Members)
+		if result.Error != nil {
+			return template, result.Error
+		}
+	}
+
+	return template, nil
+}
+
+// instantiateTemplate creates a project from the parts of a template chosen by the request,
+// with dates shifted to the request's start date. People who have left the tenant are skipped.
+// Computed KPIs keep their baseline until evaluated with projectDataChanged after the commit.
+func instantiateTemplate(tx *gorm.DB, tenantID uint, template *models.ProjectTemplate, req *newProjectRequest, actorID *uint) (models.Project, error) {
+	start := time.Now()
+	if req.StartDate != nil {
+		start = *req.StartDate
+	}
+
+	project := models.Project{
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+		Budget:      template.Budget,
+		StartDate:   start,
+	}
+	if project.Description == "" {
+		project.Description = template.Description
+	}
+	if req.Budget != nil {
+		project.Budget = *req.Budget
+	}
+	if template.DurationDays != nil {
+		end := start.AddDate(0, 0, *template.DurationDays)
+		project.EndDate = &end
+	}
+	if err := tx.Create(&project).Error; err != nil {
+		return project, err
+	}
+
+	// Only people still in the tenant are added to the team or assigned tasks
+	var personIDs []uint
+	personIDs = append(personIDs, template.Members...)
+	for _, task := range template.Tasks {
+		if task.AssignedToID != nil {
+			personIDs = append(personIDs, *task.AssignedToID)
+		}
+	}
+	people := make(map[uint]*models.Person)
+	if len(personIDs) > 0 {
+		var found []*models.Person
+		if err := tx.Where("id IN ? AND tenant_id = ?", personIDs, tenantID).Find(&found).Error; err != nil {
+			return project, err
+		}
+		for _, person := range found {
+			people[person.ID] = person
+		}
+	}
+
+	if included(req.Include.Members) {
+		var members []*models.Person
+		for _, id := range template.Members {
+			if person, ok := people[id]; ok {
+				members = append(members, person)
+			}
+		}
+		if len(members) > 0 {
+			if err := tx.Model(&project).Association("People").Append(members); err != nil {
+				return project, err
+			}
+		}
+	}
+
+	if included(req.Include.Milestones) {
+		for _, m := range template.Milestones {
+			milestone := models.Milestone{
+				ProjectID:   project.ID,
+				Title:       m.Title,
+				Description: m.Description,
+				DueDate:     start.AddDate(0, 0, m.DueOffsetDays),
+				Status:      models.MilestoneStatusPlanned,
+			}
+			if err := tx.Create(&milestone).Error; err != nil {
+				return project, err
+			}
+		}
+	}
+
+	if included(req.Include.Tasks) {
+		def, _, err := resolveWorkflowIn(tx, tenantID, &project.ID, models.WorkflowEntityTask)
+		if err != nil {
+			return project, err
+		}
+		for _, t := range template.Tasks {
+			task := models.Task{
+				ProjectID:      project.ID,
+				Title:          t.Title,
+				Description:    t.Description,
+				Status:         models.TaskStatus(def.InitialState()),
+				StoryPoints:    t.StoryPoints,
+				EstimatedHours: t.EstimatedHours,
+				Type:           t.Type,
+				CustomFields:   t.CustomFields,
+				Rank:           nextTaskRank(tx, project.ID),
+			}
+			if t.AssignedToID != nil && included(req.Include.Assignees) {
+				if _, ok := people[*t.AssignedToID]; ok {
+					task.AssignedToID = t.AssignedToID
+				}
+			}
+			if t.DueOffsetDays != nil {
+				due := start.AddDate(0, 0, *t.DueOffsetDays)
+				task.DueDate = &due
+			}
+			if err := tx.Create(&task).Error; err != nil {
+				return project, err
+			}
+			if err := recordTaskState(tx, tenantID, &task, actorID); err != nil {
+				return project, err
+			}
+		}
+	}
+
+	if included(req.Include.KPIs) {
+		for _, k := range template.KPIs {
+			kpi := models.KPI{
+				ProjectID:      project.ID,
+				Description:    k.Description,
+				Direction:      k.Direction,
+				BaselineValue:  k.BaselineValue,
+				TargetValue:    k.TargetValue,
+				RangeMin:       k.RangeMin,
+				RangeMax:       k.RangeMax,
+				AmberThreshold: k.AmberThreshold,
+				RedThreshold:   k.RedThreshold,
+				CurrentValue:   k.BaselineValue,
+				Unit:           k.Unit,
+				SourceType:     k.SourceType,
+				Formula:        k.Formula,
+			}
+			if kpi.SourceType == "" {
+				kpi.SourceType = models.KPISourceManual
+			}
+			kpi.UpdateAchievement()
+			if err := tx.Create(&kpi).Error; err != nil {
+				return project, err
+			}
+			if _, err := recordKPIMeasurement(tx, &kpi, kpi.CurrentValue, &kpiMeasurementRequest{Source: "template"}, actorID); err != nil {
+				return project, err
+			}
+		}
+	}
+
+	return project, nil
+}
+
+// loadCreatedProject loads a project with the records copied into it
+func loadCreatedProject(id uint) models.Project {
+	var project models.Project
+	database.DB.
+		Preload("People").
+		Preload("Milestones").
+		Preload("Tasks", func(db *gorm.DB) *gorm.DB {
+			return db.Order("rank ASC")
+		}).
+		Preload("KPIs").
+		First(&project, id)
+	return project
+}
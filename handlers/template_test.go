@@ -0,0 +1,120 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTemplateDB sets up an isolated in-memory SQLite database with a project to copy
+func setupTemplateDB(t *testing.T) {
+	newTestDB(t)
+
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	design := start.AddDate(0, 0, 14)
+	three := 3.0
+	alice := uint(1)
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "developer"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Client A", Description: "Onboarding", Budget: 5000, StartDate: start, EndDate: &end})
+	database.DB.Exec("INSERT INTO project_people (project_id, person_id) VALUES (1, 1)")
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Design", Status: models.TaskStatusCompleted, Rank: "a", DueDate: &design, AssignedToID: &alice, StoryPoints: &three})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Build", Status: models.TaskStatusInProgress, Rank: "b"})
+	database.DB.Create(&models.Milestone{ProjectID: 1, Title: "Kickoff", DueDate: start.AddDate(0, 0, 7), Status: models.MilestoneStatusCompleted})
+	database.DB.Create(&models.KPI{ProjectID: 1, Description: "Adoption", BaselineValue: 10, TargetValue: 90, CurrentValue: 80, Unit: "%"})
+}
+
+func setupTemplateApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Post("/projects/:id/template", handlers.CreateProjectTemplate)
+	app.Post("/projects/:id/clone", handlers.CloneProject)
+	app.Get("/project-templates", handlers.GetProjectTemplates)
+	app.Post("/project-templates/:id/projects", handlers.CreateProjectFromTemplate)
+	return app
+}
+
+func TestProjectFromTemplateShiftsDates(t *testing.T) {
+	setupTemplateDB(t)
+	app := setupTemplateApp()
+
+	status, _ := doRequest(t, app, "POST", "/projects/1/template", `{"description":"No name"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	status, body := doRequest(t, app, "POST", "/projects/1/template", `{"name":"Engagement","include":{"kpis":false}}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	var template models.ProjectTemplate
+	assert.NoError(t, json.Unmarshal(body, &template))
+	assert.Len(t, template.Tasks, 2)
+	assert.Len(t, template.Milestones, 1)
+	assert.Empty(t, template.KPIs)
+	assert.Equal(t, []uint{1}, template.Members)
+	if assert.NotNil(t, template.DurationDays) {
+		assert.Equal(t, 60, *template.DurationDays)
+	}
+
+	status, body = doRequest(t, app, "POST", "/project-templates/1/projects",
+		`{"name":"Client B","start_date":"2025-06-01T00:00:00Z","include":{"milestones":false}}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	var project models.Project
+	assert.NoError(t, json.Unmarshal(body, &project))
+	assert.Equal(t, "Client B", project.Name)
+	assert.Equal(t, 5000.0, project.Budget)
+	assert.True(t, project.EndDate.Equal(time.Date(2025, time.July, 31, 0, 0, 0, 0, time.UTC)))
+	assert.Len(t, project.People, 1)
+	assert.Empty(t, project.Milestones)
+	if assert.Len(t, project.Tasks, 2) {
+		design := project.Tasks[0]
+		assert.Equal(t, "Design", design.Title)
+		assert.Equal(t, models.TaskStatusTodo, design.Status)
+		assert.True(t, design.DueDate.Equal(time.Date(2025, time.June, 15, 0, 0, 0, 0, time.UTC)))
+		assert.Equal(t, uint(1), *design.AssignedToID)
+		assert.Nil(t, project.Tasks[1].DueDate)
+	}
+}
+
+func TestCloneProjectResetsProgress(t *testing.T) {
+	setupTemplateDB(t)
+	app := setupTemplateApp()
+
+	status, body := doRequest(t, app, "POST", "/projects/1/clone",
+		`{"name":"Client A, phase 2","start_date":"2024-06-01T00:00:00Z","budget":8000,"include":{"assignees":false,"members":false}}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	var project models.Project
+	assert.NoError(t, json.Unmarshal(body, &project))
+	assert.Equal(t, "Onboarding", project.Description)
+	assert.Equal(t, 8000.0, project.Budget)
+	assert.Empty(t, project.People)
+	if assert.Len(t, project.Milestones, 1) {
+		assert.Equal(t, models.MilestoneStatusPlanned, project.Milestones[0].Status)
+		assert.True(t, project.Milestones[0].DueDate.Equal(time.Date(2024, time.June, 8, 0, 0, 0, 0, time.UTC)))
+	}
+	if assert.Len(t, project.Tasks, 2) {
+		assert.Equal(t, models.TaskStatusTodo, project.Tasks[0].Status)
+		assert.Nil(t, project.Tasks[0].AssignedToID)
+	}
+	if assert.Len(t, project.KPIs, 1) {
+		assert.Equal(t, 10.0, project.KPIs[0].CurrentValue)
+		assert.False(t, project.KPIs[0].Achieved)
+	}
+
+	// The source project is untouched
+	var tasks int64
+	database.DB.Model(&models.Task{}).Where("project_id = ?", 1).Count(&tasks)
+	assert.Equal(t, int64(2), tasks)
+
+	status, _ = doRequest(t, app, "POST", "/projects/99/clone", `{"name":"Missing"}`)
+	assert.Equal(t, fiber.StatusNotFound, status)
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// timeEntryRequest logs time spent on a task
+type timeEntryRequest struct {
+	PersonID *uint      `json:"person_id"` // defaults to the person making the request
+	Hours    float64    `json:"hours"`
+	Date     *time.Time `json:"date"` // defaults to now
+}
+
+// GetTaskTimeEntries returns the time logged on a task
+// @Summary Get task time entries
+// @Description Get the time logged on a task, latest first
+// @Tags time-tracking
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.TimeTracking
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/time-entries [get]
+func GetTaskTimeEntries(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	task, err := findTask(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	var entries []models.TimeTracking
+	result := database.DB.Where("task_id = ?", task.ID).Preload("Person").Order("date DESC, id DESC").Find(&entries)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve time entries",
+		})
+	}
+
+	return c.JSON(entries)
+}
+
+// CreateTaskTimeEntry logs time spent on a task
+// @Summary Log time on a task
+// @Description Log hours spent on a task. The hours come off the task's remaining estimate, and once the hours logged exceed the original estimate by the tenant's overrun percentage the assignee and the person logging are alerted.
+// @Tags time-tracking
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param entry body timeEntryRequest true "Time entry"
+// @Success 201 {object} models.TimeTracking
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/time-entries [post]
+func CreateTaskTimeEntry(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	task, err := findTask(uint(tenantID), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	req := new(timeEntryRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.PersonID == nil {
+		req.PersonID = actorID(c)
+	}
+	if req.PersonID == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Person ID is required",
+		})
+	}
+	if req.Hours <= 0 || req.Hours > 24 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Hours must be more than 0 and at most 24",
+		})
+	}
+
+	var person models.Person
+	result := database.DB.Where("id = ? AND tenant_id = ?", *req.PersonID, tenantID).First(&person)
+	if result.Error != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Person not found or not in the same tenant",
+		})
+	}
+
+	entry := models.TimeTracking{TaskID: task.ID, PersonID: person.ID, Hours: req.Hours, Date: time.Now()}
+	if req.Date != nil {
+		entry.Date = *req.Date
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+
+		// Logged time comes off the remaining estimate
+		if task.EstimatedHours != nil || task.RemainingHours != nil {
+			remaining := math.Max(task.RemainingEstimate()-entry.Hours, 0)
+			if err := tx.Model(&task).Update("remaining_hours", remaining).Error; err != nil {
+				return err
+			}
+		}
+
+		return alertEstimateOverrun(tx, uint(tenantID), &task, person.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to log time: " + err.Error(),
+		})
+	}
+
+	projectDataChanged(task.ProjectID)
+
+	entry.Person = &person
+	return c.Status(fiber.StatusCreated).JSON(entry)
+}
+
+// DeleteTimeEntry removes logged time
+// @Summary Delete a time entry
+// @Description Delete logged time. Its hours are added back to the task's remaining estimate.
+// @Tags time-tracking
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Time entry ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /time-entries/{id} [delete]
+func DeleteTimeEntry(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var entry models.TimeTracking
+	result := database.DB.Joins("JOIN tasks ON tasks.id = time_trackings.task_id").
+		Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("time_trackings.id = ? AND projects.tenant_id = ?", c.Params("id"), tenantID).
+		First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Time entry not found",
+		})
+	}
+
+	task, err := findTask(uint(tenantID), entry.TaskID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&entry).Error; err != nil {
+			return err
+		}
+		if task.RemainingHours != nil {
+			return tx.Model(&task).Update("remaining_hours", *task.RemainingHours+entry.Hours).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete time entry: " + err.Error(),
+		})
+	}
+
+	projectDataChanged(task.ProjectID)
+
+	return c.JSON(fiber.Map{
+		"message": "Time entry deleted successfully",
+	})
+}
+
+// loggedHours returns the hours logged on a task
+func loggedHours(db *gorm.DB, taskID uint) float64 {
+	var hours float64
+	db.Model(&models.TimeTracking{}).Where("task_id = ?", taskID).Select("COALESCE(SUM(hours), 0)").Scan(&hours)
+	return hours
+}
+
+// alertEstimateOverrun notifies a task's assignee and the person who logged time once the hours
+// logged on the task exceed its estimate by the tenant's overrun percentage. Each task is alerted once.
+func alertEstimateOverrun(tx *gorm.DB, tenantID uint, task *models.Task, loggedByID uint) error {
+	if task.EstimatedHours == nil {
+		return nil
+	}
+	settings := tenantEstimateSettings(tx, tenantID)
+	estimated, actual := *task.EstimatedHours, loggedHours(tx, task.ID)
+	if !settings.Overruns(estimated, actual) {
+		return nil
+	}
+
+	var alerted int64
+	tx.Model(&models.Notification{}).
+		Where("type = ? AND entity_type = ? AND entity_id = ?", models.NotificationTypeEstimateOverrun, models.CommentEntityTask, task.ID).
+		Count(&alerted)
+	if alerted > 0 {
+		return nil
+	}
+
+	recipients := []uint{loggedByID}
+	if task.AssignedToID != nil {
+		recipients = append(recipients, *task.AssignedToID)
+	}
+	notification := models.Notification{
+		TenantID:   tenantID,
+		Type:       models.NotificationTypeEstimateOverrun,
+		EntityType: string(models.CommentEntityTask),
+		EntityID:   task.ID,
+		ActorID:    &loggedByID,
+		Message: fmt.Sprintf("Task #%d %q has %.1f hours logged against an estimate of %.1f hours (%.0f%% over)",
+			task.ID, task.Title, actual, estimated, (actual/estimated-1)*100),
+	}
+	_, err := notify(tx, notification, recipients)
+	return err
+}
@@ -0,0 +1,457 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// watchTarget describes the table of a watchable entity type
+type watchTarget struct {
+	name     string // display name
+	table    string
+	tenanted bool // whether the table has its own tenant_id, rather than its project's
+}
+
+// watchTargets are the watchable entity types
+var watchTargets = map[models.WatchEntity]watchTarget{
+	models.WatchEntityProject:     {name: "Project", table: "projects", tenanted: true},
+	models.WatchEntityTask:        {name: "Task", table: "tasks"},
+	models.WatchEntityIssue:       {name: "Issue", table: "issues"},
+	models.WatchEntityProcurement: {name: "Procurement request", table: "procurement_requests", tenanted: true},
+}
+
+// watchRequest is the payload that adds a watcher; the acting person watches when no person is given
+type watchRequest struct {
+	PersonID uint `json:"person_id"`
+}
+
+// GetProjectWatchers returns the watchers of a project
+// @Summary Get project watchers
+// @Description Get the people notified about changes to a project
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/watchers [get]
+func GetProjectWatchers(c *fiber.Ctx) error {
+	return getWatchers(c, models.WatchEntityProject)
+}
+
+// WatchProject adds a watcher to a project
+// @Summary Watch a project
+// @Description Add a person, or else the acting person, to the watchers of a project
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Project ID"
+// @Param watcher body watchRequest false "Person to add"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/watchers [post]
+func WatchProject(c *fiber.Ctx) error {
+	return addWatcher(c, models.WatchEntityProject)
+}
+
+// UnwatchProject removes a watcher from a project
+// @Summary Unwatch a project
+// @Description Remove a person from the watchers of a project
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Project ID"
+// @Param person_id path int true "Person ID"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{id}/watchers/{person_id} [delete]
+func UnwatchProject(c *fiber.Ctx) error {
+	return removeWatcher(c, models.WatchEntityProject)
+}
+
+// GetTaskWatchers returns the watchers of a task
+// @Summary Get task watchers
+// @Description Get the people notified about changes to a task
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/watchers [get]
+func GetTaskWatchers(c *fiber.Ctx) error {
+	return getWatchers(c, models.WatchEntityTask)
+}
+
+// WatchTask adds a watcher to a task
+// @Summary Watch a task
+// @Description Add a person, or else the acting person, to the watchers of a task
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Task ID"
+// @Param watcher body watchRequest false "Person to add"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/watchers [post]
+func WatchTask(c *fiber.Ctx) error {
+	return addWatcher(c, models.WatchEntityTask)
+}
+
+// UnwatchTask removes a watcher from a task
+// @Summary Unwatch a task
+// @Description Remove a person from the watchers of a task
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param person_id path int true "Person ID"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/watchers/{person_id} [delete]
+func UnwatchTask(c *fiber.Ctx) error {
+	return removeWatcher(c, models.WatchEntityTask)
+}
+
+// GetIssueWatchers returns the watchers of an issue
+// @Summary Get issue watchers
+// @Description Get the people notified about changes to an issue
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Issue ID"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/watchers [get]
+func GetIssueWatchers(c *fiber.Ctx) error {
+	return getWatchers(c, models.WatchEntityIssue)
+}
+
+// WatchIssue adds a watcher to an issue
+// @Summary Watch an issue
+// @Description Add a person, or else the acting person, to the watchers of an issue
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Issue ID"
+// @Param watcher body watchRequest false "Person to add"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/watchers [post]
+func WatchIssue(c *fiber.Ctx) error {
+	return addWatcher(c, models.WatchEntityIssue)
+}
+
+// UnwatchIssue removes a watcher from an issue
+// @Summary Unwatch an issue
+// @Description Remove a person from the watchers of an issue
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Issue ID"
+// @Param person_id path int true "Person ID"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /issues/{id}/watchers/{person_id} [delete]
+func UnwatchIssue(c *fiber.Ctx) error {
+	return removeWatcher(c, models.WatchEntityIssue)
+}
+
+// GetProcurementRequestWatchers returns the watchers of a procurement request
+// @Summary Get procurement request watchers
+// @Description Get the people notified about changes to a procurement request
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Procurement Request ID"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /procurement-requests/{id}/watchers [get]
+func GetProcurementRequestWatchers(c *fiber.Ctx) error {
+	return getWatchers(c, models.WatchEntityProcurement)
+}
+
+// WatchProcurementRequest adds a watcher to a procurement request
+// @Summary Watch a procurement request
+// @Description Add a person, or else the acting person, to the watchers of a procurement request
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Procurement Request ID"
+// @Param watcher body watchRequest false "Person to add"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /procurement-requests/{id}/watchers [post]
+func WatchProcurementRequest(c *fiber.Ctx) error {
+	return addWatcher(c, models.WatchEntityProcurement)
+}
+
+// UnwatchProcurementRequest removes a watcher from a procurement request
+// @Summary Unwatch a procurement request
+// @Description Remove a person from the watchers of a procurement request
+// @Tags watchers
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Procurement Request ID"
+// @Param person_id path int true "Person ID"
+// @Success 200 {array} models.Watcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /procurement-requests/{id}/watchers/{person_id} [delete]
+func UnwatchProcurementRequest(c *fiber.Ctx) error {
+	return removeWatcher(c, models.WatchEntityProcurement)
+}
+
+// getWatchers lists the watchers of a record
+func getWatchers(c *fiber.Ctx, entity models.WatchEntity) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	entityID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + string(entity) + " ID format",
+		})
+	}
+
+	if !watchEntityExists(database.DB, entity, entityID, tenantID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": watchTargets[entity].name + " not found",
+		})
+	}
+
+	watchers, err := entityWatchers(database.DB, entity, uint(entityID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve watchers",
+		})
+	}
+
+	return c.JSON(watchers)
+}
+
+// addWatcher adds a person to the watchers of a record and returns its watchers
+func addWatcher(c *fiber.Ctx, entity models.WatchEntity) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	entityID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + string(entity) + " ID format",
+		})
+	}
+
+	if !watchEntityExists(database.DB, entity, entityID, tenantID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": watchTargets[entity].name + " not found",
+		})
+	}
+
+	var req watchRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body: " + err.Error(),
+			})
+		}
+	}
+	if req.PersonID == 0 {
+		if actor := actorID(c); actor != nil {
+			req.PersonID = *actor
+		} else {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "person_id or the X-Person-ID header is required",
+			})
+		}
+	}
+
+	var person models.Person
+	result := database.DB.Where("id = ? AND tenant_id = ?", req.PersonID, tenantID).First(&person)
+	if result.Error != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Person not found or not in the same tenant",
+		})
+	}
+
+	if err := watch(database.DB, uint(tenantID), entity, uint(entityID), person.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to add watcher: " + err.Error(),
+		})
+	}
+
+	watchers, err := entityWatchers(database.DB, entity, uint(entityID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve watchers",
+		})
+	}
+
+	return c.JSON(watchers)
+}
+
+// removeWatcher removes a person from the watchers of a record and returns its watchers
+func removeWatcher(c *fiber.Ctx, entity models.WatchEntity) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	entityID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid " + string(entity) + " ID format",
+		})
+	}
+
+	personID, err := c.ParamsInt("person_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid person ID format",
+		})
+	}
+
+	if !watchEntityExists(database.DB, entity, entityID, tenantID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": watchTargets[entity].name + " not found",
+		})
+	}
+
+	result := database.DB.Where("entity_type = ? AND entity_id = ? AND person_id = ?", entity, entityID, personID).Delete(&models.Watcher{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to remove watcher: " + result.Error.Error(),
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Person is not watching this " + string(entity),
+		})
+	}
+
+	watchers, err := entityWatchers(database.DB, entity, uint(entityID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve watchers",
+		})
+	}
+
+	return c.JSON(watchers)
+}
+
+// watchEntityExists reports whether a watchable record exists for the tenant
+func watchEntityExists(db *gorm.DB, entity models.WatchEntity, entityID int, tenantID int) bool {
+	target, ok := watchTargets[entity]
+	if !ok {
+		return false
+	}
+
+	var count int64
+	query := db.Table(target.table).Where(target.table+".id = ? AND "+target.table+".deleted_at IS NULL", entityID)
+	if target.tenanted {
+		query = query.Where(target.table+".tenant_id = ?", tenantID)
+	} else {
+		query = query.Joins("JOIN projects ON projects.id = "+target.table+".project_id").
+			Where("projects.tenant_id = ?", tenantID)
+	}
+	query.Count(&count)
+	return count > 0
+}
+
+// entityWatchers returns the watchers of a record in the order they started watching
+func entityWatchers(db *gorm.DB, entity models.WatchEntity, entityID uint) ([]models.Watcher, error) {
+	watchers := []models.Watcher{}
+	err := db.Where("entity_type = ? AND entity_id = ?", entity, entityID).
+		Preload("Person").
+		Order("id ASC").
+		Find(&watchers).Error
+	return watchers, err
+}
+
+// watch makes a person watch a record; watching it again changes nothing
+func watch(tx *gorm.DB, tenantID uint, entity models.WatchEntity, entityID, personID uint) error {
+	watcher := models.Watcher{TenantID: tenantID, EntityType: entity, EntityID: entityID, PersonID: personID}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&watcher).Error
+}
+
+// watcherIDs returns the people watching a record
+func watcherIDs(tx *gorm.DB, entity models.WatchEntity, entityID uint) ([]uint, error) {
+	var ids []uint
+	err := tx.Model(&models.Watcher{}).Where("entity_type = ? AND entity_id = ?", entity, entityID).Order("id ASC").Pluck("person_id", &ids).Error
+	return ids, err
+}
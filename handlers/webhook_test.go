@@ -0,0 +1,263 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/webhook"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookReceiver records the requests it gets and answers them with its status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+	w.Write([]byte("thanks"))
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// allowLocalWebhooks lets endpoints point at the test's receiver, which listens on loopback
+func allowLocalWebhooks(t *testing.T) {
+	webhook.AllowPrivateNetworks = true
+	t.Cleanup(func() { webhook.AllowPrivateNetworks = false })
+}
+
+func setupWebhookApp() *fiber.App {
+	app := setupNotificationApp()
+	app.Get("/webhooks/events", handlers.GetWebhookEvents)
+	app.Get("/webhooks", handlers.GetWebhookEndpoints)
+	app.Post("/webhooks", handlers.CreateWebhookEndpoint)
+	app.Get("/webhooks/:id", handlers.GetWebhookEndpoint)
+	app.Put("/webhooks/:id", handlers.UpdateWebhookEndpoint)
+	app.Delete("/webhooks/:id", handlers.DeleteWebhookEndpoint)
+	app.Post("/webhooks/:id/rotate-secret", handlers.RotateWebhookSecret)
+	app.Get("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+	app.Post("/webhooks/:id/deliveries/:delivery_id/replay", handlers.ReplayWebhookDelivery)
+	return app
+}
+
+// deliverWebhooks dispatches pending domain events, which queues their webhooks, and delivers the webhooks that are due
+func deliverWebhooks(now time.Time) error {
+	if err := handlers.DispatchEvents(now); err != nil {
+		return err
+	}
+	return handlers.DeliverWebhooks(now)
+}
+
+func webhookDeliveries(t *testing.T, app *fiber.App, query string) []models.WebhookDelivery {
+	status, body := doRequest(t, app, "GET", "/webhooks/1/deliveries"+query, "")
+	assert.Equal(t, 200, status, string(body))
+
+	var deliveries []models.WebhookDelivery
+	assert.NoError(t, json.Unmarshal(body, &deliveries))
+	return deliveries
+}
+
+func TestWebhookDeliveriesAreSigned(t *testing.T) {
+	setupNotificationDB(t)
+	app := setupWebhookApp()
+	receiver := &webhookReceiver{status: 200}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	status, _ := doRequest(t, app, "POST", "/webhooks", `{"url": "not a url", "events": ["task.created"]}`)
+	assert.Equal(t, 400, status)
+	status, body := doRequest(t, app, "POST", "/webhooks", `{"url": "`+server.URL+`", "events": ["task.created"]}`)
+	assert.Equal(t, 400, status, "internal addresses need to be allowed")
+	assert.Contains(t, string(body), "private")
+
+	allowLocalWebhooks(t)
+	status, _ = doRequest(t, app, "POST", "/webhooks", `{"url": "`+server.URL+`", "events": ["ticket.exploded"]}`)
+	assert.Equal(t, 400, status)
+
+	status, body = doRequest(t, app, "POST", "/webhooks", `{"url": "`+server.URL+`", "events": ["task.created", "task.created"]}`)
+	assert.Equal(t, 201, status, string(body))
+	var created struct {
+		Events []string `json:"events"`
+		Active bool     `json:"active"`
+		Secret string   `json:"secret"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &created))
+	assert.Equal(t, []string{models.EventTaskCreated}, created.Events)
+	assert.True(t, created.Active)
+	assert.NotEmpty(t, created.Secret)
+
+	// The secret is only shown when it is created
+	_, body = doRequest(t, app, "GET", "/webhooks/1", "")
+	assert.NotContains(t, string(body), created.Secret)
+
+	// Only subscribed events are delivered
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch"}`)
+	doRequestAs(t, app, "1", "PATCH", "/tasks/1", `{"title": "Launch v2"}`)
+	assert.NoError(t, deliverWebhooks(time.Now()))
+	assert.Equal(t, 1, receiver.count())
+
+	req, payload := receiver.requests[0], receiver.bodies[0]
+	assert.Equal(t, models.EventTaskCreated, req.Header.Get(webhook.EventHeader))
+	timestamp, _ := strconv.ParseInt(req.Header.Get(webhook.TimestampHeader), 10, 64)
+	assert.True(t, webhook.Verify(created.Secret, req.Header.Get(webhook.SignatureHeader), timestamp, payload))
+
+	var envelope struct {
+		Event    string `json:"event"`
+		TenantID uint   `json:"tenant_id"`
+		Data     struct {
+			ID    uint   `json:"id"`
+			Title string `json:"title"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(payload, &envelope))
+	assert.Equal(t, models.EventTaskCreated, envelope.Event)
+	assert.Equal(t, uint(1), envelope.TenantID)
+	assert.Equal(t, "Launch", envelope.Data.Title)
+
+	deliveries := webhookDeliveries(t, app, "")
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, models.WebhookDeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, 200, deliveries[0].ResponseStatus)
+	assert.Equal(t, "thanks", deliveries[0].ResponseBody)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+
+	// Rotated secrets sign the next deliveries
+	_, body = doRequest(t, app, "POST", "/webhooks/1/rotate-secret", "")
+	var rotated struct {
+		Secret string `json:"secret"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &rotated))
+	assert.NotEqual(t, created.Secret, rotated.Secret)
+
+	status, _ = doRequest(t, app, "POST", "/webhooks/1/deliveries/1/replay", "")
+	assert.Equal(t, 201, status)
+	assert.NoError(t, deliverWebhooks(time.Now()))
+	assert.Equal(t, 2, receiver.count())
+	req = receiver.requests[1]
+	timestamp, _ = strconv.ParseInt(req.Header.Get(webhook.TimestampHeader), 10, 64)
+	assert.True(t, webhook.Verify(rotated.Secret, req.Header.Get(webhook.SignatureHeader), timestamp, receiver.bodies[1]))
+	assert.Equal(t, payload, receiver.bodies[1], "replays send the original payload")
+}
+
+func TestWebhookRetriesAndAutoDisable(t *testing.T) {
+	setupNotificationDB(t)
+	app := setupWebhookApp()
+	allowLocalWebhooks(t)
+	receiver := &webhookReceiver{status: 500}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	status, body := doRequest(t, app, "POST", "/webhooks", `{"url": "`+server.URL+`", "events": ["task.created"]}`)
+	assert.Equal(t, 201, status, string(body))
+
+	// Failed attempts are retried with a doubling delay
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch"}`)
+	now := time.Now()
+	assert.NoError(t, deliverWebhooks(now))
+	delivery := webhookDeliveries(t, app, "")[0]
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, 500, delivery.ResponseStatus)
+	assert.Equal(t, "endpoint answered 500 Internal Server Error", delivery.LastError)
+	assert.WithinDuration(t, now.Add(30*time.Second), *delivery.NextAttemptAt, time.Second)
+
+	assert.NoError(t, deliverWebhooks(now.Add(10*time.Second)))
+	assert.Equal(t, 1, receiver.count(), "retries wait for their delay")
+	assert.NoError(t, deliverWebhooks(now.Add(30*time.Second)))
+	delivery = webhookDeliveries(t, app, "")[0]
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.WithinDuration(t, now.Add(90*time.Second), *delivery.NextAttemptAt, time.Second)
+
+	// Endpoints that keep failing are disabled
+	for i := 0; i < webhook.DisableAfter; i++ {
+		doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "More"}`)
+	}
+	later := now.Add(time.Hour)
+	assert.NoError(t, deliverWebhooks(later))
+	assert.Equal(t, webhook.DisableAfter, receiver.count(), "delivery stops once the endpoint is disabled")
+
+	var endpoint models.WebhookEndpoint
+	_, body = doRequest(t, app, "GET", "/webhooks/1", "")
+	assert.NoError(t, json.Unmarshal(body, &endpoint))
+	assert.False(t, endpoint.Active)
+	assert.NotNil(t, endpoint.DisabledAt)
+	assert.Equal(t, webhook.DisableAfter, endpoint.ConsecutiveFailures)
+
+	assert.NoError(t, deliverWebhooks(later.Add(time.Hour)))
+	assert.Equal(t, webhook.DisableAfter, receiver.count())
+	status, _ = doRequest(t, app, "POST", "/webhooks/1/deliveries/1/replay", "")
+	assert.Equal(t, 409, status)
+
+	// Once the receiver is fixed, the endpoint is enabled again and pending deliveries go out
+	receiver.setStatus(200)
+	status, body = doRequest(t, app, "PUT", "/webhooks/1", `{"url": "`+server.URL+`", "events": ["task.created"], "active": true}`)
+	assert.Equal(t, 200, status, string(body))
+	assert.NoError(t, json.Unmarshal(body, &endpoint))
+	assert.True(t, endpoint.Active)
+	assert.Nil(t, endpoint.DisabledAt)
+	assert.Equal(t, 0, endpoint.ConsecutiveFailures)
+
+	assert.NoError(t, deliverWebhooks(later.Add(24*time.Hour)))
+	assert.Empty(t, webhookDeliveries(t, app, "?status=pending"))
+	assert.Len(t, webhookDeliveries(t, app, "?status=succeeded"), webhook.DisableAfter+1)
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	setupNotificationDB(t)
+	app := setupWebhookApp()
+	allowLocalWebhooks(t)
+	receiver := &webhookReceiver{status: 503}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	doRequest(t, app, "POST", "/webhooks", `{"url": "`+server.URL+`", "events": ["task.created"]}`)
+	doRequestAs(t, app, "1", "POST", "/projects/1/tasks", `{"title": "Launch"}`)
+
+	now := time.Now()
+	for i := 0; i < 12; i++ {
+		assert.NoError(t, deliverWebhooks(now))
+		now = now.Add(24 * time.Hour)
+	}
+
+	delivery := webhookDeliveries(t, app, "")[0]
+	assert.Equal(t, models.WebhookDeliveryFailed, delivery.Status)
+	assert.Equal(t, webhook.MaxAttempts, delivery.Attempts)
+	assert.Nil(t, delivery.NextAttemptAt)
+
+	// A failed delivery can be replayed by hand
+	status, body := doRequest(t, app, "POST", "/webhooks/1/deliveries/1/replay", "")
+	assert.Equal(t, 201, status, string(body))
+	var replay models.WebhookDelivery
+	assert.NoError(t, json.Unmarshal(body, &replay))
+	assert.Equal(t, models.WebhookDeliveryPending, replay.Status)
+	assert.Equal(t, uint(1), *replay.ReplayOfID)
+	assert.Equal(t, delivery.Payload, replay.Payload)
+}
@@ -0,0 +1,852 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/workflow"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// availableTransition is a transition out of a record's current status
+type availableTransition struct {
+	workflow.Transition
+	Permitted bool `json:"permitted"` // whether the acting person's role passes the guard
+}
+
+// transitionsResponse lists the transitions available for a record
+type transitionsResponse struct {
+	WorkflowID    *uint                 `json:"workflow_id"` // null when the built-in workflow applies
+	CurrentStatus string                `json:"current_status"`
+	Transitions   []availableTransition `json:"transitions"`
+}
+
+// GetWorkflows returns all workflows for a tenant
+// @Summary Get all workflows
+// @Description Get all workflows for the current tenant
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param entity_type query string false "Filter by entity type (task, issue, risk, procurement_request)"
+// @Param project_id query int false "Filter by project ID"
+// @Success 200 {array} models.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /workflows [get]
+func GetWorkflows(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ?", tenantID)
+
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+
+	if projectID := c.Query("project_id"); projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+
+	var workflows []models.Workflow
+	result := query.Preload("States", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position ASC")
+	}).Preload("Transitions").Find(&workflows)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve workflows",
+		})
+	}
+
+	return c.JSON(workflows)
+}
+
+// GetWorkflow returns a specific workflow
+// @Summary Get a workflow
+// @Description Get a workflow by ID with its states and transitions
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Workflow ID"
+// @Success 200 {object} models.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /workflows/{id} [get]
+func GetWorkflow(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workflow ID format",
+		})
+	}
+
+	var wf models.Workflow
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).
+		Preload("States", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Preload("Transitions").
+		First(&wf)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workflow not found",
+		})
+	}
+
+	return c.JSON(wf)
+}
+
+// CreateWorkflow creates a new workflow with its states and transitions
+// @Summary Create a workflow
+// @Description Create a workflow for a kind of record, optionally scoped to one project
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param workflow body models.Workflow true "Workflow object"
+// @Success 201 {object} models.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /workflows [post]
+func CreateWorkflow(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	wf := new(models.Workflow)
+	if err := c.BodyParser(wf); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	wf.ID = 0
+	wf.TenantID = uint(tenantID)
+
+	if msg := validateWorkflow(wf); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	// Verify project belongs to tenant if provided
+	if wf.ProjectID != nil {
+		var project models.Project
+		result := database.DB.Where("id = ? AND tenant_id = ?", *wf.ProjectID, tenantID).First(&project)
+		if result.Error != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Project not found or not in the same tenant",
+			})
+		}
+	}
+
+	// Only one workflow per entity type and scope
+	var count int64
+	scope := database.DB.Model(&models.Workflow{}).Where("tenant_id = ? AND entity_type = ?", tenantID, wf.EntityType)
+	if wf.ProjectID != nil {
+		scope = scope.Where("project_id = ?", *wf.ProjectID)
+	} else {
+		scope = scope.Where("project_id IS NULL")
+	}
+	scope.Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A workflow for this entity type already exists in this scope",
+		})
+	}
+
+	for i := range wf.States {
+		wf.States[i].ID = 0
+		wf.States[i].Position = i
+	}
+	for i := range wf.Transitions {
+		wf.Transitions[i].ID = 0
+	}
+
+	// States and transitions are created together with the workflow
+	result := database.DB.Create(&wf)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create workflow: " + result.Error.Error(),
+		})
+	}
+
+	invalidateTenantHealth(uint(tenantID))
+
+	return c.Status(fiber.StatusCreated).JSON(wf)
+}
+
+// UpdateWorkflow replaces the name, states and transitions of a workflow
+// @Summary Update a workflow
+// @Description Replace the name, states and transitions of a workflow. A state cannot be removed while records it governs are in it.
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Workflow ID"
+// @Param workflow body models.Workflow true "Workflow object"
+// @Success 200 {object} models.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /workflows/{id} [put]
+func UpdateWorkflow(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workflow ID format",
+		})
+	}
+
+	var wf models.Workflow
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&wf)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workflow not found",
+		})
+	}
+
+	updateData := new(models.Workflow)
+	if err := c.BodyParser(updateData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	// Entity type and scope cannot be changed
+	updateData.EntityType = wf.EntityType
+	if updateData.Name == "" {
+		updateData.Name = wf.Name
+	}
+
+	if msg := validateWorkflow(updateData); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	// Begin transaction
+	tx := database.DB.Begin()
+
+	// Records in a removed state could not move on, so their states are kept
+	inUse, err := removedStatesInUse(tx, &wf, updateData.States)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check workflow states",
+		})
+	}
+	if len(inUse) > 0 {
+		tx.Rollback()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  "States still in use cannot be removed: " + strings.Join(inUse, ", "),
+			"states": inUse,
+		})
+	}
+
+	if err := tx.Where("workflow_id = ?", wf.ID).Delete(&models.WorkflowState{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to replace workflow states",
+		})
+	}
+
+	if err := tx.Where("workflow_id = ?", wf.ID).Delete(&models.WorkflowTransition{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to replace workflow transitions",
+		})
+	}
+
+	wf.Name = updateData.Name
+	if err := tx.Save(&wf).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update workflow",
+		})
+	}
+
+	for i := range updateData.States {
+		state := updateData.States[i]
+		state.ID = 0
+		state.WorkflowID = wf.ID
+		state.Position = i
+		if err := tx.Create(&state).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create workflow state",
+			})
+		}
+	}
+
+	for i := range updateData.Transitions {
+		transition := updateData.Transitions[i]
+		transition.ID = 0
+		transition.WorkflowID = wf.ID
+		if err := tx.Create(&transition).Error; err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to create workflow transition",
+			})
+		}
+	}
+
+	// Commit transaction
+	tx.Commit()
+
+	database.DB.Where("id = ?", wf.ID).
+		Preload("States", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Preload("Transitions").
+		First(&wf)
+
+	invalidateTenantHealth(uint(tenantID))
+
+	return c.JSON(wf)
+}
+
+// DeleteWorkflow deletes a workflow so the next broader workflow applies again
+// @Summary Delete a workflow
+// @Description Delete a workflow. Records fall back to the tenant-wide or built-in workflow.
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Workflow ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /workflows/{id} [delete]
+func DeleteWorkflow(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workflow ID format",
+		})
+	}
+
+	var wf models.Workflow
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&wf)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workflow not found",
+		})
+	}
+
+	// Begin transaction
+	tx := database.DB.Begin()
+
+	if err := tx.Where("workflow_id = ?", wf.ID).Delete(&models.WorkflowState{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete workflow states",
+		})
+	}
+
+	if err := tx.Where("workflow_id = ?", wf.ID).Delete(&models.WorkflowTransition{}).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete workflow transitions",
+		})
+	}
+
+	if err := tx.Delete(&wf).Error; err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete workflow",
+		})
+	}
+
+	// Commit transaction
+	tx.Commit()
+
+	invalidateTenantHealth(uint(tenantID))
+
+	return c.JSON(fiber.Map{
+		"message": "Workflow deleted successfully",
+	})
+}
+
+// GetTaskTransitions lists the status transitions available for a task
+// @Summary Get available task transitions
+// @Description List the transitions out of the task's current status. Pass X-Person-ID to see which ones the person may perform.
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Task ID"
+// @Success 200 {object} transitionsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tasks/{id}/transitions [get]
+func GetTaskTransitions(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid task ID format",
+		})
+	}
+
+	var task models.Task
+	result := database.DB.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("tasks.id = ? AND projects.tenant_id = ?", id, tenantID).
+		First(&task)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	return listTransitions(c, uint(tenantID), &task.ProjectID, models.WorkflowEntityTask, string(task.Status))
+}
+
+// GetIssueTransitions lists the status transitions available for an issue
+// @Summary Get available issue transitions
+// @Description List the transitions out of the issue's current status. Pass X-Person-ID to see which ones the person may perform.
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Issue ID"
+// @Success 200 {object} transitionsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /issues/{id}/transitions [get]
+func GetIssueTransitions(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid issue ID format",
+		})
+	}
+
+	var issue models.Issue
+	result := database.DB.Joins("JOIN projects ON projects.id = issues.project_id").
+		Where("issues.id = ? AND projects.tenant_id = ?", id, tenantID).
+		First(&issue)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Issue not found",
+		})
+	}
+
+	return listTransitions(c, uint(tenantID), &issue.ProjectID, models.WorkflowEntityIssue, string(issue.Status))
+}
+
+// GetRiskTransitions lists the status transitions available for a risk
+// @Summary Get available risk transitions
+// @Description List the transitions out of the risk's current status. Pass X-Person-ID to see which ones the person may perform.
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Risk ID"
+// @Success 200 {object} transitionsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /risks/{id}/transitions [get]
+func GetRiskTransitions(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid risk ID format",
+		})
+	}
+
+	var risk models.Risk
+	result := database.DB.Joins("JOIN projects ON projects.id = risks.project_id").
+		Where("risks.id = ? AND projects.tenant_id = ?", id, tenantID).
+		First(&risk)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Risk not found",
+		})
+	}
+
+	return listTransitions(c, uint(tenantID), &risk.ProjectID, models.WorkflowEntityRisk, string(risk.Status))
+}
+
+// GetProcurementRequestTransitions lists the status transitions available for a procurement request
+// @Summary Get available procurement request transitions
+// @Description List the transitions out of the request's current status. Pass X-Person-ID to see which ones the person may perform.
+// @Tags procurement-requests
+// @Accept json
+// @Produce json
+// @Param tenant_id query string false "Tenant ID"
+// @Param X-Person-ID header string false "Acting person ID"
+// @Param id path int true "Procurement Request ID"
+// @Success 200 {object} transitionsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /procurement-requests/{id}/transitions [get]
+func GetProcurementRequestTransitions(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenant_id").(string)
+	tenantID, err := strconv.ParseUint(tenantIDStr, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID",
+		})
+	}
+
+	var request models.ProcurementRequest
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&request)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Procurement request not found",
+		})
+	}
+
+	return listTransitions(c, uint(tenantID), nil, models.WorkflowEntityProcurement, string(request.Status))
+}
+
+// listTransitions responds with the transitions out of a status for the acting person
+func listTransitions(c *fiber.Ctx, tenantID uint, projectID *uint, entityType models.WorkflowEntityType, status string) error {
+	def, workflowID, err := resolveWorkflow(tenantID, projectID, entityType)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load workflow: " + err.Error(),
+		})
+	}
+
+	role, err := actorRole(c, tenantID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := transitionsResponse{
+		WorkflowID:    workflowID,
+		CurrentStatus: status,
+		Transitions:   []availableTransition{},
+	}
+	for _, t := range def.Available(status) {
+		response.Transitions = append(response.Transitions, availableTransition{
+			Transition: t,
+			Permitted:  t.Permits(role),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// resolveWorkflow finds the workflow that governs a kind of record: the project's own
+// workflow first, then the tenant-wide one, then the built-in default.
+// The returned ID is nil when the built-in default applies; the error is set only
+// when the workflows could not be read.
+func resolveWorkflow(tenantID uint, projectID *uint, entityType models.WorkflowEntityType) (workflow.Definition, *uint, error) {
+	return resolveWorkflowIn(database.DB, tenantID, projectID, entityType)
+}
+
+// resolveWorkflowIn is resolveWorkflow running on the given connection, for use inside transactions
+func resolveWorkflowIn(db *gorm.DB, tenantID uint, projectID *uint, entityType models.WorkflowEntityType) (workflow.Definition, *uint, error) {
+	var wf models.Workflow
+	err := gorm.ErrRecordNotFound
+
+	if projectID != nil {
+		err = db.Where("tenant_id = ? AND project_id = ? AND entity_type = ?", tenantID, *projectID, entityType).
+			Preload("States").
+			Preload("Transitions").
+			First(&wf).Error
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = db.Where("tenant_id = ? AND project_id IS NULL AND entity_type = ?", tenantID, entityType).
+			Preload("States").
+			Preload("Transitions").
+			First(&wf).Error
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultWorkflow(entityType), nil, nil
+	}
+	if err != nil {
+		return workflow.Definition{}, nil, err
+	}
+
+	return wf.Definition(), &wf.ID, nil
+}
+
+// actorRole returns the role of the person identified by the X-Person-ID header,
+// or an empty role when the request does not identify a person
+func actorRole(c *fiber.Ctx, tenantID uint) (string, error) {
+	personIDStr := c.Locals("person_id")
+	if personIDStr == nil {
+		return "", nil
+	}
+
+	var person models.Person
+	result := database.DB.Where("id = ? AND tenant_id = ?", personIDStr.(string), tenantID).First(&person)
+	if result.Error != nil {
+		return "", errors.New("Acting person not found or not in the same tenant")
+	}
+
+	return person.Role, nil
+}
+
+// actorID returns the ID from the X-Person-ID header, or nil when the request does not identify a person
+func actorID(c *fiber.Ctx) *uint {
+	personIDStr := c.Locals("person_id")
+	if personIDStr == nil {
+		return nil
+	}
+
+	id, err := strconv.ParseUint(personIDStr.(string), 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	personID := uint(id)
+	return &personID
+}
+
+// workflowError responds with the status code that matches a workflow validation error
+func workflowError(c *fiber.Ctx, err error) error {
+	var missing *workflow.MissingFieldsError
+	switch {
+	case errors.Is(err, workflow.ErrRoleNotPermitted):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.As(err, &missing):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":          err.Error(),
+			"missing_fields": missing.Fields,
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+}
+
+// removedStatesInUse returns the states of a workflow that an update leaves out while
+// records the workflow governs are still in them
+func removedStatesInUse(db *gorm.DB, wf *models.Workflow, updated []models.WorkflowState) ([]string, error) {
+	var current []models.WorkflowState
+	if err := db.Where("workflow_id = ?", wf.ID).Find(&current).Error; err != nil {
+		return nil, err
+	}
+	kept := make(map[string]bool, len(updated))
+	for _, state := range updated {
+		kept[state.Name] = true
+	}
+	var removed []string
+	for _, state := range current {
+		if !kept[state.Name] {
+			removed = append(removed, state.Name)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	var inUse []string
+	if wf.EntityType == models.WorkflowEntityProcurement {
+		err := db.Model(&models.ProcurementRequest{}).
+			Where("tenant_id = ? AND status IN ?", wf.TenantID, removed).
+			Distinct("status").Order("status").Pluck("status", &inUse).Error
+		return inUse, err
+	}
+
+	var model interface{}
+	var table string
+	switch wf.EntityType {
+	case models.WorkflowEntityTask:
+		model, table = &models.Task{}, "tasks"
+	case models.WorkflowEntityIssue:
+		model, table = &models.Issue{}, "issues"
+	case models.WorkflowEntityRisk:
+		model, table = &models.Risk{}, "risks"
+	default:
+		return nil, nil
+	}
+
+	// A project's own workflow governs its records; a tenant-wide one those of the projects without one
+	query := db.Model(model).
+		Joins("JOIN projects ON projects.id = "+table+".project_id").
+		Where("projects.tenant_id = ? AND "+table+".status IN ?", wf.TenantID, removed)
+	if wf.ProjectID != nil {
+		query = query.Where(table+".project_id = ?", *wf.ProjectID)
+	} else {
+		query = query.Where(table+".project_id NOT IN (?)", db.Model(&models.Workflow{}).
+			Select("project_id").
+			Where("tenant_id = ? AND entity_type = ? AND project_id IS NOT NULL", wf.TenantID, wf.EntityType))
+	}
+	err := query.Distinct(table+".status").Order(table+".status").Pluck(table+".status", &inUse).Error
+	return inUse, err
+}
+
+// validateWorkflow checks that a workflow is internally consistent and returns a message if not
+func validateWorkflow(wf *models.Workflow) string {
+	switch wf.EntityType {
+	case models.WorkflowEntityTask, models.WorkflowEntityIssue, models.WorkflowEntityRisk:
+	case models.WorkflowEntityProcurement:
+		if wf.ProjectID != nil {
+			return "Procurement request workflows cannot be scoped to a project"
+		}
+	default:
+		return "Entity type must be one of task, issue, risk, procurement_request"
+	}
+
+	if wf.Name == "" {
+		return "Workflow name is required"
+	}
+
+	if len(wf.States) == 0 {
+		return "Workflow must have at least one state"
+	}
+
+	states := make(map[string]bool)
+	initial := 0
+	for _, state := range wf.States {
+		if state.Name == "" {
+			return "Workflow state name is required"
+		}
+		if state.Name == workflow.AnyState {
+			return "Workflow state name cannot be " + workflow.AnyState
+		}
+		if states[state.Name] {
+			return "Duplicate workflow state: " + state.Name
+		}
+		states[state.Name] = true
+		if state.Initial {
+			initial++
+		}
+	}
+	if initial > 1 {
+		return "Workflow can only have one initial state"
+	}
+
+	for _, t := range wf.Transitions {
+		if t.Name == "" {
+			return "Workflow transition name is required"
+		}
+		if t.FromState != workflow.AnyState && !states[t.FromState] {
+			return "Transition " + t.Name + " starts from unknown state " + t.FromState
+		}
+		if !states[t.ToState] {
+			return "Transition " + t.Name + " leads to unknown state " + t.ToState
+		}
+	}
+
+	return ""
+}
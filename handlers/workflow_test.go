@@ -0,0 +1,46 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateWorkflowKeepsStatesInUse(t *testing.T) {
+	setupBoardDB(t)
+	app := setupBoardApp()
+	app.Post("/workflows", handlers.CreateWorkflow)
+	app.Put("/workflows/:id", handlers.UpdateWorkflow)
+
+	status, body := doRequest(t, app, "POST", "/workflows",
+		`{"entity_type":"task","name":"Review","states":[{"name":"todo","initial":true},{"name":"review"},{"name":"done","final":true}]}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Launch", Status: "review"})
+
+	status, body = doRequest(t, app, "PUT", "/workflows/1",
+		`{"states":[{"name":"todo","initial":true},{"name":"done","final":true}]}`)
+	assert.Equal(t, fiber.StatusConflict, status, string(body))
+	var conflict struct {
+		States []string `json:"states"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &conflict))
+	assert.Equal(t, []string{"review"}, conflict.States)
+
+	// States nobody is in can go
+	status, body = doRequest(t, app, "PUT", "/workflows/1",
+		`{"states":[{"name":"todo","initial":true},{"name":"review","final":true}]}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+
+	// Once the project has its own workflow, the tenant-wide one no longer governs its tasks
+	status, body = doRequest(t, app, "POST", "/workflows",
+		`{"entity_type":"task","name":"Website","project_id":1,"states":[{"name":"todo","initial":true},{"name":"review","final":true}]}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	status, body = doRequest(t, app, "PUT", "/workflows/1",
+		`{"states":[{"name":"todo","initial":true},{"name":"done","final":true}]}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+}
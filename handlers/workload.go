@@ -0,0 +1,739 @@
+package handlers
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/Masozee/kontena/api/timeseries"
+	"github.com/Masozee/kontena/api/workflow"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultWorkloadWeeks = 4
+	maxWorkloadWeeks     = 26
+	// suggestionWindowDays is how far ahead load is compared when suggesting an assignee for a
+	// task without a future due date
+	suggestionWindowDays = 14
+)
+
+// workloadWeek is a person's capacity and load in one week
+type workloadWeek struct {
+	Start          time.Time `json:"start"`
+	CapacityHours  float64   `json:"capacity_hours"`
+	AbsenceHours   float64   `json:"absence_hours"`   // working hours lost to holidays and approved leave
+	AllocatedHours float64   `json:"allocated_hours"` // reserved by project allocations
+	TaskHours      float64   `json:"task_hours"`      // estimates of open tasks spread up to their due dates
+	LoadHours      float64   `json:"load_hours"`      // per project the larger of allocated and task hours
+	Utilization    float64   `json:"utilization"`     // load as a percentage of capacity
+	OverAllocated  bool      `json:"over_allocated"`
+}
+
+// personWorkload is a person's load against capacity over the requested weeks
+type personWorkload struct {
+	PersonID         uint           `json:"person_id"`
+	Name             string         `json:"name"`
+	Role             string         `json:"role"`
+	CapacityHours    float64        `json:"capacity_hours"`
+	LoadHours        float64        `json:"load_hours"`
+	UnscheduledHours float64        `json:"unscheduled_hours"` // estimates of open tasks without a due date
+	OverAllocated    bool           `json:"over_allocated"`    // in any of the weeks
+	Weeks            []workloadWeek `json:"weeks"`
+}
+
+// workloadResponse is the workload of a tenant's people
+type workloadResponse struct {
+	From   time.Time        `json:"from"`
+	Weeks  int              `json:"weeks"`
+	People []personWorkload `json:"people"`
+}
+
+// assigneeCandidate is a person who could take a task, with their load until it is due
+type assigneeCandidate struct {
+	PersonID      uint    `json:"person_id"`
+	Name          string  `json:"name"`
+	Role          string  `json:"role"`
+	CapacityHours float64 `json:"capacity_hours"`
+	LoadHours     float64 `json:"load_hours"`
+	FreeHours     float64 `json:"free_hours"`
+	Fits          bool    `json:"fits"`    // free hours cover the task's estimate
+	Current       bool    `json:"current"` // the task's current assignee
+}
+
+// assigneeSuggestions ranks the people who could take a task, least loaded first
+type assigneeSuggestions struct {
+	TaskID            uint                `json:"task_id"`
+	Role              string              `json:"role"`
+	From              time.Time           `json:"from"`
+	To                time.Time           `json:"to"`
+	EstimatedHours    float64             `json:"estimated_hours"`
+	SuggestedPersonID *uint               `json:"suggested_person_id"` // unset when nobody has the role
+	Candidates        []assigneeCandidate `json:"candidates"`
+}
+
+// GetAllocations returns the allocations of people to a project
+// @Summary Get project allocations
+// @Description Get the shares of people's capacity allocated to a project
+// @Tags workload
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.Allocation
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/allocations [get]
+func GetAllocations(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("project_id"), tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	var allocations []models.Allocation
+	result = database.DB.Where("project_id = ?", project.ID).Preload("Person").Order("start_date ASC").Find(&allocations)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve allocations",
+		})
+	}
+
+	return c.JSON(allocations)
+}
+
+// CreateAllocation allocates a share of a person's capacity to a project
+// @Summary Create an allocation
+// @Description Allocate a percentage of a person's capacity to a project from a start date, optionally until an end date
+// @Tags workload
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param project_id path int true "Project ID"
+// @Param allocation body models.Allocation true "Allocation object"
+// @Success 201 {object} models.Allocation
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /projects/{project_id}/allocations [post]
+func CreateAllocation(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	// Verify project belongs to tenant
+	var project models.Project
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("project_id"), tenantID).First(&project)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	}
+
+	var allocation models.Allocation
+	if err := c.BodyParser(&allocation); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if msg := validateAllocation(uint(tenantID), &allocation); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	allocation.ID = 0
+	allocation.TenantID = uint(tenantID)
+	allocation.ProjectID = project.ID
+	allocation.Person = nil
+
+	result = database.DB.Create(&allocation)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create allocation: " + result.Error.Error(),
+		})
+	}
+
+	database.DB.Preload("Person").First(&allocation, allocation.ID)
+	return c.Status(fiber.StatusCreated).JSON(allocation)
+}
+
+// UpdateAllocation updates an allocation
+// @Summary Update an allocation
+// @Description Replace the person, percentage and dates of an allocation
+// @Tags workload
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Allocation ID"
+// @Param allocation body models.Allocation true "Allocation object"
+// @Success 200 {object} models.Allocation
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /allocations/{id} [put]
+func UpdateAllocation(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var allocation models.Allocation
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&allocation)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Allocation not found",
+		})
+	}
+
+	var updated models.Allocation
+	if err := c.BodyParser(&updated); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	if msg := validateAllocation(uint(tenantID), &updated); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	allocation.PersonID = updated.PersonID
+	allocation.Percent = updated.Percent
+	allocation.StartDate = updated.StartDate
+	allocation.EndDate = updated.EndDate
+
+	result = database.DB.Save(&allocation)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update allocation: " + result.Error.Error(),
+		})
+	}
+
+	database.DB.Preload("Person").First(&allocation, allocation.ID)
+	return c.JSON(allocation)
+}
+
+// DeleteAllocation deletes an allocation
+// @Summary Delete an allocation
+// @Description Delete an allocation by ID
+// @Tags workload
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Allocation ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /allocations/{id} [delete]
+func DeleteAllocation(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var allocation models.Allocation
+	result := database.DB.Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&allocation)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Allocation not found",
+		})
+	}
+
+	result = database.DB.Delete(&allocation)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete allocation: " + result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Allocation deleted successfully",
+	})
+}
+
+// GetWorkload returns weekly load against capacity for the tenant's people
+// @Summary Get workload
+// @Description Get each person's weekly capacity and load across all projects. Load combines project allocations with the estimates of open assigned tasks, spread over working days up to their due dates.
+// @Tags workload
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param from query string false "Start of the first week (RFC 3339), defaults to this week"
+// @Param weeks query int false "Number of weeks, default 4, at most 26"
+// @Param person_id query int false "Only this person"
+// @Param role query string false "Only people with this role"
+// @Param over_allocated query bool false "Only people over-allocated in some week"
+// @Success 200 {object} workloadResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /workload [get]
+func GetWorkload(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	now := time.Now()
+	from := now
+	if value := c.Query("from"); value != "" {
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid from time: must be RFC 3339",
+			})
+		}
+	}
+	from = timeseries.Weekly.Start(dayOf(from))
+
+	weeks := c.QueryInt("weeks", defaultWorkloadWeeks)
+	if weeks < 1 || weeks > maxWorkloadWeeks {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Weeks must be between 1 and " + strconv.Itoa(maxWorkloadWeeks),
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ?", tenantID)
+	if personID := c.Query("person_id"); personID != "" {
+		query = query.Where("id = ?", personID)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	var people []models.Person
+	result := query.Order("name ASC").Find(&people)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve people",
+		})
+	}
+
+	plan, err := planWorkload(database.DB, uint(tenantID), people, from, weeks*7, now, 0)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute workload: " + err.Error(),
+		})
+	}
+
+	response := workloadResponse{From: from, Weeks: weeks, People: []personWorkload{}}
+	for _, person := range people {
+		workload := personWorkload{
+			PersonID:         person.ID,
+			Name:             person.Name,
+			Role:             person.Role,
+			UnscheduledHours: plan.unscheduled[person.ID],
+		}
+		days := plan.days[person.ID]
+		for w := 0; w < weeks; w++ {
+			week := workloadWeek{Start: from.AddDate(0, 0, w*7)}
+			for _, day := range days[w*7 : (w+1)*7] {
+				week.CapacityHours += day.capacity
+				week.AbsenceHours += day.absence
+				week.AllocatedHours += sumHours(day.allocated)
+				week.TaskHours += sumHours(day.tasks)
+				week.LoadHours += day.load()
+			}
+			week.Utilization = utilization(week.LoadHours, week.CapacityHours)
+			week.OverAllocated = week.LoadHours > week.CapacityHours+1e-9
+
+			workload.CapacityHours += week.CapacityHours
+			workload.LoadHours += week.LoadHours
+			workload.OverAllocated = workload.OverAllocated || week.OverAllocated
+			workload.Weeks = append(workload.Weeks, week)
+		}
+
+		if c.QueryBool("over_allocated") && !workload.OverAllocated {
+			continue
+		}
+		response.People = append(response.People, workload)
+	}
+
+	return c.JSON(response)
+}
+
+// GetAssigneeSuggestions ranks the people with a role by how loaded they are until a task is due
+// @Summary Suggest an assignee for a task
+// @Description Rank the people with the task assignee's role, or the given role, by their free hours until the task is due, least loaded first
+// @Tags workload
+// @Accept json
+// @Produce json
+// @Param tenant_id header string true "Tenant ID"
+// @Param id path int true "Task ID"
+// @Param role query string false "Role to choose from, required when the task is unassigned"
+// @Success 200 {object} assigneeSuggestions
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tasks/{id}/assignee-suggestions [get]
+func GetAssigneeSuggestions(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenant_id")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var task models.Task
+	result := database.DB.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("tasks.id = ? AND projects.tenant_id = ?", c.Params("id"), tenantID).
+		Preload("AssignedTo").
+		First(&task)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Task not found",
+		})
+	}
+
+	role := c.Query("role")
+	if role == "" && task.AssignedTo != nil {
+		role = task.AssignedTo.Role
+	}
+	if role == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Role is required for an unassigned task",
+		})
+	}
+
+	var people []models.Person
+	result = database.DB.Where("tenant_id = ? AND role = ?", tenantID, role).Find(&people)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve people",
+		})
+	}
+
+	// Load is compared from today until the task is due
+	now := time.Now()
+	from := dayOf(now)
+	days := suggestionWindowDays
+	if task.DueDate != nil && !dayOf(*task.DueDate).Before(from) {
+		days = int(dayOf(*task.DueDate).Sub(from).Hours()/24) + 1
+	}
+
+	// The task's own estimate is left out so the current assignee is compared fairly
+	plan, err := planWorkload(database.DB, uint(tenantID), people, from, days, now, task.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compute workload: " + err.Error(),
+		})
+	}
+
+	estimate := task.RemainingEstimate()
+
+	response := assigneeSuggestions{
+		TaskID:         task.ID,
+		Role:           role,
+		From:           from,
+		To:             from.AddDate(0, 0, days-1),
+		EstimatedHours: estimate,
+		Candidates:     []assigneeCandidate{},
+	}
+	for _, person := range people {
+		candidate := assigneeCandidate{
+			PersonID: person.ID,
+			Name:     person.Name,
+			Role:     person.Role,
+			Current:  task.AssignedToID != nil && *task.AssignedToID == person.ID,
+		}
+		for _, day := range plan.days[person.ID] {
+			candidate.CapacityHours += day.capacity
+			candidate.LoadHours += day.load()
+		}
+		candidate.FreeHours = math.Max(0, candidate.CapacityHours-candidate.LoadHours)
+		candidate.Fits = candidate.FreeHours >= estimate
+		response.Candidates = append(response.Candidates, candidate)
+	}
+
+	sort.SliceStable(response.Candidates, func(i, j int) bool {
+		a, b := response.Candidates[i], response.Candidates[j]
+		if a.FreeHours != b.FreeHours {
+			return a.FreeHours > b.FreeHours
+		}
+		return utilization(a.LoadHours, a.CapacityHours) < utilization(b.LoadHours, b.CapacityHours)
+	})
+	if len(response.Candidates) > 0 {
+		response.SuggestedPersonID = &response.Candidates[0].PersonID
+	}
+
+	return c.JSON(response)
+}
+
+// validateAllocation checks an allocation and returns a message describing the first problem
+func validateAllocation(tenantID uint, allocation *models.Allocation) string {
+	if allocation.Percent <= 0 || allocation.Percent > 100 {
+		return "Allocation percent must be greater than 0 and at most 100"
+	}
+	if allocation.StartDate.IsZero() {
+		return "Allocation start date is required"
+	}
+	if allocation.EndDate != nil && allocation.EndDate.Before(allocation.StartDate) {
+		return "Allocation end date must not be before its start date"
+	}
+
+	var person models.Person
+	if err := database.DB.Where("id = ? AND tenant_id = ?", allocation.PersonID, tenantID).First(&person).Error; err != nil {
+		return "Person not found or not in the same tenant"
+	}
+	return ""
+}
+
+// dayLoad is a person's capacity and planned work on one day, by project
+type dayLoad struct {
+	capacity  float64
+	absence   float64 // working hours lost to holidays and leave
+	allocated map[uint]float64
+	tasks     map[uint]float64
+}
+
+// load is the day's work: per project, task work fills the project's allocation before adding to it
+func (d *dayLoad) load() float64 {
+	return d.loadExcept(0)
+}
+
+// loadExcept is the day's work on every project but one
+func (d *dayLoad) loadExcept(skipProjectID uint) float64 {
+	total := 0.0
+	for projectID, hours := range d.allocated {
+		if projectID != skipProjectID {
+			total += math.Max(hours, d.tasks[projectID])
+		}
+	}
+	for projectID, hours := range d.tasks {
+		if _, ok := d.allocated[projectID]; !ok && projectID != skipProjectID {
+			total += hours
+		}
+	}
+	return total
+}
+
+// workloadPlan is the day-by-day load of people over a period
+type workloadPlan struct {
+	days        map[uint][]dayLoad // by person, one per day of the period
+	unscheduled map[uint]float64   // by person, remaining estimates of open tasks without a due date
+}
+
+// planWorkload works out the daily capacity and load of people over the days from a date,
+// leaving out holidays and approved leave.
+// The remaining estimate of each open task is spread over its assignee's working hours from now until
+// it is due; overdue tasks fall on today. The task with ID skipTaskID, if any, is left out.
+func planWorkload(db *gorm.DB, tenantID uint, people []models.Person, from time.Time, days int, now time.Time, skipTaskID uint) (*workloadPlan, error) {
+	plan := &workloadPlan{days: make(map[uint][]dayLoad), unscheduled: make(map[uint]float64)}
+	if len(people) == 0 {
+		return plan, nil
+	}
+
+	byID := make(map[uint]*models.Person, len(people))
+	personIDs := make([]uint, len(people))
+	for i := range people {
+		byID[people[i].ID] = &people[i]
+		personIDs[i] = people[i].ID
+	}
+
+	var tasks []models.Task
+	result := db.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("projects.tenant_id = ? AND projects.deleted_at IS NULL AND tasks.assigned_to_id IN ? AND tasks.estimated_hours > 0 AND tasks.id <> ?", tenantID, personIDs, skipTaskID).
+		Find(&tasks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	// The calendar covers the plan and the time up to the latest due date
+	today := dayOf(now)
+	first, last := from, from.AddDate(0, 0, days-1)
+	if today.Before(first) {
+		first = today
+	}
+	for _, task := range tasks {
+		if task.DueDate != nil && task.DueDate.After(last) {
+			last = dayOf(*task.DueDate)
+		}
+	}
+	calendar, err := loadWorkCalendar(db, tenantID, first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, person := range byID {
+		plan.days[person.ID] = make([]dayLoad, days)
+		for d := range plan.days[person.ID] {
+			day := from.AddDate(0, 0, d)
+			load := dayLoad{
+				capacity:  calendar.workingHours(person, day),
+				allocated: make(map[uint]float64),
+				tasks:     make(map[uint]float64),
+			}
+			if person.WorksOn(day.Weekday()) {
+				load.absence = person.DailyHours() - load.capacity
+			}
+			plan.days[person.ID][d] = load
+		}
+	}
+	// dayIndex returns the position of a day in the plan, or -1 outside it
+	dayIndex := func(day time.Time) int {
+		i := int(math.Round(day.Sub(from).Hours() / 24))
+		if i < 0 || i >= days {
+			return -1
+		}
+		return i
+	}
+
+	var allocations []models.Allocation
+	result = db.Where("tenant_id = ? AND person_id IN ?", tenantID, personIDs).
+		Where("start_date < ? AND (end_date IS NULL OR end_date >= ?)", from.AddDate(0, 0, days), from).
+		Find(&allocations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, allocation := range allocations {
+		for d, day := range plan.days[allocation.PersonID] {
+			if allocation.Covers(from.AddDate(0, 0, d)) {
+				day.allocated[allocation.ProjectID] += day.capacity * allocation.Percent / 100
+			}
+		}
+	}
+
+	workflows := make(map[uint]workflow.Definition)
+	for i := range tasks {
+		task := &tasks[i]
+		def, ok := workflows[task.ProjectID]
+		if !ok {
+			var err error
+			def, _, err = resolveWorkflowIn(db, tenantID, &task.ProjectID, models.WorkflowEntityTask)
+			if err != nil {
+				return nil, err
+			}
+			workflows[task.ProjectID] = def
+		}
+		remaining := task.RemainingEstimate()
+		if remaining <= 0 || task.Status == models.TaskStatusCompleted || def.IsFinal(string(task.Status)) {
+			continue
+		}
+
+		person := byID[*task.AssignedToID]
+		if task.DueDate == nil {
+			plan.unscheduled[person.ID] += remaining
+			continue
+		}
+
+		// Spread the remaining estimate over the working hours left until the due date
+		due := dayOf(*task.DueDate)
+		if due.Before(today) {
+			due = today
+		}
+		var available []float64
+		total := 0.0
+		for day := today; !day.After(due); day = day.AddDate(0, 0, 1) {
+			hours := calendar.workingHours(person, day)
+			available = append(available, hours)
+			total += hours
+		}
+		for d, hours := range available {
+			share := hours / total
+			if total == 0 {
+				share = 0
+				if d == len(available)-1 {
+					share = 1 // no working time left, so it all lands on the due date
+				}
+			}
+			if i := dayIndex(today.AddDate(0, 0, d)); i >= 0 {
+				plan.days[person.ID][i].tasks[task.ProjectID] += remaining * share
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// dayOf returns midnight UTC of the day containing t
+func dayOf(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// sumHours adds up hours by project
+func sumHours(hours map[uint]float64) float64 {
+	total := 0.0
+	for _, h := range hours {
+		total += h
+	}
+	return total
+}
+
+// utilization returns load as a percentage of capacity, or 0 without capacity; load on a day
+// off still counts as over-allocation
+func utilization(load, capacity float64) float64 {
+	if capacity == 0 {
+		return 0
+	}
+	return load / capacity * 100
+}
@@ -0,0 +1,151 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/database"
+	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupWorkloadDB sets up an isolated in-memory SQLite database with two developers on two projects
+func setupWorkloadDB(t *testing.T) {
+	newTestDB(t)
+
+	now := time.Now()
+	soon := now.AddDate(0, 0, 10)
+	week := now.AddDate(0, 0, 7)
+	hundred, eight, ten := 100.0, 8.0, 10.0
+	alice, bob := uint(1), uint(2)
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Domain: "test.example.com"})
+	database.DB.Create(&models.Tenant{Name: "Other Tenant", Domain: "other.example.com"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Alice", Email: "alice@example.com", Role: "developer", HoursPerWeek: 40, WorkingDays: "mon,tue,wed,thu,fri"})
+	database.DB.Create(&models.Person{TenantID: 1, Name: "Bob", Email: "bob@example.com", Role: "developer", HoursPerWeek: 20, WorkingDays: "mon,tue,wed,thu,fri"})
+	database.DB.Create(&models.Person{TenantID: 2, Name: "Mallory", Email: "mallory@example.com", Role: "developer", HoursPerWeek: 40, WorkingDays: "mon,tue,wed,thu,fri"})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Website", StartDate: now.AddDate(0, 0, -30)})
+	database.DB.Create(&models.Project{TenantID: 1, Name: "Intranet", StartDate: now.AddDate(0, 0, -30)})
+	database.DB.Create(&models.Allocation{TenantID: 1, ProjectID: 1, PersonID: 1, Percent: 50, StartDate: now.AddDate(-1, 0, 0)})
+	database.DB.Create(&models.Task{ProjectID: 2, Title: "Migration", Status: models.TaskStatusInProgress, AssignedToID: &alice, EstimatedHours: &hundred, DueDate: &soon})
+	database.DB.Create(&models.Task{ProjectID: 2, Title: "Docs", Status: models.TaskStatusTodo, AssignedToID: &alice, EstimatedHours: &ten})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Login", Status: models.TaskStatusTodo, AssignedToID: &alice, EstimatedHours: &eight, DueDate: &week})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Done", Status: models.TaskStatusCompleted, AssignedToID: &bob, EstimatedHours: &hundred, DueDate: &week})
+	database.DB.Create(&models.Task{ProjectID: 1, Title: "Unassigned", Status: models.TaskStatusTodo})
+}
+
+func setupWorkloadApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Get("/projects/:project_id/allocations", handlers.GetAllocations)
+	app.Post("/projects/:project_id/allocations", handlers.CreateAllocation)
+	app.Get("/workload", handlers.GetWorkload)
+	app.Get("/tasks/:id/assignee-suggestions", handlers.GetAssigneeSuggestions)
+	return app
+}
+
+type workloadBody struct {
+	People []struct {
+		Name             string  `json:"name"`
+		CapacityHours    float64 `json:"capacity_hours"`
+		LoadHours        float64 `json:"load_hours"`
+		UnscheduledHours float64 `json:"unscheduled_hours"`
+		OverAllocated    bool    `json:"over_allocated"`
+		Weeks            []struct {
+			CapacityHours  float64 `json:"capacity_hours"`
+			AllocatedHours float64 `json:"allocated_hours"`
+			TaskHours      float64 `json:"task_hours"`
+			LoadHours      float64 `json:"load_hours"`
+			Utilization    float64 `json:"utilization"`
+			OverAllocated  bool    `json:"over_allocated"`
+		} `json:"weeks"`
+	} `json:"people"`
+}
+
+func TestWorkloadAcrossProjects(t *testing.T) {
+	setupWorkloadDB(t)
+	app := setupWorkloadApp()
+
+	status, _ := doRequest(t, app, "POST", "/projects/2/allocations", `{"person_id":2,"percent":150,"start_date":"2024-01-01T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "POST", "/projects/2/allocations", `{"person_id":3,"percent":50,"start_date":"2024-01-01T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "POST", "/projects/2/allocations",
+		`{"person_id":2,"percent":25,"start_date":"2030-01-01T00:00:00Z","end_date":"2030-01-08T00:00:00Z"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	// Far ahead only the allocations count
+	status, body := doRequest(t, app, "GET", "/workload?from=2030-01-09T12:00:00Z&weeks=2&role=developer", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var workload workloadBody
+	assert.NoError(t, json.Unmarshal(body, &workload))
+	if assert.Len(t, workload.People, 2) {
+		alice, bob := workload.People[0], workload.People[1]
+		assert.Equal(t, "Alice", alice.Name)
+		assert.Equal(t, 10.0, alice.UnscheduledHours)
+		if assert.Len(t, alice.Weeks, 2) {
+			assert.Equal(t, 40.0, alice.Weeks[0].CapacityHours)
+			assert.Equal(t, 20.0, alice.Weeks[0].AllocatedHours)
+			assert.Equal(t, 50.0, alice.Weeks[0].Utilization)
+			assert.False(t, alice.Weeks[0].OverAllocated)
+		}
+		// Bob's allocation ends on Tuesday of the first week
+		assert.InDelta(t, 2.0, bob.Weeks[0].AllocatedHours, 1e-9)
+		assert.Equal(t, 0.0, bob.Weeks[1].AllocatedHours)
+	}
+
+	// Over the coming weeks Alice's task estimates pile on top of her allocation
+	status, body = doRequest(t, app, "GET", "/workload?over_allocated=true", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	workload = workloadBody{}
+	assert.NoError(t, json.Unmarshal(body, &workload))
+	if assert.Len(t, workload.People, 1) {
+		alice := workload.People[0]
+		assert.Equal(t, "Alice", alice.Name)
+		assert.True(t, alice.OverAllocated)
+		taskHours := 0.0
+		for _, week := range alice.Weeks {
+			taskHours += week.TaskHours
+		}
+		assert.InDelta(t, 108, taskHours, 1e-6)
+	}
+}
+
+func TestAssigneeSuggestions(t *testing.T) {
+	setupWorkloadDB(t)
+	app := setupWorkloadApp()
+
+	status, body := doRequest(t, app, "GET", "/tasks/3/assignee-suggestions", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var suggestions struct {
+		Role              string `json:"role"`
+		SuggestedPersonID *uint  `json:"suggested_person_id"`
+		Candidates        []struct {
+			Name      string  `json:"name"`
+			FreeHours float64 `json:"free_hours"`
+			Fits      bool    `json:"fits"`
+			Current   bool    `json:"current"`
+		} `json:"candidates"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &suggestions))
+	assert.Equal(t, "developer", suggestions.Role)
+	if assert.NotNil(t, suggestions.SuggestedPersonID) {
+		assert.Equal(t, uint(2), *suggestions.SuggestedPersonID)
+	}
+	if assert.Len(t, suggestions.Candidates, 2) {
+		assert.Equal(t, "Bob", suggestions.Candidates[0].Name)
+		assert.True(t, suggestions.Candidates[0].Fits)
+		assert.True(t, suggestions.Candidates[1].Current)
+	}
+
+	status, _ = doRequest(t, app, "GET", "/tasks/5/assignee-suggestions", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doRequest(t, app, "GET", "/tasks/5/assignee-suggestions?role=developer", "")
+	assert.Equal(t, fiber.StatusOK, status)
+}
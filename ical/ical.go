@@ -0,0 +1,130 @@
+// Package ical writes iCalendar (RFC 5545) feeds of dated events, so due dates can be
+// subscribed to from calendar apps.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ProdID identifies the product that produced a calendar
+const ProdID = "-//Kontena//Kontena API//EN"
+
+// maxLineOctets is the longest content line allowed before folding
+const maxLineOctets = 75
+
+// Event is a single calendar entry. Calendar apps match events by UID, so an event
+// keeps its UID across feed refreshes and changes replace the earlier copy.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	AllDay      bool      // an all-day event on the date of Start
+	Updated     time.Time // when the source record last changed
+	Categories  []string
+}
+
+// Calendar is a named collection of events
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Write writes the calendar as an iCalendar stream
+func (c *Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeLine(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", ProdID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	for _, event := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", escape(event.UID))
+		// DTSTAMP follows the record rather than the request so unchanged events stay byte-identical
+		line("DTSTAMP", formatDateTime(event.Updated))
+		line("LAST-MODIFIED", formatDateTime(event.Updated))
+		if event.AllDay {
+			line("DTSTART;VALUE=DATE", formatDate(event.Start))
+			line("DTEND;VALUE=DATE", formatDate(event.Start.AddDate(0, 0, 1)))
+			line("TRANSP", "TRANSPARENT")
+		} else {
+			line("DTSTART", formatDateTime(event.Start))
+			line("DTEND", formatDateTime(event.Start))
+		}
+		line("SUMMARY", escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escape(event.Description))
+		}
+		if len(event.Categories) > 0 {
+			categories := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				categories[i] = escape(category)
+			}
+			line("CATEGORIES", strings.Join(categories, ","))
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// String returns the calendar as an iCalendar stream
+func (c *Calendar) String() string {
+	var sb strings.Builder
+	c.Write(&sb)
+	return sb.String()
+}
+
+// escape escapes a TEXT value
+func escape(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// writeLine writes a content line, folding it after 75 octets without splitting a UTF-8 character
+func writeLine(w *bufio.Writer, s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts towards their length
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// formatDate formats the date of t as a DATE value
+func formatDate(t time.Time) string {
+	return t.Format("20060102")
+}
+
+// formatDateTime formats t as a UTC DATE-TIME value
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
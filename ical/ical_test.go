@@ -0,0 +1,69 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarWrite(t *testing.T) {
+	updated := time.Date(2024, time.March, 7, 15, 30, 0, 0, time.UTC)
+	cal := Calendar{
+		Name: "Website",
+		Events: []Event{
+			{
+				UID:         "task-1@kontena",
+				Summary:     "Design; review, sign off",
+				Description: "First line\nSecond line",
+				Start:       time.Date(2024, time.March, 8, 17, 0, 0, 0, time.UTC),
+				AllDay:      true,
+				Updated:     updated,
+				Categories:  []string{"Task", "Website"},
+			},
+			{
+				UID:     "maintenance-2@kontena",
+				Summary: "Service",
+				Start:   time.Date(2024, time.March, 9, 10, 0, 0, 0, time.FixedZone("WIB", 7*3600)),
+				Updated: updated,
+			},
+		},
+	}
+	got := cal.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:Website\r\n",
+		"UID:task-1@kontena\r\nDTSTAMP:20240307T153000Z\r\n",
+		"DTSTART;VALUE=DATE:20240308\r\nDTEND;VALUE=DATE:20240309\r\n",
+		`SUMMARY:Design\; review\, sign off` + "\r\n",
+		`DESCRIPTION:First line\nSecond line` + "\r\n",
+		"CATEGORIES:Task,Website\r\n",
+		"DTSTART:20240309T030000Z\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("calendar does not contain %q:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "BEGIN:VEVENT"); n != 2 {
+		t.Errorf("got %d events, want 2", n)
+	}
+}
+
+func TestLongLinesAreFolded(t *testing.T) {
+	cal := Calendar{Events: []Event{{UID: "1", Summary: strings.Repeat("é", 60)}}}
+
+	var summary []string
+	for i, line := range strings.Split(cal.String(), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line %d is %d octets long", i, len(line))
+		}
+		if strings.HasPrefix(line, "SUMMARY:") || (len(summary) > 0 && strings.HasPrefix(line, " ")) {
+			summary = append(summary, strings.TrimPrefix(line, " "))
+		}
+	}
+
+	if got := strings.Join(summary, ""); got != "SUMMARY:"+strings.Repeat("é", 60) {
+		t.Errorf("unfolded summary = %q", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/search"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -47,9 +48,23 @@ func InitDB() {
 		&models.Archive{},
 		&models.Asset{},
 		&models.Ticket{},
+		&models.TicketComment{},
+		&models.TicketCommentRevision{},
+		&models.Notification{},
+		&models.TicketWatcher{},
+		&models.Workflow{},
+		&models.WorkflowState{},
+		&models.WorkflowTransition{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
+
+	// Full-text search is backed by GIN indexes of the searched columns
+	if err := search.CreateIndexes(DB, models.SearchSources); err != nil {
+		log.Fatalf("Failed to create search indexes: %v", err)
+	}
 	log.Println("Database migration completed")
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/Masozee/kontena/api/internal/database"
 	"github.com/Masozee/kontena/api/internal/models"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // GetLeads returns all leads for a tenant
@@ -109,8 +110,13 @@ func CreateLead(c *fiber.Ctx) error {
 		}
 	}
 
-	result := database.DB.Create(&lead)
-	if result.Error != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&lead).Error; err != nil {
+			return err
+		}
+		return emitWebhook(tx, lead.TenantID, models.WebhookEventLeadCreated, lead)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create lead",
 		})
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetNotifications returns the notifications of the acting staff member
+// @Summary Get notifications
+// @Description Get the notifications of the staff member in X-Staff-ID, newest first. Pass unread=true for unread ones only.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param X-Staff-ID header string true "Acting staff ID"
+// @Param unread query bool false "Only unread notifications"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /notifications [get]
+func GetNotifications(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	staffID := actorStaffID(c)
+	if staffID == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "X-Staff-ID is required",
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ? AND staff_id = ?", tenantID, *staffID)
+	if c.QueryBool("unread") {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []models.Notification
+	result := query.Order("created_at DESC, id DESC").Find(&notifications)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve notifications",
+		})
+	}
+
+	var unread int64
+	database.DB.Model(&models.Notification{}).
+		Where("tenant_id = ? AND staff_id = ? AND read_at IS NULL", tenantID, *staffID).
+		Count(&unread)
+
+	return c.JSON(fiber.Map{
+		"unread":        unread,
+		"notifications": notifications,
+	})
+}
+
+// MarkNotificationRead marks one of the acting staff member's notifications as read
+// @Summary Mark a notification as read
+// @Description Mark a notification of the staff member in X-Staff-ID as read
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param X-Staff-ID header string true "Acting staff ID"
+// @Param id path int true "Notification ID"
+// @Success 200 {object} models.Notification
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /notifications/{id}/read [post]
+func MarkNotificationRead(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	staffID := actorStaffID(c)
+	if staffID == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "X-Staff-ID is required",
+		})
+	}
+
+	var notification models.Notification
+	result := database.DB.Where("id = ? AND tenant_id = ? AND staff_id = ?", id, tenantID, *staffID).First(&notification)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Notification not found",
+		})
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		database.DB.Model(&notification).Update("read_at", now)
+	}
+
+	return c.JSON(notification)
+}
+
+// MarkAllNotificationsRead marks all of the acting staff member's notifications as read
+// @Summary Mark all notifications as read
+// @Description Mark every unread notification of the staff member in X-Staff-ID as read
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param X-Staff-ID header string true "Acting staff ID"
+// @Success 200 {object} map[string]int64
+// @Failure 400 {object} map[string]string
+// @Router /notifications/read-all [post]
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	staffID := actorStaffID(c)
+	if staffID == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "X-Staff-ID is required",
+		})
+	}
+
+	result := database.DB.Model(&models.Notification{}).
+		Where("tenant_id = ? AND staff_id = ? AND read_at IS NULL", tenantID, *staffID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to mark notifications as read",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"updated": result.RowsAffected,
+	})
+}
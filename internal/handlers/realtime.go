@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bufio"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/realtime"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Realtime streams ticket changes to the ticket queue
+var Realtime = realtime.NewHub(realtime.DefaultHistory)
+
+// Stream streams changes on channels as Server-Sent Events
+// @Summary Stream real-time updates
+// @Description Stream the changes on channels as Server-Sent Events. The channels are tickets (the ticket queue) and ticket:<id>. Each event has the type of change as its event and its ID as its id; send the ID of the last event seen in Last-Event-ID to get the events missed while disconnected.
+// @Tags realtime
+// @Produce text/event-stream
+// @Param channels query string true "Comma-separated channels, e.g. tickets or ticket:1"
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stream [get]
+func Stream(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	channels, status, msg := streamChannels(uint(tenantID), c.Query("channels"))
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	lastEventID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	var lastID uint64
+	if lastEventID != "" {
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid Last-Event-ID",
+			})
+		}
+	}
+
+	subscription, missed := Realtime.Subscribe(uint(tenantID), channels, lastID)
+	heartbeat := Realtime.Heartbeat
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		realtime.Stream(w, subscription, missed, heartbeat)
+	})
+	return nil
+}
+
+// streamChannels parses and checks the channels of a stream request. Tickets of other
+// tenants are not found.
+func streamChannels(tenantID uint, list string) ([]string, int, string) {
+	var channels []string
+	for _, channel := range strings.Split(list, ",") {
+		channel = strings.TrimSpace(channel)
+		if channel == "" {
+			continue
+		}
+		if channel == "tickets" {
+			channels = append(channels, channel)
+			continue
+		}
+
+		kind, idStr, _ := strings.Cut(channel, ":")
+		id, err := strconv.Atoi(idStr)
+		if kind != "ticket" || err != nil || id <= 0 {
+			return nil, fiber.StatusBadRequest, "Invalid channel: " + channel
+		}
+
+		var count int64
+		database.DB.Model(&models.Ticket{}).Where("id = ? AND tenant_id = ?", id, tenantID).Count(&count)
+		if count == 0 {
+			return nil, fiber.StatusNotFound, "Channel not found: " + channel
+		}
+		channels = append(channels, "ticket:"+strconv.Itoa(id))
+	}
+
+	if len(channels) == 0 {
+		return nil, fiber.StatusBadRequest, "At least one channel is required"
+	}
+	return channels, 0, ""
+}
+
+// publishTicket publishes a ticket change on the ticket queue and the ticket's channel.
+// Call it once the change is saved.
+func publishTicket(ticket *models.Ticket, event string) {
+	channels := []string{"tickets", "ticket:" + strconv.FormatUint(uint64(ticket.ID), 10)}
+	if _, err := Realtime.Publish(ticket.TenantID, channels, event, ticket); err != nil {
+		log.Printf("Failed to publish %s for ticket %d: %v", event, ticket.ID, err)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/handlers"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTicketQueueIsStreamed(t *testing.T) {
+	setupTicketCommentDB(t)
+	database.DB.Create(&models.Tenant{Name: "Other Tenant", Plan: "Basic", Status: "Active"})
+	database.DB.Create(&models.Ticket{TenantID: 2, Title: "Secret", Description: "Other", Priority: models.TicketPriorityLow, Status: models.TicketStatusOpen})
+	app := setupTicketWatcherApp()
+	app.Delete("/tickets/:id", handlers.DeleteTicket)
+	app.Get("/stream", handlers.Stream)
+
+	// Channels must exist in the tenant
+	status, _ := doStaffRequest(t, app, "1", "GET", "/stream", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doStaffRequest(t, app, "1", "GET", "/stream?channels=ticket:2", "")
+	assert.Equal(t, fiber.StatusNotFound, status, "other tenants' tickets are not found")
+	status, _ = doStaffRequest(t, app, "1", "GET", "/stream?channels=leads", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+
+	queue, _ := handlers.Realtime.Subscribe(1, []string{"tickets"}, 0)
+	defer queue.Close()
+	printer, _ := handlers.Realtime.Subscribe(1, []string{"ticket:1"}, 0)
+	defer printer.Close()
+	other, _ := handlers.Realtime.Subscribe(2, []string{"tickets"}, 0)
+	defer other.Close()
+
+	status, body := doStaffRequest(t, app, "1", "POST", "/tickets",
+		`{"title":"VPN down","description":"Office","priority":"high","created_by_id":1}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	status, body = doStaffRequest(t, app, "1", "PATCH", "/tickets/1", `{"assigned_to_id":2}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	status, _ = doStaffRequest(t, app, "1", "DELETE", "/tickets/1", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var events []string
+	for len(queue.Messages()) > 0 {
+		events = append(events, (<-queue.Messages()).Event)
+	}
+	assert.Equal(t, []string{models.TicketEventCreated, models.TicketEventUpdated, models.TicketEventDeleted}, events)
+	assert.Len(t, printer.Messages(), 2, "ticket channels only get their ticket's changes")
+	assert.Len(t, other.Messages(), 0, "tenants never see each other's tickets")
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/search"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Search finds the tenant's records matching a query
+// @Summary Search
+// @Description Full-text search across tickets and leads (name, contact, email and notes). Every word of the query has to match, as a word prefix; results are ranked best first and their highlight marks the matches with <mark>.
+// @Tags search
+// @Accept json
+// @Produce json
+// @Param q query string true "Words to search for"
+// @Param types query string false "Comma-separated result types to search, e.g. ticket,lead (default all)"
+// @Param limit query int false "Maximum number of results (default 20, at most 100)"
+// @Success 200 {array} search.Result
+// @Failure 400 {object} map[string]string
+// @Router /search [get]
+func Search(c *fiber.Ctx) error {
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	query := c.Query("q")
+	if len(search.Terms(query)) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "q must contain at least one word",
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultSearchLimit)
+	if limit < 1 || limit > maxSearchLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit),
+		})
+	}
+
+	var types []string
+	if param := c.Query("types"); param != "" {
+		for _, t := range strings.Split(param, ",") {
+			types = append(types, strings.TrimSpace(t))
+		}
+	}
+	sources, err := search.Only(models.SearchSources, types)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	results, err := search.Search(database.DB, uint(tenantID), query, sources, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to search",
+		})
+	}
+
+	return c.JSON(results)
+}
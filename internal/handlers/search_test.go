@@ -0,0 +1,44 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/handlers"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/search"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTicketsAndLeads(t *testing.T) {
+	setupTicketCommentDB(t)
+	database.DB.AutoMigrate(&models.Lead{})
+	database.DB.Create(&models.Ticket{TenantID: 1, Title: "Printer offline", Description: "The Brother printer on floor 3", Priority: models.TicketPriorityLow, Status: models.TicketStatusOpen})
+	database.DB.Create(&models.Ticket{TenantID: 2, Title: "Printer toner", Description: "Other tenant", Priority: models.TicketPriorityLow, Status: models.TicketStatusOpen})
+	database.DB.Create(&models.Lead{TenantID: 1, Name: "Printworks Ltd", Contact: "Dana", Email: "dana@printworks.example.com", Notes: "Wants 20 printers"})
+	app := setupTicketWatcherApp()
+	app.Get("/search", handlers.Search)
+
+	status, body := doStaffRequest(t, app, "1", "GET", "/search?q=printer", "")
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	var results []search.Result
+	assert.NoError(t, json.Unmarshal(body, &results))
+
+	var found []string
+	for _, result := range results {
+		found = append(found, result.Type+":"+result.Title)
+	}
+	assert.Equal(t, []string{"ticket:Printer offline", "ticket:Printer jam", "lead:Printworks Ltd"}, found,
+		"tickets titled printer rank above the lead that wants printers; other tenants' tickets are not found")
+	assert.Equal(t, "<mark>Printer</mark> offline", results[0].Highlight)
+
+	status, body = doStaffRequest(t, app, "1", "GET", "/search?q=dana&types=lead", "")
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	assert.NoError(t, json.Unmarshal(body, &results))
+	assert.Len(t, results, 1)
+
+	status, _ = doStaffRequest(t, app, "1", "GET", "/search?q=printer&types=asset", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+}
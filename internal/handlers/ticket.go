@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/Masozee/kontena/api/internal/database"
 	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/workflow"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // GetTickets returns all tickets for a tenant
@@ -110,12 +114,42 @@ func CreateTicket(c *fiber.Ctx) error {
 		}
 	}
 
-	result := database.DB.Create(&ticket)
-	if result.Error != nil {
+	// New tickets start in the initial state of the tenant's workflow
+	def, _, err := resolveTicketWorkflow(ticket.TenantID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load workflow: " + err.Error(),
+		})
+	}
+	if ticket.Status == "" {
+		ticket.Status = models.TicketStatus(def.InitialState())
+	} else if !def.HasState(string(ticket.Status)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ticket status: " + string(ticket.Status),
+		})
+	}
+
+	// The creator and the assignee watch the ticket from the start
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ticket).Error; err != nil {
+			return err
+		}
+		for _, staffID := range []*uint{ticket.CreatedByID, ticket.AssignedToID} {
+			if staffID == nil {
+				continue
+			}
+			if err := watchTicket(tx, ticket, *staffID); err != nil {
+				return err
+			}
+		}
+		return emitWebhook(tx, ticket.TenantID, models.WebhookEventTicketCreated, ticket)
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create ticket",
 		})
 	}
+	publishTicket(ticket, models.TicketEventCreated)
 
 	return c.Status(fiber.StatusCreated).JSON(ticket)
 }
@@ -169,8 +203,51 @@ func UpdateTicket(c *fiber.Ctx) error {
 	updatedTicket.TenantID = existingTicket.TenantID
 	updatedTicket.ID = existingTicket.ID
 
-	// Update ticket
-	database.DB.Model(&existingTicket).Updates(updatedTicket)
+	// Status changes must follow the tenant's ticket workflow
+	if updatedTicket.Status != "" && updatedTicket.Status != existingTicket.Status {
+		role, err := actorRole(c, uint(tenantID))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		def, _, err := resolveTicketWorkflow(uint(tenantID))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load workflow: " + err.Error(),
+			})
+		}
+		if err := def.Validate(string(existingTicket.Status), string(updatedTicket.Status), role, workflow.Merge(existingTicket, updatedTicket)); err != nil {
+			return workflowError(c, err)
+		}
+	}
+
+	previousStatus := existingTicket.Status
+	previousAssignee := existingTicket.AssignedToID
+
+	// Update ticket and tell its watchers about status and assignee changes
+	var notifications []models.Notification
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existingTicket).Updates(updatedTicket).Error; err != nil {
+			return err
+		}
+		notifications, err = notifyTicketChange(tx, &existingTicket, previousStatus, previousAssignee, actorStaffID(c))
+		if err != nil {
+			return err
+		}
+		if existingTicket.Status == models.TicketStatusResolved && previousStatus != models.TicketStatusResolved {
+			return emitWebhook(tx, existingTicket.TenantID, models.WebhookEventTicketResolved, existingTicket)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update ticket",
+		})
+	}
+	emailTicketUpdates(&existingTicket, notifications)
+	publishTicket(&existingTicket, models.TicketEventUpdated)
 
 	return c.JSON(existingTicket)
 }
@@ -210,8 +287,41 @@ func DeleteTicket(c *fiber.Ctx) error {
 	}
 
 	database.DB.Delete(&ticket)
+	publishTicket(&ticket, models.TicketEventDeleted)
 
 	return c.JSON(fiber.Map{
 		"message": "Ticket deleted successfully",
 	})
 }
+
+// notifyTicketChange makes a new assignee a watcher and notifies the watchers, except the actor,
+// when the status or the assignee of a ticket changed
+func notifyTicketChange(tx *gorm.DB, ticket *models.Ticket, previousStatus models.TicketStatus, previousAssignee *uint, actorID *uint) ([]models.Notification, error) {
+	var changes []string
+	if ticket.Status != previousStatus {
+		changes = append(changes, fmt.Sprintf("status changed from %s to %s", previousStatus, ticket.Status))
+	}
+	reassigned := ticket.AssignedToID != nil && (previousAssignee == nil || *previousAssignee != *ticket.AssignedToID)
+	if reassigned {
+		if err := watchTicket(tx, ticket, *ticket.AssignedToID); err != nil {
+			return nil, err
+		}
+		changes = append(changes, "assignee changed")
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	var except []uint
+	if actorID != nil {
+		except = append(except, *actorID)
+	}
+
+	return notifyTicketWatchers(tx, models.Notification{
+		TenantID: ticket.TenantID,
+		Type:     models.NotificationTypeTicketUpdated,
+		TicketID: &ticket.ID,
+		ActorID:  actorID,
+		Message:  fmt.Sprintf("Ticket #%d %s: %s", ticket.ID, ticket.Title, strings.Join(changes, " and ")),
+	}, except...)
+}
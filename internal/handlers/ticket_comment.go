@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/like"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ticketCommentUpdate is the payload accepted when editing a ticket comment
+type ticketCommentUpdate struct {
+	Body     string `json:"body"`
+	Internal *bool  `json:"internal"`
+}
+
+// GetTicketComments returns all comments on a ticket
+// @Summary Get ticket comments
+// @Description Get the comments on a ticket, oldest first. Internal comments are left out unless include_internal=true.
+// @Tags ticket-comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Ticket ID"
+// @Param include_internal query bool false "Include internal comments"
+// @Success 200 {array} models.TicketComment
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/comments [get]
+func GetTicketComments(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var ticket models.Ticket
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&ticket)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	query := database.DB.Where("tenant_id = ? AND ticket_id = ?", tenantID, ticket.ID)
+	if !c.QueryBool("include_internal") {
+		query = query.Where("internal = ?", false)
+	}
+
+	var comments []models.TicketComment
+	result = query.Order("created_at ASC").Find(&comments)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve ticket comments",
+		})
+	}
+
+	return c.JSON(comments)
+}
+
+// CreateTicketComment adds a comment to a ticket
+// @Summary Comment on a ticket
+// @Description Add a comment or reply to a ticket. @mentions notify the mentioned staff members.
+// @Tags ticket-comments
+// @Accept json
+// @Produce json
+// @Param id path int true "Ticket ID"
+// @Param comment body models.TicketComment true "Comment information"
+// @Success 201 {object} models.TicketComment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/comments [post]
+func CreateTicketComment(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var ticket models.Ticket
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&ticket)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	comment := new(models.TicketComment)
+	if err := c.BodyParser(comment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if strings.TrimSpace(comment.Body) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is required",
+		})
+	}
+
+	var author models.Staff
+	result = database.DB.Where("id = ? AND tenant_id = ?", comment.AuthorID, tenantID).First(&author)
+	if result.Error != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Author not found",
+		})
+	}
+
+	// Replies must stay on the same ticket as their parent
+	if comment.ParentID != nil {
+		var parent models.TicketComment
+		result = database.DB.Where("id = ? AND ticket_id = ?", *comment.ParentID, ticket.ID).First(&parent)
+		if result.Error != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Parent comment not found on this ticket",
+			})
+		}
+	}
+
+	comment.ID = 0
+	comment.TenantID = uint(tenantID)
+	comment.TicketID = ticket.ID
+	comment.EditedAt = nil
+	comment.Revisions = nil
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		mentioned, err := notifyTicketMentions(tx, comment, models.ParseMentions(comment.Body))
+		if err != nil {
+			return err
+		}
+
+		// The author watches the ticket; the other watchers hear about the comment
+		// unless it already mentioned them
+		if err := watchTicket(tx, &ticket, comment.AuthorID); err != nil {
+			return err
+		}
+		_, err = notifyTicketWatchers(tx, models.Notification{
+			TenantID:  comment.TenantID,
+			Type:      models.NotificationTypeCommentAdded,
+			TicketID:  &comment.TicketID,
+			CommentID: &comment.ID,
+			ActorID:   &comment.AuthorID,
+			Message:   fmt.Sprintf("New comment on ticket #%d", comment.TicketID),
+		}, append(mentioned, comment.AuthorID)...)
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create ticket comment",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(comment)
+}
+
+// UpdateTicketComment edits a ticket comment and keeps the previous body as a revision
+// @Summary Edit a ticket comment
+// @Description Edit a ticket comment. Only the author, identified by X-Staff-ID, may edit; the previous body is kept as a revision.
+// @Tags ticket-comments
+// @Accept json
+// @Produce json
+// @Param X-Staff-ID header string true "ID of the staff member editing"
+// @Param id path int true "Ticket ID"
+// @Param comment_id path int true "Comment ID"
+// @Param comment body ticketCommentUpdate true "New body and whether the comment is internal"
+// @Success 200 {object} models.TicketComment
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/comments/{comment_id} [patch]
+func UpdateTicketComment(c *fiber.Ctx) error {
+	id := c.Params("id")
+	commentID := c.Params("comment_id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var comment models.TicketComment
+	result := database.DB.Where("id = ? AND ticket_id = ? AND tenant_id = ?", commentID, id, tenantID).First(&comment)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Comment not found",
+		})
+	}
+
+	update := new(ticketCommentUpdate)
+	if err := c.BodyParser(update); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if strings.TrimSpace(update.Body) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Comment body is required",
+		})
+	}
+
+	actor := actorStaffID(c)
+	if actor == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "X-Staff-ID is required to edit a comment",
+		})
+	}
+	if *actor != comment.AuthorID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author can edit this comment",
+		})
+	}
+
+	if update.Internal != nil {
+		comment.Internal = *update.Internal
+	}
+
+	previousMentions := models.ParseMentions(comment.Body)
+	bodyChanged := update.Body != comment.Body
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if bodyChanged {
+			revision := models.TicketCommentRevision{
+				CommentID:  comment.ID,
+				Body:       comment.Body,
+				EditedByID: *actor,
+			}
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+
+			now := time.Now()
+			comment.Body = update.Body
+			comment.EditedAt = &now
+		}
+
+		if err := tx.Save(&comment).Error; err != nil {
+			return err
+		}
+
+		if !bodyChanged {
+			return nil
+		}
+
+		// Only staff who were not already mentioned get a new notification
+		alreadyMentioned := make(map[string]bool)
+		for _, handle := range previousMentions {
+			alreadyMentioned[handle] = true
+		}
+		var newMentions []string
+		for _, handle := range models.ParseMentions(comment.Body) {
+			if !alreadyMentioned[handle] {
+				newMentions = append(newMentions, handle)
+			}
+		}
+		_, err := notifyTicketMentions(tx, &comment, newMentions)
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update ticket comment",
+		})
+	}
+
+	return c.JSON(comment)
+}
+
+// DeleteTicketComment soft deletes a ticket comment
+// @Summary Delete a ticket comment
+// @Description Soft delete a ticket comment. Only the author, identified by X-Staff-ID, may delete it. Replies to it are kept.
+// @Tags ticket-comments
+// @Accept json
+// @Produce json
+// @Param X-Staff-ID header string true "ID of the staff member deleting"
+// @Param id path int true "Ticket ID"
+// @Param comment_id path int true "Comment ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /tickets/{id}/comments/{comment_id} [delete]
+func DeleteTicketComment(c *fiber.Ctx) error {
+	id := c.Params("id")
+	commentID := c.Params("comment_id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var comment models.TicketComment
+	result := database.DB.Where("id = ? AND ticket_id = ? AND tenant_id = ?", commentID, id, tenantID).First(&comment)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Comment not found",
+		})
+	}
+
+	actor := actorStaffID(c)
+	if actor == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "X-Staff-ID is required to delete a comment",
+		})
+	}
+	if *actor != comment.AuthorID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author can delete this comment",
+		})
+	}
+
+	result = database.DB.Delete(&comment)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete ticket comment",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Comment deleted successfully",
+	})
+}
+
+// notifyTicketMentions creates a mention notification for every staff member referenced by handle
+// and returns the IDs of the staff members it notified.
+// A handle matches a staff member's full email address or the part before the @.
+func notifyTicketMentions(tx *gorm.DB, comment *models.TicketComment, handles []string) ([]uint, error) {
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	var clauses []string
+	var args []interface{}
+	for _, handle := range handles {
+		if strings.Contains(handle, "@") {
+			clauses = append(clauses, "LOWER(email) = ?")
+			args = append(args, handle)
+		} else {
+			clauses = append(clauses, `LOWER(email) LIKE ? ESCAPE '\'`)
+			args = append(args, like.Escape(handle)+"@%")
+		}
+	}
+
+	var staff []models.Staff
+	result := tx.Where("tenant_id = ?", comment.TenantID).
+		Where(strings.Join(clauses, " OR "), args...).
+		Find(&staff)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var notified []uint
+	for _, member := range staff {
+		if member.ID == comment.AuthorID {
+			continue
+		}
+
+		notification := models.Notification{
+			TenantID:  comment.TenantID,
+			StaffID:   member.ID,
+			Type:      models.NotificationTypeMention,
+			TicketID:  &comment.TicketID,
+			CommentID: &comment.ID,
+			ActorID:   &comment.AuthorID,
+			Message:   fmt.Sprintf("You were mentioned in a comment on ticket #%d", comment.TicketID),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return nil, err
+		}
+		notified = append(notified, member.ID)
+	}
+
+	return notified, nil
+}
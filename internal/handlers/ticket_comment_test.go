@@ -0,0 +1,159 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/handlers"
+	"github.com/Masozee/kontena/api/internal/middleware"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTicketCommentDB sets up an isolated in-memory SQLite database with one ticket and two staff members
+func setupTicketCommentDB(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to in-memory database: %v", err)
+	}
+
+	database.DB.AutoMigrate(
+		&models.Tenant{},
+		&models.Staff{},
+		&models.Ticket{},
+		&models.TicketComment{},
+		&models.TicketCommentRevision{},
+		&models.Notification{},
+		&models.TicketWatcher{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.Workflow{},
+		&models.WorkflowState{},
+		&models.WorkflowTransition{},
+	)
+
+	database.DB.Create(&models.Tenant{Name: "Test Tenant", Plan: "Basic", Status: "Active"})
+	database.DB.Create(&models.Staff{TenantID: 1, Name: "Alice", Email: "alice@acme.example.com", Role: models.RoleStaffManager})
+	database.DB.Create(&models.Staff{TenantID: 1, Name: "Bob", Email: "bob@acme.example.com", Role: models.RoleStaffEmployee})
+	database.DB.Create(&models.Ticket{TenantID: 1, Title: "Printer jam", Description: "Floor 2", Priority: models.TicketPriorityLow, Status: models.TicketStatusOpen})
+}
+
+func setupTicketCommentApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Get("/tickets/:id/comments", handlers.GetTicketComments)
+	app.Post("/tickets/:id/comments", handlers.CreateTicketComment)
+	app.Patch("/tickets/:id/comments/:comment_id", handlers.UpdateTicketComment)
+	app.Delete("/tickets/:id/comments/:comment_id", handlers.DeleteTicketComment)
+	return app
+}
+
+func doTicketCommentRequest(t *testing.T, app *fiber.App, method, url, body string) (int, []byte) {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "1")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+func TestCreateTicketCommentNotifiesMentions(t *testing.T) {
+	setupTicketCommentDB(t)
+	app := setupTicketCommentApp()
+
+	status, _ := doTicketCommentRequest(t, app, "POST", "/tickets/1/comments",
+		`{"author_id":1,"body":"@bob can you take this? @alice is me"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+
+	var notifications []models.Notification
+	database.DB.Find(&notifications)
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, uint(2), notifications[0].StaffID)
+	assert.Equal(t, models.NotificationTypeMention, notifications[0].Type)
+
+	// LIKE wildcards in a handle match nobody
+	status, _ = doTicketCommentRequest(t, app, "POST", "/tickets/1/comments",
+		`{"author_id":1,"body":"Everyone? @% @_ob"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	var count int64
+	database.DB.Model(&models.Notification{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestGetTicketCommentsHidesInternal(t *testing.T) {
+	setupTicketCommentDB(t)
+	app := setupTicketCommentApp()
+
+	doTicketCommentRequest(t, app, "POST", "/tickets/1/comments", `{"author_id":1,"body":"Customer visible"}`)
+	doTicketCommentRequest(t, app, "POST", "/tickets/1/comments", `{"author_id":1,"body":"Staff only","internal":true}`)
+
+	status, body := doTicketCommentRequest(t, app, "GET", "/tickets/1/comments", "")
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var comments []models.TicketComment
+	assert.NoError(t, json.Unmarshal(body, &comments))
+	if assert.Len(t, comments, 1) {
+		assert.Equal(t, "Customer visible", comments[0].Body)
+	}
+
+	status, body = doTicketCommentRequest(t, app, "GET", "/tickets/1/comments?include_internal=true", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.NoError(t, json.Unmarshal(body, &comments))
+	assert.Len(t, comments, 2)
+}
+
+func TestUpdateTicketCommentKeepsRevision(t *testing.T) {
+	setupTicketCommentDB(t)
+	app := setupTicketCommentApp()
+
+	doTicketCommentRequest(t, app, "POST", "/tickets/1/comments", `{"author_id":1,"body":"First draft"}`)
+
+	status, _ := doStaffRequest(t, app, "2", "PATCH", "/tickets/1/comments/1", `{"body":"Hijacked"}`)
+	assert.Equal(t, fiber.StatusForbidden, status)
+	status, _ = doTicketCommentRequest(t, app, "PATCH", "/tickets/1/comments/1", `{"body":"Hijacked"}`)
+	assert.Equal(t, fiber.StatusBadRequest, status, "the editor is identified")
+	status, _ = doStaffRequest(t, app, "1", "PATCH", "/tickets/1/comments/1", `{"internal":true}`)
+	assert.Equal(t, fiber.StatusBadRequest, status, "the body is required")
+
+	status, body := doStaffRequest(t, app, "1", "PATCH", "/tickets/1/comments/1", `{"body":"Final text"}`)
+	assert.Equal(t, fiber.StatusOK, status)
+
+	var comment models.TicketComment
+	assert.NoError(t, json.Unmarshal(body, &comment))
+	assert.Equal(t, "Final text", comment.Body)
+	assert.NotNil(t, comment.EditedAt)
+
+	var revisions []models.TicketCommentRevision
+	database.DB.Where("comment_id = ?", 1).Find(&revisions)
+	assert.Len(t, revisions, 1)
+	assert.Equal(t, "First draft", revisions[0].Body)
+}
+
+func TestOnlyTheAuthorDeletesATicketComment(t *testing.T) {
+	setupTicketCommentDB(t)
+	app := setupTicketCommentApp()
+
+	doTicketCommentRequest(t, app, "POST", "/tickets/1/comments", `{"author_id":1,"body":"On it"}`)
+
+	status, _ := doTicketCommentRequest(t, app, "DELETE", "/tickets/1/comments/1", "")
+	assert.Equal(t, fiber.StatusBadRequest, status)
+	status, _ = doStaffRequest(t, app, "2", "DELETE", "/tickets/1/comments/1", "")
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	status, _ = doStaffRequest(t, app, "1", "DELETE", "/tickets/1/comments/1", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	var count int64
+	database.DB.Model(&models.TicketComment{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
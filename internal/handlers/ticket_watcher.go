@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/mailer"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Mailer emails ticket updates to watching staff. Without one, only in-app notifications are created.
+var Mailer mailer.Sender
+
+// ticketWatchRequest is the payload accepted when adding a ticket watcher
+type ticketWatchRequest struct {
+	StaffID uint `json:"staff_id"`
+}
+
+// GetTicketWatchers returns the staff members watching a ticket
+// @Summary Get ticket watchers
+// @Description Get the staff members who are notified about activity on a ticket
+// @Tags ticket-watchers
+// @Accept json
+// @Produce json
+// @Param id path int true "Ticket ID"
+// @Success 200 {array} models.TicketWatcher
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/watchers [get]
+func GetTicketWatchers(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var ticket models.Ticket
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&ticket)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	var watchers []models.TicketWatcher
+	result = database.DB.Preload("Staff").Where("ticket_id = ?", ticket.ID).Order("created_at ASC").Find(&watchers)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve ticket watchers",
+		})
+	}
+
+	return c.JSON(watchers)
+}
+
+// WatchTicket adds a staff member to a ticket's watchers
+// @Summary Watch a ticket
+// @Description Add a staff member to a ticket's watchers. Without a staff_id the staff member in X-Staff-ID is added. Watching twice has no effect.
+// @Tags ticket-watchers
+// @Accept json
+// @Produce json
+// @Param id path int true "Ticket ID"
+// @Param watcher body ticketWatchRequest false "Staff member to add"
+// @Success 200 {array} models.TicketWatcher
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/watchers [post]
+func WatchTicket(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var ticket models.Ticket
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&ticket)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	request := new(ticketWatchRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	if request.StaffID == 0 {
+		if actor := actorStaffID(c); actor != nil {
+			request.StaffID = *actor
+		}
+	}
+	if request.StaffID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "staff_id or X-Staff-ID is required",
+		})
+	}
+
+	var staff models.Staff
+	result = database.DB.Where("id = ? AND tenant_id = ?", request.StaffID, tenantID).First(&staff)
+	if result.Error != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Staff member not found",
+		})
+	}
+
+	if err := watchTicket(database.DB, &ticket, staff.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to watch ticket",
+		})
+	}
+
+	return GetTicketWatchers(c)
+}
+
+// UnwatchTicket removes a staff member from a ticket's watchers
+// @Summary Unwatch a ticket
+// @Description Remove a staff member from a ticket's watchers
+// @Tags ticket-watchers
+// @Accept json
+// @Produce json
+// @Param id path int true "Ticket ID"
+// @Param staff_id path int true "Staff ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/watchers/{staff_id} [delete]
+func UnwatchTicket(c *fiber.Ctx) error {
+	id := c.Params("id")
+	staffID := c.Params("staff_id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	result := database.DB.Where("ticket_id = ? AND staff_id = ? AND tenant_id = ?", id, staffID, tenantID).
+		Delete(&models.TicketWatcher{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to unwatch ticket",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Watcher not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Watcher removed successfully",
+	})
+}
+
+// actorStaffID returns the ID of the staff member identified by the X-Staff-ID header, if any
+func actorStaffID(c *fiber.Ctx) *uint {
+	staffIDStr := c.Locals("staffID")
+	if staffIDStr == nil {
+		return nil
+	}
+
+	staffID, err := strconv.Atoi(staffIDStr.(string))
+	if err != nil || staffID <= 0 {
+		return nil
+	}
+
+	id := uint(staffID)
+	return &id
+}
+
+// watchTicket adds a staff member to a ticket's watchers unless they already watch it
+func watchTicket(tx *gorm.DB, ticket *models.Ticket, staffID uint) error {
+	watcher := models.TicketWatcher{
+		TenantID: ticket.TenantID,
+		TicketID: ticket.ID,
+		StaffID:  staffID,
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&watcher).Error
+}
+
+// notifyTicketWatchers sends a notification to every watcher of a ticket except the given staff members
+// and returns the notifications it created
+func notifyTicketWatchers(tx *gorm.DB, notification models.Notification, except ...uint) ([]models.Notification, error) {
+	var staffIDs []uint
+	result := tx.Model(&models.TicketWatcher{}).Where("ticket_id = ?", *notification.TicketID).Pluck("staff_id", &staffIDs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	skip := make(map[uint]bool)
+	for _, id := range except {
+		skip[id] = true
+	}
+
+	var created []models.Notification
+	for _, staffID := range staffIDs {
+		if skip[staffID] {
+			continue
+		}
+
+		notification.ID = 0
+		notification.StaffID = staffID
+		if err := tx.Create(&notification).Error; err != nil {
+			return nil, err
+		}
+		created = append(created, notification)
+	}
+
+	return created, nil
+}
+
+// emailTicketUpdates emails ticket update notifications to their staff members. Sending is
+// best effort: failures are logged and not retried.
+func emailTicketUpdates(ticket *models.Ticket, notifications []models.Notification) {
+	if Mailer == nil {
+		return
+	}
+
+	for _, notification := range notifications {
+		var staff models.Staff
+		if err := database.DB.Where("id = ?", notification.StaffID).First(&staff).Error; err != nil {
+			continue
+		}
+		if !mailer.ValidAddress(staff.Email) {
+			continue
+		}
+
+		data := mailer.Data{
+			Recipient:  staff.Name,
+			Message:    notification.Message,
+			EntityType: "ticket",
+			EntityID:   ticket.ID,
+			Date:       notification.CreatedAt,
+		}
+		if notification.ActorID != nil {
+			var actor models.Staff
+			if database.DB.Where("id = ?", *notification.ActorID).First(&actor).Error == nil {
+				data.Actor = actor.Name
+			}
+		}
+
+		msg, err := mailer.Defaults[mailer.EventTicketUpdated].Render(staff.Email, data)
+		if err == nil {
+			err = Mailer.Send(msg)
+		}
+		if err != nil {
+			log.Printf("Failed to email ticket %d update to staff %d: %v", ticket.ID, staff.ID, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/handlers"
+	"github.com/Masozee/kontena/api/internal/middleware"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/mailer"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTicketWatcherApp() *fiber.App {
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Post("/tickets", handlers.CreateTicket)
+	app.Patch("/tickets/:id", handlers.UpdateTicket)
+	app.Post("/tickets/:id/comments", handlers.CreateTicketComment)
+	app.Get("/tickets/:id/watchers", handlers.GetTicketWatchers)
+	app.Post("/tickets/:id/watchers", handlers.WatchTicket)
+	app.Delete("/tickets/:id/watchers/:staff_id", handlers.UnwatchTicket)
+	app.Get("/notifications", handlers.GetNotifications)
+	app.Post("/notifications/read-all", handlers.MarkAllNotificationsRead)
+	app.Post("/notifications/:id/read", handlers.MarkNotificationRead)
+	return app
+}
+
+func doStaffRequest(t *testing.T, app *fiber.App, staffID, method, url, body string) (int, []byte) {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "1")
+	req.Header.Set("X-Staff-ID", staffID)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, data
+}
+
+type staffNotifications struct {
+	Unread        int64                 `json:"unread"`
+	Notifications []models.Notification `json:"notifications"`
+}
+
+func getStaffNotifications(t *testing.T, app *fiber.App, staffID string) staffNotifications {
+	status, body := doStaffRequest(t, app, staffID, "GET", "/notifications", "")
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+
+	var result staffNotifications
+	assert.NoError(t, json.Unmarshal(body, &result))
+	return result
+}
+
+func TestTicketWatchersAreNotified(t *testing.T) {
+	setupTicketCommentDB(t)
+	database.DB.Create(&models.Staff{TenantID: 1, Name: "Carol", Email: "carol@acme.example.com", Role: models.RoleStaffEmployee})
+	app := setupTicketWatcherApp()
+	outbox := &mailer.Outbox{}
+	handlers.Mailer = outbox
+	defer func() { handlers.Mailer = nil }()
+
+	// Alice opens a ticket for Bob; both watch it
+	status, body := doStaffRequest(t, app, "1", "POST", "/tickets",
+		`{"title":"VPN down","description":"Office","priority":"high","created_by_id":1,"assigned_to_id":2}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	var ticket models.Ticket
+	assert.NoError(t, json.Unmarshal(body, &ticket))
+	url := fmt.Sprintf("/tickets/%d", ticket.ID)
+
+	status, _ = doStaffRequest(t, app, "3", "POST", url+"/watchers", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, body = doStaffRequest(t, app, "3", "POST", url+"/watchers", `{"staff_id":3}`)
+	assert.Equal(t, fiber.StatusOK, status, "watching twice is harmless")
+	var watchers []models.TicketWatcher
+	assert.NoError(t, json.Unmarshal(body, &watchers))
+	assert.Len(t, watchers, 3)
+
+	// Bob starts work: Alice and Carol are told, Bob is not
+	status, body = doStaffRequest(t, app, "2", "PATCH", url, `{"status":"in_progress"}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	assert.Equal(t, int64(1), getStaffNotifications(t, app, "1").Unread)
+	assert.Equal(t, models.NotificationTypeTicketUpdated, getStaffNotifications(t, app, "3").Notifications[0].Type)
+	assert.Empty(t, getStaffNotifications(t, app, "2").Notifications)
+	emails := outbox.Messages()
+	assert.Len(t, emails, 2)
+	assert.Equal(t, "alice@acme.example.com", emails[0].To)
+	assert.Equal(t, fmt.Sprintf("Ticket #%d updated", ticket.ID), emails[0].Subject)
+	assert.Contains(t, emails[0].Body, "status changed from open to in_progress, by Bob")
+
+	// A comment mentioning Carol gives her a mention only; Alice gets the comment
+	status, _ = doStaffRequest(t, app, "2", "POST", url+"/comments", `{"author_id":2,"body":"@carol can you check the router?"}`)
+	assert.Equal(t, fiber.StatusCreated, status)
+	carol := getStaffNotifications(t, app, "3")
+	assert.Len(t, carol.Notifications, 2)
+	assert.Equal(t, models.NotificationTypeMention, carol.Notifications[0].Type)
+	assert.Equal(t, models.NotificationTypeCommentAdded, getStaffNotifications(t, app, "1").Notifications[0].Type)
+
+	// Unwatching stops the notifications
+	status, _ = doStaffRequest(t, app, "1", "DELETE", url+"/watchers/1", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	status, _ = doStaffRequest(t, app, "1", "DELETE", url+"/watchers/1", "")
+	assert.Equal(t, fiber.StatusNotFound, status)
+	doStaffRequest(t, app, "2", "PATCH", url, `{"status":"resolved"}`)
+	assert.Len(t, getStaffNotifications(t, app, "1").Notifications, 2)
+
+	// Reading notifications
+	status, _ = doStaffRequest(t, app, "1", "POST", "/notifications/3/read", "")
+	assert.Equal(t, fiber.StatusNotFound, status, "someone else's notification")
+	oldest := carol.Notifications[1].ID
+	status, body = doStaffRequest(t, app, "3", "POST", fmt.Sprintf("/notifications/%d/read", oldest), "")
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	assert.Equal(t, int64(2), getStaffNotifications(t, app, "3").Unread)
+	status, body = doStaffRequest(t, app, "3", "POST", "/notifications/read-all", "")
+	assert.Equal(t, fiber.StatusOK, status)
+	assert.JSONEq(t, `{"updated":2}`, string(body))
+	assert.Equal(t, int64(0), getStaffNotifications(t, app, "3").Unread)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/webhook"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// webhookClient sends webhook deliveries. Endpoints get ten seconds to answer.
+var webhookClient = webhook.NewClient(10 * time.Second)
+
+// webhooks serves the webhook API on this app's database, tenants and events
+var webhooks = webhook.API{
+	DB:       func() *gorm.DB { return database.DB },
+	TenantID: webhookTenantID,
+	Events:   models.WebhookEvents,
+}
+
+// GetWebhookEvents returns the events webhook endpoints can subscribe to
+// @Summary Get webhook events
+// @Description Get the event types webhook endpoints can subscribe to
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Success 200 {array} string
+// @Router /webhooks/events [get]
+func GetWebhookEvents(c *fiber.Ctx) error {
+	return webhooks.GetEvents(c)
+}
+
+// GetWebhookEndpoints returns the tenant's webhook endpoints
+// @Summary Get webhook endpoints
+// @Description Get the tenant's webhook endpoints. Secrets are not shown.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Success 200 {array} webhook.Endpoint
+// @Failure 400 {object} map[string]string
+// @Router /webhooks [get]
+func GetWebhookEndpoints(c *fiber.Ctx) error {
+	return webhooks.GetEndpoints(c)
+}
+
+// GetWebhookEndpoint returns a webhook endpoint
+// @Summary Get a webhook endpoint
+// @Description Get a webhook endpoint by ID. The secret is not shown.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook endpoint ID"
+// @Success 200 {object} webhook.Endpoint
+// @Failure 404 {object} map[string]string
+// @Router /webhooks/{id} [get]
+func GetWebhookEndpoint(c *fiber.Ctx) error {
+	return webhooks.GetEndpoint(c)
+}
+
+// CreateWebhookEndpoint registers a webhook endpoint
+// @Summary Create a webhook endpoint
+// @Description Register a URL to be sent the given events. The response has the signing secret, which is not shown again.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param endpoint body webhook.EndpointRequest true "URL, description and events"
+// @Success 201 {object} webhook.EndpointWithSecret
+// @Failure 400 {object} map[string]string
+// @Router /webhooks [post]
+func CreateWebhookEndpoint(c *fiber.Ctx) error {
+	return webhooks.CreateEndpoint(c)
+}
+
+// UpdateWebhookEndpoint updates a webhook endpoint
+// @Summary Update a webhook endpoint
+// @Description Update a webhook endpoint's URL, description, events and whether it is active. Activating an endpoint that was disabled for failing resets its failure count.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook endpoint ID"
+// @Param endpoint body webhook.EndpointRequest true "URL, description, events and active"
+// @Success 200 {object} webhook.Endpoint
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /webhooks/{id} [put]
+func UpdateWebhookEndpoint(c *fiber.Ctx) error {
+	return webhooks.UpdateEndpoint(c)
+}
+
+// RotateWebhookSecret replaces the signing secret of a webhook endpoint
+// @Summary Rotate a webhook secret
+// @Description Replace the signing secret of a webhook endpoint. The response has the new secret, which is not shown again.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook endpoint ID"
+// @Success 200 {object} webhook.EndpointWithSecret
+// @Failure 404 {object} map[string]string
+// @Router /webhooks/{id}/rotate-secret [post]
+func RotateWebhookSecret(c *fiber.Ctx) error {
+	return webhooks.RotateSecret(c)
+}
+
+// DeleteWebhookEndpoint deletes a webhook endpoint and its delivery log
+// @Summary Delete a webhook endpoint
+// @Description Delete a webhook endpoint, its pending deliveries and its delivery log
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook endpoint ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /webhooks/{id} [delete]
+func DeleteWebhookEndpoint(c *fiber.Ctx) error {
+	return webhooks.DeleteEndpoint(c)
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook endpoint, newest first
+// @Summary Get webhook deliveries
+// @Description Get the deliveries to a webhook endpoint, newest first, with the outcome of their latest attempt
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook endpoint ID"
+// @Param status query string false "Only deliveries with this status (pending, succeeded or failed)"
+// @Param limit query int false "Maximum number of deliveries (default 50, at most 200)"
+// @Success 200 {array} webhook.Delivery
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(c *fiber.Ctx) error {
+	return webhooks.GetDeliveries(c)
+}
+
+// ReplayWebhookDelivery sends a delivery again
+// @Summary Replay a webhook delivery
+// @Description Queue a new delivery with the same event and payload as an earlier one, e.g. after fixing the receiving system
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook endpoint ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 201 {object} webhook.Delivery
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /webhooks/{id}/deliveries/{delivery_id}/replay [post]
+func ReplayWebhookDelivery(c *fiber.Ctx) error {
+	return webhooks.ReplayDelivery(c)
+}
+
+// DeliverWebhooks attempts the webhook deliveries that are due. It is run periodically by the scheduler.
+func DeliverWebhooks(now time.Time) error {
+	return webhook.Deliver(database.DB, webhookClient, now)
+}
+
+// emitWebhook queues a delivery of the event to every active endpoint of the tenant that
+// subscribes to it. Run it in the transaction of the change so deliveries are only queued
+// for changes that were saved.
+func emitWebhook(tx *gorm.DB, tenantID uint, event string, data interface{}) error {
+	return webhook.Queue(tx, tenantID, event, time.Now(), data)
+}
+
+// webhookTenantID returns the tenant the request is made for
+func webhookTenantID(c *fiber.Ctx) (uint, error) {
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return 0, errors.New("Tenant ID is required")
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return 0, errors.New("Invalid tenant ID format")
+	}
+	return uint(tenantID), nil
+}
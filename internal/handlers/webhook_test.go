@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/handlers"
+	"github.com/Masozee/kontena/api/internal/middleware"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/webhook"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTicketAndLeadWebhooks(t *testing.T) {
+	setupTicketCommentDB(t)
+	database.DB.AutoMigrate(&models.Category{}, &models.User{}, &models.Lead{})
+	webhook.AllowPrivateNetworks = true // the receiver listens on loopback
+	t.Cleanup(func() { webhook.AllowPrivateNetworks = false })
+
+	app := fiber.New()
+	app.Use(middleware.TenantMiddleware())
+	app.Use(middleware.ActorMiddleware())
+	app.Post("/tickets", handlers.CreateTicket)
+	app.Patch("/tickets/:id", handlers.UpdateTicket)
+	app.Post("/leads", handlers.CreateLead)
+	app.Post("/webhooks", handlers.CreateWebhookEndpoint)
+	app.Get("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+
+	var events []string
+	var secret string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+		if !webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), timestamp, body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		events = append(events, r.Header.Get(webhook.EventHeader))
+	}))
+	defer server.Close()
+
+	status, body := doStaffRequest(t, app, "1", "POST", "/webhooks",
+		`{"url":"`+server.URL+`","events":["ticket.resolved","lead.created"]}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	var endpoint struct {
+		Secret string `json:"secret"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &endpoint))
+	secret = endpoint.Secret
+
+	// Tickets are only announced when they are resolved
+	status, body = doStaffRequest(t, app, "1", "POST", "/tickets", `{"title":"VPN down","description":"Office","priority":"high"}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+	doStaffRequest(t, app, "1", "PATCH", "/tickets/2", `{"status":"in_progress"}`)
+	status, body = doStaffRequest(t, app, "1", "PATCH", "/tickets/2", `{"status":"resolved"}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	doStaffRequest(t, app, "1", "PATCH", "/tickets/2", `{"notes":"Router replaced"}`)
+
+	status, body = doStaffRequest(t, app, "1", "POST", "/leads", `{"name":"Globex","email":"sales@globex.example.com"}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+
+	assert.NoError(t, handlers.DeliverWebhooks(time.Now()))
+	assert.Equal(t, []string{models.WebhookEventTicketResolved, models.WebhookEventLeadCreated}, events)
+
+	status, body = doStaffRequest(t, app, "1", "GET", "/webhooks/1/deliveries?status=succeeded", "")
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+	var deliveries []models.WebhookDelivery
+	assert.NoError(t, json.Unmarshal(body, &deliveries))
+	assert.Len(t, deliveries, 2)
+}
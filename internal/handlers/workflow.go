@@ -0,0 +1,546 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Masozee/kontena/api/internal/database"
+	"github.com/Masozee/kontena/api/internal/models"
+	"github.com/Masozee/kontena/api/workflow"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// availableTransition is a transition out of a record's current status
+type availableTransition struct {
+	workflow.Transition
+	Permitted bool `json:"permitted"` // whether the acting staff member's role passes the guard
+}
+
+// transitionsResponse lists the transitions available for a record
+type transitionsResponse struct {
+	WorkflowID    *uint                 `json:"workflow_id"` // null when the built-in workflow applies
+	CurrentStatus string                `json:"current_status"`
+	Transitions   []availableTransition `json:"transitions"`
+}
+
+// GetWorkflows returns all workflows for a tenant
+// @Summary Get all workflows
+// @Description Get all workflows for the current tenant
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Workflow
+// @Router /workflows [get]
+func GetWorkflows(c *fiber.Ctx) error {
+	// Get tenant ID from context
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var workflows []models.Workflow
+	result := database.DB.Where("tenant_id = ?", tenantID).
+		Preload("States", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Preload("Transitions").
+		Find(&workflows)
+
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve workflows",
+		})
+	}
+
+	return c.JSON(workflows)
+}
+
+// GetWorkflow returns a specific workflow
+// @Summary Get a workflow
+// @Description Get a workflow by ID for the current tenant
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param id path int true "Workflow ID"
+// @Success 200 {object} models.Workflow
+// @Failure 404 {object} map[string]string
+// @Router /workflows/{id} [get]
+func GetWorkflow(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var wf models.Workflow
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).
+		Preload("States", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Preload("Transitions").
+		First(&wf)
+
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workflow not found",
+		})
+	}
+
+	return c.JSON(wf)
+}
+
+// CreateWorkflow creates a new workflow
+// @Summary Create a workflow
+// @Description Create a workflow with its states and transitions for the current tenant
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param workflow body models.Workflow true "Workflow information"
+// @Success 201 {object} models.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /workflows [post]
+func CreateWorkflow(c *fiber.Ctx) error {
+	wf := new(models.Workflow)
+	if err := c.BodyParser(wf); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	wf.ID = 0
+	wf.TenantID = uint(tenantID)
+
+	if msg := validateWorkflow(wf); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	// Only one workflow per entity type
+	var count int64
+	database.DB.Model(&models.Workflow{}).Where("tenant_id = ? AND entity_type = ?", tenantID, wf.EntityType).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A workflow for this entity type already exists",
+		})
+	}
+
+	for i := range wf.States {
+		wf.States[i].ID = 0
+		wf.States[i].Position = i
+	}
+	for i := range wf.Transitions {
+		wf.Transitions[i].ID = 0
+	}
+
+	result := database.DB.Create(&wf)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create workflow",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(wf)
+}
+
+// UpdateWorkflow replaces the states and transitions of a workflow
+// @Summary Update a workflow
+// @Description Replace the name, states and transitions of a workflow. A state cannot be removed while tickets are in it.
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param id path int true "Workflow ID"
+// @Param workflow body models.Workflow true "Workflow information"
+// @Success 200 {object} models.Workflow
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /workflows/{id} [put]
+func UpdateWorkflow(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var wf models.Workflow
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&wf)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workflow not found",
+		})
+	}
+
+	updateData := new(models.Workflow)
+	if err := c.BodyParser(updateData); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Entity type cannot be changed
+	updateData.EntityType = wf.EntityType
+	if updateData.Name == "" {
+		updateData.Name = wf.Name
+	}
+
+	if msg := validateWorkflow(updateData); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	var inUse []string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Tickets in a removed state could not move on, so their states are kept
+		var err error
+		if inUse, err = removedStatesInUse(tx, &wf, updateData.States); err != nil || len(inUse) > 0 {
+			return err
+		}
+
+		if err := tx.Where("workflow_id = ?", wf.ID).Delete(&models.WorkflowState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workflow_id = ?", wf.ID).Delete(&models.WorkflowTransition{}).Error; err != nil {
+			return err
+		}
+
+		wf.Name = updateData.Name
+		if err := tx.Save(&wf).Error; err != nil {
+			return err
+		}
+
+		for i := range updateData.States {
+			state := updateData.States[i]
+			state.ID = 0
+			state.WorkflowID = wf.ID
+			state.Position = i
+			if err := tx.Create(&state).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := range updateData.Transitions {
+			transition := updateData.Transitions[i]
+			transition.ID = 0
+			transition.WorkflowID = wf.ID
+			if err := tx.Create(&transition).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update workflow",
+		})
+	}
+	if len(inUse) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  "States still in use cannot be removed: " + strings.Join(inUse, ", "),
+			"states": inUse,
+		})
+	}
+
+	database.DB.Where("id = ?", wf.ID).
+		Preload("States", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC")
+		}).
+		Preload("Transitions").
+		First(&wf)
+
+	return c.JSON(wf)
+}
+
+// DeleteWorkflow deletes a workflow so the built-in workflow applies again
+// @Summary Delete a workflow
+// @Description Delete a workflow by ID for the current tenant
+// @Tags workflows
+// @Accept json
+// @Produce json
+// @Param id path int true "Workflow ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /workflows/{id} [delete]
+func DeleteWorkflow(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var wf models.Workflow
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&wf)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workflow not found",
+		})
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workflow_id = ?", wf.ID).Delete(&models.WorkflowState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workflow_id = ?", wf.ID).Delete(&models.WorkflowTransition{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&wf).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete workflow",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Workflow deleted successfully",
+	})
+}
+
+// GetTicketTransitions lists the status transitions available for a ticket
+// @Summary Get available ticket transitions
+// @Description List the transitions out of the ticket's current status. Pass X-Staff-ID to see which ones the staff member may perform.
+// @Tags tickets
+// @Accept json
+// @Produce json
+// @Param X-Staff-ID header string false "Acting staff ID"
+// @Param id path int true "Ticket ID"
+// @Success 200 {object} transitionsResponse
+// @Failure 404 {object} map[string]string
+// @Router /tickets/{id}/transitions [get]
+func GetTicketTransitions(c *fiber.Ctx) error {
+	id := c.Params("id")
+	tenantIDStr := c.Locals("tenantID")
+	if tenantIDStr == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Tenant ID is required",
+		})
+	}
+
+	tenantID, err := strconv.Atoi(tenantIDStr.(string))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid tenant ID format",
+		})
+	}
+
+	var ticket models.Ticket
+	result := database.DB.Where("id = ? AND tenant_id = ?", id, tenantID).First(&ticket)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Ticket not found",
+		})
+	}
+
+	def, workflowID, err := resolveTicketWorkflow(uint(tenantID))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load workflow: " + err.Error(),
+		})
+	}
+
+	role, err := actorRole(c, uint(tenantID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := transitionsResponse{
+		WorkflowID:    workflowID,
+		CurrentStatus: string(ticket.Status),
+		Transitions:   []availableTransition{},
+	}
+	for _, t := range def.Available(string(ticket.Status)) {
+		response.Transitions = append(response.Transitions, availableTransition{
+			Transition: t,
+			Permitted:  t.Permits(role),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// resolveTicketWorkflow returns the tenant's ticket workflow, or the built-in one when
+// the tenant has not defined any. The returned ID is nil for the built-in workflow; the
+// error is set only when the workflows could not be read.
+func resolveTicketWorkflow(tenantID uint) (workflow.Definition, *uint, error) {
+	var wf models.Workflow
+	err := database.DB.Where("tenant_id = ? AND entity_type = ?", tenantID, models.WorkflowEntityTicket).
+		Preload("States").
+		Preload("Transitions").
+		First(&wf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultTicketWorkflow(), nil, nil
+	}
+	if err != nil {
+		return workflow.Definition{}, nil, err
+	}
+
+	return wf.Definition(), &wf.ID, nil
+}
+
+// actorRole returns the role of the staff member identified by the X-Staff-ID header,
+// or an empty role when the request does not identify anyone
+func actorRole(c *fiber.Ctx, tenantID uint) (string, error) {
+	staffIDStr := c.Locals("staffID")
+	if staffIDStr == nil {
+		return "", nil
+	}
+
+	var staff models.Staff
+	result := database.DB.Where("id = ? AND tenant_id = ?", staffIDStr.(string), tenantID).First(&staff)
+	if result.Error != nil {
+		return "", errors.New("Acting staff member not found")
+	}
+
+	return string(staff.Role), nil
+}
+
+// workflowError responds with the status code that matches a workflow validation error
+func workflowError(c *fiber.Ctx, err error) error {
+	var missing *workflow.MissingFieldsError
+	switch {
+	case errors.Is(err, workflow.ErrRoleNotPermitted):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.As(err, &missing):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":          err.Error(),
+			"missing_fields": missing.Fields,
+		})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+}
+
+// removedStatesInUse returns the states of a workflow that an update leaves out while
+// tickets are still in them
+func removedStatesInUse(db *gorm.DB, wf *models.Workflow, updated []models.WorkflowState) ([]string, error) {
+	var current []models.WorkflowState
+	if err := db.Where("workflow_id = ?", wf.ID).Find(&current).Error; err != nil {
+		return nil, err
+	}
+	kept := make(map[string]bool, len(updated))
+	for _, state := range updated {
+		kept[state.Name] = true
+	}
+	var removed []string
+	for _, state := range current {
+		if !kept[state.Name] {
+			removed = append(removed, state.Name)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	var inUse []string
+	err := db.Model(&models.Ticket{}).
+		Where("tenant_id = ? AND status IN ?", wf.TenantID, removed).
+		Distinct("status").Order("status").Pluck("status", &inUse).Error
+	return inUse, err
+}
+
+// validateWorkflow checks that a workflow is internally consistent and returns a message if not
+func validateWorkflow(wf *models.Workflow) string {
+	if wf.EntityType != models.WorkflowEntityTicket {
+		return "Entity type must be ticket"
+	}
+
+	if wf.Name == "" {
+		return "Workflow name is required"
+	}
+
+	if len(wf.States) == 0 {
+		return "Workflow must have at least one state"
+	}
+
+	states := make(map[string]bool)
+	initial := 0
+	for _, state := range wf.States {
+		if state.Name == "" || state.Name == workflow.AnyState {
+			return "Invalid workflow state name: " + state.Name
+		}
+		if states[state.Name] {
+			return "Duplicate workflow state: " + state.Name
+		}
+		states[state.Name] = true
+		if state.Initial {
+			initial++
+		}
+	}
+	if initial > 1 {
+		return "Workflow can only have one initial state"
+	}
+
+	for _, t := range wf.Transitions {
+		if t.Name == "" {
+			return "Workflow transition name is required"
+		}
+		if t.FromState != workflow.AnyState && !states[t.FromState] {
+			return "Transition " + t.Name + " starts from unknown state " + t.FromState
+		}
+		if !states[t.ToState] {
+			return "Transition " + t.Name + " leads to unknown state " + t.ToState
+		}
+	}
+
+	return ""
+}
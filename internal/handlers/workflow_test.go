@@ -0,0 +1,30 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"github.com/Masozee/kontena/api/internal/handlers"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateTicketWorkflowKeepsStatesInUse(t *testing.T) {
+	setupTicketCommentDB(t)
+	app := setupTicketCommentApp()
+	app.Post("/workflows", handlers.CreateWorkflow)
+	app.Put("/workflows/:id", handlers.UpdateWorkflow)
+
+	status, body := doTicketCommentRequest(t, app, "POST", "/workflows",
+		`{"entity_type":"ticket","name":"Support","states":[{"name":"open","initial":true},{"name":"waiting"},{"name":"closed","final":true}]}`)
+	assert.Equal(t, fiber.StatusCreated, status, string(body))
+
+	// The seeded ticket is open
+	status, body = doTicketCommentRequest(t, app, "PUT", "/workflows/1",
+		`{"states":[{"name":"new","initial":true},{"name":"closed","final":true}]}`)
+	assert.Equal(t, fiber.StatusConflict, status, string(body))
+	assert.Contains(t, string(body), `"states":["open"]`)
+
+	status, body = doTicketCommentRequest(t, app, "PUT", "/workflows/1",
+		`{"states":[{"name":"open","initial":true},{"name":"closed","final":true}]}`)
+	assert.Equal(t, fiber.StatusOK, status, string(body))
+}
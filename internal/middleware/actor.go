@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ActorMiddleware extracts the ID of the staff member making the request from the X-Staff-ID header
+func ActorMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		staffID := c.Get("X-Staff-ID")
+
+		// Store staff_id in context locals for handlers to use
+		if staffID != "" {
+			// Validate that it's a valid number
+			_, err := strconv.Atoi(staffID)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid staff ID format",
+				})
+			}
+			c.Locals("staffID", staffID)
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TicketComment represents a threaded comment on a support ticket
+type TicketComment struct {
+	ID        uint                    `json:"id" gorm:"primaryKey"`
+	TenantID  uint                    `json:"tenant_id" gorm:"not null;index"`
+	Tenant    Tenant                  `json:"-" gorm:"foreignKey:TenantID"`
+	TicketID  uint                    `json:"ticket_id" gorm:"not null;index"`
+	Ticket    Ticket                  `json:"-" gorm:"foreignKey:TicketID"`
+	ParentID  *uint                   `json:"parent_id" gorm:"index"`
+	AuthorID  uint                    `json:"author_id" gorm:"not null;index"`
+	Author    Staff                   `json:"-" gorm:"foreignKey:AuthorID"`
+	Body      string                  `json:"body" gorm:"type:text;not null"`
+	Internal  bool                    `json:"internal" gorm:"not null;default:false"` // Internal comments are hidden from customer-facing views
+	EditedAt  *time.Time              `json:"edited_at"`
+	Revisions []TicketCommentRevision `json:"revisions,omitempty" gorm:"foreignKey:CommentID"`
+	CreatedAt time.Time               `json:"created_at"`
+	UpdatedAt time.Time               `json:"updated_at"`
+	DeletedAt gorm.DeletedAt          `json:"-" gorm:"index"` // Hide from JSON and Swagger
+}
+
+// TicketCommentRevision keeps a previous body of a ticket comment each time it is edited
+type TicketCommentRevision struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CommentID  uint      `json:"comment_id" gorm:"not null;index"`
+	Body       string    `json:"body" gorm:"type:text;not null"`
+	EditedByID uint      `json:"edited_by_id" gorm:"not null;index"`
+	EditedBy   Staff     `json:"-" gorm:"foreignKey:EditedByID"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// mentionPattern matches @handle tokens that are not part of an email address.
+// A handle is either a full email address or the local part of one.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([A-Za-z0-9._%+-]+(?:@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)?)`)
+
+// ParseMentions returns the distinct, lower-cased handles mentioned in a comment body
+func ParseMentions(body string) []string {
+	var handles []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(strings.TrimRight(match[1], "."))
+		if handle == "" || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// NotificationType represents the event that produced a notification
+type NotificationType string
+
+const (
+	NotificationTypeMention       NotificationType = "mention"
+	NotificationTypeTicketUpdated NotificationType = "ticket_updated"
+	NotificationTypeCommentAdded  NotificationType = "comment_added"
+)
+
+// Notification represents a message delivered to a staff member about ticket activity
+type Notification struct {
+	ID        uint             `json:"id" gorm:"primaryKey"`
+	TenantID  uint             `json:"tenant_id" gorm:"not null;index"`
+	Tenant    Tenant           `json:"-" gorm:"foreignKey:TenantID"`
+	StaffID   uint             `json:"staff_id" gorm:"not null;index"`
+	Staff     Staff            `json:"-" gorm:"foreignKey:StaffID"`
+	Type      NotificationType `json:"type" gorm:"size:50;not null"`
+	TicketID  *uint            `json:"ticket_id" gorm:"index"`
+	CommentID *uint            `json:"comment_id" gorm:"index"`
+	ActorID   *uint            `json:"actor_id" gorm:"index"`
+	Message   string           `json:"message" gorm:"type:text"`
+	ReadAt    *time.Time       `json:"read_at"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// TableName keeps ticket notifications apart from the project app's notifications, which
+// live in the same database with other columns
+func (Notification) TableName() string {
+	return "ticket_notifications"
+}
@@ -0,0 +1,26 @@
+package models
+
+import "github.com/Masozee/kontena/api/search"
+
+// SearchSources are the records found by full-text search
+var SearchSources = []search.Source{
+	{
+		Type:         "ticket",
+		Table:        "tickets",
+		Title:        "title",
+		Fields:       []search.Field{{Column: "title", Weight: search.WeightA}, {Column: "description", Weight: search.WeightB}},
+		TenantColumn: "tickets.tenant_id",
+	},
+	{
+		Type:  "lead",
+		Table: "leads",
+		Title: "name",
+		Fields: []search.Field{
+			{Column: "name", Weight: search.WeightA},
+			{Column: "contact", Weight: search.WeightB},
+			{Column: "email", Weight: search.WeightB},
+			{Column: "notes", Weight: search.WeightC},
+		},
+		TenantColumn: "leads.tenant_id",
+	},
+}
@@ -26,6 +26,13 @@ const (
 	TicketStatusClosed     TicketStatus = "closed"
 )
 
+// Ticket changes streamed to the ticket queue
+const (
+	TicketEventCreated = "ticket.created"
+	TicketEventUpdated = "ticket.updated"
+	TicketEventDeleted = "ticket.deleted"
+)
+
 // Ticket represents a support ticket in the system
 type Ticket struct {
 	ID             uint           `json:"id" gorm:"primaryKey"`
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// TicketWatcher represents a staff member who is notified about activity on a ticket
+type TicketWatcher struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TenantID  uint      `json:"tenant_id" gorm:"not null;index"`
+	Tenant    Tenant    `json:"-" gorm:"foreignKey:TenantID"`
+	TicketID  uint      `json:"ticket_id" gorm:"not null;uniqueIndex:idx_ticket_watcher"`
+	Ticket    Ticket    `json:"-" gorm:"foreignKey:TicketID"`
+	StaffID   uint      `json:"staff_id" gorm:"not null;uniqueIndex:idx_ticket_watcher"`
+	Staff     Staff     `json:"staff,omitempty" gorm:"foreignKey:StaffID"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"github.com/Masozee/kontena/api/webhook"
+)
+
+// Webhook event types
+const (
+	WebhookEventTicketCreated  = "ticket.created"
+	WebhookEventTicketResolved = "ticket.resolved"
+	WebhookEventLeadCreated    = "lead.created"
+)
+
+// WebhookEvents lists the events endpoints can subscribe to
+var WebhookEvents = []string{
+	WebhookEventTicketCreated,
+	WebhookEventTicketResolved,
+	WebhookEventLeadCreated,
+}
+
+// Webhook endpoints and their deliveries are kept by the webhook package, which both apps share
+type (
+	WebhookEndpoint       = webhook.Endpoint
+	WebhookDelivery       = webhook.Delivery
+	WebhookDeliveryStatus = webhook.DeliveryStatus
+)
+
+const (
+	WebhookDeliveryPending   = webhook.DeliveryPending
+	WebhookDeliverySucceeded = webhook.DeliverySucceeded
+	WebhookDeliveryFailed    = webhook.DeliveryFailed
+)
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Masozee/kontena/api/workflow"
+	"gorm.io/gorm"
+)
+
+// WorkflowEntityType represents the kind of record a workflow governs
+type WorkflowEntityType string
+
+const (
+	WorkflowEntityTicket WorkflowEntityType = "ticket"
+)
+
+// Workflow represents a tenant-defined state machine for the status of a kind of record
+type Workflow struct {
+	ID          uint                 `json:"id" gorm:"primaryKey"`
+	TenantID    uint                 `json:"tenant_id" gorm:"not null;index"`
+	Tenant      Tenant               `json:"-" gorm:"foreignKey:TenantID"`
+	EntityType  WorkflowEntityType   `json:"entity_type" gorm:"size:50;not null;index"`
+	Name        string               `json:"name" gorm:"size:100;not null"`
+	States      []WorkflowState      `json:"states" gorm:"foreignKey:WorkflowID"`
+	Transitions []WorkflowTransition `json:"transitions" gorm:"foreignKey:WorkflowID"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt       `json:"-" gorm:"index"` // Hide from JSON and Swagger
+}
+
+// TableName keeps ticket workflows apart from the project app's workflows, which live in
+// the same database
+func (Workflow) TableName() string {
+	return "ticket_workflows"
+}
+
+// WorkflowState represents one status in a workflow
+type WorkflowState struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	WorkflowID uint   `json:"workflow_id" gorm:"not null;index"`
+	Name       string `json:"name" gorm:"size:50;not null"` // the status value stored on records
+	Label      string `json:"label" gorm:"size:100"`
+	Initial    bool   `json:"initial" gorm:"default:false"`
+	Final      bool   `json:"final" gorm:"default:false"`
+	Position   int    `json:"position" gorm:"default:0"`
+}
+
+// TableName keeps the states of ticket workflows apart from the project app's
+func (WorkflowState) TableName() string {
+	return "ticket_workflow_states"
+}
+
+// WorkflowTransition represents an allowed move between two states of a workflow
+type WorkflowTransition struct {
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	WorkflowID     uint   `json:"workflow_id" gorm:"not null;index"`
+	Name           string `json:"name" gorm:"size:100;not null"`
+	FromState      string `json:"from_state" gorm:"size:50;not null"` // "*" allows the transition from any state
+	ToState        string `json:"to_state" gorm:"size:50;not null"`
+	RequiredFields string `json:"required_fields" gorm:"size:255"` // comma separated JSON field names
+	GuardRoles     string `json:"guard_roles" gorm:"size:255"`     // comma separated staff roles
+}
+
+// TableName keeps the transitions of ticket workflows apart from the project app's
+func (WorkflowTransition) TableName() string {
+	return "ticket_workflow_transitions"
+}
+
+// Definition converts the stored workflow into a definition the workflow engine can validate against
+func (w *Workflow) Definition() workflow.Definition {
+	var def workflow.Definition
+	for _, state := range w.States {
+		def.States = append(def.States, workflow.State{
+			Name:    state.Name,
+			Initial: state.Initial,
+			Final:   state.Final,
+		})
+	}
+	for _, t := range w.Transitions {
+		def.Transitions = append(def.Transitions, workflow.Transition{
+			Name:           t.Name,
+			From:           t.FromState,
+			To:             t.ToState,
+			RequiredFields: workflow.SplitList(t.RequiredFields),
+			GuardRoles:     workflow.SplitList(t.GuardRoles),
+		})
+	}
+	return def
+}
+
+// DefaultTicketWorkflow returns the built-in ticket workflow used when a tenant has not defined one.
+// Tickets may move freely between their statuses.
+func DefaultTicketWorkflow() workflow.Definition {
+	return workflow.Permissive(
+		string(TicketStatusOpen),
+		string(TicketStatusInProgress),
+		string(TicketStatusResolved),
+		string(TicketStatusClosed),
+	)
+}
@@ -0,0 +1,12 @@
+// Package like builds SQL LIKE patterns that match text literally
+package like
+
+import "strings"
+
+// escaper escapes the wildcards of LIKE patterns, with \ as the escape character
+var escaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Escape makes s match itself in a LIKE pattern ending in ESCAPE '\'
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,14 @@
+package like
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, "alice", Escape("alice"))
+	assert.Equal(t, `a\_b`, Escape("a_b"))
+	assert.Equal(t, `100\%`, Escape("100%"))
+	assert.Equal(t, `c:\\tmp`, Escape(`c:\tmp`))
+}
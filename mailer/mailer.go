@@ -0,0 +1,168 @@
+// Package mailer sends plain-text email through a pluggable Sender: SMTP in production, and
+// a directory of .eml files or an in-memory outbox in development and tests.
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBounced marks a permanent delivery failure: the address does not accept mail and
+// retrying will not help
+var ErrBounced = errors.New("address rejected")
+
+// Message is a plain-text email to one recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages. An error wrapping ErrBounced means the address is dead;
+// any other error is temporary and the message may be retried.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPSender sends messages through an SMTP server
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send sends the message. Permanent (5xx) replies from the server are reported as bounces.
+func (s *SMTPSender) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	err := smtp.SendMail(s.Host+":"+s.Port, auth, s.From, []string{msg.To}, format(s.From, msg, time.Now()))
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return fmt.Errorf("%w: %v", ErrBounced, err)
+	}
+	return err
+}
+
+// FileSender writes each message as an .eml file into a directory, for development
+type FileSender struct {
+	Dir  string
+	From string
+
+	mu sync.Mutex
+	n  int
+}
+
+// Send writes the message to a new file in the directory
+func (s *FileSender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.n++
+	name := fmt.Sprintf("%s-%04d.eml", now.Format("20060102T150405.000000000"), s.n)
+	return os.WriteFile(filepath.Join(s.Dir, name), format(s.From, msg, now), 0o644)
+}
+
+// Outbox keeps sent messages in memory, for tests. Addresses in Reject bounce.
+type Outbox struct {
+	Reject map[string]bool
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+// Send records the message, or reports a bounce for a rejected address
+func (o *Outbox) Send(msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.Reject[msg.To] {
+		return fmt.Errorf("%w: %s", ErrBounced, msg.To)
+	}
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Message(nil), o.sent...)
+}
+
+// FromEnv returns an SMTP sender when SMTP_HOST is set, and otherwise a file sender writing
+// to MAIL_OUTBOX_DIR (./outbox by default)
+func FromEnv() Sender {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@kontena.local"
+	}
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			dir = "outbox"
+		}
+		return &FileSender{Dir: dir, From: from}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	return &SMTPSender{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
+
+// ValidAddress reports whether s is a single bare email address
+func ValidAddress(s string) bool {
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Address == s
+}
+
+// format renders the message in RFC 5322 form
+func format(from string, msg Message, date time.Time) []byte {
+	var b bytes.Buffer
+	header := func(name, value string) {
+		// Header values must stay on one line
+		value = strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSenderWritesMessages(t *testing.T) {
+	dir := t.TempDir()
+	sender := &FileSender{Dir: filepath.Join(dir, "outbox"), From: "no-reply@example.com"}
+
+	assert.NoError(t, sender.Send(Message{To: "alice@example.com", Subject: "Héllo", Body: "Line one\nLine two\n"}))
+	assert.NoError(t, sender.Send(Message{To: "bob@example.com", Subject: "Hi", Body: "Body"}))
+
+	files, err := os.ReadDir(sender.Dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	data, err := os.ReadFile(filepath.Join(sender.Dir, files[0].Name()))
+	assert.NoError(t, err)
+	text := string(data)
+	assert.Contains(t, text, "From: no-reply@example.com\r\n")
+	assert.Contains(t, text, "To: alice@example.com\r\n")
+	assert.Contains(t, text, "Subject: =?UTF-8?q?H=C3=A9llo?=\r\n")
+	assert.True(t, strings.HasSuffix(text, "\r\n\r\nLine one\r\nLine two\r\n"))
+}
+
+func TestOutboxRejectsBouncedAddresses(t *testing.T) {
+	outbox := &Outbox{Reject: map[string]bool{"gone@example.com": true}}
+
+	err := outbox.Send(Message{To: "gone@example.com"})
+	assert.True(t, errors.Is(err, ErrBounced))
+	assert.NoError(t, outbox.Send(Message{To: "alice@example.com", Subject: "Hi"}))
+	assert.Equal(t, []Message{{To: "alice@example.com", Subject: "Hi"}}, outbox.Messages())
+}
+
+func TestFormatKeepsHeadersOnOneLine(t *testing.T) {
+	text := string(format("a@example.com", Message{To: "b@example.com\r\nBcc: c@example.com", Subject: "Hi"}, time.Unix(0, 0).UTC()))
+	assert.NotContains(t, text, "\r\nBcc:")
+}
+
+func TestRenderTemplates(t *testing.T) {
+	msg, err := Defaults[EventTaskAssigned].Render("bob@example.com", Data{
+		Recipient: "Bob",
+		Tenant:    "Acme",
+		Actor:     "Alice",
+		Message:   "You were assigned to task Launch",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "bob@example.com", msg.To)
+	assert.Equal(t, "[Acme] You were assigned to task Launch", msg.Subject)
+	assert.Equal(t, "Hello Bob,\n\nYou were assigned to task Launch, assigned by Alice.\n", msg.Body)
+
+	msg, err = Defaults[EventDigest].Render("bob@example.com", Data{
+		Recipient: "Bob",
+		Tenant:    "Acme",
+		Date:      time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		Items:     []Item{{Message: "One"}, {Message: "Two", Actor: "Alice"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "[Acme] Your daily summary: 2 notifications", msg.Subject)
+	assert.Equal(t, "Hello Bob,\n\nHere is what happened since 4 March 2024:\n\n- One\n- Two (Alice)\n", msg.Body)
+
+	for event, template := range Defaults {
+		assert.NoError(t, template.Validate(), event)
+	}
+	assert.Error(t, Template{Subject: "{{.Message", Body: ""}.Validate())
+	_, err = Template{Subject: "{{.Nope}}"}.Render("bob@example.com", Data{})
+	assert.Error(t, err, "unknown fields fail to render")
+}
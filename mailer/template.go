@@ -0,0 +1,125 @@
+package mailer
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template events. Notifications without a template of their own use Notification.
+const (
+	EventTaskAssigned        = "task_assigned"
+	EventMention             = "mention"
+	EventProcurementApproved = "procurement_approved"
+	EventMaintenanceDue      = "maintenance_due"
+	EventTicketUpdated       = "ticket_updated"
+	EventNotification        = "notification"
+	EventDigest              = "digest"
+)
+
+// Events lists the events that have a template, in the order they are presented
+var Events = []string{
+	EventTaskAssigned,
+	EventMention,
+	EventProcurementApproved,
+	EventMaintenanceDue,
+	EventTicketUpdated,
+	EventNotification,
+	EventDigest,
+}
+
+// Template is a subject and body in text/template syntax
+type Template struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Data is what templates are rendered with. Digest templates use Items, and Date is when the
+// earliest of them happened; the others use the fields of the single notification.
+type Data struct {
+	Recipient  string
+	Tenant     string
+	Actor      string
+	Message    string
+	EntityType string
+	EntityID   uint
+	Date       time.Time
+	Items      []Item
+}
+
+// Item is one notification in a digest
+type Item struct {
+	Type    string
+	Message string
+	Actor   string
+	Date    time.Time
+}
+
+// Defaults are the templates used by tenants that have not overridden them
+var Defaults = map[string]Template{
+	EventTaskAssigned: {
+		Subject: "[{{.Tenant}}] {{.Message}}",
+		Body:    "Hello {{.Recipient}},\n\n{{.Message}}{{if .Actor}}, assigned by {{.Actor}}{{end}}.\n",
+	},
+	EventMention: {
+		Subject: "[{{.Tenant}}] {{if .Actor}}{{.Actor}} mentioned you{{else}}You were mentioned{{end}}",
+		Body:    "Hello {{.Recipient}},\n\n{{.Message}}.\n",
+	},
+	EventProcurementApproved: {
+		Subject: "[{{.Tenant}}] Procurement request #{{.EntityID}} approved",
+		Body:    "Hello {{.Recipient}},\n\n{{.Message}}{{if .Actor}} by {{.Actor}}{{end}}.\n",
+	},
+	EventMaintenanceDue: {
+		Subject: "[{{.Tenant}}] Maintenance due",
+		Body:    "Hello {{.Recipient}},\n\n{{.Message}}.\n",
+	},
+	EventTicketUpdated: {
+		Subject: "Ticket #{{.EntityID}} updated",
+		Body:    "Hello {{.Recipient}},\n\n{{.Message}}{{if .Actor}}, by {{.Actor}}{{end}}.\n",
+	},
+	EventNotification: {
+		Subject: "[{{.Tenant}}] {{.Message}}",
+		Body:    "Hello {{.Recipient}},\n\n{{.Message}}{{if .Actor}} ({{.Actor}}){{end}}.\n",
+	},
+	EventDigest: {
+		Subject: "[{{.Tenant}}] Your daily summary: {{len .Items}} notification{{if ne (len .Items) 1}}s{{end}}",
+		Body: "Hello {{.Recipient}},\n\nHere is what happened since {{.Date.Format \"2 January 2006\"}}:\n\n" +
+			"{{range .Items}}- {{.Message}}{{if .Actor}} ({{.Actor}}){{end}}\n{{end}}",
+	},
+}
+
+// Validate reports whether the subject and body parse
+func (t Template) Validate() error {
+	if _, err := template.New("subject").Parse(t.Subject); err != nil {
+		return err
+	}
+	_, err := template.New("body").Parse(t.Body)
+	return err
+}
+
+// Render renders the template into a message to the given address
+func (t Template) Render(to string, data Data) (Message, error) {
+	subject, err := execute(t.Subject, data)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := execute(t.Body, data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: strings.TrimSpace(subject), Body: body}, nil
+}
+
+func execute(text string, data Data) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -12,7 +13,10 @@ import (
 
 	"github.com/Masozee/kontena/api/database"
 	"github.com/Masozee/kontena/api/handlers"
+	"github.com/Masozee/kontena/api/mailer"
 	"github.com/Masozee/kontena/api/middleware"
+	"github.com/Masozee/kontena/api/scheduler"
+	"github.com/Masozee/kontena/api/webhook"
 )
 
 // @title Project Management API
@@ -35,6 +39,26 @@ func main() {
 	// Initialize database
 	database.InitDB()
 
+	// Email goes out through SMTP when configured, and into an outbox directory otherwise
+	handlers.Mailer = mailer.FromEnv()
+
+	// Webhooks only go to public addresses unless internal ones are explicitly allowed
+	webhook.AllowPrivateNetworks = os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+
+	// Start background jobs
+	scheduler.Start(time.Minute,
+		scheduler.Job{Name: "recurring tasks", Run: handlers.GenerateRecurringTasks},
+		scheduler.Job{Name: "computed KPIs", Run: handlers.RecomputeKPIs},
+		scheduler.Job{Name: "maintenance reminders", Run: handlers.NotifyMaintenanceDue},
+		scheduler.Job{Name: "notification emails", Run: handlers.SendNotificationEmails},
+		scheduler.Job{Name: "webhooks", Run: handlers.DeliverWebhooks},
+	)
+
+	// Domain events are dispatched from the outbox soon after they are committed
+	scheduler.Start(time.Second,
+		scheduler.Job{Name: "domain events", Run: handlers.DispatchEvents},
+	)
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName: "Project Management API",
@@ -58,17 +82,49 @@ func main() {
 	tenants.Put("/:id", handlers.UpdateTenant)
 	tenants.Delete("/:id", handlers.DeleteTenant)
 
+	// Calendar feeds are authenticated by their token instead of a tenant header
+	api.Get("/calendar/:token", handlers.ServeCalendarFeed)
+
 	// Protected routes (with tenant middleware)
 	api.Use(middleware.TenantMiddleware())
+	api.Use(middleware.ActorMiddleware())
 
 	// Project routes
 	projects := api.Group("/projects")
 	projects.Get("/", handlers.GetProjects)
+	projects.Get("/health", handlers.GetProjectsHealth)
 	projects.Get("/:id", handlers.GetProject)
 	projects.Get("/:id/details", handlers.GetProjectWithDetails)
 	projects.Post("/", handlers.CreateProject)
 	projects.Patch("/:id", handlers.UpdateProject)
 	projects.Delete("/:id", handlers.DeleteProject)
+	projects.Get("/:id/financials", handlers.GetProjectFinancials)
+	projects.Get("/:id/health", handlers.GetProjectHealth)
+	projects.Post("/:id/clone", handlers.CloneProject)
+	projects.Post("/:id/template", handlers.CreateProjectTemplate)
+	projects.Get("/:id/calendar-feed", handlers.GetProjectCalendarFeed)
+	projects.Post("/:id/calendar-feed", handlers.CreateProjectCalendarFeed)
+	projects.Delete("/:id/calendar-feed", handlers.DeleteProjectCalendarFeed)
+	projects.Get("/:id/baselines", handlers.GetProjectBaselines)
+	projects.Post("/:id/baselines", handlers.CreateProjectBaseline)
+	projects.Post("/:id/auto-schedule", handlers.AutoScheduleProject)
+
+	// Project baseline routes
+	baselines := api.Group("/baselines")
+	baselines.Get("/:id", handlers.GetProjectBaseline)
+	baselines.Delete("/:id", handlers.DeleteProjectBaseline)
+	baselines.Get("/:id/variance", handlers.GetBaselineVariance)
+
+	// Project template routes
+	projectTemplates := api.Group("/project-templates")
+	projectTemplates.Get("/", handlers.GetProjectTemplates)
+	projectTemplates.Get("/:id", handlers.GetProjectTemplate)
+	projectTemplates.Delete("/:id", handlers.DeleteProjectTemplate)
+	projectTemplates.Post("/:id/projects", handlers.CreateProjectFromTemplate)
+
+	// Project health weight routes
+	api.Get("/health-weights", handlers.GetHealthWeights)
+	api.Put("/health-weights", handlers.UpdateHealthWeights)
 
 	// Person routes
 	people := api.Group("/people")
@@ -77,17 +133,65 @@ func main() {
 	people.Post("/", handlers.CreatePerson)
 	people.Patch("/:id", handlers.UpdatePerson)
 	people.Delete("/:id", handlers.DeletePerson)
+	people.Get("/:id/calendar-feed", handlers.GetPersonCalendarFeed)
+	people.Post("/:id/calendar-feed", handlers.CreatePersonCalendarFeed)
+	people.Delete("/:id/calendar-feed", handlers.DeletePersonCalendarFeed)
+
+	// Workload routes
+	projectAllocations := api.Group("/projects/:project_id/allocations")
+	projectAllocations.Get("/", handlers.GetAllocations)
+	projectAllocations.Post("/", handlers.CreateAllocation)
+
+	allocations := api.Group("/allocations")
+	allocations.Put("/:id", handlers.UpdateAllocation)
+	allocations.Delete("/:id", handlers.DeleteAllocation)
+
+	api.Get("/workload", handlers.GetWorkload)
+
+	// Absence routes
+	holidays := api.Group("/holidays")
+	holidays.Get("/", handlers.GetHolidays)
+	holidays.Post("/", handlers.CreateHoliday)
+	holidays.Put("/:id", handlers.UpdateHoliday)
+	holidays.Delete("/:id", handlers.DeleteHoliday)
+
+	leave := api.Group("/leave")
+	leave.Get("/", handlers.GetLeaves)
+	leave.Get("/:id", handlers.GetLeave)
+	leave.Post("/", handlers.CreateLeave)
+	leave.Put("/:id", handlers.UpdateLeave)
+	leave.Post("/:id/approve", handlers.ApproveLeave)
+	leave.Post("/:id/reject", handlers.RejectLeave)
+	leave.Post("/:id/cancel", handlers.CancelLeave)
+
+	api.Get("/absences", handlers.GetAbsences)
+
+	// Custom field routes
+	customFields := api.Group("/custom-fields")
+	customFields.Get("/", handlers.GetCustomFields)
+	customFields.Post("/", handlers.CreateCustomField)
+	customFields.Put("/:id", handlers.UpdateCustomField)
+	customFields.Delete("/:id", handlers.DeleteCustomField)
+
+	// Estimate routes
+	api.Get("/estimate-settings", handlers.GetEstimateSettings)
+	api.Put("/estimate-settings", handlers.UpdateEstimateSettings)
+	api.Get("/estimate-accuracy", handlers.GetEstimateAccuracy)
 
 	// KPI routes
 	kpis := api.Group("/kpis")
 	kpis.Get("/:id", handlers.GetKPI)
 	kpis.Patch("/:id", handlers.UpdateKPI)
 	kpis.Delete("/:id", handlers.DeleteKPI)
+	kpis.Post("/:id/measurements", handlers.CreateKPIMeasurement)
+	kpis.Get("/:id/history", handlers.GetKPIHistory)
+	kpis.Post("/:id/recompute", handlers.RecomputeKPI)
 
 	// Project KPI routes
 	projectKpis := api.Group("/projects/:project_id/kpis")
 	projectKpis.Get("/", handlers.GetKPIs)
 	projectKpis.Post("/", handlers.CreateKPI)
+	api.Get("/projects/:project_id/metrics", handlers.GetProjectMetrics)
 
 	// Task routes
 	tasks := api.Group("/tasks")
@@ -96,12 +200,94 @@ func main() {
 	tasks.Post("/", handlers.CreateTask)
 	tasks.Patch("/:id", handlers.UpdateTask)
 	tasks.Delete("/:id", handlers.DeleteTask)
+	tasks.Get("/:id/dependencies", handlers.GetTaskDependencies)
+	tasks.Post("/:id/dependencies", handlers.CreateTaskDependency)
+	tasks.Delete("/:id/dependencies/:depends_on_id", handlers.DeleteTaskDependency)
+	tasks.Get("/:id/time-entries", handlers.GetTaskTimeEntries)
+	tasks.Post("/:id/time-entries", handlers.CreateTaskTimeEntry)
+	api.Delete("/time-entries/:id", handlers.DeleteTimeEntry)
 
 	// Project Task routes
 	projectTasks := api.Group("/projects/:project_id/tasks")
 	projectTasks.Get("/", handlers.GetTasks)
 	projectTasks.Post("/", handlers.CreateTask)
 
+	// Board routes
+	projectBoards := api.Group("/projects/:project_id/boards")
+	projectBoards.Get("/", handlers.GetBoards)
+	projectBoards.Post("/", handlers.CreateBoard)
+
+	boards := api.Group("/boards")
+	boards.Get("/:id", handlers.GetBoard)
+	boards.Put("/:id", handlers.UpdateBoard)
+	boards.Delete("/:id", handlers.DeleteBoard)
+	boards.Post("/:id/move", handlers.MoveCard)
+
+	// Comment routes
+	tasks.Get("/:id/comments", handlers.GetTaskComments)
+	tasks.Post("/:id/comments", handlers.CreateTaskComment)
+
+	issues := api.Group("/issues")
+	issues.Get("/:id/comments", handlers.GetIssueComments)
+	issues.Post("/:id/comments", handlers.CreateIssueComment)
+
+	comments := api.Group("/comments")
+	comments.Get("/:id", handlers.GetComment)
+	comments.Patch("/:id", handlers.UpdateComment)
+	comments.Delete("/:id", handlers.DeleteComment)
+
+	// Workflow routes
+	workflows := api.Group("/workflows")
+	workflows.Get("/", handlers.GetWorkflows)
+	workflows.Get("/:id", handlers.GetWorkflow)
+	workflows.Post("/", handlers.CreateWorkflow)
+	workflows.Put("/:id", handlers.UpdateWorkflow)
+	workflows.Delete("/:id", handlers.DeleteWorkflow)
+
+	tasks.Get("/:id/transitions", handlers.GetTaskTransitions)
+	issues.Get("/:id/transitions", handlers.GetIssueTransitions)
+
+	risks := api.Group("/risks")
+	risks.Get("/:id/transitions", handlers.GetRiskTransitions)
+
+	// Task recurrence routes
+	tasks.Get("/:id/recurrence", handlers.GetTaskRecurrence)
+	tasks.Put("/:id/recurrence", handlers.SetTaskRecurrence)
+	tasks.Delete("/:id/recurrence", handlers.DeleteTaskRecurrence)
+
+	// Task assignee suggestion routes
+	tasks.Get("/:id/assignee-suggestions", handlers.GetAssigneeSuggestions)
+
+	// Sprint routes
+	projectSprints := api.Group("/projects/:project_id/sprints")
+	projectSprints.Get("/", handlers.GetSprints)
+	projectSprints.Post("/", handlers.CreateSprint)
+	api.Get("/projects/:project_id/velocity", handlers.GetProjectVelocity)
+
+	sprints := api.Group("/sprints")
+	sprints.Get("/:id", handlers.GetSprint)
+	sprints.Patch("/:id", handlers.UpdateSprint)
+	sprints.Delete("/:id", handlers.DeleteSprint)
+	sprints.Post("/:id/start", handlers.StartSprint)
+	sprints.Post("/:id/close", handlers.CloseSprint)
+	sprints.Post("/:id/tasks", handlers.AddSprintTasks)
+	sprints.Delete("/:id/tasks/:task_id", handlers.RemoveSprintTask)
+	sprints.Get("/:id/burndown", handlers.GetSprintBurndown)
+	sprints.Get("/:id/burnup", handlers.GetSprintBurnup)
+
+	// Cost routes
+	rateCards := api.Group("/rate-cards")
+	rateCards.Get("/", handlers.GetRateCards)
+	rateCards.Get("/:id", handlers.GetRateCard)
+	rateCards.Post("/", handlers.CreateRateCard)
+	rateCards.Put("/:id", handlers.UpdateRateCard)
+	rateCards.Delete("/:id", handlers.DeleteRateCard)
+
+	projectCosts := api.Group("/projects/:project_id/costs")
+	projectCosts.Get("/", handlers.GetProjectCosts)
+	projectCosts.Post("/", handlers.CreateProjectCost)
+	api.Delete("/project-costs/:id", handlers.DeleteProjectCost)
+
 	// Asset Management Routes
 
 	// Asset Category routes
@@ -143,6 +329,7 @@ func main() {
 	procurementRequests.Post("/", handlers.CreateProcurementRequest)
 	procurementRequests.Put("/:id", handlers.UpdateProcurementRequest)
 	procurementRequests.Delete("/:id", handlers.DeleteProcurementRequest)
+	procurementRequests.Get("/:id/transitions", handlers.GetProcurementRequestTransitions)
 
 	// Asset Assignment routes
 	assetAssignments := api.Group("/asset-assignments")
@@ -160,6 +347,98 @@ func main() {
 	maintenanceRecords.Put("/:id", handlers.UpdateMaintenanceRecord)
 	maintenanceRecords.Delete("/:id", handlers.DeleteMaintenanceRecord)
 
+	// Notification routes
+	notifications := api.Group("/notifications")
+	notifications.Get("/", handlers.GetNotifications)
+	notifications.Get("/unread-count", handlers.GetUnreadNotificationCount)
+	notifications.Post("/read-all", handlers.MarkAllNotificationsRead)
+	notifications.Get("/preferences", handlers.GetNotificationPreferences)
+	notifications.Put("/preferences", handlers.UpdateNotificationPreferences)
+	notifications.Post("/:id/read", handlers.MarkNotificationRead)
+
+	// Watcher routes
+	projects.Get("/:id/watchers", handlers.GetProjectWatchers)
+	projects.Post("/:id/watchers", handlers.WatchProject)
+	projects.Delete("/:id/watchers/:person_id", handlers.UnwatchProject)
+	tasks.Get("/:id/watchers", handlers.GetTaskWatchers)
+	tasks.Post("/:id/watchers", handlers.WatchTask)
+	tasks.Delete("/:id/watchers/:person_id", handlers.UnwatchTask)
+	issues.Get("/:id/watchers", handlers.GetIssueWatchers)
+	issues.Post("/:id/watchers", handlers.WatchIssue)
+	issues.Delete("/:id/watchers/:person_id", handlers.UnwatchIssue)
+	procurementRequests.Get("/:id/watchers", handlers.GetProcurementRequestWatchers)
+	procurementRequests.Post("/:id/watchers", handlers.WatchProcurementRequest)
+	procurementRequests.Delete("/:id/watchers/:person_id", handlers.UnwatchProcurementRequest)
+
+	// Label routes
+	labels := api.Group("/labels")
+	labels.Get("/", handlers.GetLabels)
+	labels.Get("/stats", handlers.GetLabelStats)
+	labels.Post("/", handlers.CreateLabel)
+	labels.Put("/:id", handlers.UpdateLabel)
+	labels.Delete("/:id", handlers.DeleteLabel)
+
+	projects.Get("/:id/labels", handlers.GetProjectLabels)
+	projects.Post("/:id/labels", handlers.AddProjectLabels)
+	projects.Put("/:id/labels", handlers.SetProjectLabels)
+	projects.Delete("/:id/labels/:label_id", handlers.RemoveProjectLabel)
+
+	tasks.Get("/:id/labels", handlers.GetTaskLabels)
+	tasks.Post("/:id/labels", handlers.AddTaskLabels)
+	tasks.Put("/:id/labels", handlers.SetTaskLabels)
+	tasks.Delete("/:id/labels/:label_id", handlers.RemoveTaskLabel)
+
+	issues.Get("/:id/labels", handlers.GetIssueLabels)
+	issues.Post("/:id/labels", handlers.AddIssueLabels)
+	issues.Put("/:id/labels", handlers.SetIssueLabels)
+	issues.Delete("/:id/labels/:label_id", handlers.RemoveIssueLabel)
+
+	risks.Get("/:id/labels", handlers.GetRiskLabels)
+	risks.Post("/:id/labels", handlers.AddRiskLabels)
+	risks.Put("/:id/labels", handlers.SetRiskLabels)
+	risks.Delete("/:id/labels/:label_id", handlers.RemoveRiskLabel)
+
+	assets.Get("/:id/labels", handlers.GetAssetLabels)
+	assets.Post("/:id/labels", handlers.AddAssetLabels)
+	assets.Put("/:id/labels", handlers.SetAssetLabels)
+	assets.Delete("/:id/labels/:label_id", handlers.RemoveAssetLabel)
+
+	// Email routes
+	emailTemplates := api.Group("/email-templates")
+	emailTemplates.Get("/", handlers.GetEmailTemplates)
+	emailTemplates.Put("/:event", handlers.UpdateEmailTemplate)
+	emailTemplates.Delete("/:event", handlers.DeleteEmailTemplate)
+
+	api.Get("/emails", handlers.GetEmails)
+
+	emailBounces := api.Group("/email-bounces")
+	emailBounces.Get("/", handlers.GetEmailBounces)
+	emailBounces.Post("/", handlers.CreateEmailBounce)
+	emailBounces.Delete("/:id", handlers.DeleteEmailBounce)
+
+	// Domain event routes
+	api.Get("/events", handlers.GetEvents)
+	api.Post("/events/:id/retry", handlers.RetryEvent)
+	api.Post("/events/:id/discard", handlers.DiscardEvent)
+
+	// Search routes
+	api.Get("/search", handlers.Search)
+
+	// Real-time routes
+	api.Get("/stream", handlers.Stream)
+
+	// Webhook routes
+	webhooks := api.Group("/webhooks")
+	webhooks.Get("/events", handlers.GetWebhookEvents)
+	webhooks.Get("/", handlers.GetWebhookEndpoints)
+	webhooks.Post("/", handlers.CreateWebhookEndpoint)
+	webhooks.Get("/:id", handlers.GetWebhookEndpoint)
+	webhooks.Put("/:id", handlers.UpdateWebhookEndpoint)
+	webhooks.Delete("/:id", handlers.DeleteWebhookEndpoint)
+	webhooks.Post("/:id/rotate-secret", handlers.RotateWebhookSecret)
+	webhooks.Get("/:id/deliveries", handlers.GetWebhookDeliveries)
+	webhooks.Post("/:id/deliveries/:delivery_id/replay", handlers.ReplayWebhookDelivery)
+
 	// Get port from environment
 	port := os.Getenv("APP_PORT")
 	if port == "" {
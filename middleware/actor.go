@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ActorMiddleware extracts the ID of the person making the request from the X-Person-ID header.
+// The header is optional; handlers that need an actor check for person_id themselves.
+func ActorMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		personID := c.Get("X-Person-ID")
+		if personID != "" {
+			// Validate that it's a valid number
+			_, err := strconv.Atoi(personID)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid person ID format",
+				})
+			}
+			c.Locals("person_id", personID)
+		}
+
+		return c.Next()
+	}
+}
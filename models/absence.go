@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LeaveType represents the reason a person is away
+type LeaveType string
+
+const (
+	LeaveTypeVacation LeaveType = "vacation"
+	LeaveTypeSick     LeaveType = "sick"
+	LeaveTypeOther    LeaveType = "other"
+)
+
+// LeaveStatus represents where a leave request is in its approval
+type LeaveStatus string
+
+const (
+	LeaveStatusPending   LeaveStatus = "pending"
+	LeaveStatusApproved  LeaveStatus = "approved"
+	LeaveStatusRejected  LeaveStatus = "rejected"
+	LeaveStatusCancelled LeaveStatus = "cancelled"
+)
+
+// Holiday is a day off for everyone in a tenant
+type Holiday struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	TenantID  uint           `json:"tenant_id" gorm:"not null;index"`
+	Tenant    *Tenant        `json:"-" gorm:"foreignKey:TenantID"`
+	Name      string         `json:"name" gorm:"size:100;not null"`
+	Date      time.Time      `json:"date" gorm:"not null;index"`
+	Annual    bool           `json:"annual"` // falls on the same day every year
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// FallsOn reports whether the holiday is on the given day
+func (h *Holiday) FallsOn(day time.Time) bool {
+	y, m, d := day.Date()
+	hy, hm, hd := h.Date.UTC().Date()
+	return m == hm && d == hd && (h.Annual || y == hy)
+}
+
+// Leave is a request by a person to be away from the start date to the end date, inclusive.
+// Only approved leave reduces the person's capacity.
+type Leave struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	TenantID    uint           `json:"tenant_id" gorm:"not null;index"`
+	Tenant      *Tenant        `json:"-" gorm:"foreignKey:TenantID"`
+	PersonID    uint           `json:"person_id" gorm:"not null;index"`
+	Person      *Person        `json:"person,omitempty" gorm:"foreignKey:PersonID"`
+	Type        LeaveType      `json:"type" gorm:"size:20;not null;default:'vacation'"`
+	Status      LeaveStatus    `json:"status" gorm:"size:20;not null;default:'pending';index"`
+	StartDate   time.Time      `json:"start_date" gorm:"not null"`
+	EndDate     time.Time      `json:"end_date" gorm:"not null"`
+	Reason      string         `json:"reason" gorm:"type:text"`
+	DecidedByID *uint          `json:"decided_by_id"` // who approved or rejected the request
+	DecidedAt   *time.Time     `json:"decided_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Covers reports whether the leave includes a day
+func (l *Leave) Covers(day time.Time) bool {
+	return !day.Before(l.StartDate) && !day.After(l.EndDate)
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Allocation reserves a share of a person's capacity for a project over a period
+type Allocation struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	TenantID  uint           `json:"tenant_id" gorm:"not null;index"`
+	ProjectID uint           `json:"project_id" gorm:"not null;index"`
+	Project   *Project       `json:"-" gorm:"foreignKey:ProjectID"`
+	PersonID  uint           `json:"person_id" gorm:"not null;index"`
+	Person    *Person        `json:"person,omitempty" gorm:"foreignKey:PersonID"`
+	Percent   float64        `json:"percent" gorm:"not null"` // of the person's capacity
+	StartDate time.Time      `json:"start_date" gorm:"not null"`
+	EndDate   *time.Time     `json:"end_date"` // last day of the allocation; open-ended when not set
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Covers reports whether the allocation applies on a day
+func (a *Allocation) Covers(day time.Time) bool {
+	return !day.Before(a.StartDate) && (a.EndDate == nil || !day.After(*a.EndDate))
+}
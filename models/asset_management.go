@@ -53,7 +53,8 @@ type Asset struct {
 	AssignedTo       *Person        `json:"assigned_to" gorm:"foreignKey:CurrentAssignee"`
 	ExpectedLifespan int            `json:"expected_lifespan"` // in months
 	Notes            string         `json:"notes" gorm:"type:text"`
-	Tags             string         `json:"tags" gorm:"size:255"`
+	Labels           []*Label       `json:"labels,omitempty" gorm:"many2many:asset_labels;"`
+	LegacyTags       string         `json:"legacy_tags" gorm:"->;type:text"` // comma-separated tags from before labels, read-only
 	Barcode          string         `json:"barcode" gorm:"size:100"`
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
@@ -280,6 +281,7 @@ type MaintenanceRecord struct {
 	Description     string            `json:"description" gorm:"type:text;not null"`
 	Results         string            `json:"results" gorm:"type:text"`
 	NextScheduled   *time.Time        `json:"next_scheduled"`
+	DueNotifiedAt   *time.Time        `json:"due_notified_at"` // when the performer was reminded it is due
 	CreatedAt       time.Time         `json:"created_at"`
 	UpdatedAt       time.Time         `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt    `json:"-" gorm:"index"`
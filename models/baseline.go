@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// ProjectBaseline is a named snapshot of a project's agreed plan: its dates, budget and scope.
+// The current project is compared with a baseline to measure slippage.
+type ProjectBaseline struct {
+	ID          uint                `json:"id" gorm:"primaryKey"`
+	TenantID    uint                `json:"tenant_id" gorm:"not null;index"`
+	Tenant      *Tenant             `json:"-" gorm:"foreignKey:TenantID"`
+	ProjectID   uint                `json:"project_id" gorm:"not null;uniqueIndex:idx_project_baseline_name"`
+	Project     *Project            `json:"-" gorm:"foreignKey:ProjectID"`
+	Name        string              `json:"name" gorm:"size:100;not null;uniqueIndex:idx_project_baseline_name"`
+	Description string              `json:"description" gorm:"type:text"`
+	StartDate   time.Time           `json:"start_date"`
+	EndDate     *time.Time          `json:"end_date"`
+	Budget      float64             `json:"budget"`
+	Tasks       []BaselineTask      `json:"tasks" gorm:"type:text;serializer:json"`
+	Milestones  []BaselineMilestone `json:"milestones" gorm:"type:text;serializer:json"`
+	CreatedByID *uint               `json:"created_by_id"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+// BaselineTask is a task as it was when a baseline was taken
+type BaselineTask struct {
+	TaskID         uint       `json:"task_id"`
+	Title          string     `json:"title"`
+	Status         TaskStatus `json:"status"`
+	DueDate        *time.Time `json:"due_date"`
+	StoryPoints    *float64   `json:"story_points"`
+	EstimatedHours *float64   `json:"estimated_hours"`
+}
+
+// BaselineMilestone is a milestone as it was when a baseline was taken
+type BaselineMilestone struct {
+	MilestoneID uint      `json:"milestone_id"`
+	Title       string    `json:"title"`
+	DueDate     time.Time `json:"due_date"`
+}
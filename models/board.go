@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Board represents a kanban board over the tasks of a project
+type Board struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	ProjectID uint           `json:"project_id" gorm:"not null;index"`
+	Project   *Project       `json:"-" gorm:"foreignKey:ProjectID"`
+	Name      string         `json:"name" gorm:"size:100;not null"`
+	Columns   []BoardColumn  `json:"columns" gorm:"foreignKey:BoardID"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// BoardColumn represents a column of a board. Each column shows the tasks in one status.
+type BoardColumn struct {
+	ID       uint       `json:"id" gorm:"primaryKey"`
+	BoardID  uint       `json:"board_id" gorm:"not null;index"`
+	Board    *Board     `json:"-" gorm:"foreignKey:BoardID"`
+	Name     string     `json:"name" gorm:"size:100;not null"`
+	Status   TaskStatus `json:"status" gorm:"size:20;not null"`
+	Position int        `json:"position" gorm:"default:0"`
+	WIPLimit *int       `json:"wip_limit"` // maximum number of tasks in the column, null for no limit
+	Cards    []Task     `json:"cards,omitempty" gorm:"-"`
+}
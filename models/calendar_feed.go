@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// CalendarFeedScope represents what a calendar feed covers
+type CalendarFeedScope string
+
+const (
+	CalendarFeedScopePerson  CalendarFeedScope = "person"
+	CalendarFeedScopeProject CalendarFeedScope = "project"
+)
+
+// CalendarFeed is an iCalendar feed of a person's or a project's dates. The feed URL is
+// authenticated by its token alone, so calendar apps can subscribe without a tenant header;
+// regenerating the token revokes the old URL.
+type CalendarFeed struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	TenantID  uint              `json:"tenant_id" gorm:"not null;index"`
+	Tenant    *Tenant           `json:"-" gorm:"foreignKey:TenantID"`
+	Scope     CalendarFeedScope `json:"scope" gorm:"size:20;not null"`
+	PersonID  *uint             `json:"person_id" gorm:"index"`
+	ProjectID *uint             `json:"project_id" gorm:"index"`
+	Token     string            `json:"token" gorm:"size:64;not null;uniqueIndex"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CommentEntityType represents the kind of record a comment is attached to
+type CommentEntityType string
+
+const (
+	CommentEntityTask  CommentEntityType = "task"
+	CommentEntityIssue CommentEntityType = "issue"
+)
+
+// Comment represents a threaded discussion comment on a task or issue
+type Comment struct {
+	ID         uint              `json:"id" gorm:"primaryKey"`
+	TenantID   uint              `json:"tenant_id" gorm:"not null;index"`
+	Tenant     *Tenant           `json:"-" gorm:"foreignKey:TenantID"`
+	EntityType CommentEntityType `json:"entity_type" gorm:"size:20;not null;index:idx_comment_entity"`
+	EntityID   uint              `json:"entity_id" gorm:"not null;index:idx_comment_entity"`
+	ParentID   *uint             `json:"parent_id" gorm:"index"`
+	Parent     *Comment          `json:"-" gorm:"foreignKey:ParentID"`
+	AuthorID   uint              `json:"author_id" gorm:"not null;index"`
+	Author     *Person           `json:"author" gorm:"foreignKey:AuthorID"`
+	Body       string            `json:"body" gorm:"type:text;not null"`
+	EditedAt   *time.Time        `json:"edited_at"`
+	Revisions  []CommentRevision `json:"revisions,omitempty" gorm:"foreignKey:CommentID"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt    `json:"-" gorm:"index"`
+}
+
+// CommentRevision keeps a previous body of a comment each time it is edited
+type CommentRevision struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CommentID  uint      `json:"comment_id" gorm:"not null;index"`
+	Comment    *Comment  `json:"-" gorm:"foreignKey:CommentID"`
+	Body       string    `json:"body" gorm:"type:text;not null"`
+	EditedByID uint      `json:"edited_by_id" gorm:"not null;index"`
+	EditedBy   *Person   `json:"edited_by,omitempty" gorm:"foreignKey:EditedByID"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// mentionPattern matches @handle tokens that are not part of an email address.
+// A handle is either a full email address or the local part of one.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([A-Za-z0-9._%+-]+(?:@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)?)`)
+
+// ParseMentions returns the distinct, lower-cased handles mentioned in a comment body
+func ParseMentions(body string) []string {
+	var handles []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(strings.TrimRight(match[1], "."))
+		if handle == "" || seen[handle] {
+			continue
+		}
+		seen[handle] = true
+		handles = append(handles, handle)
+	}
+	return handles
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"no mentions", "Looks good to me", nil},
+		{"single handle", "@alice can you review?", []string{"alice"}},
+		{"full email", "cc @Bob.Smith@acme.example.com", []string{"bob.smith@acme.example.com"}},
+		{"duplicates collapse", "@alice and @ALICE again", []string{"alice"}},
+		{"trailing punctuation", "Thanks @carol.", []string{"carol"}},
+		{"plain email is not a mention", "Mail dave@acme.example.com for access", nil},
+		{"several", "@alice, @bob: see (@carol)", []string{"alice", "bob", "carol"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseMentions(tt.body))
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RateCard is the hourly cost of a person, or of everyone with a role, from a date on.
+// A card for the person takes precedence over a card for their role.
+type RateCard struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	TenantID      uint           `json:"tenant_id" gorm:"not null;index"`
+	Tenant        *Tenant        `json:"-" gorm:"foreignKey:TenantID"`
+	PersonID      *uint          `json:"person_id" gorm:"index"`
+	Person        *Person        `json:"person,omitempty" gorm:"foreignKey:PersonID"`
+	Role          string         `json:"role" gorm:"size:50;index"` // used when PersonID is not set
+	HourlyRate    float64        `json:"hourly_rate" gorm:"not null"`
+	EffectiveFrom time.Time      `json:"effective_from" gorm:"not null"`
+	EffectiveTo   *time.Time     `json:"effective_to"` // last day the rate applies; open-ended when not set
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// ProjectCost links a non-labour cost to a project. The amount and date are read from
+// the linked purchase order or maintenance record, so later changes to it are reflected.
+type ProjectCost struct {
+	ID                  uint               `json:"id" gorm:"primaryKey"`
+	TenantID            uint               `json:"tenant_id" gorm:"not null;index"`
+	ProjectID           uint               `json:"project_id" gorm:"not null;index"`
+	Project             *Project           `json:"-" gorm:"foreignKey:ProjectID"`
+	PurchaseOrderID     *uint              `json:"purchase_order_id" gorm:"index"`
+	PurchaseOrder       *PurchaseOrder     `json:"purchase_order,omitempty" gorm:"foreignKey:PurchaseOrderID"`
+	MaintenanceRecordID *uint              `json:"maintenance_record_id" gorm:"index"`
+	MaintenanceRecord   *MaintenanceRecord `json:"maintenance_record,omitempty" gorm:"foreignKey:MaintenanceRecordID"`
+	Description         string             `json:"description" gorm:"type:text"`
+	CreatedAt           time.Time          `json:"created_at"`
+	UpdatedAt           time.Time          `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt     `json:"-" gorm:"index"`
+}
+
+// Amount returns the cost of the linked record and the date it was incurred
+func (pc *ProjectCost) Amount() (float64, time.Time) {
+	switch {
+	case pc.PurchaseOrder != nil:
+		return pc.PurchaseOrder.TotalAmount, pc.PurchaseOrder.OrderDate
+	case pc.MaintenanceRecord != nil:
+		if pc.MaintenanceRecord.CompletedDate != nil {
+			return pc.MaintenanceRecord.Cost, *pc.MaintenanceRecord.CompletedDate
+		}
+		return pc.MaintenanceRecord.Cost, pc.MaintenanceRecord.ScheduledDate
+	}
+	return 0, pc.CreatedAt
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// CustomFieldEntity represents the kind of record a custom field belongs to
+type CustomFieldEntity string
+
+const (
+	CustomFieldEntityProject CustomFieldEntity = "project"
+	CustomFieldEntityTask    CustomFieldEntity = "task"
+	CustomFieldEntityPerson  CustomFieldEntity = "person"
+)
+
+// CustomFieldType represents the kind of value a custom field holds
+type CustomFieldType string
+
+const (
+	CustomFieldTypeText        CustomFieldType = "text"
+	CustomFieldTypeNumber      CustomFieldType = "number"
+	CustomFieldTypeDate        CustomFieldType = "date"   // stored as YYYY-MM-DD
+	CustomFieldTypeSelect      CustomFieldType = "select" // one of Options
+	CustomFieldTypeMultiSelect CustomFieldType = "multi_select"
+	CustomFieldTypePerson      CustomFieldType = "person" // ID of a person of the tenant
+)
+
+// customFieldKeyPattern is the form of custom field keys: they are used in JSON and query parameters
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// CustomField is a field a tenant adds to its projects, tasks or people. Values are kept
+// by key in the record's CustomFields.
+type CustomField struct {
+	ID         uint              `json:"id" gorm:"primaryKey"`
+	TenantID   uint              `json:"tenant_id" gorm:"not null;uniqueIndex:idx_custom_field_key"`
+	Tenant     *Tenant           `json:"-" gorm:"foreignKey:TenantID"`
+	EntityType CustomFieldEntity `json:"entity_type" gorm:"size:20;not null;uniqueIndex:idx_custom_field_key"`
+	Key        string            `json:"key" gorm:"size:50;not null;uniqueIndex:idx_custom_field_key"`
+	Name       string            `json:"name" gorm:"size:100;not null"`
+	Type       CustomFieldType   `json:"type" gorm:"size:20;not null"`
+	Options    []string          `json:"options" gorm:"type:text;serializer:json"` // choices of select and multi-select fields
+	Required   bool              `json:"required"`
+	Position   int               `json:"position"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// CustomFieldValues are the values of a record's custom fields by key
+type CustomFieldValues map[string]interface{}
+
+// IsValidCustomFieldKey reports whether a key can name a custom field
+func IsValidCustomFieldKey(key string) bool {
+	return customFieldKeyPattern.MatchString(key)
+}
+
+// HasOption reports whether a choice is one of the field's options
+func (f *CustomField) HasOption(choice string) bool {
+	for _, option := range f.Options {
+		if option == choice {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// TaskDependency makes a task wait for another task of the same project to finish
+// before it starts (finish-to-start), optionally with a lag in days
+type TaskDependency struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TaskID      uint      `json:"task_id" gorm:"not null;uniqueIndex:idx_task_dependency"`
+	Task        *Task     `json:"-" gorm:"foreignKey:TaskID"`
+	DependsOnID uint      `json:"depends_on_id" gorm:"not null;uniqueIndex:idx_task_dependency;index"`
+	DependsOn   *Task     `json:"depends_on,omitempty" gorm:"foreignKey:DependsOnID"`
+	LagDays     int       `json:"lag_days"`
+	CreatedAt   time.Time `json:"created_at"`
+}
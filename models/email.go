@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// EmailTemplate is a tenant's own version of the email sent for one event. Events without
+// one use the built-in template.
+type EmailTemplate struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TenantID  uint      `json:"tenant_id" gorm:"not null;uniqueIndex:idx_email_template"`
+	Tenant    *Tenant   `json:"-" gorm:"foreignKey:TenantID"`
+	Event     string    `json:"event" gorm:"size:50;not null;uniqueIndex:idx_email_template"`
+	Subject   string    `json:"subject" gorm:"size:255;not null"`
+	Body      string    `json:"body" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EmailStatus represents where an outgoing email is in its delivery
+type EmailStatus string
+
+const (
+	EmailStatusPending EmailStatus = "pending" // waiting for its first or next attempt
+	EmailStatusSent    EmailStatus = "sent"
+	EmailStatusFailed  EmailStatus = "failed"  // gave up after too many attempts
+	EmailStatusBounced EmailStatus = "bounced" // the address does not accept mail
+)
+
+// Email is an outgoing email, kept until it is sent or given up on
+type Email struct {
+	ID            uint        `json:"id" gorm:"primaryKey"`
+	TenantID      uint        `json:"tenant_id" gorm:"not null;index"`
+	Tenant        *Tenant     `json:"-" gorm:"foreignKey:TenantID"`
+	PersonID      *uint       `json:"person_id" gorm:"index"`
+	Person        *Person     `json:"-" gorm:"foreignKey:PersonID"`
+	Event         string      `json:"event" gorm:"size:50;not null"`
+	To            string      `json:"to" gorm:"column:to_address;size:255;not null;index"`
+	Subject       string      `json:"subject" gorm:"size:255;not null"`
+	Body          string      `json:"body" gorm:"type:text;not null"`
+	Status        EmailStatus `json:"status" gorm:"size:20;not null;index"`
+	Attempts      int         `json:"attempts"`
+	NextAttemptAt *time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastError     string      `json:"last_error" gorm:"type:text"`
+	SentAt        *time.Time  `json:"sent_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// EmailBounce records an address that rejected mail. Nothing more is sent to it until the
+// bounce is removed.
+type EmailBounce struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TenantID  uint      `json:"tenant_id" gorm:"not null;uniqueIndex:idx_email_bounce"`
+	Tenant    *Tenant   `json:"-" gorm:"foreignKey:TenantID"`
+	Address   string    `json:"address" gorm:"size:255;not null;uniqueIndex:idx_email_bounce"`
+	Reason    string    `json:"reason" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
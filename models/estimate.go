@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// EstimateSettings is a tenant's settings for comparing task estimates with the time logged.
+// Tenants without their own use DefaultEstimateSettings.
+type EstimateSettings struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	TenantID       uint      `json:"tenant_id" gorm:"not null;uniqueIndex"`
+	Tenant         *Tenant   `json:"-" gorm:"foreignKey:TenantID"`
+	OverrunPercent float64   `json:"overrun_percent" gorm:"not null"` // alert once logged hours exceed the estimate by this much
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// DefaultEstimateSettings returns the settings used by tenants that have not set their own
+func DefaultEstimateSettings(tenantID uint) EstimateSettings {
+	return EstimateSettings{
+		TenantID:       tenantID,
+		OverrunPercent: 20,
+	}
+}
+
+// Overruns reports whether logged hours exceed an estimate by more than the overrun percentage.
+// Tasks without an estimate never overrun.
+func (s *EstimateSettings) Overruns(estimated, actual float64) bool {
+	return estimated > 0 && actual > estimated*(1+s.OverrunPercent/100)
+}
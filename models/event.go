@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// Domain event types, named "<aggregate>.<what happened>"
+const (
+	EventProjectCreated           = "project.created"
+	EventProjectUpdated           = "project.updated"
+	EventTaskCreated              = "task.created"
+	EventTaskUpdated              = "task.updated"
+	EventTaskDeleted              = "task.deleted"
+	EventCommentCreated           = "comment.created"
+	EventAssetCreated             = "asset.created"
+	EventAssetUpdated             = "asset.updated"
+	EventAssetDeleted             = "asset.deleted"
+	EventAssetAssigned            = "asset.assigned"
+	EventProcurementCreated       = "procurement.created"
+	EventProcurementStatusChanged = "procurement.status_changed"
+)
+
+// OutboxEventStatus represents where an event is in its dispatch
+type OutboxEventStatus string
+
+const (
+	OutboxEventPending    OutboxEventStatus = "pending"
+	OutboxEventDispatched OutboxEventStatus = "dispatched" // every subscriber handled it
+	OutboxEventFailed     OutboxEventStatus = "failed"     // gave up after too many attempts; holds back its aggregate
+	OutboxEventDiscarded  OutboxEventStatus = "discarded"  // a failed event that will not be dispatched
+)
+
+// OutboxEventsOpen are the statuses of events that hold back the later events of their aggregate
+var OutboxEventsOpen = []OutboxEventStatus{OutboxEventPending, OutboxEventFailed}
+
+// OutboxEvent is a domain event written in the transaction of the change it describes,
+// waiting to be dispatched to the subscribers of its type
+type OutboxEvent struct {
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	TenantID      uint              `json:"tenant_id" gorm:"not null;index"`
+	Tenant        *Tenant           `json:"-" gorm:"foreignKey:TenantID"`
+	Type          string            `json:"type" gorm:"size:100;not null"`
+	AggregateType string            `json:"aggregate_type" gorm:"size:50;not null;index:idx_outbox_aggregate"`
+	AggregateID   uint              `json:"aggregate_id" gorm:"not null;index:idx_outbox_aggregate"`
+	Data          string            `json:"data" gorm:"type:text;not null"`
+	Status        OutboxEventStatus `json:"status" gorm:"size:20;not null;index"`
+	HandledBy     []string          `json:"handled_by" gorm:"type:text;serializer:json"` // subscribers that are done with it
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at"`
+	LastError     string            `json:"last_error" gorm:"type:text"`
+	OccurredAt    time.Time         `json:"occurred_at"`
+	DispatchedAt  *time.Time        `json:"dispatched_at"`
+}
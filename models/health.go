@@ -0,0 +1,129 @@
+package models
+
+import (
+	"time"
+)
+
+// HealthStatus is the red/amber/green status of a project's health
+type HealthStatus string
+
+const (
+	HealthStatusGreen HealthStatus = "green"
+	HealthStatusAmber HealthStatus = "amber"
+	HealthStatusRed   HealthStatus = "red"
+)
+
+// Project health factors
+const (
+	HealthFactorOverdueTasks      = "overdue_tasks"
+	HealthFactorBlockedTasks      = "blocked_tasks"
+	HealthFactorDelayedMilestones = "delayed_milestones"
+	HealthFactorKPIs              = "kpis"
+	HealthFactorHighRisks         = "high_risks"
+	HealthFactorUnresolvedIssues  = "unresolved_issues"
+	HealthFactorBudgetBurn        = "budget_burn"
+)
+
+// HealthWeights is a tenant's weighting of the factors of project health and the scores
+// below which a project turns amber or red. Tenants without their own use DefaultHealthWeights.
+type HealthWeights struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	TenantID          uint      `json:"tenant_id" gorm:"not null;uniqueIndex"`
+	Tenant            *Tenant   `json:"-" gorm:"foreignKey:TenantID"`
+	OverdueTasks      float64   `json:"overdue_tasks" gorm:"not null"`
+	BlockedTasks      float64   `json:"blocked_tasks" gorm:"not null"`
+	DelayedMilestones float64   `json:"delayed_milestones" gorm:"not null"`
+	KPIs              float64   `json:"kpis" gorm:"column:kpis;not null"`
+	HighRisks         float64   `json:"high_risks" gorm:"not null"`
+	UnresolvedIssues  float64   `json:"unresolved_issues" gorm:"not null"`
+	BudgetBurn        float64   `json:"budget_burn" gorm:"not null"`
+	AmberBelow        float64   `json:"amber_below" gorm:"not null"` // scores below this are amber
+	RedBelow          float64   `json:"red_below" gorm:"not null"`   // scores below this are red
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// DefaultHealthWeights returns the weights used by tenants that have not set their own
+func DefaultHealthWeights(tenantID uint) HealthWeights {
+	return HealthWeights{
+		TenantID:          tenantID,
+		OverdueTasks:      20,
+		BlockedTasks:      10,
+		DelayedMilestones: 15,
+		KPIs:              15,
+		HighRisks:         15,
+		UnresolvedIssues:  10,
+		BudgetBurn:        15,
+		AmberBelow:        75,
+		RedBelow:          50,
+	}
+}
+
+// Weight returns the weight of a factor
+func (w *HealthWeights) Weight(factor string) float64 {
+	switch factor {
+	case HealthFactorOverdueTasks:
+		return w.OverdueTasks
+	case HealthFactorBlockedTasks:
+		return w.BlockedTasks
+	case HealthFactorDelayedMilestones:
+		return w.DelayedMilestones
+	case HealthFactorKPIs:
+		return w.KPIs
+	case HealthFactorHighRisks:
+		return w.HighRisks
+	case HealthFactorUnresolvedIssues:
+		return w.UnresolvedIssues
+	case HealthFactorBudgetBurn:
+		return w.BudgetBurn
+	}
+	return 0
+}
+
+// HealthFactor is one input to a project's health score, scored from 0 (worst) to 100
+type HealthFactor struct {
+	Name       string  `json:"name"`
+	Score      float64 `json:"score"`
+	Weight     float64 `json:"weight"`
+	Applicable bool    `json:"applicable"` // false when the project has nothing to measure, e.g. no milestones
+	Detail     string  `json:"detail"`
+}
+
+// Score combines factors into a score from 0 to 100 weighted by w, and its status.
+// Factors that are not applicable are left out; a project with none scores 100.
+func (w *HealthWeights) Score(factors []HealthFactor) (float64, HealthStatus) {
+	var weighted, total float64
+	for i := range factors {
+		factors[i].Weight = w.Weight(factors[i].Name)
+		if factors[i].Applicable {
+			weighted += factors[i].Score * factors[i].Weight
+			total += factors[i].Weight
+		}
+	}
+
+	score := 100.0
+	if total > 0 {
+		score = weighted / total
+	}
+
+	switch {
+	case score < w.RedBelow:
+		return score, HealthStatusRed
+	case score < w.AmberBelow:
+		return score, HealthStatusAmber
+	}
+	return score, HealthStatusGreen
+}
+
+// ProjectHealth is the last computed health of a project. It serves as a cache: it is dropped
+// when the project's records change and recomputed when it is next requested.
+type ProjectHealth struct {
+	ID          uint           `json:"-" gorm:"primaryKey"`
+	ProjectID   uint           `json:"project_id" gorm:"not null;uniqueIndex"`
+	ProjectName string         `json:"project_name" gorm:"-"`
+	TenantID    uint           `json:"-" gorm:"not null;index"`
+	Score       float64        `json:"score" gorm:"not null"`
+	Status      HealthStatus   `json:"status" gorm:"size:10;not null;index"`
+	Factors     []HealthFactor `json:"factors" gorm:"type:text;serializer:json"`
+	ComputedAt  time.Time      `json:"computed_at" gorm:"not null"`
+}
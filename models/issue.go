@@ -28,6 +28,7 @@ type Issue struct {
 	ReportedBy   *Person        `json:"reported_by" gorm:"foreignKey:ReportedByID"`
 	AssignedToID *uint          `json:"assigned_to_id" gorm:"index"`
 	AssignedTo   *Person        `json:"assigned_to" gorm:"foreignKey:AssignedToID"`
+	Labels       []*Label       `json:"labels,omitempty" gorm:"many2many:issue_labels;"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
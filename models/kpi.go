@@ -1,35 +1,174 @@
 package models
 
 import (
+	"math"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// KPI represents a Key Performance Indicator for a project
+// KPIDirection is which way a KPI should move
+type KPIDirection string
+
+const (
+	KPIDirectionIncrease KPIDirection = "increase" // higher is better, e.g. revenue
+	KPIDirectionDecrease KPIDirection = "decrease" // lower is better, e.g. defect count
+	KPIDirectionRange    KPIDirection = "range"    // stay between RangeMin and RangeMax, e.g. budget burn
+)
+
+// KPISource is where a KPI's current value comes from
+type KPISource string
+
+const (
+	KPISourceManual   KPISource = "manual"   // set through the API
+	KPISourceComputed KPISource = "computed" // evaluated from Formula over project metrics
+)
+
+// KPIStatus is the red/amber/green status of a KPI
+type KPIStatus string
+
+const (
+	KPIStatusGreen KPIStatus = "green"
+	KPIStatusAmber KPIStatus = "amber"
+	KPIStatusRed   KPIStatus = "red"
+)
+
+// KPI represents a Key Performance Indicator for a project.
+//
+// For increase and decrease KPIs the thresholds are values: the KPI turns amber once it is
+// worse than AmberThreshold (the target when unset) and red once it is worse than RedThreshold.
+// For range KPIs they are how far outside the range the value may be before turning amber
+// (zero when unset) or red.
 type KPI struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	ProjectID    uint           `json:"project_id" gorm:"not null;index"`
-	Project      *Project       `json:"-" gorm:"foreignKey:ProjectID"`
-	Description  string         `json:"description" gorm:"type:text;not null"`
-	TargetValue  float64        `json:"target_value" gorm:"not null"`
-	CurrentValue float64        `json:"current_value" gorm:"not null;default:0"`
-	Unit         string         `json:"unit" gorm:"size:20;not null"` // %, $, count, etc.
-	Achieved     bool           `json:"achieved" gorm:"default:false"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
-}
-
-// Progress calculates the KPI progress as a percentage
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	ProjectID      uint           `json:"project_id" gorm:"not null;index"`
+	Project        *Project       `json:"-" gorm:"foreignKey:ProjectID"`
+	Description    string         `json:"description" gorm:"type:text;not null"`
+	Direction      KPIDirection   `json:"direction" gorm:"size:20;not null;default:'increase'"`
+	BaselineValue  float64        `json:"baseline_value" gorm:"not null;default:0"` // value progress is measured from
+	TargetValue    float64        `json:"target_value" gorm:"not null"`
+	RangeMin       *float64       `json:"range_min"` // range KPIs only
+	RangeMax       *float64       `json:"range_max"` // range KPIs only
+	AmberThreshold *float64       `json:"amber_threshold"`
+	RedThreshold   *float64       `json:"red_threshold"`
+	CurrentValue   float64        `json:"current_value" gorm:"not null;default:0"`
+	SourceType     KPISource      `json:"source_type" gorm:"size:20;not null;default:'manual'"`
+	Formula        string         `json:"formula" gorm:"type:text"`       // computed KPIs only, see package formula
+	LastComputedAt *time.Time     `json:"last_computed_at"`               // when the formula was last evaluated
+	ComputeError   string         `json:"compute_error" gorm:"type:text"` // why the last evaluation failed, if it did
+	Unit           string         `json:"unit" gorm:"size:20;not null"`   // %, $, count, etc.
+	Achieved       bool           `json:"achieved" gorm:"default:false"`
+	Status         KPIStatus      `json:"status" gorm:"size:10;index"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// KPIMeasurement is a value a KPI had from a point in time. A measurement is recorded
+// every time the KPI's current value changes.
+type KPIMeasurement struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	KPIID        uint      `json:"kpi_id" gorm:"column:kpi_id;not null;index"`
+	Value        float64   `json:"value" gorm:"not null"`
+	Note         string    `json:"note" gorm:"type:text"`
+	Source       string    `json:"source" gorm:"size:50"` // where the value came from, e.g. manual, import, api
+	MeasuredAt   time.Time `json:"measured_at" gorm:"not null;index"`
+	RecordedByID *uint     `json:"recorded_by_id"`
+	RecordedBy   *Person   `json:"recorded_by,omitempty" gorm:"foreignKey:RecordedByID"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Progress calculates how far the KPI has moved from its baseline towards its target, as a
+// percentage. A range KPI inside its range is at 100%.
 func (k *KPI) Progress() float64 {
-	if k.TargetValue == 0 {
+	if k.direction() != KPIDirectionRange {
+		if k.TargetValue == k.BaselineValue {
+			return 0
+		}
+		return (k.CurrentValue - k.BaselineValue) / (k.TargetValue - k.BaselineValue) * 100
+	}
+
+	if k.inRange() {
+		return 100
+	}
+	// Outside the range, progress is how far the value has come from the baseline towards
+	// the range; a value that overshot the range has made none
+	bound := k.nearestBound()
+	if bound == k.BaselineValue {
+		return 0
+	}
+	ratio := (k.CurrentValue - k.BaselineValue) / (bound - k.BaselineValue)
+	if ratio < 0 || ratio > 1 {
 		return 0
 	}
-	return (k.CurrentValue / k.TargetValue) * 100
+	return ratio * 100
 }
 
-// UpdateAchievement sets Achieved to true if CurrentValue >= TargetValue
+// UpdateAchievement sets Achieved when the target is met and recomputes Status
 func (k *KPI) UpdateAchievement() {
-	k.Achieved = k.CurrentValue >= k.TargetValue
+	switch k.direction() {
+	case KPIDirectionDecrease:
+		k.Achieved = k.CurrentValue <= k.TargetValue
+	case KPIDirectionRange:
+		k.Achieved = k.inRange()
+	default:
+		k.Achieved = k.CurrentValue >= k.TargetValue
+	}
+	k.Status = k.RAGStatus()
+}
+
+// RAGStatus computes the red/amber/green status from the current value and thresholds
+func (k *KPI) RAGStatus() KPIStatus {
+	// shortfall is how far the value is past each threshold; positive means worse
+	var shortfall func(threshold float64) float64
+	amber := k.TargetValue
+	switch k.direction() {
+	case KPIDirectionDecrease:
+		shortfall = func(threshold float64) float64 { return k.CurrentValue - threshold }
+	case KPIDirectionRange:
+		distance := 0.0
+		if !k.inRange() {
+			distance = math.Abs(k.CurrentValue - k.nearestBound())
+		}
+		shortfall = func(threshold float64) float64 { return distance - threshold }
+		amber = 0
+	default:
+		shortfall = func(threshold float64) float64 { return threshold - k.CurrentValue }
+	}
+	if k.AmberThreshold != nil {
+		amber = *k.AmberThreshold
+	}
+
+	switch {
+	case k.RedThreshold != nil && shortfall(*k.RedThreshold) > 0:
+		return KPIStatusRed
+	case shortfall(amber) > 0:
+		return KPIStatusAmber
+	default:
+		return KPIStatusGreen
+	}
+}
+
+// direction returns the KPI's direction, treating unset as increase
+func (k *KPI) direction() KPIDirection {
+	if k.Direction == "" {
+		return KPIDirectionIncrease
+	}
+	return k.Direction
+}
+
+// inRange reports whether the current value is within the KPI's range
+func (k *KPI) inRange() bool {
+	return (k.RangeMin == nil || k.CurrentValue >= *k.RangeMin) && (k.RangeMax == nil || k.CurrentValue <= *k.RangeMax)
+}
+
+// nearestBound returns the bound of the range closest to the current value
+func (k *KPI) nearestBound() float64 {
+	if k.RangeMin != nil && k.CurrentValue < *k.RangeMin {
+		return *k.RangeMin
+	}
+	if k.RangeMax != nil {
+		return *k.RangeMax
+	}
+	return k.CurrentValue
 }
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestKPIStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		kpi      KPI
+		achieved bool
+		status   KPIStatus
+		progress float64
+	}{
+		{
+			name:     "increase below target without thresholds",
+			kpi:      KPI{TargetValue: 100, CurrentValue: 60},
+			status:   KPIStatusAmber,
+			progress: 60,
+		},
+		{
+			name:     "increase above amber threshold",
+			kpi:      KPI{TargetValue: 100, CurrentValue: 85, AmberThreshold: float(80), RedThreshold: float(50)},
+			status:   KPIStatusGreen,
+			progress: 85,
+		},
+		{
+			name:     "increase below red threshold, from a baseline",
+			kpi:      KPI{BaselineValue: 20, TargetValue: 120, CurrentValue: 40, AmberThreshold: float(80), RedThreshold: float(50)},
+			status:   KPIStatusRed,
+			progress: 20,
+		},
+		{
+			name:     "decrease achieved",
+			kpi:      KPI{Direction: KPIDirectionDecrease, BaselineValue: 50, TargetValue: 10, CurrentValue: 8},
+			achieved: true,
+			status:   KPIStatusGreen,
+			progress: 105,
+		},
+		{
+			name:     "decrease above red threshold",
+			kpi:      KPI{Direction: KPIDirectionDecrease, BaselineValue: 50, TargetValue: 10, CurrentValue: 45, AmberThreshold: float(20), RedThreshold: float(40)},
+			status:   KPIStatusRed,
+			progress: 12.5,
+		},
+		{
+			name:     "range inside",
+			kpi:      KPI{Direction: KPIDirectionRange, RangeMin: float(90), RangeMax: float(110), CurrentValue: 100},
+			achieved: true,
+			status:   KPIStatusGreen,
+			progress: 100,
+		},
+		{
+			name:     "range overshot, within red tolerance",
+			kpi:      KPI{Direction: KPIDirectionRange, RangeMin: float(90), RangeMax: float(110), CurrentValue: 115, RedThreshold: float(10)},
+			status:   KPIStatusAmber,
+			progress: 0,
+		},
+		{
+			name:     "range far outside",
+			kpi:      KPI{Direction: KPIDirectionRange, RangeMin: float(90), RangeMax: float(110), CurrentValue: 70, AmberThreshold: float(5), RedThreshold: float(15)},
+			status:   KPIStatusRed,
+			progress: 70.0 / 90 * 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kpi := tt.kpi
+			kpi.UpdateAchievement()
+			assert.Equal(t, tt.achieved, kpi.Achieved)
+			assert.Equal(t, tt.status, kpi.Status)
+			assert.InDelta(t, tt.progress, kpi.Progress(), 1e-9)
+		})
+	}
+}
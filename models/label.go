@@ -0,0 +1,44 @@
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// LabelEntity represents the kind of record a label can be attached to
+type LabelEntity string
+
+const (
+	LabelEntityProject LabelEntity = "project"
+	LabelEntityTask    LabelEntity = "task"
+	LabelEntityIssue   LabelEntity = "issue"
+	LabelEntityRisk    LabelEntity = "risk"
+	LabelEntityAsset   LabelEntity = "asset"
+)
+
+// LabelEntities lists the labelable entity types in display order
+var LabelEntities = []LabelEntity{LabelEntityProject, LabelEntityTask, LabelEntityIssue, LabelEntityRisk, LabelEntityAsset}
+
+// DefaultLabelColor is given to labels created without a colour
+const DefaultLabelColor = "#9e9e9e"
+
+// labelColorPattern is the form of label colours: a #RRGGBB hex triplet
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Label is an entry of a tenant's label catalogue. Projects, tasks, issues, risks and assets
+// carry any number of labels through their <entity>_labels join tables.
+type Label struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TenantID    uint      `json:"tenant_id" gorm:"not null;uniqueIndex:idx_label_name"`
+	Tenant      *Tenant   `json:"-" gorm:"foreignKey:TenantID"`
+	Name        string    `json:"name" gorm:"size:50;not null;uniqueIndex:idx_label_name"`
+	Color       string    `json:"color" gorm:"size:7;not null"`
+	Description string    `json:"description" gorm:"size:255"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// IsValidLabelColor reports whether a colour is a #RRGGBB hex triplet
+func IsValidLabelColor(color string) bool {
+	return labelColorPattern.MatchString(color)
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+)
+
+// NotificationType represents the event that produced a notification
+type NotificationType string
+
+const (
+	NotificationTypeMention                  NotificationType = "mention"
+	NotificationTypeEstimateOverrun          NotificationType = "estimate_overrun"
+	NotificationTypeTaskAssigned             NotificationType = "task_assigned"
+	NotificationTypeTaskUpdated              NotificationType = "task_updated" // status change or reassignment of a watched task
+	NotificationTypeCommentAdded             NotificationType = "comment_added"
+	NotificationTypeProjectUpdated           NotificationType = "project_updated"
+	NotificationTypeProcurementStatusChanged NotificationType = "procurement_status_changed"
+	NotificationTypeProcurementApproved      NotificationType = "procurement_approved"
+	NotificationTypeAssetAssigned            NotificationType = "asset_assigned"
+	NotificationTypeMaintenanceDue           NotificationType = "maintenance_due"
+)
+
+// NotificationTypes lists the notification types people can set preferences for
+var NotificationTypes = []NotificationType{
+	NotificationTypeMention,
+	NotificationTypeEstimateOverrun,
+	NotificationTypeTaskAssigned,
+	NotificationTypeTaskUpdated,
+	NotificationTypeCommentAdded,
+	NotificationTypeProjectUpdated,
+	NotificationTypeProcurementStatusChanged,
+	NotificationTypeProcurementApproved,
+	NotificationTypeAssetAssigned,
+	NotificationTypeMaintenanceDue,
+}
+
+// IsValidNotificationType reports whether a type is one of NotificationTypes
+func IsValidNotificationType(t NotificationType) bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationDelivery represents when notifications sent by email go out
+type NotificationDelivery string
+
+const (
+	NotificationDeliveryImmediate NotificationDelivery = "immediate"
+	NotificationDeliveryDigest    NotificationDelivery = "digest" // collected into a daily email
+)
+
+// Notification represents a message delivered to a person about activity they care about
+type Notification struct {
+	ID         uint             `json:"id" gorm:"primaryKey"`
+	TenantID   uint             `json:"tenant_id" gorm:"not null;index"`
+	Tenant     *Tenant          `json:"-" gorm:"foreignKey:TenantID"`
+	PersonID   uint             `json:"person_id" gorm:"not null;index"`
+	Person     *Person          `json:"-" gorm:"foreignKey:PersonID"`
+	Type       NotificationType `json:"type" gorm:"size:50;not null"`
+	EntityType string           `json:"entity_type" gorm:"size:50;not null"`
+	EntityID   uint             `json:"entity_id" gorm:"not null"`
+	CommentID  *uint            `json:"comment_id" gorm:"index"`
+	ActorID    *uint            `json:"actor_id" gorm:"index"`
+	Actor      *Person          `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	Message    string           `json:"message" gorm:"type:text"`
+	EmailOnly  bool             `json:"email_only"` // left out of the notification center
+	Email      bool             `json:"email"`      // to be sent by email
+	Digest     bool             `json:"digest"`     // emailed in the daily digest rather than on its own
+	EmailedAt  *time.Time       `json:"emailed_at"` // when the email, or the digest carrying it, was queued
+	ReadAt     *time.Time       `json:"read_at"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// NotificationPreference is how a person wants to be told about one type of event. Without
+// one, notifications appear in the notification center only.
+type NotificationPreference struct {
+	ID        uint                 `json:"id" gorm:"primaryKey"`
+	TenantID  uint                 `json:"tenant_id" gorm:"not null;index"`
+	Tenant    *Tenant              `json:"-" gorm:"foreignKey:TenantID"`
+	PersonID  uint                 `json:"person_id" gorm:"not null;uniqueIndex:idx_notification_preference"`
+	Person    *Person              `json:"-" gorm:"foreignKey:PersonID"`
+	Type      NotificationType     `json:"type" gorm:"size:50;not null;uniqueIndex:idx_notification_preference"`
+	InApp     bool                 `json:"in_app"`
+	Email     bool                 `json:"email"`
+	Delivery  NotificationDelivery `json:"delivery" gorm:"size:20;not null"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// DefaultNotificationPreference returns the preference of a person who has not set one for a type
+func DefaultNotificationPreference(tenantID, personID uint, t NotificationType) NotificationPreference {
+	return NotificationPreference{
+		TenantID: tenantID,
+		PersonID: personID,
+		Type:     t,
+		InApp:    true,
+		Delivery: NotificationDeliveryImmediate,
+	}
+}
+
+// Muted reports whether the person does not want to be told about the event at all
+func (p *NotificationPreference) Muted() bool {
+	return !p.InApp && !p.Email
+}
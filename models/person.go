@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,17 +10,63 @@ import (
 
 // Person represents a team member in the project management system
 type Person struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	TenantID  uint           `json:"tenant_id" gorm:"not null;index"`
-	Tenant    *Tenant        `json:"-" gorm:"foreignKey:TenantID"`
-	Name      string         `json:"name" gorm:"size:100;not null"`
-	Email     string         `json:"email" gorm:"size:100;not null;uniqueIndex:idx_tenant_email"`
-	Role      string         `json:"role" gorm:"size:50;not null"`
-	Position  string         `json:"position" gorm:"size:100"`
-	Phone     string         `json:"phone" gorm:"size:20"`
-	Avatar    string         `json:"avatar" gorm:"size:500"`
-	Projects  []*Project     `json:"projects,omitempty" gorm:"many2many:project_people;"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint              `json:"id" gorm:"primaryKey"`
+	TenantID     uint              `json:"tenant_id" gorm:"not null;index"`
+	Tenant       *Tenant           `json:"-" gorm:"foreignKey:TenantID"`
+	Name         string            `json:"name" gorm:"size:100;not null"`
+	Email        string            `json:"email" gorm:"size:100;not null;uniqueIndex:idx_tenant_email"`
+	Role         string            `json:"role" gorm:"size:50;not null"`
+	Position     string            `json:"position" gorm:"size:100"`
+	Phone        string            `json:"phone" gorm:"size:20"`
+	Avatar       string            `json:"avatar" gorm:"size:500"`
+	HoursPerWeek float64           `json:"hours_per_week" gorm:"not null;default:40"`                          // capacity across the working days
+	WorkingDays  string            `json:"working_days" gorm:"size:50;not null;default:'mon,tue,wed,thu,fri'"` // comma-separated, see ParseWorkingDays
+	CustomFields CustomFieldValues `json:"custom_fields" gorm:"type:text;serializer:json"`
+	Projects     []*Project        `json:"projects,omitempty" gorm:"many2many:project_people;"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt    `json:"-" gorm:"index"`
+}
+
+// weekdays maps the abbreviations used in Person.WorkingDays to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWorkingDays parses a comma-separated list of weekdays such as "mon,tue,wed"
+func ParseWorkingDays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	seen := make(map[time.Weekday]bool)
+	for _, name := range strings.Split(s, ",") {
+		day, ok := weekdays[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, errors.New("invalid working day: " + name)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// WorksOn reports whether a weekday is one of the person's working days
+func (p *Person) WorksOn(day time.Weekday) bool {
+	days, _ := ParseWorkingDays(p.WorkingDays)
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// DailyHours returns the hours the person works on each working day
+func (p *Person) DailyHours() float64 {
+	days, err := ParseWorkingDays(p.WorkingDays)
+	if err != nil || len(days) == 0 {
+		return 0
+	}
+	return p.HoursPerWeek / float64(len(days))
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskRecurrence represents a series of recurring tasks generated from an RFC 5545 RRULE.
+// Each occurrence is a regular task linked to the series through its RecurrenceID.
+type TaskRecurrence struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	ProjectID       uint           `json:"project_id" gorm:"not null;index"`
+	Project         *Project       `json:"-" gorm:"foreignKey:ProjectID"`
+	RRule           string         `json:"rrule" gorm:"size:500;not null"`
+	StartDate       time.Time      `json:"start_date" gorm:"not null"` // DTSTART, the due date of the first occurrence
+	SkipWeekends    bool           `json:"skip_weekends"`              // move occurrences that fall on a weekend or holiday to the next working day
+	CopyAssignee    bool           `json:"copy_assignee"`
+	CopyDescription bool           `json:"copy_description"`
+	LeadDays        int            `json:"lead_days"` // create the next occurrence this many days before it is due
+	Paused          bool           `json:"paused"`
+	LastTaskID      *uint          `json:"last_task_id"`                 // the most recent occurrence
+	NextDueAt       *time.Time     `json:"next_due_at"`                  // due date of the next occurrence, null once the series has ended
+	NextRunAt       *time.Time     `json:"next_run_at" gorm:"index"`     // when the scheduler creates the next occurrence
+	Occurrences     int            `json:"occurrences" gorm:"default:0"` // number of tasks generated so far
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
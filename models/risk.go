@@ -26,6 +26,7 @@ type Risk struct {
 	Probability string         `json:"probability" gorm:"size:50;not null"` // High, Medium, Low
 	Mitigation  string         `json:"mitigation" gorm:"type:text"`
 	Status      RiskStatus     `json:"status" gorm:"size:20;not null;default:'identified'"`
+	Labels      []*Label       `json:"labels,omitempty" gorm:"many2many:risk_labels;"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
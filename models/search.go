@@ -0,0 +1,69 @@
+package models
+
+import "github.com/Masozee/kontena/api/search"
+
+// SearchSources are the records found by full-text search. Tasks, issues and documents
+// belong to the tenant of their project.
+var SearchSources = []search.Source{
+	{
+		Type:         "project",
+		Table:        "projects",
+		Title:        "name",
+		Fields:       []search.Field{{Column: "name", Weight: search.WeightA}, {Column: "description", Weight: search.WeightB}},
+		TenantColumn: "projects.tenant_id",
+	},
+	{
+		Type:         "task",
+		Table:        "tasks",
+		Title:        "title",
+		Fields:       []search.Field{{Column: "title", Weight: search.WeightA}, {Column: "description", Weight: search.WeightB}},
+		Joins:        "JOIN projects ON projects.id = tasks.project_id",
+		TenantColumn: "projects.tenant_id",
+		SoftDeleted:  []string{"projects"},
+	},
+	{
+		Type:         "issue",
+		Table:        "issues",
+		Title:        "title",
+		Fields:       []search.Field{{Column: "title", Weight: search.WeightA}, {Column: "description", Weight: search.WeightB}},
+		Joins:        "JOIN projects ON projects.id = issues.project_id",
+		TenantColumn: "projects.tenant_id",
+		SoftDeleted:  []string{"projects"},
+	},
+	{
+		Type:         "document",
+		Table:        "documents",
+		Title:        "name",
+		Fields:       []search.Field{{Column: "name", Weight: search.WeightA}, {Column: "file_url", Weight: search.WeightC}},
+		Joins:        "JOIN projects ON projects.id = documents.project_id",
+		TenantColumn: "projects.tenant_id",
+		SoftDeleted:  []string{"projects"},
+	},
+	{
+		Type:  "asset",
+		Table: "assets",
+		Title: "name",
+		Fields: []search.Field{
+			{Column: "name", Weight: search.WeightA},
+			{Column: "serial_number", Weight: search.WeightA},
+			{Column: "barcode", Weight: search.WeightA},
+			{Column: "model_number", Weight: search.WeightB},
+			{Column: "manufacturer", Weight: search.WeightB},
+			{Column: "description", Weight: search.WeightC},
+			{Column: "notes", Weight: search.WeightD},
+		},
+		TenantColumn: "assets.tenant_id",
+	},
+	{
+		Type:  "vendor",
+		Table: "vendors",
+		Title: "name",
+		Fields: []search.Field{
+			{Column: "name", Weight: search.WeightA},
+			{Column: "contact_name", Weight: search.WeightB},
+			{Column: "contact_email", Weight: search.WeightB},
+			{Column: "notes", Weight: search.WeightD},
+		},
+		TenantColumn: "vendors.tenant_id",
+	},
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SprintStatus represents the status of a sprint
+type SprintStatus string
+
+const (
+	SprintStatusPlanned SprintStatus = "planned"
+	SprintStatusActive  SprintStatus = "active"
+	SprintStatusClosed  SprintStatus = "closed"
+)
+
+// Sprint represents a time-boxed iteration of a project
+type Sprint struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	ProjectID       uint           `json:"project_id" gorm:"not null;index"`
+	Project         *Project       `json:"-" gorm:"foreignKey:ProjectID"`
+	Name            string         `json:"name" gorm:"size:100;not null"`
+	Goal            string         `json:"goal" gorm:"type:text"`
+	StartDate       time.Time      `json:"start_date" gorm:"not null"`
+	EndDate         time.Time      `json:"end_date" gorm:"not null"`
+	Status          SprintStatus   `json:"status" gorm:"size:20;not null;default:'planned'"`
+	ClosedAt        *time.Time     `json:"closed_at"`
+	CommittedPoints float64        `json:"committed_points"` // story points in the sprint at the end of its first day, set on close
+	CompletedPoints float64        `json:"completed_points"` // story points completed when the sprint closed
+	Tasks           []Task         `json:"tasks,omitempty" gorm:"foreignKey:SprintID"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TaskStateChange records the status, estimate and sprint of a task after a change.
+// Burndown, burnup and velocity are replayed from these records rather than from the
+// tasks' current state, so later edits do not rewrite history.
+type TaskStateChange struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	TaskID      uint       `json:"task_id" gorm:"not null;index"`
+	ProjectID   uint       `json:"project_id" gorm:"not null;index"`
+	SprintID    *uint      `json:"sprint_id" gorm:"index"`
+	Status      TaskStatus `json:"status" gorm:"size:20;not null"`
+	StoryPoints *float64   `json:"story_points"`
+	Done        bool       `json:"done"` // whether the status counted as done when the change was made
+	Deleted     bool       `json:"deleted"`
+	ChangedByID *uint      `json:"changed_by_id"`
+	ChangedBy   *Person    `json:"changed_by,omitempty" gorm:"foreignKey:ChangedByID"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"index"`
+}
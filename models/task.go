@@ -18,17 +18,39 @@ const (
 
 // Task represents a task in a project
 type Task struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	ProjectID    uint           `json:"project_id" gorm:"not null;index"`
-	Project      *Project       `json:"-" gorm:"foreignKey:ProjectID"`
-	Title        string         `json:"title" gorm:"size:200;not null"`
-	Description  string         `json:"description" gorm:"type:text"`
-	AssignedToID *uint          `json:"assigned_to_id" gorm:"index"`
-	AssignedTo   *Person        `json:"assigned_to" gorm:"foreignKey:AssignedToID"`
-	Status       TaskStatus     `json:"status" gorm:"size:20;not null;default:'todo'"`
-	DueDate      *time.Time     `json:"due_date"`
-	TimeEntries  []TimeTracking `json:"time_entries,omitempty" gorm:"foreignKey:TaskID"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	ProjectID      uint              `json:"project_id" gorm:"not null;index"`
+	Project        *Project          `json:"-" gorm:"foreignKey:ProjectID"`
+	Title          string            `json:"title" gorm:"size:200;not null"`
+	Description    string            `json:"description" gorm:"type:text"`
+	AssignedToID   *uint             `json:"assigned_to_id" gorm:"index"`
+	AssignedTo     *Person           `json:"assigned_to" gorm:"foreignKey:AssignedToID"`
+	Status         TaskStatus        `json:"status" gorm:"size:20;not null;default:'todo'"`
+	Rank           string            `json:"rank" gorm:"size:255;index"` // sort key within a board column, see package rank
+	StartDate      *time.Time        `json:"start_date"`                 // planned start, set by auto-scheduling
+	DueDate        *time.Time        `json:"due_date"`
+	RecurrenceID   *uint             `json:"recurrence_id" gorm:"index"` // series the task was generated from
+	SprintID       *uint             `json:"sprint_id" gorm:"index"`
+	StoryPoints    *float64          `json:"story_points"`
+	EstimatedHours *float64          `json:"estimated_hours"`           // original estimate
+	RemainingHours *float64          `json:"remaining_hours"`           // remaining estimate, reduced as time is logged
+	Type           string            `json:"type" gorm:"size:50;index"` // kind of work, such as bug or feature
+	CustomFields   CustomFieldValues `json:"custom_fields" gorm:"type:text;serializer:json"`
+	Labels         []*Label          `json:"labels,omitempty" gorm:"many2many:task_labels;"`
+	TimeEntries    []TimeTracking    `json:"time_entries,omitempty" gorm:"foreignKey:TaskID"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt    `json:"-" gorm:"index"`
+}
+
+// RemainingEstimate returns the hours of work left on the task: the remaining estimate, or
+// the original estimate until time is logged against it
+func (t *Task) RemainingEstimate() float64 {
+	if t.RemainingHours != nil {
+		return *t.RemainingHours
+	}
+	if t.EstimatedHours != nil {
+		return *t.EstimatedHours
+	}
+	return 0
 }
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectTemplate is a reusable snapshot of a project's milestones, tasks, KPIs and team.
+// Dates are stored as days from the project's start, so a project created from the
+// template has them shifted to its own start date.
+type ProjectTemplate struct {
+	ID              uint                `json:"id" gorm:"primaryKey"`
+	TenantID        uint                `json:"tenant_id" gorm:"not null;index"`
+	Tenant          *Tenant             `json:"-" gorm:"foreignKey:TenantID"`
+	Name            string              `json:"name" gorm:"size:200;not null"`
+	Description     string              `json:"description" gorm:"type:text"`
+	SourceProjectID *uint               `json:"source_project_id" gorm:"index"` // project the template was saved from
+	Budget          float64             `json:"budget"`
+	DurationDays    *int                `json:"duration_days"` // end date relative to the start; open-ended when not set
+	Tasks           []TemplateTask      `json:"tasks" gorm:"type:text;serializer:json"`
+	Milestones      []TemplateMilestone `json:"milestones" gorm:"type:text;serializer:json"`
+	KPIs            []TemplateKPI       `json:"kpis" gorm:"column:kpis;type:text;serializer:json"`
+	Members         []uint              `json:"members" gorm:"type:text;serializer:json"` // IDs of the people on the project
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt      `json:"-" gorm:"index"`
+}
+
+// TemplateTask is a task of a project template. Tasks are created in the workflow's initial state.
+type TemplateTask struct {
+	Title          string            `json:"title"`
+	Description    string            `json:"description"`
+	AssignedToID   *uint             `json:"assigned_to_id"`
+	StoryPoints    *float64          `json:"story_points"`
+	EstimatedHours *float64          `json:"estimated_hours"`
+	Type           string            `json:"type"`
+	CustomFields   CustomFieldValues `json:"custom_fields"`
+	DueOffsetDays  *int              `json:"due_offset_days"` // due date in days from the project's start
+}
+
+// TemplateMilestone is a milestone of a project template
+type TemplateMilestone struct {
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	DueOffsetDays int    `json:"due_offset_days"` // due date in days from the project's start
+}
+
+// TemplateKPI is a KPI of a project template. Its current value starts at the baseline.
+type TemplateKPI struct {
+	Description    string       `json:"description"`
+	Direction      KPIDirection `json:"direction"`
+	BaselineValue  float64      `json:"baseline_value"`
+	TargetValue    float64      `json:"target_value"`
+	RangeMin       *float64     `json:"range_min"`
+	RangeMax       *float64     `json:"range_max"`
+	AmberThreshold *float64     `json:"amber_threshold"`
+	RedThreshold   *float64     `json:"red_threshold"`
+	Unit           string       `json:"unit"`
+	SourceType     KPISource    `json:"source_type"`
+	Formula        string       `json:"formula"`
+}
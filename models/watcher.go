@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// WatchEntity represents the kind of record people can watch
+type WatchEntity string
+
+const (
+	WatchEntityProject     WatchEntity = "project"
+	WatchEntityTask        WatchEntity = "task"
+	WatchEntityIssue       WatchEntity = "issue"
+	WatchEntityProcurement WatchEntity = "procurement_request"
+)
+
+// Watcher is a person who is notified about changes to a record. Assignees, commenters and
+// requesters start watching the records they work on.
+type Watcher struct {
+	ID         uint        `json:"id" gorm:"primaryKey"`
+	TenantID   uint        `json:"tenant_id" gorm:"not null;index"`
+	Tenant     *Tenant     `json:"-" gorm:"foreignKey:TenantID"`
+	EntityType WatchEntity `json:"entity_type" gorm:"size:30;not null;uniqueIndex:idx_watcher"`
+	EntityID   uint        `json:"entity_id" gorm:"not null;uniqueIndex:idx_watcher"`
+	PersonID   uint        `json:"person_id" gorm:"not null;uniqueIndex:idx_watcher;index"`
+	Person     *Person     `json:"person,omitempty" gorm:"foreignKey:PersonID"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"github.com/Masozee/kontena/api/webhook"
+)
+
+// WebhookEvents lists the events endpoints can subscribe to
+var WebhookEvents = []string{
+	EventProjectCreated,
+	EventProjectUpdated,
+	EventTaskCreated,
+	EventTaskUpdated,
+	EventTaskDeleted,
+	EventCommentCreated,
+	EventAssetCreated,
+	EventAssetUpdated,
+	EventAssetDeleted,
+	EventAssetAssigned,
+	EventProcurementCreated,
+	EventProcurementStatusChanged,
+}
+
+// Webhook endpoints and their deliveries are kept by the webhook package, which both apps share
+type (
+	WebhookEndpoint       = webhook.Endpoint
+	WebhookDelivery       = webhook.Delivery
+	WebhookDeliveryStatus = webhook.DeliveryStatus
+)
+
+const (
+	WebhookDeliveryPending   = webhook.DeliveryPending
+	WebhookDeliverySucceeded = webhook.DeliverySucceeded
+	WebhookDeliveryFailed    = webhook.DeliveryFailed
+)
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Masozee/kontena/api/workflow"
+	"gorm.io/gorm"
+)
+
+// WorkflowEntityType represents the kind of record a workflow governs
+type WorkflowEntityType string
+
+const (
+	WorkflowEntityTask        WorkflowEntityType = "task"
+	WorkflowEntityIssue       WorkflowEntityType = "issue"
+	WorkflowEntityRisk        WorkflowEntityType = "risk"
+	WorkflowEntityProcurement WorkflowEntityType = "procurement_request"
+)
+
+// Workflow represents a tenant-defined state machine for the status of a kind of record.
+// A workflow without a project applies to every project of the tenant that has no workflow of its own.
+type Workflow struct {
+	ID          uint                 `json:"id" gorm:"primaryKey"`
+	TenantID    uint                 `json:"tenant_id" gorm:"not null;index"`
+	Tenant      *Tenant              `json:"-" gorm:"foreignKey:TenantID"`
+	ProjectID   *uint                `json:"project_id" gorm:"index"`
+	Project     *Project             `json:"-" gorm:"foreignKey:ProjectID"`
+	EntityType  WorkflowEntityType   `json:"entity_type" gorm:"size:50;not null;index"`
+	Name        string               `json:"name" gorm:"size:100;not null"`
+	States      []WorkflowState      `json:"states" gorm:"foreignKey:WorkflowID"`
+	Transitions []WorkflowTransition `json:"transitions" gorm:"foreignKey:WorkflowID"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt       `json:"-" gorm:"index"`
+}
+
+// WorkflowState represents one status in a workflow
+type WorkflowState struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	WorkflowID uint      `json:"workflow_id" gorm:"not null;index"`
+	Workflow   *Workflow `json:"-" gorm:"foreignKey:WorkflowID"`
+	Name       string    `json:"name" gorm:"size:50;not null"` // the status value stored on records
+	Label      string    `json:"label" gorm:"size:100"`
+	Initial    bool      `json:"initial" gorm:"default:false"`
+	Final      bool      `json:"final" gorm:"default:false"`
+	Position   int       `json:"position" gorm:"default:0"`
+}
+
+// WorkflowTransition represents an allowed move between two states of a workflow
+type WorkflowTransition struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	WorkflowID     uint      `json:"workflow_id" gorm:"not null;index"`
+	Workflow       *Workflow `json:"-" gorm:"foreignKey:WorkflowID"`
+	Name           string    `json:"name" gorm:"size:100;not null"`
+	FromState      string    `json:"from_state" gorm:"size:50;not null"` // "*" allows the transition from any state
+	ToState        string    `json:"to_state" gorm:"size:50;not null"`
+	RequiredFields string    `json:"required_fields" gorm:"size:255"` // comma separated JSON field names
+	GuardRoles     string    `json:"guard_roles" gorm:"size:255"`     // comma separated person roles
+}
+
+// Definition converts the stored workflow into a definition the workflow engine can validate against
+func (w *Workflow) Definition() workflow.Definition {
+	var def workflow.Definition
+	for _, state := range w.States {
+		def.States = append(def.States, workflow.State{
+			Name:    state.Name,
+			Initial: state.Initial,
+			Final:   state.Final,
+		})
+	}
+	for _, t := range w.Transitions {
+		def.Transitions = append(def.Transitions, workflow.Transition{
+			Name:           t.Name,
+			From:           t.FromState,
+			To:             t.ToState,
+			RequiredFields: workflow.SplitList(t.RequiredFields),
+			GuardRoles:     workflow.SplitList(t.GuardRoles),
+		})
+	}
+	return def
+}
+
+// DefaultWorkflow returns the built-in workflow used when a tenant has not defined one.
+// Tasks, issues and risks may move freely between their statuses; procurement requests
+// follow the draft, submitted, approved/rejected approval flow.
+func DefaultWorkflow(entityType WorkflowEntityType) workflow.Definition {
+	switch entityType {
+	case WorkflowEntityTask:
+		return workflow.Permissive(
+			string(TaskStatusTodo),
+			string(TaskStatusInProgress),
+			string(TaskStatusCompleted),
+			string(TaskStatusBlocked),
+		)
+	case WorkflowEntityIssue:
+		return workflow.Permissive(
+			string(IssueStatusOpen),
+			string(IssueStatusInProgress),
+			string(IssueStatusResolved),
+			string(IssueStatusClosed),
+		)
+	case WorkflowEntityRisk:
+		return workflow.Permissive(
+			string(RiskStatusIdentified),
+			string(RiskStatusMonitoring),
+			string(RiskStatusMitigated),
+			string(RiskStatusClosed),
+		)
+	case WorkflowEntityProcurement:
+		return workflow.Definition{
+			States: []workflow.State{
+				{Name: string(ProcurementStatusDraft), Initial: true},
+				{Name: string(ProcurementStatusSubmitted)},
+				{Name: string(ProcurementStatusApproved)},
+				{Name: string(ProcurementStatusRejected), Final: true},
+				{Name: string(ProcurementStatusOrdered)},
+				{Name: string(ProcurementStatusReceived), Final: true},
+				{Name: string(ProcurementStatusCancelled), Final: true},
+			},
+			Transitions: []workflow.Transition{
+				{Name: "submit", From: string(ProcurementStatusDraft), To: string(ProcurementStatusSubmitted)},
+				{Name: "cancel", From: string(ProcurementStatusDraft), To: string(ProcurementStatusCancelled)},
+				{Name: "approve", From: string(ProcurementStatusSubmitted), To: string(ProcurementStatusApproved), RequiredFields: []string{"approved_by_id"}},
+				{Name: "reject", From: string(ProcurementStatusSubmitted), To: string(ProcurementStatusRejected)},
+				{Name: "cancel", From: string(ProcurementStatusSubmitted), To: string(ProcurementStatusCancelled)},
+			},
+		}
+	}
+	return workflow.Definition{}
+}
@@ -0,0 +1,106 @@
+// Package rank generates lexicographically ordered keys for manually sorted lists.
+// A key can always be generated between two existing keys, so moving an item only
+// rewrites the key of the item that moved.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+// digits are the characters keys are made of, in sort order
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// ErrInvalidRange is returned when prev does not sort before next, or a key contains
+// characters outside the rank alphabet. Callers should rebalance the list with Spread.
+var ErrInvalidRange = errors.New("rank: no key fits between the given keys")
+
+// Between returns a key that sorts after prev and before next.
+// An empty prev means the start of the list and an empty next means the end.
+func Between(prev, next string) (string, error) {
+	if !valid(prev) || !valid(next) || (next != "" && prev >= next) {
+		return "", ErrInvalidRange
+	}
+
+	// Appending is the common case; bump the first digit that can still grow
+	// so keys stay short when items are added one after another
+	if next == "" && prev != "" {
+		for i := 0; i < len(prev); i++ {
+			if d := strings.IndexByte(digits, prev[i]); d < base-1 {
+				return prev[:i] + string(digits[d+1]), nil
+			}
+		}
+		return prev + string(digits[base/2]), nil
+	}
+
+	upper := next
+	var key []byte
+	for i := 0; ; i++ {
+		lo := 0
+		if i < len(prev) {
+			lo = strings.IndexByte(digits, prev[i])
+		}
+		hi := base
+		if upper != "" && i < len(upper) {
+			hi = strings.IndexByte(digits, upper[i])
+		}
+
+		if lo == hi {
+			key = append(key, digits[lo])
+			continue
+		}
+
+		if mid := (lo + hi) / 2; mid > lo {
+			key = append(key, digits[mid])
+			break
+		}
+
+		// The digits are adjacent; keep prev's digit and continue without an upper bound
+		key = append(key, digits[lo])
+		upper = ""
+	}
+
+	result := string(key)
+	if result <= prev || (next != "" && result >= next) {
+		return "", ErrInvalidRange
+	}
+	return result, nil
+}
+
+// Spread returns n evenly spaced keys of equal length, used to rebalance a list
+// whose keys are missing or have grown too long
+func Spread(n int) []string {
+	width := 1
+	for capacity := base; capacity <= n; capacity *= base {
+		width++
+	}
+
+	total := 1
+	for i := 0; i < width; i++ {
+		total *= base
+	}
+	step := total / (n + 1)
+
+	keys := make([]string, n)
+	for i := range keys {
+		value := (i + 1) * step
+		key := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			key[j] = digits[value%base]
+			value /= base
+		}
+		keys[i] = string(key)
+	}
+	return keys
+}
+
+func valid(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
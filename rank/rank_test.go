@@ -0,0 +1,88 @@
+package rank
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		prev, next string
+	}{
+		{"", ""},
+		{"i", ""},
+		{"zz", ""},
+		{"", "i"},
+		{"", "1"},
+		{"", "001"},
+		{"a", "b"},
+		{"a", "a1"},
+		{"az", "b"},
+		{"i", "j0i"},
+	}
+
+	for _, tt := range tests {
+		got, err := Between(tt.prev, tt.next)
+		if err != nil {
+			t.Errorf("Between(%q, %q) error = %v", tt.prev, tt.next, err)
+			continue
+		}
+		if got <= tt.prev || (tt.next != "" && got >= tt.next) {
+			t.Errorf("Between(%q, %q) = %q, not between", tt.prev, tt.next, got)
+		}
+	}
+}
+
+func TestBetweenInvalid(t *testing.T) {
+	for _, keys := range [][2]string{{"b", "a"}, {"a", "a"}, {"A", ""}} {
+		if _, err := Between(keys[0], keys[1]); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("Between(%q, %q) error = %v, want ErrInvalidRange", keys[0], keys[1], err)
+		}
+	}
+}
+
+func TestRepeatedInserts(t *testing.T) {
+	// Keep inserting at the front, the back and just after the first key
+	keys := []string{}
+	for i := 0; i < 300; i++ {
+		var prev, next string
+		switch i % 3 {
+		case 0:
+			if len(keys) > 0 {
+				prev = keys[len(keys)-1]
+			}
+		case 1:
+			if len(keys) > 0 {
+				next = keys[0]
+			}
+		case 2:
+			prev, next = keys[0], keys[1]
+		}
+
+		key, err := Between(prev, next)
+		if err != nil {
+			t.Fatalf("insert %d: Between(%q, %q) error = %v", i, prev, next, err)
+		}
+		keys = append(keys, key)
+		sort.Strings(keys)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	keys := Spread(100)
+	if len(keys) != 100 {
+		t.Fatalf("Spread(100) returned %d keys", len(keys))
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Errorf("Spread(100) keys are not sorted")
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			t.Errorf("Spread(100) returned duplicate key %q", keys[i])
+		}
+		if len(keys[i]) != len(keys[0]) {
+			t.Errorf("Spread(100) keys have different lengths")
+		}
+	}
+}
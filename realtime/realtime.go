@@ -0,0 +1,219 @@
+// Package realtime streams changes to clients as Server-Sent Events. A Hub fans published
+// messages out to the subscriptions of their tenant and channels, and keeps the latest ones so
+// a client that reconnects with the ID of the last message it saw gets the ones it missed.
+package realtime
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHistory is the number of messages a hub keeps for clients that reconnect
+	DefaultHistory = 1000
+	// DefaultHeartbeat is how often an idle stream sends a comment to keep the connection open
+	DefaultHeartbeat = 15 * time.Second
+	// retryMillis tells clients how long to wait before reconnecting
+	retryMillis = 3000
+	// bufferSize is the number of messages a subscription holds for a slow client
+	bufferSize = 64
+)
+
+// Message is a change published on one or more channels of a tenant
+type Message struct {
+	ID       uint64          `json:"id"`
+	TenantID uint            `json:"tenant_id"`
+	Channels []string        `json:"channels"`
+	Event    string          `json:"event"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Hub fans messages out to subscriptions. The zero value is not usable; use NewHub.
+type Hub struct {
+	Heartbeat time.Duration
+
+	mu            sync.Mutex
+	seq           uint64
+	history       []Message
+	historySize   int
+	subscriptions map[*Subscription]struct{}
+}
+
+// NewHub returns a hub that keeps the given number of messages for reconnecting clients.
+// Message IDs start from the current time in microseconds, so IDs a client saw before a
+// restart are lower than the IDs after it.
+func NewHub(historySize int) *Hub {
+	return &Hub{
+		Heartbeat:     DefaultHeartbeat,
+		seq:           uint64(time.Now().UnixMicro()),
+		historySize:   historySize,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the messages of one tenant's channels
+type Subscription struct {
+	hub      *Hub
+	tenantID uint
+	channels map[string]bool
+	messages chan Message
+	closed   bool
+}
+
+// Publish sends data, encoded as JSON, to the subscriptions of the tenant that subscribe to
+// any of the channels
+func (h *Hub) Publish(tenantID uint, channels []string, event string, data interface{}) (Message, error) {
+	var raw json.RawMessage
+	if encoded, ok := data.(json.RawMessage); ok {
+		raw = encoded
+	} else {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return Message{}, err
+		}
+		raw = encoded
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	msg := Message{ID: h.seq, TenantID: tenantID, Channels: channels, Event: event, Data: raw}
+	h.history = append(h.history, msg)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for s := range h.subscriptions {
+		if !s.wants(msg) {
+			continue
+		}
+		select {
+		case s.messages <- msg:
+		default:
+			// The client is not keeping up; it reconnects and catches up from the history
+			s.close()
+		}
+	}
+
+	return msg, nil
+}
+
+// Subscribe subscribes to channels of a tenant. With a lastID, the kept messages published
+// after it are returned, to be sent before the subscription's messages.
+func (h *Hub) Subscribe(tenantID uint, channels []string, lastID uint64) (*Subscription, []Message) {
+	s := &Subscription{
+		hub:      h,
+		tenantID: tenantID,
+		channels: make(map[string]bool, len(channels)),
+		messages: make(chan Message, bufferSize),
+	}
+	for _, channel := range channels {
+		s.channels[channel] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var missed []Message
+	if lastID > 0 {
+		for _, msg := range h.history {
+			if msg.ID > lastID && s.wants(msg) {
+				missed = append(missed, msg)
+			}
+		}
+	}
+	h.subscriptions[s] = struct{}{}
+	return s, missed
+}
+
+// Messages returns the channel the subscription's messages arrive on.
+// It is closed when the subscription is.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.close()
+}
+
+func (s *Subscription) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.hub.subscriptions, s)
+	close(s.messages)
+}
+
+func (s *Subscription) wants(msg Message) bool {
+	if msg.TenantID != s.tenantID {
+		return false
+	}
+	for _, channel := range msg.Channels {
+		if s.channels[channel] {
+			return true
+		}
+	}
+	return false
+}
+
+// Stream writes the missed messages and then the subscription's messages to w as Server-Sent
+// Events, with a heartbeat comment whenever it is idle, until the client goes away or the
+// subscription is closed. It closes the subscription when it returns.
+func Stream(w *bufio.Writer, s *Subscription, missed []Message, heartbeat time.Duration) {
+	defer s.Close()
+
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	for _, msg := range missed {
+		if err := WriteMessage(w, msg); err != nil {
+			return
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-s.Messages():
+			if !ok {
+				return
+			}
+			if err := WriteMessage(w, msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := w.WriteString(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// WriteMessage writes a message as a Server-Sent Event. The data is the message without its
+// ID, which is the event's id.
+func WriteMessage(w *bufio.Writer, msg Message) error {
+	data, err := json.Marshal(struct {
+		Event    string          `json:"event"`
+		Channels []string        `json:"channels"`
+		Data     json.RawMessage `json:"data"`
+	}{msg.Event, msg.Channels, msg.Data})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Event, data)
+	return err
+}
@@ -0,0 +1,106 @@
+package realtime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func received(s *Subscription) []string {
+	var events []string
+	for {
+		select {
+		case msg, ok := <-s.Messages():
+			if !ok {
+				return events
+			}
+			events = append(events, msg.Event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestHubIsolatesTenantsAndChannels(t *testing.T) {
+	hub := NewHub(10)
+	board, _ := hub.Subscribe(1, []string{"board:1"}, 0)
+	all, _ := hub.Subscribe(1, []string{"board:1", "asset:7"}, 0)
+	other, _ := hub.Subscribe(2, []string{"board:1", "asset:7"}, 0)
+	defer all.Close()
+	defer other.Close()
+
+	hub.Publish(1, []string{"project:1", "board:1"}, "task.created", map[string]int{"id": 1})
+	hub.Publish(1, []string{"asset:7"}, "asset.updated", map[string]int{"id": 7})
+	hub.Publish(2, []string{"asset:7"}, "asset.deleted", map[string]int{"id": 7})
+
+	assert.Equal(t, []string{"task.created"}, received(board))
+	assert.Equal(t, []string{"task.created", "asset.updated"}, received(all))
+	assert.Equal(t, []string{"asset.deleted"}, received(other), "tenants never see each other's messages")
+
+	board.Close()
+	board.Close()
+	_, err := hub.Publish(1, []string{"board:1"}, "task.updated", nil)
+	assert.NoError(t, err, "closed subscriptions are skipped")
+}
+
+func TestHubResumesAfterLastID(t *testing.T) {
+	hub := NewHub(3)
+	first, _ := hub.Publish(1, []string{"assets"}, "asset.created", nil)
+	hub.Publish(2, []string{"assets"}, "asset.created", nil)
+	hub.Publish(1, []string{"assets"}, "asset.updated", nil)
+	hub.Publish(1, []string{"asset:1"}, "asset.assigned", nil)
+
+	s, missed := hub.Subscribe(1, []string{"assets"}, first.ID)
+	defer s.Close()
+	assert.Len(t, missed, 1)
+	assert.Equal(t, "asset.updated", missed[0].Event)
+
+	// Only the latest messages are kept
+	_, missed = hub.Subscribe(1, []string{"assets"}, first.ID-1)
+	assert.Len(t, missed, 1)
+
+	// IDs keep growing across hubs, e.g. after a restart
+	assert.Greater(t, NewHub(3).seq, first.ID)
+}
+
+func TestHubDropsSlowSubscriptions(t *testing.T) {
+	hub := NewHub(DefaultHistory)
+	s, _ := hub.Subscribe(1, []string{"assets"}, 0)
+	for i := 0; i < bufferSize+1; i++ {
+		hub.Publish(1, []string{"assets"}, "asset.updated", nil)
+	}
+
+	assert.Len(t, received(s), bufferSize)
+	_, ok := <-s.Messages()
+	assert.False(t, ok, "the subscription is closed so the client reconnects")
+}
+
+func TestStreamWritesEventsAndHeartbeats(t *testing.T) {
+	hub := NewHub(10)
+	missedMsg, _ := hub.Publish(1, []string{"board:1"}, "task.created", json.RawMessage(`{"id": 1}`))
+	s, missed := hub.Subscribe(1, []string{"board:1"}, missedMsg.ID-1)
+
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		Stream(bufio.NewWriter(&out), s, missed, 5*time.Millisecond)
+		close(done)
+	}()
+
+	live, _ := hub.Publish(1, []string{"board:1"}, "task.updated", map[string]string{"title": "Launch"})
+	time.Sleep(30 * time.Millisecond)
+	s.Close()
+	<-done
+
+	stream := out.String()
+	assert.True(t, strings.HasPrefix(stream, "retry: 3000\n\n"))
+	assert.Contains(t, stream, "id: "+strconv.FormatUint(missedMsg.ID, 10)+"\nevent: task.created\ndata: {\"event\":\"task.created\",\"channels\":[\"board:1\"],\"data\":{\"id\":1}}\n\n")
+	assert.Contains(t, stream, "id: "+strconv.FormatUint(live.ID, 10)+"\nevent: task.updated\n")
+	assert.Contains(t, stream, ": heartbeat\n\n")
+}
@@ -0,0 +1,356 @@
+// Package recurrence parses RFC 5545 recurrence rules and computes their occurrences.
+// It supports the subset of RRULE used for recurring work: FREQ (DAILY, WEEKLY,
+// MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the base unit a rule repeats over
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds the search for the next occurrence so impossible rules terminate
+const maxPeriods = 50000
+
+// ErrInvalidRule is wrapped by every parse error
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// WeekdayNum is a BYDAY entry, such as MO or -1FR (the last Friday)
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int // 0 means every such weekday in the period
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int       // 0 means unlimited
+	Until      time.Time // zero means unlimited
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE". The "RRULE:" prefix is optional.
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(strings.ToUpper(value), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("%w: rule is empty", ErrInvalidRule)
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+		key, val := kv[0], kv[1]
+
+		switch key {
+		case "FREQ":
+			switch Frequency(val) {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = Frequency(val)
+			default:
+				return nil, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalidRule, val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive number", ErrInvalidRule)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive number", ErrInvalidRule)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				day, err := parseWeekdayNum(item)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("%w: invalid BYMONTHDAY %s", ErrInvalidRule, item)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, item := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("%w: invalid BYMONTH %s", ErrInvalidRule, item)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			// Weeks always start on Monday, the RFC 5545 default
+			if _, ok := weekdays[val]; !ok {
+				return nil, fmt.Errorf("%w: invalid WKST %s", ErrInvalidRule, val)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %s", ErrInvalidRule, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot both be set", ErrInvalidRule)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, fmt.Errorf("%w: numbered BYDAY is only allowed with FREQ=MONTHLY or YEARLY", ErrInvalidRule)
+		}
+	}
+	if rule.Freq == Yearly && len(rule.ByDay) > 0 && len(rule.ByMonth) == 0 {
+		return nil, fmt.Errorf("%w: BYDAY with FREQ=YEARLY requires BYMONTH", ErrInvalidRule)
+	}
+	if rule.Freq == Weekly && len(rule.ByMonthDay) > 0 {
+		return nil, fmt.Errorf("%w: BYMONTHDAY is not allowed with FREQ=WEEKLY", ErrInvalidRule)
+	}
+
+	return rule, nil
+}
+
+// Next returns the first occurrence of the series starting at start that is strictly
+// after the given time. ok is false when the series has ended.
+// Occurrences keep the time of day and location of start.
+func (r *Rule) Next(start, after time.Time) (next time.Time, ok bool) {
+	count := 0
+	for period := 0; period < maxPeriods; period++ {
+		for _, t := range r.candidates(start, period) {
+			if t.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return time.Time{}, false
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if t.After(after) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// candidates returns the sorted occurrences the rule produces in the given period
+func (r *Rule) candidates(start time.Time, period int) []time.Time {
+	y, m, d := start.Date()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := at(y, m, d+period*r.Interval)
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+	case Weekly:
+		offset := (int(start.Weekday()) + 6) % 7 // days since Monday
+		monday := at(y, m, d-offset+period*r.Interval*7)
+		if len(r.ByDay) == 0 {
+			days = append(days, monday.AddDate(0, 0, offset))
+		}
+		for _, wd := range r.ByDay {
+			days = append(days, monday.AddDate(0, 0, (int(wd.Weekday)+6)%7))
+		}
+	case Monthly:
+		first := at(y, m+time.Month(period*r.Interval), 1)
+		days = r.monthDays(first, d)
+	case Yearly:
+		year := y + period*r.Interval
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			days = append(days, r.monthDays(at(year, month, 1), d)...)
+		}
+	}
+
+	var result []time.Time
+	for _, day := range days {
+		if r.matchesMonth(day) {
+			result = append(result, day)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result
+}
+
+// monthDays returns the occurrences within the month that starts at first.
+// defaultDay is used when the rule has neither BYMONTHDAY nor BYDAY.
+func (r *Rule) monthDays(first time.Time, defaultDay int) []time.Time {
+	length := first.AddDate(0, 1, -1).Day()
+
+	var days []time.Time
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		// Months without the day are skipped, as in RFC 5545
+		if defaultDay <= length {
+			days = append(days, first.AddDate(0, 0, defaultDay-1))
+		}
+		return days
+	}
+
+	for day := 1; day <= length; day++ {
+		t := first.AddDate(0, 0, day-1)
+		if len(r.ByMonthDay) > 0 && !r.matchesMonthDay(t) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !r.matchesNumberedWeekday(t, length) {
+			continue
+		}
+		days = append(days, t)
+	}
+	return days
+}
+
+func (r *Rule) matchesMonth(t time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if t.Month() == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, day := range r.ByMonthDay {
+		if day == t.Day() || (day < 0 && length+day+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNumberedWeekday checks BYDAY entries like 2TU or -1FR within a month of the given length
+func (r *Rule) matchesNumberedWeekday(t time.Time, length int) bool {
+	for _, wd := range r.ByDay {
+		if wd.Weekday != t.Weekday() {
+			continue
+		}
+		switch {
+		case wd.N == 0:
+			return true
+		case wd.N > 0 && (t.Day()-1)/7+1 == wd.N:
+			return true
+		case wd.N < 0 && (length-t.Day())/7+1 == -wd.N:
+			return true
+		}
+	}
+	return false
+}
+
+// NextWeekday moves a Saturday or Sunday forward to the following Monday
+func NextWeekday(t time.Time) time.Time {
+	switch t.Weekday() {
+	case time.Saturday:
+		return t.AddDate(0, 0, 2)
+	case time.Sunday:
+		return t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	if len(value) < 2 {
+		return WeekdayNum{}, fmt.Errorf("%w: invalid BYDAY %s", ErrInvalidRule, value)
+	}
+
+	weekday, ok := weekdays[value[len(value)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("%w: invalid BYDAY %s", ErrInvalidRule, value)
+	}
+
+	n := 0
+	if prefix := value[:len(value)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("%w: invalid BYDAY %s", ErrInvalidRule, value)
+		}
+	}
+
+	return WeekdayNum{Weekday: weekday, N: n}, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// A date-only UNTIL includes the whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: invalid UNTIL %s", ErrInvalidRule, value)
+}
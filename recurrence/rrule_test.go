@@ -0,0 +1,131 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+}
+
+// occurrences returns the first n occurrences of rule starting at start
+func occurrences(t *testing.T, rule string, start time.Time, n int) []time.Time {
+	r, err := Parse(rule)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", rule, err)
+	}
+
+	var result []time.Time
+	after := start.Add(-time.Second)
+	for len(result) < n {
+		next, ok := r.Next(start, after)
+		if !ok {
+			break
+		}
+		result = append(result, next)
+		after = next
+	}
+	return result
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   string
+		start  time.Time
+		want   []time.Time
+		finite bool
+	}{
+		{
+			name:  "daily",
+			rule:  "FREQ=DAILY;INTERVAL=2",
+			start: date(2024, time.January, 30),
+			want:  []time.Time{date(2024, time.January, 30), date(2024, time.February, 1), date(2024, time.February, 3)},
+		},
+		{
+			name:  "weekly on monday and thursday",
+			rule:  "RRULE:FREQ=WEEKLY;BYDAY=MO,TH",
+			start: date(2024, time.March, 5), // a Tuesday
+			want:  []time.Time{date(2024, time.March, 7), date(2024, time.March, 11), date(2024, time.March, 14)},
+		},
+		{
+			name:  "monthly skips short months",
+			rule:  "FREQ=MONTHLY",
+			start: date(2024, time.January, 31),
+			want:  []time.Time{date(2024, time.January, 31), date(2024, time.March, 31), date(2024, time.May, 31)},
+		},
+		{
+			name:  "last friday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			start: date(2024, time.January, 1),
+			want:  []time.Time{date(2024, time.January, 26), date(2024, time.February, 23), date(2024, time.March, 29)},
+		},
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: date(2024, time.January, 1),
+			want:  []time.Time{date(2024, time.January, 31), date(2024, time.February, 29), date(2024, time.March, 31)},
+		},
+		{
+			name:   "quarterly with count",
+			rule:   "FREQ=MONTHLY;INTERVAL=3;COUNT=2",
+			start:  date(2024, time.January, 15),
+			want:   []time.Time{date(2024, time.January, 15), date(2024, time.April, 15)},
+			finite: true,
+		},
+		{
+			name:   "yearly until",
+			rule:   "FREQ=YEARLY;BYMONTH=6;BYDAY=1MO;UNTIL=20251231",
+			start:  date(2024, time.January, 1),
+			want:   []time.Time{date(2024, time.June, 3), date(2025, time.June, 2)},
+			finite: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Ask finite series for one more occurrence than they have to check that they end
+			n := len(tt.want)
+			if tt.finite {
+				n++
+			}
+			got := occurrences(t, tt.rule, tt.start, n)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d occurrences %v, want %v", len(got), got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=DAILY;COUNT=3;UNTIL=20250101",
+		"FREQ=DAILY;BYSETPOS=1",
+	} {
+		if _, err := Parse(rule); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidRule", rule, err)
+		}
+	}
+}
+
+func TestNextWeekday(t *testing.T) {
+	if got := NextWeekday(date(2024, time.March, 9)); !got.Equal(date(2024, time.March, 11)) {
+		t.Errorf("NextWeekday(Saturday) = %v", got)
+	}
+	if got := NextWeekday(date(2024, time.March, 12)); !got.Equal(date(2024, time.March, 12)) {
+		t.Errorf("NextWeekday(Tuesday) = %v", got)
+	}
+}
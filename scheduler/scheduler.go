@@ -0,0 +1,36 @@
+// Package scheduler runs background jobs of the project management API at a fixed interval.
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// Job is a unit of background work. Run receives the time of the tick it runs for.
+type Job struct {
+	Name string
+	Run  func(now time.Time) error
+}
+
+// Start runs every job once immediately and then once per interval, in a background goroutine.
+// Jobs run one after another, so a slow job delays the others rather than overlapping itself.
+func Start(interval time.Duration, jobs ...Job) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			RunOnce(time.Now(), jobs...)
+			<-ticker.C
+		}
+	}()
+}
+
+// RunOnce runs every job for the given time, logging failures
+func RunOnce(now time.Time, jobs ...Job) {
+	for _, job := range jobs {
+		if err := job.Run(now); err != nil {
+			log.Printf("Scheduled job %s failed: %v", job.Name, err)
+		}
+	}
+}
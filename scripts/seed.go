@@ -207,7 +207,8 @@ func createKPIs(project models.Project) []models.KPI {
 		{
 			ProjectID:    project.ID,
 			Description:  "Stay within budget",
-			TargetValue:  project.Budget,
+			Direction:    models.KPIDirectionRange,
+			RangeMax:     &project.Budget,
 			CurrentValue: project.Budget * 0.3,
 			Unit:         "$",
 		},
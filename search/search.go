@@ -0,0 +1,427 @@
+// Package search runs full-text searches over database tables. On PostgreSQL it matches
+// weighted tsvector expressions, which CreateIndexes backs with GIN indexes, and highlights
+// matches with ts_headline. Other databases, such as the SQLite used in tests, fall back to
+// substring matching with ranking and highlighting done in Go.
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Config is the PostgreSQL text search configuration documents and queries are parsed with
+	Config = "english"
+	// StartSel and StopSel surround matches in highlights
+	StartSel = "<mark>"
+	StopSel  = "</mark>"
+	// highlightRunes is about how long a highlight of the fallback is
+	highlightRunes = 160
+)
+
+// Weight is the importance of a field, from A (most) to D, as in PostgreSQL's setweight
+type Weight byte
+
+const (
+	WeightA Weight = 'A'
+	WeightB Weight = 'B'
+	WeightC Weight = 'C'
+	WeightD Weight = 'D'
+)
+
+// rank is what a match in a field of the weight adds to a result's rank in the fallback.
+// They are PostgreSQL's default weights for ts_rank.
+func (w Weight) rank() float64 {
+	switch w {
+	case WeightA:
+		return 1.0
+	case WeightB:
+		return 0.4
+	case WeightC:
+		return 0.2
+	}
+	return 0.1
+}
+
+// Field is a searched column of a source
+type Field struct {
+	Column string
+	Weight Weight
+}
+
+// Source is a table searched for one type of result. Its rows are scoped to a tenant by the
+// TenantColumn, which may be in a table joined by Joins; soft-deleted rows are left out.
+type Source struct {
+	Type         string   // type of the results, e.g. "task"
+	Table        string   // table searched
+	Title        string   // column used as the results' title
+	Fields       []Field  // columns searched, most important first
+	Joins        string   // joins needed to reach the tenant column
+	TenantColumn string   // qualified column holding the tenant ID, e.g. "projects.tenant_id"
+	SoftDeleted  []string // joined tables whose soft-deleted rows also hide a row
+}
+
+// Result is a row that matched a search
+type Result struct {
+	Type      string  `json:"type"`
+	ID        uint    `json:"id"`
+	Title     string  `json:"title"`
+	Highlight string  `json:"highlight"`
+	Rank      float64 `json:"rank"`
+}
+
+// Terms splits a query into the lowercase words it searches for. Anything that is not a
+// letter or a digit separates words, so terms are safe to put in a tsquery or LIKE pattern.
+func Terms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	terms := words[:0]
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// Only returns the sources of the given result types, or all of them when no types are given
+func Only(sources []Source, types []string) ([]Source, error) {
+	if len(types) == 0 {
+		return sources, nil
+	}
+
+	wanted := make(map[string]bool, len(types))
+	for _, t := range types {
+		wanted[t] = true
+	}
+	var selected []Source
+	for _, source := range sources {
+		if wanted[source.Type] {
+			selected = append(selected, source)
+			delete(wanted, source.Type)
+		}
+	}
+	for _, t := range types {
+		if wanted[t] {
+			return nil, fmt.Errorf("unknown result type: %s", t)
+		}
+	}
+	return selected, nil
+}
+
+// Search returns up to limit results of the tenant's rows matching every term of the query,
+// best ranked first
+func Search(db *gorm.DB, tenantID uint, query string, sources []Source, limit int) ([]Result, error) {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return []Result{}, nil
+	}
+
+	results := []Result{}
+	for _, source := range sources {
+		var found []Result
+		var err error
+		if isPostgres(db) {
+			found, err = searchPostgres(db, tenantID, terms, source, limit)
+		} else {
+			found, err = searchFallback(db, tenantID, terms, source, limit)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("search %s: %w", source.Type, err)
+		}
+		results = append(results, found...)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// CreateIndexes creates the GIN indexes that back the searches of the sources on PostgreSQL.
+// Sources whose table does not exist are skipped. It does nothing on other databases.
+func CreateIndexes(db *gorm.DB, sources []Source) error {
+	if !isPostgres(db) {
+		return nil
+	}
+	for _, source := range sources {
+		if !db.Migrator().HasTable(source.Table) {
+			continue
+		}
+		sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_search ON %s USING GIN ((%s))",
+			source.Table, source.Table, vector(source, ""))
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("index %s: %w", source.Table, err)
+		}
+	}
+	return nil
+}
+
+func isPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
+
+// scope selects the tenant's rows of a source that are not deleted
+func scope(db *gorm.DB, tenantID uint, source Source) *gorm.DB {
+	query := db.Table(source.Table)
+	if source.Joins != "" {
+		query = query.Joins(source.Joins)
+	}
+	query = query.Where(source.TenantColumn+" = ?", tenantID)
+	for _, table := range append([]string{source.Table}, source.SoftDeleted...) {
+		query = query.Where(table + ".deleted_at IS NULL")
+	}
+	return query
+}
+
+// vector is the weighted tsvector of a source's fields, with columns qualified by table.
+// It must match the indexed expression exactly for PostgreSQL to use the index.
+func vector(source Source, table string) string {
+	parts := make([]string, len(source.Fields))
+	for i, field := range source.Fields {
+		parts[i] = fmt.Sprintf("setweight(to_tsvector('%s', coalesce(%s, '')), '%c')",
+			Config, column(table, field.Column), field.Weight)
+	}
+	return strings.Join(parts, " || ")
+}
+
+func column(table, name string) string {
+	if table == "" {
+		return name
+	}
+	return table + "." + name
+}
+
+// tsquery matches rows with every term, each as a prefix
+func tsquery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+func searchPostgres(db *gorm.DB, tenantID uint, terms []string, source Source, limit int) ([]Result, error) {
+	query := tsquery(terms)
+	document := vector(source, source.Table)
+	bodyColumns := make([]string, len(source.Fields))
+	for i, field := range source.Fields {
+		bodyColumns[i] = column(source.Table, field.Column)
+	}
+
+	hits := scope(db, tenantID, source).
+		Select(fmt.Sprintf("%s.id, %s AS title, concat_ws(' ', %s) AS body, ts_rank(%s, to_tsquery('%s', ?)) AS rank",
+			source.Table, column(source.Table, source.Title), strings.Join(bodyColumns, ", "), document, Config), query).
+		Where(fmt.Sprintf("(%s) @@ to_tsquery('%s', ?)", document, Config), query).
+		Order("rank DESC").
+		Limit(limit)
+
+	headline := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2", StartSel, StopSel)
+	var results []Result
+	err := db.Table("(?) AS hits", hits).
+		Select(fmt.Sprintf("id, title, ts_headline('%s', body, to_tsquery('%s', ?), ?) AS highlight, rank", Config, Config),
+			query, headline).
+		Order("rank DESC").
+		Find(&results).Error
+	for i := range results {
+		results[i].Type = source.Type
+	}
+	return results, err
+}
+
+func searchFallback(db *gorm.DB, tenantID uint, terms []string, source Source, limit int) ([]Result, error) {
+	columns := []string{source.Table + ".id", column(source.Table, source.Title)}
+	for _, field := range source.Fields {
+		columns = append(columns, "coalesce("+column(source.Table, field.Column)+", '')")
+	}
+
+	query := scope(db, tenantID, source).Select(strings.Join(columns, ", "))
+	for _, term := range terms {
+		conditions := make([]string, len(source.Fields))
+		args := make([]interface{}, len(source.Fields))
+		for i, field := range source.Fields {
+			conditions[i] = "LOWER(" + column(source.Table, field.Column) + ") LIKE ?"
+			args[i] = "%" + term + "%"
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Result
+	for rows.Next() {
+		var result Result
+		values := make([]string, len(source.Fields))
+		dest := []interface{}{&result.ID, &result.Title}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		result.Type = source.Type
+		for i, field := range source.Fields {
+			result.Rank += fieldRank(values[i], terms, field.Weight)
+			if result.Highlight == "" && matches(values[i], terms) {
+				result.Highlight = highlight(values[i], terms)
+			}
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// fieldRank adds up the weight of a field for every term it contains, twice when the term
+// starts a word
+func fieldRank(text string, terms []string, weight Weight) float64 {
+	text = strings.ToLower(text)
+	var rank float64
+	for _, term := range terms {
+		found := indexes(text, term)
+		if len(found) == 0 {
+			continue
+		}
+		rank += weight.rank()
+		for _, i := range found {
+			if i == 0 || !isWordRune(lastRune(text[:i])) {
+				rank += weight.rank()
+				break
+			}
+		}
+	}
+	return rank
+}
+
+func matches(text string, terms []string) bool {
+	text = strings.ToLower(text)
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight surrounds the terms in text with StartSel and StopSel, and cuts long text down
+// to the part around the first match
+func highlight(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Lowercasing changed byte offsets; matches cannot be marked reliably
+		return shorten(text, 0)
+	}
+
+	marked := make([]bool, len(text))
+	first := len(text)
+	for _, term := range terms {
+		for _, i := range indexes(lower, term) {
+			for j := i; j < i+len(term); j++ {
+				marked[j] = true
+			}
+			if i < first {
+				first = i
+			}
+		}
+	}
+
+	start, end := window(text, first)
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString(StartSel)
+		}
+		b.WriteByte(text[i])
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteString(StopSel)
+		}
+	}
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// shorten cuts text down to about highlightRunes runes starting near offset
+func shorten(text string, offset int) string {
+	start, end := window(text, offset)
+	out := text[start:end]
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(text) {
+		out += "…"
+	}
+	return out
+}
+
+// window returns the byte range of about highlightRunes runes of text around offset,
+// starting a little before it
+func window(text string, offset int) (int, int) {
+	if utf8.RuneCountInString(text) <= highlightRunes {
+		return 0, len(text)
+	}
+
+	start := offset
+	for n := 0; start > 0 && n < highlightRunes/4; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := start
+	for n := 0; end < len(text) && n < highlightRunes; n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	return start, end
+}
+
+// indexes returns the byte offsets of the occurrences of term in text
+func indexes(text, term string) []int {
+	var found []int
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], term)
+		if i < 0 {
+			return found
+		}
+		found = append(found, offset+i)
+		offset += i + len(term)
+	}
+}
+
+func lastRune(text string) rune {
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return r
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
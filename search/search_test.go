@@ -0,0 +1,128 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testSources = []Source{
+	{
+		Type:         "project",
+		Table:        "projects",
+		Title:        "name",
+		Fields:       []Field{{"name", WeightA}, {"description", WeightB}},
+		TenantColumn: "projects.tenant_id",
+	},
+	{
+		Type:         "task",
+		Table:        "tasks",
+		Title:        "title",
+		Fields:       []Field{{"title", WeightA}, {"description", WeightB}},
+		Joins:        "JOIN projects ON projects.id = tasks.project_id",
+		TenantColumn: "projects.tenant_id",
+		SoftDeleted:  []string{"projects"},
+	},
+}
+
+func setupDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to in-memory database: %v", err)
+	}
+
+	db.Exec("CREATE TABLE projects (id INTEGER PRIMARY KEY, tenant_id INTEGER, name TEXT, description TEXT, deleted_at DATETIME)")
+	db.Exec("CREATE TABLE tasks (id INTEGER PRIMARY KEY, project_id INTEGER, title TEXT, description TEXT, deleted_at DATETIME)")
+	db.Exec(`INSERT INTO projects (id, tenant_id, name, description, deleted_at) VALUES
+		(1, 1, 'Office move', 'Move every laptop to the new office', NULL),
+		(2, 2, 'Laptop refresh', 'Other tenant', NULL),
+		(3, 1, 'Laptop audit', 'Deleted', '2024-01-01')`)
+	db.Exec(`INSERT INTO tasks (id, project_id, title, description, deleted_at) VALUES
+		(1, 1, 'Order laptops', NULL, NULL),
+		(2, 1, 'Pack desks', 'Label each desk', NULL),
+		(3, 3, 'Count laptops', 'In a deleted project', NULL)`)
+	return db
+}
+
+func types(results []Result) []string {
+	var found []string
+	for _, result := range results {
+		found = append(found, result.Type+":"+result.Title)
+	}
+	return found
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"sn", "1234", "dell", "laptop"}, Terms("SN-1234 Dell (laptop) dell"))
+	assert.Empty(t, Terms(" %_' "))
+}
+
+func TestSearchFallbackRanksAndHighlights(t *testing.T) {
+	db := setupDB(t)
+
+	results, err := Search(db, 1, "Laptop", testSources, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"task:Order laptops", "project:Office move"}, types(results),
+		"title matches rank first; other tenants and deleted rows are left out")
+	assert.Equal(t, "Order <mark>laptop</mark>s", results[0].Highlight)
+	assert.Equal(t, "Move every <mark>laptop</mark> to the new office", results[1].Highlight)
+
+	// Every term has to match, in any field
+	results, err = Search(db, 1, "pack label", testSources, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"task:Pack desks"}, types(results))
+	results, err = Search(db, 1, "pack laptop", testSources, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, err = Search(db, 1, "o", testSources, 1)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestHighlightShortensLongText(t *testing.T) {
+	text := strings.Repeat("filler ", 60) + "the serial SN1234 is here " + strings.Repeat("filler ", 60)
+	got := highlight(text, []string{"sn1234"})
+	assert.True(t, strings.HasPrefix(got, "…"))
+	assert.True(t, strings.HasSuffix(got, "…"))
+	assert.Contains(t, got, "serial <mark>SN1234</mark> is")
+	assert.Less(t, len(got), len(text)/2)
+}
+
+type recorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *recorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+func TestPostgresSearchUsesIndexedVector(t *testing.T) {
+	rec := &recorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun: true, DisableAutomaticPing: true, Logger: rec,
+	})
+	assert.NoError(t, err)
+
+	_, err = Search(db, 1, "Dell laptop", testSources[1:], 5)
+	assert.NoError(t, err)
+
+	// The searched expression is the one CreateIndexes indexes, qualified by table
+	indexed := "setweight(to_tsvector('english', coalesce(title, '')), 'A') || setweight(to_tsvector('english', coalesce(description, '')), 'B')"
+	assert.Equal(t, indexed, vector(testSources[1], ""))
+	query := rec.statements[0]
+	assert.Contains(t, query, "("+vector(testSources[1], "tasks")+") @@ to_tsquery('english', 'dell:* & laptop:*')")
+	assert.Contains(t, vector(testSources[1], "tasks"), "coalesce(tasks.title, '')")
+	assert.Contains(t, query, "projects.tenant_id = 1")
+	assert.Contains(t, query, "projects.deleted_at IS NULL")
+	assert.Contains(t, query, "ts_headline('english', body, to_tsquery('english', 'dell:* & laptop:*'), 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2')")
+}
@@ -0,0 +1,152 @@
+// Package timeseries aggregates timestamped measurements into calendar buckets and
+// fits a linear trend to them, to chart progress and forecast when a target is reached.
+package timeseries
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Interval is the width of an aggregation bucket
+type Interval string
+
+const (
+	Daily   Interval = "daily"
+	Weekly  Interval = "weekly" // weeks start on Monday
+	Monthly Interval = "monthly"
+)
+
+// ErrInvalidInterval is returned by ParseInterval for unknown intervals
+var ErrInvalidInterval = errors.New("invalid interval: must be daily, weekly or monthly")
+
+// ParseInterval parses daily, weekly or monthly
+func ParseInterval(s string) (Interval, error) {
+	switch Interval(s) {
+	case Daily, Weekly, Monthly:
+		return Interval(s), nil
+	}
+	return "", ErrInvalidInterval
+}
+
+// Start returns the start of the bucket containing t, in t's location
+func (i Interval) Start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch i {
+	case Weekly:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	case Monthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// Point is a value measured at a time
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Bucket summarises the points that fall in one interval
+type Bucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+	Last  float64   `json:"last"` // the latest value in the bucket
+}
+
+// Aggregate groups points, which must be ordered by time, into buckets of the interval.
+// Intervals without points are left out.
+func Aggregate(points []Point, interval Interval) []Bucket {
+	var buckets []Bucket
+	sum := 0.0
+	for _, p := range points {
+		start := interval.Start(p.Time)
+		if len(buckets) == 0 || !buckets[len(buckets)-1].Start.Equal(start) {
+			buckets = append(buckets, Bucket{Start: start, Min: p.Value, Max: p.Value})
+			sum = 0
+		}
+		b := &buckets[len(buckets)-1]
+		b.Count++
+		b.Min = math.Min(b.Min, p.Value)
+		b.Max = math.Max(b.Max, p.Value)
+		b.Last = p.Value
+		sum += p.Value
+		b.Avg = sum / float64(b.Count)
+	}
+	return buckets
+}
+
+// Trend is a least-squares line through a series of points
+type Trend struct {
+	SlopePerDay float64   `json:"slope_per_day"` // change in value per day
+	Origin      time.Time `json:"-"`
+	Intercept   float64   `json:"-"` // value of the line at Origin
+}
+
+// LinearTrend fits a line to the points. It reports false when there are fewer than two
+// points or they were all measured at the same time.
+func LinearTrend(points []Point) (Trend, bool) {
+	if len(points) < 2 {
+		return Trend{}, false
+	}
+
+	origin := points[0].Time
+	n := float64(len(points))
+	var sumX, sumY float64
+	for _, p := range points {
+		sumX += days(p.Time.Sub(origin))
+		sumY += p.Value
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, variance float64
+	for _, p := range points {
+		dx := days(p.Time.Sub(origin)) - meanX
+		cov += dx * (p.Value - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return Trend{}, false
+	}
+
+	slope := cov / variance
+	return Trend{
+		SlopePerDay: slope,
+		Origin:      origin,
+		Intercept:   meanY - slope*meanX,
+	}, true
+}
+
+// At returns the value of the trend line at t
+func (t Trend) At(at time.Time) float64 {
+	return t.Intercept + t.SlopePerDay*days(at.Sub(t.Origin))
+}
+
+// Forecast returns when a value moving from the given point at the trend's rate reaches the
+// target. It reports false when the trend is flat or moving away from the target.
+func (t Trend) Forecast(from Point, target float64) (time.Time, bool) {
+	if from.Value == target {
+		return from.Time, true
+	}
+	if t.SlopePerDay == 0 {
+		return time.Time{}, false
+	}
+
+	remaining := (target - from.Value) / t.SlopePerDay
+	if remaining < 0 || remaining > maxForecastDays {
+		return time.Time{}, false
+	}
+	return from.Time.Add(time.Duration(remaining * float64(24*time.Hour))), true
+}
+
+// maxForecastDays keeps forecasts within the range time.Duration can represent
+const maxForecastDays = 100 * 365
+
+func days(d time.Duration) float64 {
+	return d.Hours() / 24
+}
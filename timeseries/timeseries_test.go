@@ -0,0 +1,104 @@
+package timeseries
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, time.March, d, 12, 0, 0, 0, time.UTC)
+}
+
+func TestIntervalStart(t *testing.T) {
+	// Thursday 7 March 2024
+	at := time.Date(2024, time.March, 7, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		interval Interval
+		want     time.Time
+	}{
+		{Daily, time.Date(2024, time.March, 7, 0, 0, 0, 0, time.UTC)},
+		{Weekly, time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)},
+		{Monthly, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.interval.Start(at); !got.Equal(tt.want) {
+			t.Errorf("%s.Start() = %v, want %v", tt.interval, got, tt.want)
+		}
+	}
+
+	// Sunday belongs to the week that started the previous Monday
+	sunday := time.Date(2024, time.March, 10, 8, 0, 0, 0, time.UTC)
+	if got := Weekly.Start(sunday); !got.Equal(time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Weekly.Start(Sunday) = %v", got)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	points := []Point{
+		{day(4), 10}, {day(5), 20}, {day(6), 15},
+		{day(12), 30},
+		{day(25), 40}, {day(26), 50},
+	}
+
+	buckets := Aggregate(points, Weekly)
+	if len(buckets) != 3 {
+		t.Fatalf("Aggregate() returned %d buckets, want 3", len(buckets))
+	}
+
+	first := buckets[0]
+	if first.Count != 3 || first.Min != 10 || first.Max != 20 || first.Avg != 15 || first.Last != 15 {
+		t.Errorf("first bucket = %+v", first)
+	}
+	if !buckets[2].Start.Equal(time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC)) || buckets[2].Last != 50 {
+		t.Errorf("last bucket = %+v", buckets[2])
+	}
+
+	if monthly := Aggregate(points, Monthly); len(monthly) != 1 || monthly[0].Count != 6 {
+		t.Errorf("Aggregate(Monthly) = %+v", monthly)
+	}
+}
+
+func TestLinearTrendAndForecast(t *testing.T) {
+	points := []Point{{day(1), 10}, {day(2), 12}, {day(3), 14}, {day(4), 16}}
+
+	trend, ok := LinearTrend(points)
+	if !ok {
+		t.Fatal("LinearTrend() reported no trend")
+	}
+	if math.Abs(trend.SlopePerDay-2) > 1e-9 {
+		t.Errorf("SlopePerDay = %v, want 2", trend.SlopePerDay)
+	}
+	if got := trend.At(day(6)); math.Abs(got-20) > 1e-9 {
+		t.Errorf("At(day 6) = %v, want 20", got)
+	}
+
+	eta, ok := trend.Forecast(points[3], 30)
+	if !ok || !eta.Equal(day(11)) {
+		t.Errorf("Forecast(30) = %v, %v, want %v", eta, ok, day(11))
+	}
+
+	// Moving away from the target never reaches it
+	if _, ok := trend.Forecast(points[3], 0); ok {
+		t.Error("Forecast(0) reported a date for a rising trend")
+	}
+}
+
+func TestLinearTrendNeedsSpread(t *testing.T) {
+	if _, ok := LinearTrend([]Point{{day(1), 1}}); ok {
+		t.Error("LinearTrend() of one point reported a trend")
+	}
+	if _, ok := LinearTrend([]Point{{day(1), 1}, {day(1), 2}}); ok {
+		t.Error("LinearTrend() of simultaneous points reported a trend")
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	if _, err := ParseInterval("hourly"); err != ErrInvalidInterval {
+		t.Errorf("ParseInterval(hourly) error = %v, want ErrInvalidInterval", err)
+	}
+	if got, err := ParseInterval("weekly"); err != nil || got != Weekly {
+		t.Errorf("ParseInterval(weekly) = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,349 @@
+package webhook
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Delivery log limits
+const (
+	DefaultDeliveryLimit = 50
+	MaxDeliveryLimit     = 200
+)
+
+// EndpointRequest is the payload accepted when creating or updating an endpoint
+type EndpointRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+	Active      *bool    `json:"active"`
+}
+
+// EndpointWithSecret is an endpoint with its signing secret, shown when it is created
+// or its secret is rotated
+type EndpointWithSecret struct {
+	Endpoint
+	Secret string `json:"secret"`
+}
+
+// API serves an app's webhook endpoints and their delivery logs. The apps differ in where
+// their database is, how a request names its tenant and which events they send.
+type API struct {
+	DB       func() *gorm.DB
+	TenantID func(c *fiber.Ctx) (uint, error)
+	Events   []string
+}
+
+// GetEvents responds with the events endpoints can subscribe to
+func (a API) GetEvents(c *fiber.Ctx) error {
+	return c.JSON(a.Events)
+}
+
+// GetEndpoints responds with the tenant's endpoints
+func (a API) GetEndpoints(c *fiber.Ctx) error {
+	tenantID, err := a.TenantID(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	endpoints := []Endpoint{}
+	result := a.DB().Where("tenant_id = ?", tenantID).Order("id").Find(&endpoints)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve webhook endpoints",
+		})
+	}
+
+	return c.JSON(endpoints)
+}
+
+// GetEndpoint responds with the endpoint named by the id parameter
+func (a API) GetEndpoint(c *fiber.Ctx) error {
+	endpoint, status, msg := a.endpoint(c)
+	if endpoint == nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	return c.JSON(endpoint)
+}
+
+// CreateEndpoint registers an endpoint and responds with it and its signing secret
+func (a API) CreateEndpoint(c *fiber.Ctx) error {
+	tenantID, err := a.TenantID(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	request := new(EndpointRequest)
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if msg := a.validate(request); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	secret, err := NewSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate webhook secret",
+		})
+	}
+
+	endpoint := Endpoint{
+		TenantID:    tenantID,
+		URL:         strings.TrimSpace(request.URL),
+		Description: request.Description,
+		Events:      uniqueStrings(request.Events),
+		Secret:      secret,
+		Active:      request.Active == nil || *request.Active,
+	}
+	result := a.DB().Create(&endpoint)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create webhook endpoint",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(EndpointWithSecret{Endpoint: endpoint, Secret: secret})
+}
+
+// UpdateEndpoint changes an endpoint's URL, description, events and whether it is active.
+// Activating an endpoint that was disabled for failing resets its failure count.
+func (a API) UpdateEndpoint(c *fiber.Ctx) error {
+	endpoint, status, msg := a.endpoint(c)
+	if endpoint == nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	request := new(EndpointRequest)
+	if err := c.BodyParser(request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if msg := a.validate(request); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	endpoint.URL = strings.TrimSpace(request.URL)
+	endpoint.Description = request.Description
+	endpoint.Events = uniqueStrings(request.Events)
+	if request.Active != nil {
+		if *request.Active && !endpoint.Active {
+			endpoint.ConsecutiveFailures = 0
+			endpoint.DisabledAt = nil
+		}
+		endpoint.Active = *request.Active
+	}
+
+	result := a.DB().Save(endpoint)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update webhook endpoint",
+		})
+	}
+
+	return c.JSON(endpoint)
+}
+
+// RotateSecret replaces an endpoint's signing secret and responds with the new one
+func (a API) RotateSecret(c *fiber.Ctx) error {
+	endpoint, status, msg := a.endpoint(c)
+	if endpoint == nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	secret, err := NewSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate webhook secret",
+		})
+	}
+
+	result := a.DB().Model(endpoint).Update("secret", secret)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to rotate webhook secret",
+		})
+	}
+
+	return c.JSON(EndpointWithSecret{Endpoint: *endpoint, Secret: secret})
+}
+
+// DeleteEndpoint deletes an endpoint, its pending deliveries and its delivery log
+func (a API) DeleteEndpoint(c *fiber.Ctx) error {
+	endpoint, status, msg := a.endpoint(c)
+	if endpoint == nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err := a.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&Delivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(endpoint).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete webhook endpoint",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Webhook endpoint deleted successfully",
+	})
+}
+
+// GetDeliveries responds with an endpoint's deliveries, newest first, optionally only those
+// with the status given in the query
+func (a API) GetDeliveries(c *fiber.Ctx) error {
+	endpoint, status, msg := a.endpoint(c)
+	if endpoint == nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	limit := c.QueryInt("limit", DefaultDeliveryLimit)
+	if limit < 1 || limit > MaxDeliveryLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and " + strconv.Itoa(MaxDeliveryLimit),
+		})
+	}
+
+	query := a.DB().Where("endpoint_id = ?", endpoint.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	deliveries := []Delivery{}
+	result := query.Order("created_at DESC, id DESC").Limit(limit).Find(&deliveries)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to retrieve webhook deliveries",
+		})
+	}
+
+	return c.JSON(deliveries)
+}
+
+// ReplayDelivery queues a new delivery with the event and payload of the one named by the
+// delivery_id parameter
+func (a API) ReplayDelivery(c *fiber.Ctx) error {
+	endpoint, status, msg := a.endpoint(c)
+	if endpoint == nil {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	if !endpoint.Active {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Webhook endpoint is disabled",
+		})
+	}
+
+	var original Delivery
+	result := a.DB().Where("id = ? AND endpoint_id = ?", c.Params("delivery_id"), endpoint.ID).First(&original)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Webhook delivery not found",
+		})
+	}
+
+	now := time.Now()
+	replay := Delivery{
+		TenantID:      original.TenantID,
+		EndpointID:    endpoint.ID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        DeliveryPending,
+		NextAttemptAt: &now,
+		ReplayOfID:    &original.ID,
+	}
+	result = a.DB().Create(&replay)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to replay webhook delivery",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(replay)
+}
+
+// endpoint loads the tenant's endpoint named by the id parameter, or returns the status and
+// message to respond with when it cannot
+func (a API) endpoint(c *fiber.Ctx) (*Endpoint, int, string) {
+	tenantID, err := a.TenantID(c)
+	if err != nil {
+		return nil, fiber.StatusBadRequest, err.Error()
+	}
+
+	var endpoint Endpoint
+	result := a.DB().Where("id = ? AND tenant_id = ?", c.Params("id"), tenantID).First(&endpoint)
+	if result.Error != nil {
+		return nil, fiber.StatusNotFound, "Webhook endpoint not found"
+	}
+
+	return &endpoint, 0, ""
+}
+
+// validate returns why an endpoint request is invalid, or "" when it is valid
+func (a API) validate(request *EndpointRequest) string {
+	if err := CheckURL(strings.TrimSpace(request.URL)); err != nil {
+		return err.Error()
+	}
+	if len(request.Events) == 0 {
+		return "At least one event is required"
+	}
+	for _, event := range request.Events {
+		if !contains(a.Events, event) {
+			return "Unknown webhook event: " + event
+		}
+	}
+	return ""
+}
+
+// contains reports whether values has value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueStrings returns the strings without duplicates, in their first order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// deliveryBatch is the number of deliveries attempted per run
+const deliveryBatch = 100
+
+// Queue queues a delivery of an event to every active endpoint of the tenant that subscribes
+// to it. Run it in the transaction of the change so deliveries are only queued for changes
+// that were saved.
+func Queue(tx *gorm.DB, tenantID uint, event string, occurredAt time.Time, data interface{}) error {
+	var endpoints []Endpoint
+	if err := tx.Where("tenant_id = ? AND active = ?", tenantID, true).Find(&endpoints).Error; err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event) {
+			continue
+		}
+
+		if payload == nil {
+			var err error
+			if payload, err = Payload(event, tenantID, occurredAt, data); err != nil {
+				return err
+			}
+		}
+
+		delivery := Delivery{
+			TenantID:      tenantID,
+			EndpointID:    endpoint.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        DeliveryPending,
+			NextAttemptAt: &occurredAt,
+		}
+		if err := tx.Create(&delivery).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver attempts the deliveries that are due. Failed attempts are retried with a doubling
+// delay until MaxAttempts; endpoints that keep failing are disabled.
+func Deliver(db *gorm.DB, client *http.Client, now time.Time) error {
+	var due []Delivery
+	result := db.
+		Joins("JOIN webhook_endpoints ON webhook_endpoints.id = webhook_deliveries.endpoint_id").
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ? AND webhook_endpoints.active = ?",
+			DeliveryPending, now, true).
+		Order("webhook_deliveries.id").Limit(deliveryBatch).Find(&due)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Endpoints are shared by their deliveries so failures add up within a run
+	endpoints := make(map[uint]*Endpoint)
+	var errs []error
+	for i := range due {
+		delivery := &due[i]
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			endpoint = new(Endpoint)
+			if err := db.First(endpoint, delivery.EndpointID).Error; err != nil {
+				errs = append(errs, fmt.Errorf("webhook delivery %d: %w", delivery.ID, err))
+				continue
+			}
+			endpoints[delivery.EndpointID] = endpoint
+		}
+		if !endpoint.Active {
+			continue // disabled by an earlier delivery of this run
+		}
+
+		response, sendErr := Send(client, Request{
+			URL:        endpoint.URL,
+			Secret:     endpoint.Secret,
+			Event:      delivery.Event,
+			DeliveryID: delivery.ID,
+			Payload:    []byte(delivery.Payload),
+		}, now)
+
+		delivery.Attempts++
+		delivery.ResponseStatus = response.StatusCode
+		delivery.ResponseBody = response.Body
+		if sendErr == nil {
+			delivery.Status = DeliverySucceeded
+			delivery.DeliveredAt = &now
+			delivery.NextAttemptAt = nil
+			delivery.LastError = ""
+			endpoint.ConsecutiveFailures = 0
+		} else {
+			delivery.LastError = sendErr.Error()
+			if delivery.Attempts >= MaxAttempts {
+				delivery.Status = DeliveryFailed
+				delivery.NextAttemptAt = nil
+			} else {
+				next := now.Add(Backoff(delivery.Attempts))
+				delivery.NextAttemptAt = &next
+			}
+
+			endpoint.ConsecutiveFailures++
+			if endpoint.ConsecutiveFailures >= DisableAfter {
+				endpoint.Active = false
+				endpoint.DisabledAt = &now
+				log.Printf("Disabled webhook endpoint %d after %d failed attempts", endpoint.ID, endpoint.ConsecutiveFailures)
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(delivery).Error; err != nil {
+				return err
+			}
+			return tx.Model(endpoint).Select("active", "consecutive_failures", "disabled_at").Updates(endpoint).Error
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook delivery %d: %w", delivery.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"time"
+)
+
+// Endpoint is a URL of a tenant's own system that is sent the events it subscribes to
+type Endpoint struct {
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	TenantID            uint       `json:"tenant_id" gorm:"not null;index"`
+	URL                 string     `json:"url" gorm:"size:500;not null"`
+	Description         string     `json:"description" gorm:"size:255"`
+	Events              []string   `json:"events" gorm:"type:text;serializer:json"`
+	Secret              string     `json:"-" gorm:"size:100;not null"` // shown once, when the endpoint is created
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"` // set when the endpoint was disabled for failing
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// TableName keeps the table the apps created before endpoints moved to this package
+func (Endpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// Subscribes reports whether the endpoint wants the event
+func (e *Endpoint) Subscribes(event string) bool {
+	for _, subscribed := range e.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus represents where a delivery is in its attempts
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed" // gave up after too many attempts
+)
+
+// Delivery is one event sent, or to be sent, to an endpoint, and the log of its attempts
+type Delivery struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	TenantID       uint           `json:"tenant_id" gorm:"not null;index"`
+	EndpointID     uint           `json:"endpoint_id" gorm:"not null;index"`
+	Endpoint       *Endpoint      `json:"-" gorm:"foreignKey:EndpointID"`
+	Event          string         `json:"event" gorm:"size:50;not null"`
+	Payload        string         `json:"payload" gorm:"type:text;not null"`
+	Status         DeliveryStatus `json:"status" gorm:"size:20;not null;index"`
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  *time.Time     `json:"next_attempt_at" gorm:"index"`
+	ResponseStatus int            `json:"response_status"`
+	ResponseBody   string         `json:"response_body" gorm:"type:text"`
+	LastError      string         `json:"last_error" gorm:"type:text"`
+	DeliveredAt    *time.Time     `json:"delivered_at"`
+	ReplayOfID     *uint          `json:"replay_of_id" gorm:"index"` // the delivery this one replays
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// TableName keeps the table the apps created before deliveries moved to this package
+func (Delivery) TableName() string {
+	return "webhook_deliveries"
+}
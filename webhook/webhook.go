@@ -0,0 +1,211 @@
+// Package webhook signs and sends webhook deliveries. Each delivery is a JSON envelope POSTed
+// to the subscriber's URL with an HMAC-SHA256 signature of the timestamp and body, so the
+// receiver can check that it came from us and is not a replay of an old request. The package
+// also keeps the endpoints tenants register and their delivery logs, and serves the webhook
+// API both apps expose.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Request headers
+const (
+	EventHeader     = "X-Kontena-Event"
+	DeliveryHeader  = "X-Kontena-Delivery"
+	TimestampHeader = "X-Kontena-Timestamp"
+	SignatureHeader = "X-Kontena-Signature"
+)
+
+// Delivery policy
+const (
+	MaxAttempts  = 8                // attempts of one delivery before it is given up on
+	DisableAfter = 15               // consecutive failed attempts after which an endpoint is disabled
+	retryDelay   = 30 * time.Second // doubled after every failed attempt
+	maxBodyBytes = 2048             // of the response kept in the delivery log
+)
+
+// Envelope is the JSON body of every delivery
+type Envelope struct {
+	Event      string      `json:"event"`
+	TenantID   uint        `json:"tenant_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Request is one attempt at a delivery
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID uint
+	Payload    []byte
+}
+
+// Response is what the endpoint answered
+type Response struct {
+	StatusCode int
+	Body       string
+}
+
+// Sign returns the signature of a body sent at the given Unix time:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature matches the body and timestamp
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// AllowPrivateNetworks lets endpoints use loopback, private and link-local addresses. It is off
+// so tenants cannot use webhooks to reach the server's own network; development setups and
+// installs that deliver to their internal systems turn it on.
+var AllowPrivateNetworks bool
+
+var (
+	ErrInvalidURL     = errors.New("url must be an absolute http or https URL")
+	ErrPrivateAddress = errors.New("url must not point at a loopback, private or link-local address")
+)
+
+// internalPrefixes are the ranges outside the public internet that net/netip has no test for
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+}
+
+// CheckURL returns why s cannot be an endpoint URL, or nil. It must be an absolute http or https
+// URL, and unless AllowPrivateNetworks is set its host must not be localhost or an internal IP
+// address. Host names are checked again by the client from NewClient, against the address they
+// resolve to when a delivery is sent.
+func CheckURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	if AllowPrivateNetworks {
+		return nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// publicAddr reports whether ip is on the public internet
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClient returns the client deliveries are sent with; endpoints get the timeout to answer.
+// Unless AllowPrivateNetworks is set it refuses to connect to internal addresses, whatever the
+// endpoint's host resolves to at the time, and it does not go through a proxy, which would
+// connect on its behalf.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if AllowPrivateNetworks {
+				return nil
+			}
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addr.Addr()) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Payload encodes the envelope of an event
+func Payload(event string, tenantID uint, occurredAt time.Time, data interface{}) ([]byte, error) {
+	return json.Marshal(Envelope{Event: event, TenantID: tenantID, OccurredAt: occurredAt.UTC(), Data: data})
+}
+
+// Backoff returns how long to wait before the next attempt after the given number of failed attempts
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	return retryDelay << (attempts - 1)
+}
+
+// Send POSTs the delivery, signed at the given time. Responses other than 2xx are errors.
+func Send(client *http.Client, r Request, now time.Time) (Response, error) {
+	req, err := http.NewRequest(http.MethodPost, r.URL, bytes.NewReader(r.Payload))
+	if err != nil {
+		return Response{}, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Kontena-Webhooks/1.0")
+	req.Header.Set(EventHeader, r.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(r.DeliveryID), 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(r.Secret, timestamp, r.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	response := Response{StatusCode: resp.StatusCode, Body: string(body)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return response, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return response, nil
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"task.created"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.Len(t, signature, 7+64)
+	assert.True(t, Verify("secret", signature, 1700000000, body))
+	assert.False(t, Verify("other", signature, 1700000000, body))
+	assert.False(t, Verify("secret", signature, 1700000001, body), "the timestamp is signed")
+	assert.False(t, Verify("secret", signature, 1700000000, []byte(`{}`)))
+}
+
+func TestBackoffDoubles(t *testing.T) {
+	assert.Equal(t, time.Duration(0), Backoff(0))
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, time.Minute, Backoff(2))
+	assert.Equal(t, 32*time.Minute, Backoff(7))
+}
+
+func TestSendSignsRequests(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	now := time.Unix(1700000000, 0)
+	payload, err := Payload("task.created", 1, now, map[string]int{"id": 7})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"event":"task.created","tenant_id":1,"occurred_at":"2023-11-14T22:13:20Z","data":{"id":7}}`, string(payload))
+
+	response, err := Send(server.Client(), Request{URL: server.URL, Secret: "secret", Event: "task.created", DeliveryID: 42, Payload: payload}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, Response{StatusCode: 200, Body: "ok"}, response)
+	assert.Equal(t, "task.created", got.Header.Get(EventHeader))
+	assert.Equal(t, "42", got.Header.Get(DeliveryHeader))
+	timestamp, _ := strconv.ParseInt(got.Header.Get(TimestampHeader), 10, 64)
+	assert.True(t, Verify("secret", got.Header.Get(SignatureHeader), timestamp, gotBody))
+}
+
+func TestSendFailsOnErrorResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	response, err := Send(server.Client(), Request{URL: server.URL, Payload: []byte(`{}`)}, time.Now())
+	assert.Error(t, err)
+	assert.Equal(t, 500, response.StatusCode)
+	assert.Equal(t, "nope\n", response.Body)
+}
+
+func TestCheckURL(t *testing.T) {
+	assert.NoError(t, CheckURL("https://example.com/hooks"))
+	assert.NoError(t, CheckURL("http://203.0.113.7:8080/hooks"))
+	assert.Equal(t, ErrInvalidURL, CheckURL("ftp://example.com"))
+	assert.Equal(t, ErrInvalidURL, CheckURL("/hooks"))
+	assert.Equal(t, ErrInvalidURL, CheckURL("not a url"))
+
+	for _, internal := range []string{
+		"http://localhost:8080", "http://api.localhost", "http://127.0.0.1", "http://[::1]:9000",
+		"http://10.1.2.3", "http://192.168.0.10", "http://172.16.0.1", "http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1", "http://0.0.0.0", "http://[fd00::1]", "http://[::ffff:127.0.0.1]",
+	} {
+		assert.Equal(t, ErrPrivateAddress, CheckURL(internal), internal)
+	}
+
+	AllowPrivateNetworks = true
+	defer func() { AllowPrivateNetworks = false }()
+	assert.NoError(t, CheckURL("http://localhost:8080"))
+	assert.NoError(t, CheckURL("http://10.1.2.3"))
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// Host names are checked against the address they resolve to when connecting
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	_, err := Send(NewClient(time.Second), Request{URL: url, Payload: []byte(`{}`)}, time.Now())
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+
+	AllowPrivateNetworks = true
+	defer func() { AllowPrivateNetworks = false }()
+	response, err := Send(NewClient(time.Second), Request{URL: url, Payload: []byte(`{}`)}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 200, response.StatusCode)
+}
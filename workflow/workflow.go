@@ -0,0 +1,234 @@
+// Package workflow validates status changes against configurable state machines.
+// It knows nothing about storage; the models of each application convert their
+// persisted workflows into a Definition.
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AnyState can be used as the From of a transition to allow it from every state
+const AnyState = "*"
+
+var (
+	// ErrUnknownState is returned when the target status is not a state of the workflow
+	ErrUnknownState = errors.New("unknown workflow state")
+	// ErrTransitionNotAllowed is returned when no transition connects the two states
+	ErrTransitionNotAllowed = errors.New("transition not allowed")
+	// ErrRoleNotPermitted is returned when the acting person's role is not one of the guard roles
+	ErrRoleNotPermitted = errors.New("role not permitted to perform this transition")
+)
+
+// MissingFieldsError is returned when a transition requires fields that are empty on the record
+type MissingFieldsError struct {
+	Transition string
+	Fields     []string
+}
+
+func (e *MissingFieldsError) Error() string {
+	return fmt.Sprintf("transition %q requires fields: %s", e.Transition, strings.Join(e.Fields, ", "))
+}
+
+// State is a status a record can be in
+type State struct {
+	Name    string `json:"name"`
+	Initial bool   `json:"initial"`
+	Final   bool   `json:"final"`
+}
+
+// Transition is an allowed move between two states
+type Transition struct {
+	Name           string   `json:"name"`
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	RequiredFields []string `json:"required_fields"`
+	GuardRoles     []string `json:"guard_roles"`
+}
+
+// Definition is a complete state machine for one kind of record
+type Definition struct {
+	States      []State      `json:"states"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// Permissive builds a definition where every state can move to every other state.
+// The first state is the initial one.
+func Permissive(states ...string) Definition {
+	var def Definition
+	for i, name := range states {
+		def.States = append(def.States, State{Name: name, Initial: i == 0})
+	}
+	for _, to := range states {
+		def.Transitions = append(def.Transitions, Transition{Name: to, From: AnyState, To: to})
+	}
+	return def
+}
+
+// HasState reports whether name is a state of the definition
+func (d Definition) HasState(name string) bool {
+	for _, state := range d.States {
+		if state.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether name is a final state of the definition
+func (d Definition) IsFinal(name string) bool {
+	for _, state := range d.States {
+		if state.Name == name {
+			return state.Final
+		}
+	}
+	return false
+}
+
+// InitialState returns the state new records start in
+func (d Definition) InitialState() string {
+	for _, state := range d.States {
+		if state.Initial {
+			return state.Name
+		}
+	}
+	if len(d.States) > 0 {
+		return d.States[0].Name
+	}
+	return ""
+}
+
+// Available returns the transitions that leave the given state
+func (d Definition) Available(from string) []Transition {
+	var transitions []Transition
+	for _, t := range d.Transitions {
+		if (t.From == from || t.From == AnyState) && t.To != from {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions
+}
+
+// Find returns the transition between two states, if there is one
+func (d Definition) Find(from, to string) (Transition, bool) {
+	for _, t := range d.Available(from) {
+		if t.To == to {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// Validate checks that a record may move from one state to another.
+// role is the role of the person making the change and record is the record
+// as it will look after the change, used to check required fields.
+func (d Definition) Validate(from, to, role string, record interface{}) error {
+	if from == to {
+		return nil
+	}
+
+	if !d.HasState(to) {
+		return fmt.Errorf("%w: %s", ErrUnknownState, to)
+	}
+
+	t, ok := d.Find(from, to)
+	if !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrTransitionNotAllowed, from, to)
+	}
+
+	if !t.Permits(role) {
+		return fmt.Errorf("%w: requires one of %s", ErrRoleNotPermitted, strings.Join(t.GuardRoles, ", "))
+	}
+
+	if missing := MissingFields(record, t.RequiredFields); len(missing) > 0 {
+		return &MissingFieldsError{Transition: t.Name, Fields: missing}
+	}
+
+	return nil
+}
+
+// Permits reports whether someone with the given role may perform the transition
+func (t Transition) Permits(role string) bool {
+	if len(t.GuardRoles) == 0 {
+		return true
+	}
+	for _, guard := range t.GuardRoles {
+		if strings.EqualFold(guard, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingFields returns the JSON field names of record that are empty.
+// Empty means absent, null, "", 0, false, an empty list or the zero time.
+func MissingFields(record interface{}, fields []string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	values := make(map[string]interface{})
+	if data, err := json.Marshal(record); err == nil {
+		json.Unmarshal(data, &values)
+	}
+
+	var missing []string
+	for _, field := range fields {
+		if isEmpty(values[field]) {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// Merge overlays the non-empty JSON fields of patch onto base, the same way a
+// partial update leaves empty fields untouched. The result can be passed to Validate.
+func Merge(base, patch interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	if data, err := json.Marshal(base); err == nil {
+		json.Unmarshal(data, &merged)
+	}
+
+	changes := make(map[string]interface{})
+	if data, err := json.Marshal(patch); err == nil {
+		json.Unmarshal(data, &changes)
+	}
+
+	for field, value := range changes {
+		if !isEmpty(value) {
+			merged[field] = value
+		}
+	}
+	return merged
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == "" || v == "0001-01-01T00:00:00Z"
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// SplitList parses a comma separated list as stored in the database
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
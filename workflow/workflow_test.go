@@ -0,0 +1,96 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+)
+
+type record struct {
+	Status       string `json:"status"`
+	ApprovedByID *uint  `json:"approved_by_id"`
+}
+
+func approvalFlow() Definition {
+	return Definition{
+		States: []State{
+			{Name: "draft", Initial: true},
+			{Name: "submitted"},
+			{Name: "approved", Final: true},
+		},
+		Transitions: []Transition{
+			{Name: "submit", From: "draft", To: "submitted"},
+			{Name: "approve", From: "submitted", To: "approved", RequiredFields: []string{"approved_by_id"}, GuardRoles: []string{"manager"}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	def := approvalFlow()
+	approver := uint(3)
+
+	tests := []struct {
+		name     string
+		from, to string
+		role     string
+		record   interface{}
+		wantErr  error
+		missing  bool
+	}{
+		{"unchanged status", "draft", "draft", "", record{}, nil, false},
+		{"allowed", "draft", "submitted", "", record{}, nil, false},
+		{"unknown state", "draft", "archived", "", record{}, ErrUnknownState, false},
+		{"no transition", "draft", "approved", "manager", record{ApprovedByID: &approver}, ErrTransitionNotAllowed, false},
+		{"role guard", "submitted", "approved", "employee", record{ApprovedByID: &approver}, ErrRoleNotPermitted, false},
+		{"role guard is case insensitive", "submitted", "approved", "Manager", record{ApprovedByID: &approver}, nil, false},
+		{"required field", "submitted", "approved", "manager", record{}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := def.Validate(tt.from, tt.to, tt.role, tt.record)
+
+			var missing *MissingFieldsError
+			if tt.missing {
+				if !errors.As(err, &missing) {
+					t.Fatalf("Validate() error = %v, want MissingFieldsError", err)
+				}
+				if len(missing.Fields) != 1 || missing.Fields[0] != "approved_by_id" {
+					t.Errorf("missing fields = %v, want [approved_by_id]", missing.Fields)
+				}
+				return
+			}
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Validate() error = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPermissive(t *testing.T) {
+	def := Permissive("open", "closed")
+
+	if def.InitialState() != "open" {
+		t.Errorf("InitialState() = %q, want open", def.InitialState())
+	}
+	if err := def.Validate("closed", "open", "", nil); err != nil {
+		t.Errorf("Validate(closed, open) error = %v", err)
+	}
+	if got := len(def.Available("open")); got != 1 {
+		t.Errorf("Available(open) returned %d transitions, want 1", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	approver := uint(3)
+	merged := Merge(record{Status: "submitted", ApprovedByID: &approver}, record{Status: "approved"})
+
+	if merged["status"] != "approved" {
+		t.Errorf("status = %v, want approved", merged["status"])
+	}
+	if merged["approved_by_id"] != float64(3) {
+		t.Errorf("approved_by_id = %v, want 3", merged["approved_by_id"])
+	}
+}